@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/bot"
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+	"github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// generateOutput is printed by generate --json.
+type generateOutput struct {
+	RequestID string             `json:"request_id"`
+	Prompt    string             `json:"prompt"`
+	Loras     []string           `json:"loras"`
+	Seed      uint64             `json:"seed"`
+	Images    []falapi.ImageInfo `json:"images"`
+}
+
+func newGenerateCmd() *cobra.Command {
+	var (
+		configPath string
+		prompt     string
+		loraName   string
+		baseLoras  []string
+		seed       uint64
+		steps      int
+		size       string
+		jsonOutput bool
+		timeout    time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:          "generate",
+		Short:        "telegram-fal-bot generate --config config.toml --prompt \"...\" --lora name",
+		Long:         "Run a single generation against fal.ai without Telegram and print the result URLs.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadConfig(configPath)
+			if err != nil {
+				return fmt.Errorf("failed to load config %s: %w", configPath, err)
+			}
+			if err := config.ValidateConfig(cfg); err != nil {
+				return fmt.Errorf("config %s is invalid: %w", configPath, err)
+			}
+
+			standard, ok := findConfigLora(loraName, cfg.LoRAs)
+			if !ok {
+				return fmt.Errorf("lora '%s' not found in loras", loraName)
+			}
+			selected := []config.LoraConfig{standard}
+			loraNames := []string{standard.Name}
+			loraWeights := []falapi.LoraWeight{{Path: standard.URL, Scale: standard.EffectiveWeight()}}
+			for _, name := range baseLoras {
+				base, ok := findConfigLora(name, cfg.BaseLoRAs)
+				if !ok {
+					return fmt.Errorf("lora '%s' not found in baseLoRAs", name)
+				}
+				selected = append(selected, base)
+				loraNames = append(loraNames, base.Name)
+				loraWeights = append(loraWeights, falapi.LoraWeight{Path: base.URL, Scale: base.EffectiveWeight()})
+			}
+
+			settings := cfg.DefaultGenerationSettings
+			if cmd.Flags().Changed("size") {
+				if !falapi.IsImageSizePreset(size) {
+					return fmt.Errorf("size must be one of: %s", strings.Join(falapi.ImageSizePresetNames, ", "))
+				}
+				settings.ImageSize = size
+			} else if standard.PreferredImageSize != "" {
+				settings.ImageSize = standard.PreferredImageSize // Like the bot with one LoRA selected
+			}
+			if cmd.Flags().Changed("steps") {
+				if steps < 1 || steps > cfg.Limits.MaxInferenceSteps {
+					return fmt.Errorf("steps must be between 1 and %d", cfg.Limits.MaxInferenceSteps)
+				}
+				settings.NumInferenceSteps = steps
+			}
+			var seedPtr *uint64
+			if cmd.Flags().Changed("seed") {
+				seedPtr = &seed
+			}
+
+			falClient, err := falapi.NewClient(
+				cfg.FalAIKey,
+				cfg.APIEndpoints.BaseURL,
+				cfg.APIEndpoints.FluxLora,
+				cfg.APIEndpoints.FlorenceCaption,
+				zap.NewNop(), // Keep stdout clean for scripts
+			)
+			if err != nil {
+				return fmt.Errorf("failed to initialize fal client: %w", err)
+			}
+			falClient.SetExtraParams(cfg.APIEndpoints.ExtraParams)
+
+			// Base LoRAs come first in the prompt, like in the bot
+			promptLoras := append(append([]config.LoraConfig{}, selected[1:]...), standard)
+			finalPrompt := bot.BuildPromptFromConfig(prompt, cfg, promptLoras...)
+
+			requestID, err := falClient.SubmitGenerationRequest(
+				finalPrompt,
+				loraWeights,
+				loraNames,
+				settings.ImageSize,
+				settings.NumInferenceSteps,
+				settings.GuidanceScale,
+				settings.NumImages,
+				seedPtr,
+				settings.EnableSafetyChecker,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to submit generation: %w", err)
+			}
+			if !jsonOutput {
+				fmt.Fprintf(cmd.ErrOrStderr(), "submitted request %s, waiting for result...\n", requestID)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			result, err := falClient.PollForResult(ctx, requestID, cfg.APIEndpoints.FluxLora, 5*time.Second)
+			if err != nil {
+				return fmt.Errorf("generation %s failed: %w", requestID, err)
+			}
+
+			out := cmd.OutOrStdout()
+			if jsonOutput {
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(generateOutput{
+					RequestID: requestID,
+					Prompt:    finalPrompt,
+					Loras:     loraNames,
+					Seed:      result.Seed,
+					Images:    result.Images,
+				})
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "seed: %d\n", result.Seed)
+			for _, img := range result.Images {
+				fmt.Fprintln(out, img.URL)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "./config.toml", "Path to the config file")
+	cmd.Flags().StringVarP(&prompt, "prompt", "p", "", "Prompt to generate")
+	cmd.Flags().StringVarP(&loraName, "lora", "l", "", "Name of the standard LoRA to use")
+	cmd.Flags().StringSliceVar(&baseLoras, "base-lora", nil, "Names of base LoRAs to add (optional)")
+	cmd.Flags().Uint64Var(&seed, "seed", 0, "Seed to use (random if not set)")
+	cmd.Flags().IntVar(&steps, "steps", 0, "Number of inference steps (default from config)")
+	cmd.Flags().StringVar(&size, "size", "", "Image size, e.g. square_hd or portrait_16_9 (default from config)")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the result as JSON")
+	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "How long to wait for the result")
+	for _, name := range []string{"prompt", "lora"} {
+		if err := cmd.MarkFlagRequired(name); err != nil {
+			panic(err) // Only fails for flags that aren't defined above
+		}
+	}
+	return cmd
+}
+
+func findConfigLora(name string, loras []config.LoraConfig) (config.LoraConfig, bool) {
+	for _, lora := range loras {
+		if lora.Name == name {
+			return lora, true
+		}
+	}
+	return config.LoraConfig{}, false
+}
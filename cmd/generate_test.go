@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestGenerateRejectsInvalidFlags checks that out-of-range flags fail before anything is
+// submitted to fal.
+func TestGenerateRejectsInvalidFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"steps 0", []string{"--prompt", "a cat", "--steps", "0"}, "steps must be between 1 and"},
+		{"steps above the limit", []string{"--prompt", "a cat", "--steps", "100000"}, "steps must be between 1 and"},
+		{"unknown size", []string{"--prompt", "a cat", "--size", "huge"}, "size must be one of"},
+		{"missing prompt", nil, `required flag(s) "prompt"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newGenerateCmd()
+			cmd.SetArgs(append([]string{"--config", "../config.toml", "--lora", "Anime Style V2"}, tt.args...))
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+			if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("generate error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -25,6 +25,8 @@ func newRootCmd(version string, buildTime string, gitCommit string) *cobra.Comma
 
 	cmd.AddCommand(newVersionCmd(version, buildTime, gitCommit))
 	cmd.AddCommand(newStartCmd(verbose, version, buildTime))
+	cmd.AddCommand(newValidateCmd())
+	cmd.AddCommand(newGenerateCmd())
 	return cmd
 }
 
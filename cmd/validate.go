@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func newValidateCmd() *cobra.Command {
+	var configPath string
+	var strict bool
+
+	cmd := &cobra.Command{
+		Use:          "validate",
+		Short:        "telegram-fal-bot validate --config config.toml",
+		Long:         "Load and validate a config file without starting the bot. Exits non-zero if the config is invalid.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+
+			cfg, err := config.LoadConfig(configPath)
+			if err != nil {
+				fmt.Fprintf(out, "FAIL: %s could not be loaded: %v\n", configPath, err)
+				return fmt.Errorf("config %s could not be loaded: %w", configPath, err)
+			}
+			if err := config.ValidateConfig(cfg); err != nil {
+				fmt.Fprintf(out, "FAIL: %s is invalid: %v\n", configPath, err)
+				return fmt.Errorf("config %s is invalid: %w", configPath, err)
+			}
+
+			if strict {
+				if err := checkEndpoints(cmd, cfg); err != nil {
+					fmt.Fprintf(out, "FAIL: %v\n", err)
+					return err
+				}
+			}
+
+			fmt.Fprintf(out, "OK: %s is valid\n", configPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", "./config.toml", "Path to the config file to validate")
+	cmd.Flags().BoolVar(&strict, "strict", false, "Also check that the Telegram and fal.ai endpoints are reachable")
+	return cmd
+}
+
+// checkEndpoints verifies that the Telegram API accepts the bot token and that the fal.ai
+// queue endpoint answers. Any HTTP response from fal counts as reachable; auth is checked at runtime.
+func checkEndpoints(cmd *cobra.Command, cfg *config.Config) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	out := cmd.OutOrStdout()
+
+	telegramURL := fmt.Sprintf(cfg.TelegramAPIURL, cfg.BotToken, "getMe")
+	resp, err := client.Get(telegramURL)
+	if err != nil {
+		// Drop the request URL from the error, it contains the bot token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram API is unreachable: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("telegram API rejected the bot token (HTTP %d)", resp.StatusCode)
+	}
+	fmt.Fprintln(out, "ok: telegram API reachable, bot token accepted")
+
+	falURL := strings.TrimRight(cfg.APIEndpoints.BaseURL, "/") + "/" + strings.TrimLeft(cfg.APIEndpoints.FluxLora, "/")
+	resp, err = client.Get(falURL)
+	if err != nil {
+		return fmt.Errorf("fal.ai endpoint %s is unreachable: %w", falURL, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("fal.ai endpoint %s returned HTTP %d", falURL, resp.StatusCode)
+	}
+	fmt.Fprintf(out, "ok: fal.ai endpoint %s reachable\n", falURL)
+	return nil
+}
@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+	"go.uber.org/zap"
+)
+
+// telegramDeleteWindow is how old a message may be for a bot to still delete it.
+const telegramDeleteWindow = 48 * time.Hour
+
+// autoDeleteEnabled reports whether results for userID are auto-deleted, following the
+// user's /myconfig toggle or the configured default.
+func autoDeleteEnabled(userID int64, deps BotDeps) bool {
+	autoDeleteCfg := deps.Cfg().AutoDelete
+	if autoDeleteCfg.TTLMinutes <= 0 {
+		return false
+	}
+	userCfg, err := st.GetUserGenerationConfig(deps.DB, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		deps.Logger.Warn("Failed to get user config for auto-delete, using default", zap.Error(err), zap.Int64("user_id", userID))
+	}
+	if userCfg != nil && userCfg.AutoDelete != nil {
+		return *userCfg.AutoDelete
+	}
+	return autoDeleteCfg.DefaultEnabled
+}
+
+// autoDeleteTTL returns how long results for userID stay in the chat, or 0 if they are kept.
+func autoDeleteTTL(userID int64, deps BotDeps) time.Duration {
+	if !autoDeleteEnabled(userID, deps) {
+		return 0
+	}
+	return time.Duration(deps.Cfg().AutoDelete.TTLMinutes) * time.Minute
+}
+
+// scheduleAutoDelete deletes messageIDs from chatID after ttl. Schedules live in memory only,
+// so pending deletions are lost on restart. Messages past Telegram's 48h window are skipped.
+func scheduleAutoDelete(chatID int64, messageIDs []int, ttl time.Duration, deps BotDeps) {
+	if ttl <= 0 || len(messageIDs) == 0 {
+		return
+	}
+	sentAt := time.Now()
+	deps.Logger.Debug("Scheduled auto-delete", zap.Int64("chat_id", chatID), zap.Ints("message_ids", messageIDs), zap.Duration("ttl", ttl))
+
+	time.AfterFunc(ttl, func() {
+		if time.Since(sentAt) >= telegramDeleteWindow {
+			deps.Logger.Warn("Skipping auto-delete, messages are too old for Telegram to delete", zap.Int64("chat_id", chatID), zap.Ints("message_ids", messageIDs))
+			return
+		}
+		for _, messageID := range messageIDs {
+			if _, err := deps.Bot.Request(tgbotapi.NewDeleteMessage(chatID, messageID)); err != nil {
+				// Usually the user already deleted it
+				deps.Logger.Debug("Failed to auto-delete message", zap.Error(err), zap.Int64("chat_id", chatID), zap.Int("message_id", messageID))
+			}
+		}
+		deps.Logger.Info("Auto-deleted generation results", zap.Int64("chat_id", chatID), zap.Int("count", len(messageIDs)))
+	})
+}
@@ -0,0 +1,345 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// maxBatchFileSize limits prompt files sent for /batch.
+const maxBatchFileSize = 64 * 1024
+
+// batchJob tracks one running /batch so it can be cancelled between prompts.
+type batchJob struct {
+	cancelled atomic.Bool
+}
+
+// BatchManager tracks running /batch jobs, at most one per user.
+type BatchManager struct {
+	jobs map[int64]*batchJob
+	mu   sync.Mutex
+}
+
+// NewBatchManager creates a new BatchManager.
+func NewBatchManager() *BatchManager {
+	return &BatchManager{jobs: make(map[int64]*batchJob)}
+}
+
+// Start registers a batch for userID. It returns false if the user already has one running.
+func (bm *BatchManager) Start(userID int64) (*batchJob, bool) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if _, exists := bm.jobs[userID]; exists {
+		return nil, false
+	}
+	job := &batchJob{}
+	bm.jobs[userID] = job
+	return job, true
+}
+
+// Finish removes the user's batch once it has stopped.
+func (bm *BatchManager) Finish(userID int64) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	delete(bm.jobs, userID)
+}
+
+// Running reports whether the user has a batch in progress.
+func (bm *BatchManager) Running(userID int64) bool {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	_, exists := bm.jobs[userID]
+	return exists
+}
+
+// Cancel asks the user's batch to stop after the current prompt. It returns false if none is running.
+func (bm *BatchManager) Cancel(userID int64) bool {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	job, exists := bm.jobs[userID]
+	if !exists {
+		return false
+	}
+	job.cancelled.Store(true)
+	return true
+}
+
+// HandleBatchCommand handles /batch. Prompts can follow the command (one per line);
+// otherwise the bot waits for a text message or a .txt file with one prompt per line.
+func HandleBatchCommand(message *tgbotapi.Message, deps BotDeps) {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+	userLang := getUserLanguagePreference(userID, deps)
+
+	if deps.Batches.Running(userID) {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "batch_already_running")))
+		return
+	}
+
+	deps.StateManager.ClearState(userID)
+	if args := strings.TrimSpace(message.CommandArguments()); args != "" {
+		startBatchSelection(chatID, userID, parseBatchPrompts(args), deps)
+		return
+	}
+
+	reply := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "batch_send_prompts", "max", deps.Cfg().Limits.MaxBatchPrompts))
+	sent, err := deps.Bot.Send(reply)
+	if err != nil {
+		deps.Logger.Error("Failed to send batch instructions", zap.Error(err), zap.Int64("user_id", userID))
+		return
+	}
+	deps.StateManager.SetState(userID, &UserState{
+		UserID:    userID,
+		ChatID:    chatID,
+		MessageID: sent.MessageID,
+		Action:    "awaiting_batch_prompts",
+	})
+}
+
+// HandleBatchInput handles the text message with prompts sent after /batch.
+func HandleBatchInput(message *tgbotapi.Message, state *UserState, deps BotDeps) {
+	deps.StateManager.ClearState(state.UserID)
+	startBatchSelection(message.Chat.ID, message.From.ID, parseBatchPrompts(message.Text), deps)
+}
+
+// HandleBatchDocument handles a .txt file with prompts sent after /batch.
+func HandleBatchDocument(message *tgbotapi.Message, state *UserState, deps BotDeps) {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+	userLang := getUserLanguagePreference(userID, deps)
+	doc := message.Document
+
+	if !strings.HasSuffix(strings.ToLower(doc.FileName), ".txt") && doc.MimeType != "text/plain" {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "batch_file_invalid")))
+		return
+	}
+	if doc.FileSize > maxBatchFileSize {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "batch_file_too_large", "max", maxBatchFileSize/1024)))
+		return
+	}
+
+	fileURL, err := deps.Bot.GetFileDirectURL(doc.FileID)
+	if err != nil {
+		err = withoutRequestURL(err)
+		deps.Logger.Error("Failed to get batch file URL", zap.Error(err), zap.Int64("user_id", userID))
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "batch_file_read_error", "error", err.Error())))
+		return
+	}
+	content, err := downloadBatchFile(fileURL)
+	if err != nil {
+		deps.Logger.Error("Failed to download batch file", zap.Error(err), zap.Int64("user_id", userID))
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "batch_file_read_error", "error", err.Error())))
+		return
+	}
+
+	deps.StateManager.ClearState(state.UserID)
+	startBatchSelection(chatID, userID, parseBatchPrompts(content), deps)
+}
+
+// withoutRequestURL drops the request URL from a failed HTTP request's error. Telegram file
+// and API URLs contain the bot token, so their errors must not reach chats or logs as is.
+func withoutRequestURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// downloadBatchFile downloads a document from its Telegram file URL. Errors never contain
+// the URL.
+func downloadBatchFile(fileURL string) (string, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(fileURL)
+	if err != nil {
+		return "", withoutRequestURL(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download failed with status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBatchFileSize))
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// parseBatchPrompts splits text into prompts, one per non-empty line.
+func parseBatchPrompts(text string) []string {
+	var prompts []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			prompts = append(prompts, line)
+		}
+	}
+	return prompts
+}
+
+// startBatchSelection validates the prompt count and cost, then starts the shared LoRA selection.
+func startBatchSelection(chatID int64, userID int64, prompts []string, deps BotDeps) {
+	userLang := getUserLanguagePreference(userID, deps)
+	maxPrompts := deps.Cfg().Limits.MaxBatchPrompts
+
+	if len(prompts) == 0 {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "batch_no_prompts")))
+		return
+	}
+	if len(prompts) > maxPrompts {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "batch_too_many", "count", len(prompts), "max", maxPrompts)))
+		return
+	}
+	// Early check for a single LoRA; the exact cost is checked again once LoRAs are selected.
+	if chargesUser(userID, deps) {
+		minCost := deps.BalanceManager.GetCost() * float64(len(prompts))
+		if current := deps.BalanceManager.GetBalance(userID); deps.BalanceManager.Spendable(current) < minCost {
+			deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "batch_insufficient_balance",
+				"cost", formatBalance(minCost, userLang, deps),
+				"current", formatBalance(current, userLang, deps),
+				"count", len(prompts),
+			)+balanceReserveNote(userLang, deps)))
+			return
+		}
+	}
+
+	deps.Logger.Info("Starting batch LoRA selection", zap.Int64("user_id", userID), zap.Int("prompts", len(prompts)))
+	startLoraSelection(&UserState{
+		UserID:          userID,
+		ChatID:          chatID,
+		OriginalCaption: batchPromptSummary(prompts),
+		SelectedLoras:   []string{},
+		BatchPrompts:    prompts,
+	}, deps)
+}
+
+// batchPromptSummary renders the prompts as a numbered list for the selection keyboards.
+func batchPromptSummary(prompts []string) string {
+	var sb strings.Builder
+	for i, prompt := range prompts {
+		fmt.Fprintf(&sb, "%d. %s\n", i+1, truncateRunes(prompt, 80))
+	}
+	return truncateRunes(strings.TrimSpace(sb.String()), 1000)
+}
+
+// RunBatchGeneration generates each prompt of state.BatchPrompts in turn with the
+// selected LoRAs. Each prompt gets its own status message and results; the original
+// message shows overall progress and a cancel button.
+func RunBatchGeneration(state *UserState, deps BotDeps) {
+	userID := state.UserID
+	chatID := state.ChatID
+	userLang := getUserLanguagePreference(userID, deps)
+	prompts := state.BatchPrompts
+
+	if refuseForMaintenance(chatID, userID, state.MessageID, deps) {
+		return
+	}
+	// The whole batch counts as one generation for the cooldown, like GenerateImagesForUser
+	if !deps.Authorizer.IsAdmin(userID) {
+		if wait := deps.Cooldowns.Remaining(userID); wait > 0 {
+			deps.Logger.Info("Batch refused during cooldown", zap.Int64("user_id", userID), zap.Duration("remaining", wait))
+			edit := tgbotapi.NewEditMessageText(chatID, state.MessageID, deps.I18n.T(userLang, "generate_cooldown", "wait", formatRetryWait(wait)))
+			deps.Edits.EditNow(edit)
+			return
+		}
+	}
+	job, ok := deps.Batches.Start(userID)
+	if !ok {
+		edit := tgbotapi.NewEditMessageText(chatID, state.MessageID, deps.I18n.T(userLang, "batch_already_running"))
+		deps.Bot.Send(edit)
+		return
+	}
+	defer deps.Batches.Finish(userID)
+
+	if chargesUser(userID, deps) {
+		totalCost := deps.BalanceManager.GetCost() * float64(len(prompts)*len(state.SelectedLoras))
+		if current := deps.BalanceManager.GetBalance(userID); deps.BalanceManager.Spendable(current) < totalCost {
+			edit := tgbotapi.NewEditMessageText(chatID, state.MessageID, deps.I18n.T(userLang, "batch_insufficient_balance",
+				"cost", formatBalance(totalCost, userLang, deps),
+				"current", formatBalance(current, userLang, deps),
+				"count", len(prompts),
+			)+balanceReserveNote(userLang, deps))
+			deps.Bot.Send(edit)
+			return
+		}
+	}
+
+	cancelKeyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "batch_cancel_button"), "batch_cancel"),
+	))
+
+	deps.Logger.Info("Batch generation started", zap.Int64("user_id", userID), zap.Int("prompts", len(prompts)), zap.Strings("loras", state.SelectedLoras))
+	done, succeeded := 0, 0
+	for i, prompt := range prompts {
+		if job.cancelled.Load() {
+			break
+		}
+		progress := tgbotapi.NewEditMessageText(chatID, state.MessageID, deps.I18n.T(userLang, "batch_progress",
+			"current", i+1, "total", len(prompts), "succeeded", succeeded))
+		progress.ReplyMarkup = &cancelKeyboard
+		deps.Edits.Edit(progress)
+
+		statusMsg, err := deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "batch_item_status",
+			"current", i+1, "total", len(prompts), "prompt", truncateRunes(prompt, 200))))
+		if err != nil {
+			deps.Logger.Error("Failed to send batch item status message", zap.Error(err), zap.Int64("user_id", userID))
+			break
+		}
+
+		itemState := &UserState{
+			UserID:            userID,
+			ChatID:            chatID,
+			MessageID:         statusMsg.MessageID,
+			Action:            "generating",
+			OriginalCaption:   prompt,
+			SelectedLoras:     append([]string{}, state.SelectedLoras...),
+			SelectedBaseLoras: append([]string{}, state.SelectedBaseLoras...),
+			Overrides:         state.Overrides, // One-off size/steps from the confirm screen
+		}
+		if runGeneration(itemState, deps) {
+			succeeded++
+		}
+		done++
+	}
+	if succeeded > 0 {
+		deps.Cooldowns.Finished(userID)
+	}
+
+	finalKey := "batch_finished"
+	if job.cancelled.Load() && done < len(prompts) {
+		finalKey = "batch_cancelled"
+	}
+	deps.Logger.Info("Batch generation finished", zap.Int64("user_id", userID), zap.Int("done", done), zap.Int("succeeded", succeeded), zap.Int("total", len(prompts)))
+	final := tgbotapi.NewEditMessageText(chatID, state.MessageID, deps.I18n.T(userLang, finalKey,
+		"done", done, "total", len(prompts), "succeeded", succeeded))
+	final.ReplyMarkup = nil
+	deps.Edits.EditNow(final)
+}
+
+// HandleBatchCallback handles the batch_cancel button.
+func HandleBatchCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
+	userID := callbackQuery.From.ID
+	userLang := getUserLanguagePreference(userID, deps)
+	answer := tgbotapi.NewCallback(callbackQuery.ID, "")
+
+	if callbackQuery.Data != "batch_cancel" {
+		answer.Text = deps.I18n.T(userLang, "lora_select_unknown_action")
+		deps.Bot.Request(answer)
+		return
+	}
+	if deps.Batches.Cancel(userID) {
+		deps.Logger.Info("User cancelled batch", zap.Int64("user_id", userID))
+		answer.Text = deps.I18n.T(userLang, "batch_cancel_requested")
+	} else {
+		answer.Text = deps.I18n.T(userLang, "batch_not_running")
+	}
+	deps.Bot.Request(answer)
+}
@@ -0,0 +1,24 @@
+package bot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestDownloadBatchFileHidesToken fails a download from a URL shaped like a Telegram file
+// URL. The error is shown in the chat, so it must not contain the bot token.
+func TestDownloadBatchFileHidesToken(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	const token = "123456:SECRET-TOKEN"
+	_, err := downloadBatchFile(srv.URL + "/file/bot" + token + "/documents/file_1.txt")
+	if err == nil {
+		t.Fatal("downloadBatchFile succeeded against a closed server")
+	}
+	if strings.Contains(err.Error(), token) {
+		t.Errorf("error %q contains the bot token", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt" // Added for panic message
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/nerdneilsfield/telegram-fal-bot/internal/auth"
 	// "github.com/nerdneilsfield/telegram-fal-bot/internal/balance" // Commented out
@@ -58,18 +59,26 @@ func StartBot(cfg *config.Config, version string, buildDate string) error {
 	if err != nil {
 		logger.Fatal("Failed to initialize Fal client", zap.Error(err))
 	}
+	falClient.SetExtraParams(cfg.APIEndpoints.ExtraParams)
 
 	// Initialize i18n Manager (Pass the initialized logger)
-	i18nManager, err := i18n.NewManager(cfg.DefaultLanguage, logger)
+	i18nManager, err := i18n.NewManager(cfg.DefaultLanguage, cfg.LanguageFallbacks, logger)
 	if err != nil {
 		logger.Fatal("Failed to initialize i18n manager", zap.Error(err))
 	}
 
 	// Initialize Database (Returns *sql.DB now)
-	db, err := storage.InitDB(cfg.DBPath)
+	db, err := storage.InitDB(cfg.DBPath, storage.DBOptions{
+		BusyTimeout:  time.Duration(cfg.Database.BusyTimeoutMs) * time.Millisecond,
+		MaxOpenConns: cfg.Database.MaxOpenConns,
+		MaxIdleConns: cfg.Database.MaxIdleConns,
+	})
 	if err != nil {
 		logger.Fatal("Failed to initialize database", zap.Error(err))
 	}
+	if cfg.Database.CheckpointIntervalMinutes > 0 {
+		go storage.RunWALCheckpoints(db, time.Duration(cfg.Database.CheckpointIntervalMinutes)*time.Minute, cfg.Database.CheckpointMode)
+	}
 	// Defer closing the DB connection pool when StartBot exits
 	// This might need adjustment based on application lifecycle
 	// defer db.Close()
@@ -84,7 +93,7 @@ func StartBot(cfg *config.Config, version string, buildDate string) error {
 	var balanceManager *storage.SQLBalanceManager // Use SQLBalanceManager
 	if cfg.Balance.CostPerGeneration > 0 {
 		// Use NewSQLBalanceManager
-		balanceManager = storage.NewSQLBalanceManager(db, cfg.Balance.InitialBalance, cfg.Balance.CostPerGeneration)
+		balanceManager = storage.NewSQLBalanceManager(db, cfg.Balance.InitialBalance, cfg.Balance.CostPerGeneration, cfg.Balance.MinReserve, cfg.Balance.LowThreshold)
 		logger.Info("Balance tracking enabled")
 	} else {
 		logger.Info("Balance tracking disabled")
@@ -110,6 +119,29 @@ func StartBot(cfg *config.Config, version string, buildDate string) error {
 		botBaseLoras = append(botBaseLoras, botLora)
 	}
 
+	// Catch dead LoRA links before users hit them
+	if cfg.LoraCheck.Enabled {
+		botLoras, botBaseLoras, err = runLoraCheck(cfg.LoraCheck, botLoras, botBaseLoras, logger)
+		if err != nil {
+			logger.Fatal("LoRA URL check failed", zap.Error(err))
+		}
+	}
+
+	// Receive fal results via webhook instead of polling, if configured
+	var webhooks *falapi.WebhookReceiver
+	if cfg.Webhook.Enabled {
+		webhooks, err = startWebhookServer(cfg.Webhook, falClient, logger)
+		if err != nil {
+			logger.Fatal("Failed to start fal webhook receiver", zap.Error(err))
+		}
+	}
+
+	// Cached fal account balance, with optional periodic snapshots for /falbalance
+	falBalance := NewFalBalanceTracker(falClient, db, time.Duration(cfg.FalBalance.CacheSeconds)*time.Second, logger.Named("fal_balance"))
+	if cfg.FalBalance.SnapshotIntervalMinutes > 0 {
+		go falBalance.RunSnapshots(time.Duration(cfg.FalBalance.SnapshotIntervalMinutes) * time.Minute)
+	}
+
 	// Prepare dependencies (Pass the initialized logger)
 	deps := BotDeps{
 		Bot:            bot,
@@ -120,11 +152,26 @@ func StartBot(cfg *config.Config, version string, buildDate string) error {
 		BalanceManager: balanceManager, // Pass the *SQLBalanceManager
 		I18n:           i18nManager,
 		Logger:         logger, // Pass the logger initialized above
-		Config:         cfg,
-		LoRA:           botLoras,
-		BaseLoRA:       botBaseLoras,
+		Limiter:        NewGenerationLimiter(cfg.Limits.MaxConcurrentGenerations, cfg.Limits.MaxQueuedGenerations),
+		ChatLimiter:    NewChatGenerationLimiter(cfg.Limits.MaxGenerationsPerChat),
+		CaptionLimiter: NewUserRateLimiter(cfg.Limits.CaptionsPerMinute, cfg.Limits.CaptionBurst),
+		Cooldowns:      NewGenerationCooldown(time.Duration(cfg.Limits.GenerationCooldownSeconds) * time.Second),
+		Batches:        NewBatchManager(),
+		Inflight:       NewInflightRegistry(),
+		Repeats:        NewQuickRepeatStore(),
+		LastResults:    NewLastResultStore(),
+		SeedButtons:    NewSeedButtonStore(),
+		StatusCards:    NewStatusCardCache(),
+		Maintenance:    NewMaintenance(db, logger),
+		Webhooks:       webhooks,
+		FalBalance:     falBalance,
+		CreditAlerts:   NewCreditAlerter(),
+		LoraHealth:     NewLoraHealth(),
+		WaitEstimates:  NewWaitEstimator(),
+		Edits:          NewEditThrottler(bot, time.Duration(cfg.StatusEditIntervalMs)*time.Millisecond, logger.Named("edits")),
 		Version:        version,   // Use passed-in version
 		BuildDate:      buildDate, // Use passed-in buildDate
+		live:           newLiveConfig(cfg, botLoras, botBaseLoras),
 	}
 
 	// Set bot commands (Pass the initialized logger)
@@ -135,7 +182,18 @@ func StartBot(cfg *config.Config, version string, buildDate string) error {
 	u.Timeout = 60
 	updates := bot.GetUpdatesChan(u)
 
+	// Liveness and readiness probes, if configured
+	var health *healthChecker
+	if cfg.Health.ListenAddr != "" {
+		health = newHealthChecker(db, cfg.APIEndpoints.BaseURL)
+		startHealthServer(cfg.Health.ListenAddr, health, logger.Named("health"))
+	}
+
 	logger.Info("Bot started, listening for updates...")
+	if health != nil {
+		health.SetUpdatesRunning(true)
+		defer health.SetUpdatesRunning(false)
+	}
 	for update := range updates {
 		go func(upd tgbotapi.Update) {
 			HandleUpdate(upd, deps)
@@ -155,11 +213,33 @@ func SetBotCommands(bot *tgbotapi.BotAPI, logger *zap.Logger, defaultLang string
 		{Command: "loras", Description: i18nManager.T(&defaultLang, "command_desc_loras")},
 		{Command: "myconfig", Description: i18nManager.T(&defaultLang, "command_desc_myconfig")},
 		{Command: "balance", Description: i18nManager.T(&defaultLang, "command_desc_balance")},
+		{Command: "me", Description: i18nManager.T(&defaultLang, "command_desc_me")},
+		{Command: "last", Description: i18nManager.T(&defaultLang, "command_desc_last")},
+		{Command: "eta", Description: i18nManager.T(&defaultLang, "command_desc_eta")},
+		{Command: "support", Description: i18nManager.T(&defaultLang, "command_desc_support")},
+		{Command: "setdefaultloras", Description: i18nManager.T(&defaultLang, "command_desc_setdefaultloras")},
+		{Command: "presets", Description: i18nManager.T(&defaultLang, "command_desc_presets")},
+		{Command: "exportconfig", Description: i18nManager.T(&defaultLang, "command_desc_exportconfig")},
+		{Command: "importconfig", Description: i18nManager.T(&defaultLang, "command_desc_importconfig")},
 		{Command: "version", Description: i18nManager.T(&defaultLang, "command_desc_version")},
 		{Command: "cancel", Description: i18nManager.T(&defaultLang, "command_desc_cancel")},
+		{Command: "stop", Description: i18nManager.T(&defaultLang, "command_desc_stop")},
 		{Command: "set", Description: i18nManager.T(&defaultLang, "command_desc_set")},
 		{Command: "log", Description: i18nManager.T(&defaultLang, "command_desc_log")},
 		{Command: "shortlog", Description: i18nManager.T(&defaultLang, "command_desc_shortlog")},
+		{Command: "resize", Description: i18nManager.T(&defaultLang, "command_desc_resize")},
+		{Command: "batch", Description: i18nManager.T(&defaultLang, "command_desc_batch")},
+		{Command: "grid", Description: i18nManager.T(&defaultLang, "command_desc_grid")},
+		{Command: "sweep", Description: i18nManager.T(&defaultLang, "command_desc_sweep")},
+		{Command: "prompt", Description: i18nManager.T(&defaultLang, "command_desc_prompt")},
+		{Command: "lockseed", Description: i18nManager.T(&defaultLang, "command_desc_lockseed")},
+		{Command: "unlockseed", Description: i18nManager.T(&defaultLang, "command_desc_unlockseed")},
+		{Command: "vgen", Description: i18nManager.T(&defaultLang, "command_desc_vgen")},
+		{Command: "falbalance", Description: i18nManager.T(&defaultLang, "command_desc_falbalance")},
+		{Command: "inflight", Description: i18nManager.T(&defaultLang, "command_desc_inflight")},
+		{Command: "replay", Description: i18nManager.T(&defaultLang, "command_desc_replay")},
+		{Command: "enablelora", Description: i18nManager.T(&defaultLang, "command_desc_enablelora")},
+		{Command: "maintenance", Description: i18nManager.T(&defaultLang, "command_desc_maintenance")},
 	}
 
 	commandsConfig := tgbotapi.NewSetMyCommands(commands...)
@@ -199,12 +279,15 @@ func GenerateLoraConfig(lora config.LoraConfig) (LoraConfig, error) {
 
 	// Return the bot.LoraConfig with only the defined fields
 	return LoraConfig{
-		ID:           id, // Use sanitized and truncated ID
-		Name:         lora.Name,
-		URL:          lora.URL,         // Field exists in config.LoraConfig
-		Weight:       lora.Weight,      // Field exists in config.LoraConfig
-		AllowGroups:  lora.AllowGroups, // Field exists in config.LoraConfig
-		AppendPrompt: lora.AppendPrompt,
+		ID:                 id, // Use sanitized and truncated ID
+		Name:               lora.Name,
+		URL:                lora.URL,               // Field exists in config.LoraConfig
+		Weight:             lora.EffectiveWeight(), // Omitted weights mean 1.0
+		AllowGroups:        lora.AllowGroups,       // Field exists in config.LoraConfig
+		AppendPrompt:       lora.AppendPrompt,
+		PromptPosition:     lora.PromptPosition,
+		Pinned:             lora.Pinned,
+		PreferredImageSize: lora.PreferredImageSize,
 		// BaseLoraOnly seems to be missing from config.LoraConfig, remove if necessary
 		// BaseLoraOnly: lora.BaseLoraOnly, // Assuming this exists, otherwise remove
 	}, nil
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -12,9 +13,38 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"go.uber.org/zap"
 
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/config"
 	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+	falapi "github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
 )
 
+// recoverableStateActions are the keyboard steps whose state can take its chat and message
+// from the callback message: the keyboard being pressed is the one the state would edit.
+var recoverableStateActions = map[string]bool{
+	"awaiting_lora_selection":       true,
+	"awaiting_base_lora_selection":  true,
+	"awaiting_caption_confirmation": true,
+}
+
+// recoverStateContext fills in the missing ChatID/MessageID of state from msg, the message of
+// the pressed button, and reports whether it did. It only does so when the state is at a
+// keyboard step with something to continue from, msg was sent by the bot, and whatever
+// context the state still has points at msg.
+func recoverStateContext(state *UserState, msg *tgbotapi.Message) bool {
+	if !recoverableStateActions[state.Action] || msg.From == nil || !msg.From.IsBot {
+		return false
+	}
+	if state.OriginalCaption == "" && state.ImageFileURL == "" {
+		return false
+	}
+	if (state.ChatID != 0 && state.ChatID != msg.Chat.ID) || (state.MessageID != 0 && state.MessageID != msg.MessageID) {
+		return false
+	}
+	state.ChatID = msg.Chat.ID
+	state.MessageID = msg.MessageID
+	return true
+}
+
 func HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 	userID := callbackQuery.From.ID
 	var chatID int64
@@ -51,6 +81,54 @@ func HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 		return
 	}
 
+	// --- Resize Callbacks (stateless, history based) ---
+	if strings.HasPrefix(data, "resize_") {
+		HandleResizeCallback(callbackQuery, deps)
+		return
+	}
+
+	// --- Batch Callbacks ---
+	if strings.HasPrefix(data, "batch_") {
+		HandleBatchCallback(callbackQuery, deps)
+		return
+	}
+
+	// --- Recent Prompt Callbacks ---
+	if strings.HasPrefix(data, "prompt_") {
+		HandlePromptCallback(callbackQuery, deps)
+		return
+	}
+
+	// --- Group Preset Callbacks ---
+	if strings.HasPrefix(data, "preset_") {
+		HandlePresetCallback(callbackQuery, deps)
+		return
+	}
+
+	// --- Default LoRA Callbacks ---
+	if strings.HasPrefix(data, "defloras_") {
+		HandleDefaultLorasCallback(callbackQuery, deps)
+		return
+	}
+
+	// --- /last Callbacks ---
+	if strings.HasPrefix(data, "last_") {
+		HandleLastCallback(callbackQuery, deps)
+		return
+	}
+
+	// --- Moderation Callbacks ---
+	if strings.HasPrefix(data, "mod_") {
+		HandleModerationCallback(callbackQuery, deps)
+		return
+	}
+
+	// --- Reuse Seed Callbacks ---
+	if strings.HasPrefix(data, "seed_reuse_") {
+		HandleSeedReuseCallback(callbackQuery, deps)
+		return
+	}
+
 	// --- Lora Selection Callbacks ---
 	state, ok := deps.StateManager.GetState(userID)
 	if !ok {
@@ -66,11 +144,13 @@ func HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 		return
 	}
 
-	// Ensure state has chat/message ID
+	// Ensure state has chat/message ID, taking it from the callback message when that is safe
+	if (state.ChatID == 0 || state.MessageID == 0) && recoverStateContext(state, callbackQuery.Message) {
+		deps.Logger.Warn("State was missing ChatID or MessageID, recovered it from the callback message", zap.Int64("userID", userID), zap.String("action", state.Action), zap.Int64("chat_id", chatID), zap.Int("message_id", messageID))
+		deps.StateManager.SetState(userID, state)
+	}
 	if state.ChatID == 0 || state.MessageID == 0 {
 		deps.Logger.Error("State is missing ChatID or MessageID during callback", zap.Int64("userID", userID), zap.Int64("stateChatID", state.ChatID), zap.Int("stateMessageID", state.MessageID))
-		// Attempt to use current callback message info as fallback? Risky.
-		// For now, treat as error.
 		answer.Text = deps.I18n.T(userLang, "callback_error_state_missing_context")
 		deps.Bot.Request(answer)
 		edit := tgbotapi.NewEditMessageText(chatID, messageID, deps.I18n.T(userLang, "callback_error_state_missing_context")) // Edit the current message
@@ -85,7 +165,7 @@ func HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 		if strings.HasPrefix(data, "lora_select_") {
 			loraID := strings.TrimPrefix(data, "lora_select_")
 			// Need BotDeps to find the LoRA details by ID
-			allLoras := append(deps.LoRA) // Only standard LoRAs are selectable here
+			allLoras := deps.StandardLoRAs() // Only standard LoRAs are selectable here
 			selectedLora := findLoraByID(loraID, allLoras)
 
 			if selectedLora.ID == "" { // Not found
@@ -107,7 +187,7 @@ func HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 				}
 			}
 			if !found {
-				maxLoras := deps.Config.APIEndpoints.MaxLoras
+				maxLoras := deps.Cfg().APIEndpoints.MaxLoras
 				if maxLoras <= 0 {
 					maxLoras = 2
 				}
@@ -132,6 +212,18 @@ func HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 			// SendLoraSelectionKeyboard handles ParseMode internally now
 			SendLoraSelectionKeyboard(state.ChatID, state.MessageID, state, deps, true)
 
+		} else if data == "lora_model_next" { // Switch to the next model, for this generation only
+			models := deps.Cfg().APIEndpoints.GenerationModels()
+			next := models[(slices.Index(models, stateModel(state, deps))+1)%len(models)]
+			if state.Overrides == nil {
+				state.Overrides = &GenerationOverrides{}
+			}
+			state.Overrides.Model = next
+			deps.StateManager.SetState(userID, state)
+			answer.Text = deps.I18n.T(userLang, "lora_select_model_switched", "model", next)
+			deps.Bot.Request(answer)
+			SendLoraSelectionKeyboard(state.ChatID, state.MessageID, state, deps, true)
+
 		} else if data == "lora_standard_done" { // Finished selecting standard LoRAs
 			if len(state.SelectedLoras) == 0 {
 				answer.Text = deps.I18n.T(userLang, "lora_select_standard_error_none_selected")
@@ -167,7 +259,7 @@ func HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 		if strings.HasPrefix(data, "base_lora_select_") {
 			loraID := strings.TrimPrefix(data, "base_lora_select_")
 			// Find the selected Base LoRA by ID
-			selectedBaseLora := findLoraByID(loraID, deps.BaseLoRA)
+			selectedBaseLora := findLoraByID(loraID, deps.BaseLoRAs())
 
 			if selectedBaseLora.ID == "" { // Not found
 				answer.Text = deps.I18n.T(userLang, "base_lora_select_invalid_id")
@@ -186,7 +278,7 @@ func HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 				}
 			}
 			if !found {
-				maxLoras := deps.Config.APIEndpoints.MaxLoras
+				maxLoras := deps.Cfg().APIEndpoints.MaxLoras
 				if maxLoras <= 0 {
 					maxLoras = 2
 				}
@@ -207,6 +299,52 @@ func HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 			// SendBaseLoraSelectionKeyboard handles ParseMode internally now
 			SendBaseLoraSelectionKeyboard(state.ChatID, state.MessageID, state, deps, true)
 
+		} else if data == "base_lora_one_image" {
+			if state.Sweep {
+				answer.Text = deps.I18n.T(userLang, "sweep_one_image_fixed")
+				deps.Bot.Request(answer)
+				return
+			}
+			answer.Text = deps.I18n.T(userLang, "base_lora_one_image_off")
+			if toggleOneImagePerLora(state) {
+				answer.Text = deps.I18n.T(userLang, "base_lora_one_image_on")
+			}
+			deps.StateManager.SetState(userID, state)
+			deps.Bot.Request(answer)
+			SendBaseLoraSelectionKeyboard(state.ChatID, state.MessageID, state, deps, true)
+
+		} else if data == "base_lora_toggle_safety" {
+			if !deps.Authorizer.IsAdmin(userID) {
+				answer.Text = deps.I18n.T(userLang, "myconfig_command_admin_only")
+				deps.Bot.Request(answer)
+				return
+			}
+			enabled := !safetyCheckerEnabled(state, deps)
+			if state.Overrides == nil {
+				state.Overrides = &GenerationOverrides{}
+			}
+			state.Overrides.SafetyChecker = &enabled
+			deps.StateManager.SetState(userID, state)
+			deps.Logger.Info("Admin toggled the safety checker for the next generation", zap.Int64("user_id", userID), zap.Bool("safety_checker", enabled))
+			answer.Text = deps.I18n.T(userLang, "base_lora_safety_off")
+			if enabled {
+				answer.Text = deps.I18n.T(userLang, "base_lora_safety_on")
+			}
+			deps.Bot.Request(answer)
+			SendBaseLoraSelectionKeyboard(state.ChatID, state.MessageID, state, deps, true)
+
+		} else if strings.HasPrefix(data, "base_lora_adjust_") {
+			if !applyQuickAdjust(state, strings.TrimPrefix(data, "base_lora_adjust_"), deps) {
+				answer.Text = deps.I18n.T(userLang, "base_lora_adjust_limit")
+				deps.Bot.Request(answer)
+				return
+			}
+			deps.StateManager.SetState(userID, state)
+			size, steps := quickAdjustValues(state, deps)
+			answer.Text = deps.I18n.T(userLang, "base_lora_adjust_success", "size", size, "steps", steps)
+			deps.Bot.Request(answer)
+			SendBaseLoraSelectionKeyboard(state.ChatID, state.MessageID, state, deps, true)
+
 		} else if data == "base_lora_skip" {
 			state.SelectedBaseLoras = []string{}
 			deps.StateManager.SetState(userID, state)
@@ -225,6 +363,12 @@ func HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 				return
 			}
 
+			if state.GridSize > 0 && len(state.SelectedLoras) != 1 {
+				answer.Text = deps.I18n.T(userLang, "grid_error_one_lora")
+				deps.Bot.Request(answer)
+				return
+			}
+
 			answer.Text = deps.I18n.T(userLang, "base_lora_confirm_submitting")
 			deps.Bot.Request(answer)
 
@@ -242,8 +386,17 @@ func HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 					"count", len(state.SelectedLoras),
 					"standardLoras", standardLorasStr))
 			}
+			confirmBuilder.WriteString(loraDetailsText(state.SelectedLoras, state.SelectedBaseLoras, userLang, deps))
 			confirmBuilder.WriteString("\n")
 			confirmBuilder.WriteString(deps.I18n.T(userLang, "base_lora_confirm_prompt", "prompt", state.OriginalCaption))
+			if state.GridSize > 0 {
+				confirmBuilder.WriteString("\n")
+				confirmBuilder.WriteString(deps.I18n.T(userLang, "grid_confirm_seeds", "count", state.GridSize))
+			}
+			if state.Sweep {
+				confirmBuilder.WriteString("\n")
+				confirmBuilder.WriteString(deps.I18n.T(userLang, "sweep_confirm", "count", len(state.SelectedLoras)))
+			}
 			confirmText := confirmBuilder.String()
 
 			edit := tgbotapi.NewEditMessageText(state.ChatID, state.MessageID, confirmText)
@@ -253,7 +406,12 @@ func HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 			deps.Bot.Send(edit)
 
 			// Start generation in background
-			go GenerateImagesForUser(state, deps)
+			if len(state.BatchPrompts) > 0 {
+				deps.StateManager.ClearState(userID)
+				go RunBatchGeneration(state, deps)
+			} else {
+				go GenerateImagesForUser(state, deps)
+			}
 
 		} else if data == "base_lora_cancel" { // Option to cancel at base lora step
 			answer.Text = "操作已取消"
@@ -269,21 +427,57 @@ func HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 			deps.Bot.Request(answer)
 		}
 
+	case "captioning": // Caption request still running
+		if data == "caption_abort" {
+			// Cancelling the context makes the caption goroutine report the cancellation
+			answer.Text = deps.I18n.T(userLang, "photo_caption_cancelled")
+			deps.Bot.Request(answer)
+			deps.StateManager.ClearState(userID)
+		} else {
+			answer.Text = deps.I18n.T(userLang, "lora_select_unknown_action")
+			deps.Bot.Request(answer)
+		}
+
 	case "awaiting_caption_confirmation": // Handle callbacks after caption is received
 		if data == "caption_confirm" {
 			// User confirmed the caption, move to LoRA selection
 			answer.Text = deps.I18n.T(userLang, "text_prompt_received") // Reuse "Select LoRA" message
 			deps.Bot.Request(answer)
 
-			// Update state for LoRA selection
-			state.Action = "awaiting_lora_selection"
 			// Keep OriginalCaption, reset SelectedLoras
 			state.SelectedLoras = []string{}
 			state.SelectedBaseLoras = []string{} // Clear base lora selection too
-			deps.StateManager.SetState(userID, state)
 
 			// Send the standard LoRA selection keyboard, editing the confirmation message
-			SendLoraSelectionKeyboard(state.ChatID, state.MessageID, state, deps, true)
+			showLoraSelection(state.ChatID, state.MessageID, state, deps, true)
+
+		} else if data == "caption_recaption" && state.ImageFileURL != "" {
+			if deps.Maintenance.On() && !deps.Authorizer.IsAdmin(userID) {
+				answer.Text = deps.I18n.T(userLang, "maintenance_refused")
+				answer.ShowAlert = true
+				deps.Bot.Request(answer)
+				return
+			}
+			// Caption the same photo again with the next caption model
+			if ok, wait := deps.CaptionLimiter.Allow(userID); !ok {
+				answer.Text = deps.I18n.T(userLang, "photo_caption_rate_limited", "wait", formatRetryWait(wait))
+				answer.ShowAlert = true
+				deps.Bot.Request(answer)
+				return
+			}
+			endpoints := deps.Cfg().APIEndpoints.CaptionEndpoints()
+			next := (state.CaptionModel + 1) % len(endpoints)
+			answer.Text = deps.I18n.T(userLang, "photo_recaption_started")
+			deps.Bot.Request(answer)
+			deps.Logger.Info("Re-captioning photo", zap.Int64("user_id", userID), zap.String("caption_endpoint", endpoints[next]))
+			startCaptioning(state.ChatID, userID, state.MessageID, state.ImageFileURL, next, state.OriginalCaption, deps)
+
+		} else if data == "caption_copy" {
+			// Send the caption as plain text, so it can be copied exactly
+			deps.Bot.Request(answer)
+			if _, err := deps.Bot.Send(tgbotapi.NewMessage(state.ChatID, state.OriginalCaption)); err != nil {
+				deps.Logger.Error("Failed to send plain caption", zap.Error(err), zap.Int64("user_id", userID))
+			}
 
 		} else if data == "caption_cancel" {
 			// User cancelled after caption
@@ -341,14 +535,14 @@ func HandleConfigCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 	// If err is sql.ErrNoRows, userCfg will be nil. Initialize a new one.
 	if userCfg == nil {
 		// Initialize with defaults from the main config, as GetUserGenerationConfig now only returns DB values or nil
-		defaultCfg := deps.Config.DefaultGenerationSettings
+		defaultCfg := deps.Cfg().DefaultGenerationSettings
 		userCfg = &st.UserGenerationConfig{
 			UserID:            userID,
 			ImageSize:         defaultCfg.ImageSize,
 			NumInferenceSteps: defaultCfg.NumInferenceSteps,
 			GuidanceScale:     defaultCfg.GuidanceScale,
 			NumImages:         defaultCfg.NumImages,
-			Language:          deps.Config.DefaultLanguage, // Use default language from config
+			Language:          deps.Cfg().DefaultLanguage, // Use default language from config
 		}
 		deps.Logger.Debug("Initialized new config for user during callback", zap.Int64("user_id", userID))
 	}
@@ -362,11 +556,10 @@ func HandleConfigCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 	case "config_set_imagesize":
 		answer.Text = deps.I18n.T(userLang, "config_callback_select_image_size")
 		deps.Bot.Request(answer) // Answer first
-		sizes := []string{"square", "portrait_16_9", "landscape_16_9", "portrait_4_3", "landscape_4_3"}
 		var rows [][]tgbotapi.InlineKeyboardButton
 		// Use the ImageSize directly from userCfg (which has defaults if needed)
 		currentSize := userCfg.ImageSize
-		for _, size := range sizes {
+		for _, size := range falapi.ImageSizePresetNames {
 			buttonText := size
 			if size == currentSize {
 				// Use I18n for arrow marker
@@ -386,26 +579,48 @@ func HandleConfigCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 		deps.Bot.Send(edit)
 		return // Waiting for selection
 
+	case "config_set_delivery":
+		deps.Bot.Request(answer)
+		currentMode := deliveryModeFor(userID, deps)
+		var rows [][]tgbotapi.InlineKeyboardButton
+		for _, mode := range config.DeliveryModes {
+			buttonText := deps.I18n.T(userLang, "delivery_mode_"+mode)
+			if mode == currentMode {
+				buttonText = deps.I18n.T(userLang, "button_arrow_right") + " " + buttonText
+			}
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(buttonText, "config_delivery_"+mode),
+			))
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "config_callback_button_back_main"), "config_back_main"),
+		))
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, deps.I18n.T(userLang, "config_callback_prompt_delivery_mode"))
+		kbd := tgbotapi.NewInlineKeyboardMarkup(rows...)
+		edit.ReplyMarkup = &kbd
+		deps.Bot.Send(edit)
+		return // Waiting for selection
+
 	case "config_set_infsteps":
-		answer.Text = deps.I18n.T(userLang, "config_callback_label_inf_steps")
+		answer.Text = deps.I18n.T(userLang, "config_callback_label_inf_steps", "max", deps.Cfg().Limits.MaxInferenceSteps)
 		newStateAction = "awaiting_config_infsteps"
-		promptText = deps.I18n.T(userLang, "config_callback_prompt_inf_steps")
+		promptText = deps.I18n.T(userLang, "config_callback_prompt_inf_steps", "max", deps.Cfg().Limits.MaxInferenceSteps)
 		cancelButtonRow := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "config_callback_button_cancel_input"), "config_cancel_input"))
 		kbd := tgbotapi.NewInlineKeyboardMarkup(cancelButtonRow)
 		keyboard = &kbd
 
 	case "config_set_guidscale":
-		answer.Text = deps.I18n.T(userLang, "config_callback_label_guid_scale")
+		answer.Text = deps.I18n.T(userLang, "config_callback_label_guid_scale", "max", deps.Cfg().Limits.GuidanceScaleCap())
 		newStateAction = "awaiting_config_guidscale"
-		promptText = deps.I18n.T(userLang, "config_callback_prompt_guid_scale")
+		promptText = deps.I18n.T(userLang, "config_callback_prompt_guid_scale", "max", deps.Cfg().Limits.GuidanceScaleCap())
 		cancelButtonRow := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "config_callback_button_cancel_input"), "config_cancel_input"))
 		kbd := tgbotapi.NewInlineKeyboardMarkup(cancelButtonRow)
 		keyboard = &kbd
 
 	case "config_set_numimages":
-		answer.Text = deps.I18n.T(userLang, "config_callback_label_num_images")
+		answer.Text = deps.I18n.T(userLang, "config_callback_label_num_images", "max", deps.Cfg().Limits.MaxNumImages)
 		newStateAction = "awaiting_config_numimages"
-		promptText = deps.I18n.T(userLang, "config_callback_prompt_num_images")
+		promptText = deps.I18n.T(userLang, "config_callback_prompt_num_images", "max", deps.Cfg().Limits.MaxNumImages)
 		cancelButtonRow := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "config_callback_button_cancel_input"), "config_cancel_input"))
 		kbd := tgbotapi.NewInlineKeyboardMarkup(cancelButtonRow)
 		keyboard = &kbd
@@ -439,19 +654,31 @@ func HandleConfigCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 		return // Waiting for language selection
 
 	case "config_reset_defaults":
-		// Revert back to using ExecContext for DELETE operation directly
-		deleteSQL := "DELETE FROM user_generation_configs WHERE user_id = ?"
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		_, err := deps.DB.ExecContext(ctx, deleteSQL, userID)
-		cancel() // Release context
+		// Resetting is destructive, so ask first and let the user choose what to reset
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, deps.I18n.T(userLang, "config_callback_reset_confirm_prompt"))
+		kbd := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "config_callback_button_reset_all"), "config_reset_confirm")),
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "config_callback_button_reset_params"), "config_reset_confirm_params")),
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "config_callback_button_back_main"), "config_back_main")),
+		)
+		edit.ReplyMarkup = &kbd
+		deps.Bot.Send(edit)
+		deps.Bot.Request(answer)
+		return // Waiting for confirmation
 
-		if err != nil {
+	case "config_reset_confirm", "config_reset_confirm_params":
+		keepLanguage := data == "config_reset_confirm_params"
+		if err := resetUserConfig(userID, keepLanguage, deps); err != nil {
 			// Log and send generic error
-			deps.Logger.Error("Failed to delete user config", zap.Error(err), zap.Int64("user_id", userID))
+			deps.Logger.Error("Failed to reset user config", zap.Error(err), zap.Int64("user_id", userID), zap.Bool("keep_language", keepLanguage))
 			answer.Text = deps.I18n.T(userLang, "config_callback_reset_fail")
 		} else {
-			deps.Logger.Info("User config reset to defaults", zap.Int64("user_id", userID))
+			deps.Logger.Info("User config reset to defaults", zap.Int64("user_id", userID), zap.Bool("keep_language", keepLanguage))
+			clearQuickRepeatKeyboard(callbackQuery.Message.Chat.ID, userID, deps) // Off by default
 			answer.Text = deps.I18n.T(userLang, "config_callback_reset_success")
+			if keepLanguage {
+				answer.Text = deps.I18n.T(userLang, "config_callback_reset_params_success")
+			}
 
 			// Create a *basic* message context for editing
 			syntheticMsg := &tgbotapi.Message{
@@ -510,6 +737,130 @@ func HandleConfigCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 		deps.StateManager.ClearState(userID)
 		return
 
+	case "config_toggle_autodelete":
+		enabled := !autoDeleteEnabled(userID, deps)
+		userCfg.AutoDelete = &enabled
+		if updateErr = st.SetUserGenerationConfig(deps.DB, *userCfg); updateErr != nil {
+			deps.Logger.Error("Failed to update auto-delete preference", zap.Error(updateErr), zap.Int64("user_id", userID), zap.Bool("enabled", enabled))
+			answer.Text = deps.I18n.T(userLang, "config_callback_auto_delete_fail")
+		} else {
+			if enabled {
+				answer.Text = deps.I18n.T(userLang, "config_callback_auto_delete_enabled")
+			} else {
+				answer.Text = deps.I18n.T(userLang, "config_callback_auto_delete_disabled")
+			}
+			syntheticMsg := &tgbotapi.Message{
+				MessageID: messageID,
+				From:      callbackQuery.From,
+				Chat:      callbackQuery.Message.Chat,
+			}
+			HandleMyConfigCommand(syntheticMsg, deps)
+		}
+		deps.Bot.Request(answer)
+		deps.StateManager.ClearState(userID)
+		return
+
+	case "config_toggle_quickrepeat":
+		enabled := !quickRepeatEnabled(userID, deps)
+		userCfg.QuickRepeat = &enabled
+		if updateErr = st.SetUserGenerationConfig(deps.DB, *userCfg); updateErr != nil {
+			deps.Logger.Error("Failed to update quick-repeat preference", zap.Error(updateErr), zap.Int64("user_id", userID), zap.Bool("enabled", enabled))
+			answer.Text = deps.I18n.T(userLang, "config_callback_quick_repeat_fail")
+		} else {
+			if enabled {
+				answer.Text = deps.I18n.T(userLang, "config_callback_quick_repeat_on")
+			} else {
+				answer.Text = deps.I18n.T(userLang, "config_callback_quick_repeat_off")
+				clearQuickRepeatKeyboard(callbackQuery.Message.Chat.ID, userID, deps)
+			}
+			syntheticMsg := &tgbotapi.Message{
+				MessageID: messageID,
+				From:      callbackQuery.From,
+				Chat:      callbackQuery.Message.Chat,
+			}
+			HandleMyConfigCommand(syntheticMsg, deps)
+		}
+		deps.Bot.Request(answer)
+		deps.StateManager.ClearState(userID)
+		return
+
+	case "config_toggle_verboseerrors":
+		if !deps.Cfg().AllowVerboseErrors || deps.Authorizer.IsAdmin(userID) {
+			deps.Bot.Request(answer) // Button from before the operator turned the option off
+			return
+		}
+		enabled := !verboseErrorsEnabled(userID, deps)
+		userCfg.VerboseErrors = &enabled
+		if updateErr = st.SetUserGenerationConfig(deps.DB, *userCfg); updateErr != nil {
+			deps.Logger.Error("Failed to update verbose errors preference", zap.Error(updateErr), zap.Int64("user_id", userID), zap.Bool("enabled", enabled))
+			answer.Text = deps.I18n.T(userLang, "config_callback_verbose_errors_fail")
+		} else {
+			if enabled {
+				answer.Text = deps.I18n.T(userLang, "config_callback_verbose_errors_on")
+			} else {
+				answer.Text = deps.I18n.T(userLang, "config_callback_verbose_errors_off")
+			}
+			syntheticMsg := &tgbotapi.Message{
+				MessageID: messageID,
+				From:      callbackQuery.From,
+				Chat:      callbackQuery.Message.Chat,
+			}
+			HandleMyConfigCommand(syntheticMsg, deps)
+		}
+		deps.Bot.Request(answer)
+		deps.StateManager.ClearState(userID)
+		return
+
+	case "config_toggle_showprompt":
+		show := !showPromptInCaption(userID, deps)
+		userCfg.ShowPrompt = &show
+		if updateErr = st.SetUserGenerationConfig(deps.DB, *userCfg); updateErr != nil {
+			deps.Logger.Error("Failed to update show-prompt preference", zap.Error(updateErr), zap.Int64("user_id", userID), zap.Bool("show", show))
+			answer.Text = deps.I18n.T(userLang, "config_callback_show_prompt_fail")
+		} else {
+			if show {
+				answer.Text = deps.I18n.T(userLang, "config_callback_show_prompt_on")
+			} else {
+				answer.Text = deps.I18n.T(userLang, "config_callback_show_prompt_off")
+			}
+			syntheticMsg := &tgbotapi.Message{
+				MessageID: messageID,
+				From:      callbackQuery.From,
+				Chat:      callbackQuery.Message.Chat,
+			}
+			HandleMyConfigCommand(syntheticMsg, deps)
+		}
+		deps.Bot.Request(answer)
+		deps.StateManager.ClearState(userID)
+		return
+
+	case "config_toggle_seedlock":
+		var seed *uint64
+		if userCfg.LockedSeed == nil {
+			lockSeed := defaultSeedToLock(userID, deps)
+			seed = &lockSeed
+		}
+		userCfg.LockedSeed = seed
+		if updateErr = st.SetUserGenerationConfig(deps.DB, *userCfg); updateErr != nil {
+			deps.Logger.Error("Failed to update locked seed", zap.Error(updateErr), zap.Int64("user_id", userID))
+			answer.Text = deps.I18n.T(userLang, "config_callback_seed_lock_fail")
+		} else {
+			if seed != nil {
+				answer.Text = deps.I18n.T(userLang, "lockseed_success", "seed", *seed)
+			} else {
+				answer.Text = deps.I18n.T(userLang, "unlockseed_success")
+			}
+			syntheticMsg := &tgbotapi.Message{
+				MessageID: messageID,
+				From:      callbackQuery.From,
+				Chat:      callbackQuery.Message.Chat,
+			}
+			HandleMyConfigCommand(syntheticMsg, deps)
+		}
+		deps.Bot.Request(answer)
+		deps.StateManager.ClearState(userID)
+		return
+
 	case "config_back_main":
 		answer.Text = deps.I18n.T(userLang, "config_callback_back_main_label")
 		// answer.Text = "返回主菜单"
@@ -540,8 +891,7 @@ func HandleConfigCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 	default:
 		if strings.HasPrefix(data, "config_imagesize_") {
 			size := strings.TrimPrefix(data, "config_imagesize_")
-			validSizes := map[string]bool{"square": true, "portrait_16_9": true, "landscape_16_9": true, "portrait_4_3": true, "landscape_4_3": true}
-			if !validSizes[size] {
+			if !falapi.IsImageSizePreset(size) {
 				deps.Logger.Warn("Invalid image size received in callback", zap.String("size", size), zap.Int64("user_id", userID))
 				answer.Text = deps.I18n.T(userLang, "config_callback_image_size_invalid")
 				// answer.Text = "无效的尺寸"
@@ -568,6 +918,30 @@ func HandleConfigCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 			deps.Bot.Request(answer)
 			deps.StateManager.ClearState(userID)
 			return
+		} else if strings.HasPrefix(data, "config_delivery_") {
+			mode := strings.TrimPrefix(data, "config_delivery_")
+			if !config.IsDeliveryMode(mode) {
+				deps.Logger.Warn("Invalid delivery mode received in callback", zap.String("mode", mode), zap.Int64("user_id", userID))
+				answer.Text = deps.I18n.T(userLang, "config_callback_delivery_mode_invalid")
+				deps.Bot.Request(answer)
+				return
+			}
+			userCfg.DeliveryMode = mode
+			if updateErr = st.SetUserGenerationConfig(deps.DB, *userCfg); updateErr == nil {
+				answer.Text = deps.I18n.T(userLang, "config_callback_delivery_mode_success", "mode", deps.I18n.T(userLang, "delivery_mode_"+mode))
+				syntheticMsg := &tgbotapi.Message{
+					MessageID: messageID,
+					From:      callbackQuery.From,
+					Chat:      callbackQuery.Message.Chat,
+				}
+				HandleMyConfigCommand(syntheticMsg, deps)
+			} else {
+				deps.Logger.Error("Failed to update delivery mode", zap.Error(updateErr), zap.Int64("user_id", userID), zap.String("mode", mode))
+				answer.Text = deps.I18n.T(userLang, "config_callback_delivery_mode_fail")
+			}
+			deps.Bot.Request(answer)
+			deps.StateManager.ClearState(userID)
+			return
 		} else if strings.HasPrefix(data, "config_language_") { // Handle language selection
 			selectedLangCode := strings.TrimPrefix(data, "config_language_")
 			// Validate if the selected code is actually available
@@ -597,6 +971,7 @@ func HandleConfigCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 				// Use the *newly selected language* for the confirmation message
 				answer.Text = deps.I18n.T(&selectedLangCode, "config_callback_lang_updated", "langName", langName, "langCode", selectedLangCode)
 				// answer.Text = fmt.Sprintf("✅ Language set to %s (%s)", langName, selectedLangCode)
+				warnIfPartialTranslation(chatID, userID, selectedLangCode, deps)
 				// Show the updated config menu
 				syntheticMsg := &tgbotapi.Message{
 					MessageID: messageID,
@@ -650,6 +1025,35 @@ func HandleConfigCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
 }
 
 // Handles the /myconfig command
+// resetUserConfig resets userID's settings to the global defaults. With keepLanguage, the
+// chosen language survives and only the generation settings are reset; otherwise the
+// user's config row is deleted.
+func resetUserConfig(userID int64, keepLanguage bool, deps BotDeps) error {
+	if keepLanguage {
+		userCfg, err := st.GetUserGenerationConfig(deps.DB, userID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if userCfg != nil && userCfg.Language != "" && userCfg.Language != deps.Cfg().DefaultLanguage {
+			defaultCfg := deps.Cfg().DefaultGenerationSettings
+			return st.SetUserGenerationConfig(deps.DB, st.UserGenerationConfig{
+				UserID:            userID,
+				ImageSize:         defaultCfg.ImageSize,
+				NumInferenceSteps: defaultCfg.NumInferenceSteps,
+				GuidanceScale:     defaultCfg.GuidanceScale,
+				NumImages:         defaultCfg.NumImages,
+				Language:          userCfg.Language,
+			})
+		}
+		// Nothing to keep; deleting the row is the same reset
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := deps.DB.ExecContext(ctx, "DELETE FROM user_generation_configs WHERE user_id = ?", userID)
+	return err
+}
+
 func HandleMyConfigCommand(message *tgbotapi.Message, deps BotDeps) {
 	userID := message.From.ID
 	chatID := message.Chat.ID
@@ -660,7 +1064,7 @@ func HandleMyConfigCommand(message *tgbotapi.Message, deps BotDeps) {
 	// Fetch user's config from DB
 	userCfg, err := st.GetUserGenerationConfig(deps.DB, userID) // Use aliased package
 
-	defaultCfg := deps.Config.DefaultGenerationSettings
+	defaultCfg := deps.Cfg().DefaultGenerationSettings
 
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		deps.Logger.Error("Failed to get user config from DB", zap.Error(err), zap.Int64("user_id", userID))
@@ -675,7 +1079,7 @@ func HandleMyConfigCommand(message *tgbotapi.Message, deps BotDeps) {
 	infSteps := defaultCfg.NumInferenceSteps
 	guidScale := defaultCfg.GuidanceScale
 	numImages := defaultCfg.NumImages
-	languageCode := deps.Config.DefaultLanguage // Start with default lang
+	languageCode := deps.Cfg().DefaultLanguage // Start with default lang
 	isLangDefault := true
 
 	var currentSettingsMsgKey string
@@ -685,9 +1089,9 @@ func HandleMyConfigCommand(message *tgbotapi.Message, deps BotDeps) {
 		imgSize = userCfg.ImageSize
 		infSteps = userCfg.NumInferenceSteps
 		guidScale = userCfg.GuidanceScale
-		numImages = userCfg.NumImages                                 // Read user's num images directly
-		languageCode = userCfg.Language                               // Check user's language preference directly
-		isLangDefault = (languageCode == deps.Config.DefaultLanguage) // Update isLangDefault based on direct comparison
+		numImages = userCfg.NumImages                                // Read user's num images directly
+		languageCode = userCfg.Language                              // Check user's language preference directly
+		isLangDefault = (languageCode == deps.Cfg().DefaultLanguage) // Update isLangDefault based on direct comparison
 
 	} else {
 		currentSettingsMsgKey = "myconfig_current_default_settings"
@@ -696,7 +1100,7 @@ func HandleMyConfigCommand(message *tgbotapi.Message, deps BotDeps) {
 		infSteps = defaultCfg.NumInferenceSteps
 		guidScale = defaultCfg.GuidanceScale
 		numImages = defaultCfg.NumImages
-		languageCode = deps.Config.DefaultLanguage
+		languageCode = deps.Cfg().DefaultLanguage
 		isLangDefault = true
 	}
 
@@ -725,17 +1129,65 @@ func HandleMyConfigCommand(message *tgbotapi.Message, deps BotDeps) {
 		settingsBuilder.WriteString(deps.I18n.T(userLang, "myconfig_setting_language", "value", fmt.Sprintf("%s (%s)", langName, languageCode)))
 	}
 
+	if userCfg != nil && userCfg.LockedSeed != nil {
+		settingsBuilder.WriteString(deps.I18n.T(userLang, "myconfig_setting_seed_locked", "seed", *userCfg.LockedSeed))
+	} else {
+		settingsBuilder.WriteString(deps.I18n.T(userLang, "myconfig_setting_seed_random"))
+	}
+	settingsBuilder.WriteString(deps.I18n.T(userLang, "myconfig_setting_delivery_mode", "value", deps.I18n.T(userLang, "delivery_mode_"+deliveryModeFor(userID, deps))))
+	if showPromptInCaption(userID, deps) {
+		settingsBuilder.WriteString(deps.I18n.T(userLang, "myconfig_setting_show_prompt_on"))
+	} else {
+		settingsBuilder.WriteString(deps.I18n.T(userLang, "myconfig_setting_show_prompt_off"))
+	}
+	if quickRepeatEnabled(userID, deps) {
+		settingsBuilder.WriteString(deps.I18n.T(userLang, "myconfig_setting_quick_repeat_on"))
+	} else {
+		settingsBuilder.WriteString(deps.I18n.T(userLang, "myconfig_setting_quick_repeat_off"))
+	}
+
+	// Verbose errors setting, only for non-admins when the operator allows it
+	verboseErrorsAvailable := deps.Cfg().AllowVerboseErrors && !deps.Authorizer.IsAdmin(userID)
+	if verboseErrorsAvailable {
+		if verboseErrorsEnabled(userID, deps) {
+			settingsBuilder.WriteString(deps.I18n.T(userLang, "myconfig_setting_verbose_errors_on"))
+		} else {
+			settingsBuilder.WriteString(deps.I18n.T(userLang, "myconfig_setting_verbose_errors_off"))
+		}
+	}
+
+	// Auto-delete setting, only when the operator enabled the feature
+	autoDeleteAvailable := deps.Cfg().AutoDelete.TTLMinutes > 0
+	if autoDeleteAvailable {
+		if autoDeleteEnabled(userID, deps) {
+			settingsBuilder.WriteString(deps.I18n.T(userLang, "myconfig_setting_auto_delete_on", "minutes", deps.Cfg().AutoDelete.TTLMinutes))
+		} else {
+			settingsBuilder.WriteString(deps.I18n.T(userLang, "myconfig_setting_auto_delete_off"))
+		}
+	}
+
 	settingsText := settingsBuilder.String()
 
 	// Create inline keyboard for modification using I18n
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "myconfig_button_set_image_size"), "config_set_imagesize")),     // "设置图片尺寸"
-		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "myconfig_button_set_inf_steps"), "config_set_infsteps")),       // "设置推理步数"
-		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "myconfig_button_set_guid_scale"), "config_set_guidscale")),     // "设置 Guidance Scale"
-		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "myconfig_button_set_num_images"), "config_set_numimages")),     // "设置生成数量"
-		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "config_callback_button_set_language"), "config_set_language")), // Add language button
-		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "myconfig_button_reset_defaults"), "config_reset_defaults")),    // "恢复默认设置"
-	)
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "myconfig_button_set_image_size"), "config_set_imagesize")),           // "设置图片尺寸"
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "myconfig_button_set_inf_steps"), "config_set_infsteps")),             // "设置推理步数"
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "myconfig_button_set_guid_scale"), "config_set_guidscale")),           // "设置 Guidance Scale"
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "myconfig_button_set_num_images"), "config_set_numimages")),           // "设置生成数量"
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "myconfig_button_set_delivery_mode"), "config_set_delivery")),         // "设置结果发送方式"
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "myconfig_button_toggle_seed_lock"), "config_toggle_seedlock")),       // "锁定/解锁种子"
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "myconfig_button_toggle_show_prompt"), "config_toggle_showprompt")),   // "显示/隐藏提示词"
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "myconfig_button_toggle_quick_repeat"), "config_toggle_quickrepeat")), // "快捷重复键盘"
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "config_callback_button_set_language"), "config_set_language")),       // Add language button
+	}
+	if autoDeleteAvailable {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "myconfig_button_toggle_auto_delete"), "config_toggle_autodelete")))
+	}
+	if verboseErrorsAvailable {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "myconfig_button_toggle_verbose_errors"), "config_toggle_verboseerrors")))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "myconfig_button_reset_defaults"), "config_reset_defaults"))) // "恢复默认设置"
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
 
 	reply := tgbotapi.NewMessage(chatID, settingsText)
 	// Switch back to ModeMarkdown
@@ -744,6 +1196,40 @@ func HandleMyConfigCommand(message *tgbotapi.Message, deps BotDeps) {
 	deps.Bot.Send(reply)
 }
 
+// applyConfigInput parses inputText for the setting of a config input action
+// ("awaiting_config_infsteps", "awaiting_config_guidscale" or "awaiting_config_numimages")
+// and stores it in userCfg if it is within limits. Otherwise userCfg is left alone and the
+// i18n key and arguments of the message asking for a valid value are returned.
+func applyConfigInput(userCfg *st.UserGenerationConfig, action string, inputText string, limits config.LimitsConfig) (string, []interface{}) {
+	switch action {
+	case "awaiting_config_infsteps":
+		steps, err := strconv.Atoi(inputText)
+		if err != nil || steps <= 0 || steps > limits.MaxInferenceSteps {
+			return "config_invalid_input_int_range", []interface{}{"min", 1, "max", limits.MaxInferenceSteps}
+		}
+		userCfg.NumInferenceSteps = steps
+
+	case "awaiting_config_guidscale":
+		scale, err := strconv.ParseFloat(inputText, 64)
+		maxScale := limits.GuidanceScaleCap()
+		if err != nil || scale < 0 || scale > maxScale {
+			return "config_invalid_input_float_range", []interface{}{"min", 0.0, "max", maxScale}
+		}
+		userCfg.GuidanceScale = scale
+
+	case "awaiting_config_numimages":
+		numImages, err := strconv.Atoi(inputText)
+		if err != nil || numImages <= 0 || numImages > limits.MaxNumImages {
+			return "config_invalid_input_int_range", []interface{}{"min", 1, "max", limits.MaxNumImages}
+		}
+		userCfg.NumImages = numImages
+
+	default:
+		return "unhandled_state_error", nil
+	}
+	return "", nil
+}
+
 // Handles text input when user is expected to provide a config value
 func HandleConfigUpdateInput(message *tgbotapi.Message, state *UserState, deps BotDeps) {
 	userID := message.From.ID
@@ -761,14 +1247,14 @@ func HandleConfigUpdateInput(message *tgbotapi.Message, state *UserState, deps B
 	}
 	// Initialize if nil (using defaults from config)
 	if userCfg == nil {
-		defaultCfg := deps.Config.DefaultGenerationSettings
+		defaultCfg := deps.Cfg().DefaultGenerationSettings
 		userCfg = &st.UserGenerationConfig{
 			UserID:            userID,
 			ImageSize:         defaultCfg.ImageSize,
 			NumInferenceSteps: defaultCfg.NumInferenceSteps,
 			GuidanceScale:     defaultCfg.GuidanceScale,
 			NumImages:         defaultCfg.NumImages,
-			Language:          deps.Config.DefaultLanguage,
+			Language:          deps.Cfg().DefaultLanguage,
 		}
 		deps.Logger.Debug("Initialized new config for user during config update", zap.Int64("user_id", userID))
 	}
@@ -777,47 +1263,12 @@ func HandleConfigUpdateInput(message *tgbotapi.Message, state *UserState, deps B
 	action := state.Action // e.g., "awaiting_config_infsteps"
 
 	switch action {
-	case "awaiting_config_infsteps":
-		steps, err := strconv.Atoi(inputText)
-		if err != nil || steps <= 0 || steps > 50 {
-			// More specific error, ask user to retry
-			// Use I18n for error message
+	case "awaiting_config_infsteps", "awaiting_config_guidscale", "awaiting_config_numimages":
+		if errKey, errArgs := applyConfigInput(userCfg, action, inputText, deps.Cfg().Limits); errKey != "" {
 			userLang := getUserLanguagePreference(userID, deps)
-			deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "config_invalid_input_int_range", "min", 1, "max", 50)))
-			// deps.Bot.Send(tgbotapi.NewMessage(chatID, "⚠️ 无效输入。请输入 1 到 50 之间的整数。"))
+			deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, errKey, errArgs...)))
 			return // Don't clear state, let user try again
 		}
-		// Assign value directly
-		userCfg.NumInferenceSteps = steps
-		// Fix SetUserGenerationConfig call signature
-		updateErr = st.SetUserGenerationConfig(deps.DB, *userCfg)
-
-	case "awaiting_config_guidscale":
-		scale, err := strconv.ParseFloat(inputText, 64)
-		if err != nil || scale < 0 || scale > 15 {
-			// More specific error, ask user to retry
-			userLang := getUserLanguagePreference(userID, deps)
-			deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "config_invalid_input_float_range", "min", 0.0, "max", 15.0)))
-			// deps.Bot.Send(tgbotapi.NewMessage(chatID, "⚠️ 无效输入。请输入 0 到 15 之间的数字 (例如 7.5)。"))
-			return // Don't clear state
-		}
-		// Assign value directly
-		userCfg.GuidanceScale = scale
-		// Fix SetUserGenerationConfig call signature
-		updateErr = st.SetUserGenerationConfig(deps.DB, *userCfg)
-
-	case "awaiting_config_numimages":
-		numImages, err := strconv.Atoi(inputText)
-		// Validate the input (e.g., 1-10, adjust as needed)
-		if err != nil || numImages <= 0 || numImages > 10 {
-			userLang := getUserLanguagePreference(userID, deps)
-			deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "config_invalid_input_int_range", "min", 1, "max", 10)))
-			// deps.Bot.Send(tgbotapi.NewMessage(chatID, "⚠️ 无效输入。请输入 1 到 10 之间的整数。"))
-			return // Don't clear state, let user try again
-		}
-		// Assign value directly
-		userCfg.NumImages = numImages
-		// Fix SetUserGenerationConfig call signature
 		updateErr = st.SetUserGenerationConfig(deps.DB, *userCfg)
 
 	default:
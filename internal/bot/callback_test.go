@@ -0,0 +1,49 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+)
+
+func TestApplyConfigInputHonorsLimits(t *testing.T) {
+	zero := 0.0
+	limits := config.LimitsConfig{MaxInferenceSteps: 30, MaxNumImages: 4}
+	zeroGuidance := limits
+	zeroGuidance.MaxGuidanceScale = &zero
+
+	tests := []struct {
+		name    string
+		action  string
+		input   string
+		limits  config.LimitsConfig
+		wantErr string
+		want    st.UserGenerationConfig
+	}{
+		{"steps at the cap", "awaiting_config_infsteps", "30", limits, "", st.UserGenerationConfig{NumInferenceSteps: 30}},
+		{"steps above the cap", "awaiting_config_infsteps", "31", limits, "config_invalid_input_int_range", st.UserGenerationConfig{}},
+		{"steps below 1", "awaiting_config_infsteps", "0", limits, "config_invalid_input_int_range", st.UserGenerationConfig{}},
+		{"steps not a number", "awaiting_config_infsteps", "many", limits, "config_invalid_input_int_range", st.UserGenerationConfig{}},
+		{"guidance under the default cap", "awaiting_config_guidscale", "15", limits, "", st.UserGenerationConfig{GuidanceScale: 15}},
+		{"guidance above the default cap", "awaiting_config_guidscale", "15.5", limits, "config_invalid_input_float_range", st.UserGenerationConfig{}},
+		{"guidance negative", "awaiting_config_guidscale", "-1", limits, "config_invalid_input_float_range", st.UserGenerationConfig{}},
+		{"guidance 0 under a 0 cap", "awaiting_config_guidscale", "0", zeroGuidance, "", st.UserGenerationConfig{}},
+		{"guidance above a 0 cap", "awaiting_config_guidscale", "0.5", zeroGuidance, "config_invalid_input_float_range", st.UserGenerationConfig{}},
+		{"images at the cap", "awaiting_config_numimages", "4", limits, "", st.UserGenerationConfig{NumImages: 4}},
+		{"images above the cap", "awaiting_config_numimages", "5", limits, "config_invalid_input_int_range", st.UserGenerationConfig{}},
+		{"unknown action", "awaiting_config_size", "4", limits, "unhandled_state_error", st.UserGenerationConfig{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var userCfg st.UserGenerationConfig
+			errKey, _ := applyConfigInput(&userCfg, tt.action, tt.input, tt.limits)
+			if errKey != tt.wantErr {
+				t.Errorf("applyConfigInput(%q, %q) error key = %q, want %q", tt.action, tt.input, errKey, tt.wantErr)
+			}
+			if userCfg.NumInferenceSteps != tt.want.NumInferenceSteps || userCfg.GuidanceScale != tt.want.GuidanceScale || userCfg.NumImages != tt.want.NumImages {
+				t.Errorf("applyConfigInput(%q, %q) set %+v, want %+v", tt.action, tt.input, userCfg, tt.want)
+			}
+		})
+	}
+}
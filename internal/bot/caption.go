@@ -0,0 +1,170 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	falapi "github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+	"go.uber.org/zap"
+)
+
+// captionRetryBackoff is the pause before retry n is n times this.
+const captionRetryBackoff = 2 * time.Second
+
+// photoFlowBudget returns limits.photoFlowBudgetSeconds for a flow that started with a photo,
+// or 0 if there is no budget. It bounds the caption and generation time together; the time
+// the user takes to confirm the caption and pick LoRAs doesn't count.
+func photoFlowBudget(state *UserState, deps BotDeps) time.Duration {
+	if state.ImageFileURL == "" {
+		return 0
+	}
+	return time.Duration(deps.Cfg().Limits.PhotoFlowBudgetSeconds) * time.Second
+}
+
+// formatElapsed formats d to the second, e.g. "2m5s".
+func formatElapsed(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+// captionWithRetry submits imageURL to captionEndpoint and polls for the caption, retrying up to
+// maxAttempts in total on transient errors (network, 429, 5xx). A failed poll re-polls the
+// same request instead of submitting a new one. ctx bounds all attempts; its deadline is
+// never retried. onSubmitted is called once a request ID is known, onRetry before each retry.
+func captionWithRetry(ctx context.Context, imageURL, captionEndpoint string, pollInterval time.Duration, maxAttempts int,
+	onSubmitted func(requestID string), onRetry func(attempt, maxAttempts int, err error), deps BotDeps) (string, string, error) {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var requestID string
+	var err error
+	for attempt := 1; ; attempt++ {
+		if requestID == "" {
+			requestID, err = deps.FalClient.SubmitCaptionRequestTo(imageURL, captionEndpoint)
+			if err == nil {
+				onSubmitted(requestID)
+			}
+		}
+		if err == nil {
+			var caption string
+			caption, err = deps.FalClient.PollForCaptionResult(ctx, requestID, captionEndpoint, pollInterval)
+			if err == nil {
+				return requestID, caption, nil
+			}
+		}
+
+		if attempt >= maxAttempts || !falapi.IsTransient(err) || ctx.Err() != nil {
+			return requestID, "", err
+		}
+		onRetry(attempt+1, maxAttempts, err)
+		select {
+		case <-time.After(time.Duration(attempt) * captionRetryBackoff):
+		case <-ctx.Done():
+			return requestID, "", ctx.Err()
+		}
+	}
+}
+
+// offerCaptionFallback tells the user a photo couldn't be captioned (reason) and points to a
+// usable next step: with fallbackPrompt configured, the usual caption confirmation is shown
+// for that prompt; otherwise the user is asked to send a text prompt. The status message
+// messageID is edited if set, else a new message is sent.
+func offerCaptionFallback(chatID, userID int64, messageID int, reason string, userLang *string, deps BotDeps) {
+	fallbackPrompt := deps.Cfg().FallbackPrompt
+	if fallbackPrompt == "" {
+		text := reason + deps.I18n.T(userLang, "photo_caption_fallback_send_text")
+		if messageID != 0 {
+			edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+			edit.ReplyMarkup = nil
+			deps.Edits.EditNow(edit)
+		} else {
+			deps.Bot.Send(tgbotapi.NewMessage(chatID, text))
+		}
+		return
+	}
+
+	text := reason + deps.I18n.T(userLang, "photo_caption_fallback_offer", "prompt", fallbackPrompt)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "photo_caption_fallback_use_button"), "caption_confirm"),
+			tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "photo_caption_cancel_button"), "caption_cancel"),
+		),
+	)
+	if messageID != 0 {
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+		edit.ReplyMarkup = &keyboard
+		if err := deps.Edits.EditNow(edit); err != nil {
+			return
+		}
+	} else {
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ReplyMarkup = keyboard
+		sent, err := deps.Bot.Send(msg)
+		if err != nil {
+			deps.Logger.Error("Failed to offer fallback prompt", zap.Error(err), zap.Int64("user_id", userID))
+			return
+		}
+		messageID = sent.MessageID
+	}
+
+	// Reuses the caption confirmation: confirming continues with LoRA selection
+	deps.StateManager.SetState(userID, &UserState{
+		UserID:          userID,
+		ChatID:          chatID,
+		MessageID:       messageID,
+		Action:          "awaiting_caption_confirmation",
+		OriginalCaption: fallbackPrompt,
+		SelectedLoras:   []string{},
+	})
+}
+
+// showCaptionConfirmation stores state as awaiting caption confirmation and shows msgText
+// (Markdown) with the confirm, cancel and copy buttons, editing state.MessageID if set. With
+// more than one caption model configured, a photo caption also gets the re-caption button.
+func showCaptionConfirmation(state *UserState, msgText, confirmButton string, userLang *string, deps BotDeps) {
+	state.Action = "awaiting_caption_confirmation"
+	state.SelectedLoras = []string{}
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(confirmButton, "caption_confirm"),
+			tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "photo_caption_cancel_button"), "caption_cancel"),
+		),
+	}
+	// The caption sits in a code block, which is awkward to copy on mobile
+	extraRow := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "photo_caption_copy_button"), "caption_copy"),
+	)
+	if state.ImageFileURL != "" && len(deps.Cfg().APIEndpoints.CaptionEndpoints()) > 1 {
+		extraRow = append(extraRow, tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "photo_recaption_button"), "caption_recaption"))
+	}
+	rows = append(rows, extraRow)
+	confirmationKeyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	var err error
+	if state.MessageID != 0 {
+		editMsg := tgbotapi.NewEditMessageText(state.ChatID, state.MessageID, msgText)
+		editMsg.ParseMode = tgbotapi.ModeMarkdown
+		editMsg.ReplyMarkup = &confirmationKeyboard
+		err = retrySend(state.ChatID, func() error { return deps.Edits.EditNow(editMsg) }, deps)
+	} else {
+		newMsg := tgbotapi.NewMessage(state.ChatID, msgText)
+		newMsg.ParseMode = tgbotapi.ModeMarkdown
+		newMsg.ReplyMarkup = &confirmationKeyboard
+		var sent tgbotapi.Message
+		sent, err = sendWithRetry(state.ChatID, newMsg, deps)
+		state.MessageID = sent.MessageID
+	}
+	if err != nil {
+		deps.Logger.Error("Failed to send caption result & confirmation keyboard", zap.Error(err), zap.Int64("user_id", state.UserID))
+	}
+	deps.StateManager.SetState(state.UserID, state)
+}
+
+// isShortCaption reports whether caption has fewer than minLength characters, ignoring
+// surrounding whitespace. A minLength of 0 disables the check.
+func isShortCaption(caption string, minLength int) bool {
+	return minLength > 0 && utf8.RuneCountInString(strings.TrimSpace(caption)) < minLength
+}
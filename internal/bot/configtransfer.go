@@ -0,0 +1,275 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+	falapi "github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	// userConfigExportVersion is written to exported files; imports of other versions are refused.
+	userConfigExportVersion = 1
+	// maxUserConfigFileSize limits config files sent for /importconfig.
+	maxUserConfigFileSize = 16 * 1024
+)
+
+// exportedUserConfig is the file format of /exportconfig and /importconfig: the settings of
+// /myconfig and /setdefaultloras, without the user ID and timestamps so a file can be moved
+// between accounts or bots.
+type exportedUserConfig struct {
+	Version           int      `json:"version"`
+	ImageSize         string   `json:"image_size"`
+	NumInferenceSteps int      `json:"num_inference_steps"`
+	GuidanceScale     float64  `json:"guidance_scale"`
+	NumImages         int      `json:"num_images"`
+	Language          string   `json:"language,omitempty"`
+	AutoDelete        *bool    `json:"auto_delete,omitempty"`
+	DeliveryMode      string   `json:"delivery_mode,omitempty"`
+	LockedSeed        *uint64  `json:"locked_seed,omitempty"`
+	ShowPrompt        *bool    `json:"show_prompt,omitempty"`
+	QuickRepeat       *bool    `json:"quick_repeat,omitempty"`
+	VerboseErrors     *bool    `json:"verbose_errors,omitempty"`
+	DefaultLoras      []string `json:"default_loras,omitempty"`
+}
+
+// configTransferTarget returns the user a /exportconfig or /importconfig message is about:
+// the sender, or for admins the user ID given as argument. It replies and returns false if
+// the argument is invalid or the sender isn't allowed to give one.
+func configTransferTarget(message *tgbotapi.Message, userLang *string, deps BotDeps) (int64, bool) {
+	userID := message.From.ID
+	arg := strings.TrimSpace(message.CommandArguments())
+	if arg == "" {
+		return userID, true
+	}
+	if !deps.Authorizer.IsAdmin(userID) {
+		deps.Bot.Send(tgbotapi.NewMessage(message.Chat.ID, deps.I18n.T(userLang, "myconfig_command_admin_only")))
+		return 0, false
+	}
+	targetID, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || targetID <= 0 {
+		deps.Bot.Send(tgbotapi.NewMessage(message.Chat.ID, deps.I18n.T(userLang, "configtransfer_invalid_user")))
+		return 0, false
+	}
+	return targetID, true
+}
+
+// HandleExportConfigCommand handles /exportconfig and sends the user's settings as a JSON
+// file that /importconfig accepts. Admins can export another user's settings with
+// "/exportconfig <userID>".
+func HandleExportConfigCommand(message *tgbotapi.Message, deps BotDeps) {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+	userLang := getUserLanguagePreference(userID, deps)
+	targetID, ok := configTransferTarget(message, userLang, deps)
+	if !ok {
+		return
+	}
+
+	userCfg, err := loadUserConfigOrDefault(targetID, deps)
+	if err != nil {
+		deps.Logger.Error("Failed to load user config for export", zap.Error(err), zap.Int64("target_user", targetID))
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "error_generic")))
+		return
+	}
+	exported := exportedUserConfig{
+		Version:           userConfigExportVersion,
+		ImageSize:         userCfg.ImageSize,
+		NumInferenceSteps: userCfg.NumInferenceSteps,
+		GuidanceScale:     userCfg.GuidanceScale,
+		NumImages:         userCfg.NumImages,
+		Language:          userCfg.Language,
+		AutoDelete:        userCfg.AutoDelete,
+		DeliveryMode:      userCfg.DeliveryMode,
+		LockedSeed:        userCfg.LockedSeed,
+		ShowPrompt:        userCfg.ShowPrompt,
+		QuickRepeat:       userCfg.QuickRepeat,
+		VerboseErrors:     userCfg.VerboseErrors,
+		DefaultLoras:      userCfg.DefaultLoras,
+	}
+	data, err := json.MarshalIndent(exported, "", "  ")
+	if err != nil {
+		deps.Logger.Error("Failed to encode user config for export", zap.Error(err), zap.Int64("target_user", targetID))
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "error_generic")))
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: fmt.Sprintf("bot-config-%d.json", targetID), Bytes: data})
+	doc.Caption = deps.I18n.T(userLang, "exportconfig_caption")
+	if _, err := deps.Bot.Send(doc); err != nil {
+		deps.Logger.Error("Failed to send exported user config", zap.Error(err), zap.Int64("target_user", targetID))
+		return
+	}
+	deps.Logger.Info("Exported user config", zap.Int64("user_id", userID), zap.Int64("target_user", targetID))
+}
+
+// HandleImportConfigCommand handles /importconfig. Sent as a reply to a config file, it
+// imports that file right away; otherwise it asks for the file. Admins can import for
+// another user with "/importconfig <userID>".
+func HandleImportConfigCommand(message *tgbotapi.Message, deps BotDeps) {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+	userLang := getUserLanguagePreference(userID, deps)
+	targetID, ok := configTransferTarget(message, userLang, deps)
+	if !ok {
+		return
+	}
+
+	if reply := message.ReplyToMessage; reply != nil && reply.Document != nil {
+		deps.StateManager.ClearState(userID)
+		importUserConfigDocument(chatID, userID, targetID, reply.Document, deps)
+		return
+	}
+
+	sent, err := deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "importconfig_send_file")))
+	if err != nil {
+		deps.Logger.Error("Failed to send config import instructions", zap.Error(err), zap.Int64("user_id", userID))
+		return
+	}
+	deps.StateManager.SetState(userID, &UserState{
+		UserID:    userID,
+		ChatID:    chatID,
+		MessageID: sent.MessageID,
+		Action:    fmt.Sprintf("awaiting_import_config_%d", targetID),
+	})
+}
+
+// HandleImportConfigDocument handles the config file sent after /importconfig.
+func HandleImportConfigDocument(message *tgbotapi.Message, state *UserState, deps BotDeps) {
+	userID := message.From.ID
+	targetID, err := strconv.ParseInt(strings.TrimPrefix(state.Action, "awaiting_import_config_"), 10, 64)
+	if err != nil {
+		deps.Logger.Error("Invalid config import state action", zap.String("action", state.Action))
+		deps.Bot.Send(tgbotapi.NewMessage(message.Chat.ID, deps.I18n.T(getUserLanguagePreference(userID, deps), "error_generic")))
+		deps.StateManager.ClearState(userID)
+		return
+	}
+	// An admin may have lost their rights since the command
+	if targetID != userID && !deps.Authorizer.IsAdmin(userID) {
+		deps.Bot.Send(tgbotapi.NewMessage(message.Chat.ID, deps.I18n.T(getUserLanguagePreference(userID, deps), "myconfig_command_admin_only")))
+		deps.StateManager.ClearState(userID)
+		return
+	}
+	deps.StateManager.ClearState(userID)
+	importUserConfigDocument(message.Chat.ID, userID, targetID, message.Document, deps)
+}
+
+// importUserConfigDocument downloads doc, validates it as an exported config for targetID
+// and stores it. Nothing is changed unless every field is valid.
+func importUserConfigDocument(chatID int64, userID int64, targetID int64, doc *tgbotapi.Document, deps BotDeps) {
+	userLang := getUserLanguagePreference(userID, deps)
+	if doc.FileSize > maxUserConfigFileSize {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "importconfig_invalid", "error", fmt.Sprintf("file is larger than %d KB", maxUserConfigFileSize/1024))))
+		return
+	}
+	fileURL, err := deps.Bot.GetFileDirectURL(doc.FileID)
+	if err != nil {
+		err = withoutRequestURL(err)
+		deps.Logger.Error("Failed to get config file URL", zap.Error(err), zap.Int64("user_id", userID))
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "batch_file_read_error", "error", err.Error())))
+		return
+	}
+	content, err := downloadBatchFile(fileURL)
+	if err != nil {
+		deps.Logger.Error("Failed to download config file", zap.Error(err), zap.Int64("user_id", userID))
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "batch_file_read_error", "error", err.Error())))
+		return
+	}
+
+	userCfg, err := parseExportedUserConfig([]byte(content), targetID, deps)
+	if err != nil {
+		deps.Logger.Info("Rejected config import", zap.Error(err), zap.Int64("user_id", userID), zap.Int64("target_user", targetID))
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "importconfig_invalid", "error", err.Error())))
+		return
+	}
+	if err := st.SetUserGenerationConfig(deps.DB, *userCfg); err != nil {
+		deps.Logger.Error("Failed to store imported user config", zap.Error(err), zap.Int64("target_user", targetID))
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "error_generic")))
+		return
+	}
+	deps.Logger.Info("Imported user config", zap.Int64("user_id", userID), zap.Int64("target_user", targetID))
+	if targetID != userID {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "importconfig_success_for_user", "userID", targetID)))
+		return
+	}
+	// The imported language applies to the confirmation already
+	deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(getUserLanguagePreference(userID, deps), "importconfig_success")))
+}
+
+// parseExportedUserConfig decodes and validates an exported config for targetID against the
+// current limits, LoRAs and languages, and returns it as targetID's stored config.
+func parseExportedUserConfig(data []byte, targetID int64, deps BotDeps) (*st.UserGenerationConfig, error) {
+	var exported exportedUserConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&exported); err != nil {
+		return nil, fmt.Errorf("not a valid config file: %w", err)
+	}
+	if exported.Version != userConfigExportVersion {
+		return nil, fmt.Errorf("unsupported version %d, expected %d", exported.Version, userConfigExportVersion)
+	}
+
+	cfg := deps.Cfg()
+	limits := cfg.Limits
+	if !falapi.IsImageSizePreset(exported.ImageSize) {
+		return nil, fmt.Errorf("unknown image_size %q", exported.ImageSize)
+	}
+	if err := checkImageSizeLimit(exported.ImageSize, nil, cfg.APIEndpoints.MaxImagePixels); err != nil {
+		return nil, err
+	}
+	if exported.NumInferenceSteps < 1 || exported.NumInferenceSteps > limits.MaxInferenceSteps {
+		return nil, fmt.Errorf("num_inference_steps must be between 1 and %d", limits.MaxInferenceSteps)
+	}
+	if exported.GuidanceScale < 0 || exported.GuidanceScale > limits.GuidanceScaleCap() {
+		return nil, fmt.Errorf("guidance_scale must be between 0 and %g", limits.GuidanceScaleCap())
+	}
+	if exported.NumImages < 1 || exported.NumImages > limits.MaxNumImages {
+		return nil, fmt.Errorf("num_images must be between 1 and %d", limits.MaxNumImages)
+	}
+	if exported.Language != "" {
+		if _, ok := deps.I18n.GetAvailableLanguages()[exported.Language]; !ok {
+			return nil, fmt.Errorf("unknown language %q", exported.Language)
+		}
+	}
+	if exported.DeliveryMode != "" && !config.IsDeliveryMode(exported.DeliveryMode) {
+		return nil, fmt.Errorf("unknown delivery_mode %q", exported.DeliveryMode)
+	}
+	if exported.LockedSeed != nil && *exported.LockedSeed > math.MaxUint32 {
+		return nil, errors.New("locked_seed must fit in 32 bits")
+	}
+	if exported.VerboseErrors != nil && *exported.VerboseErrors && !cfg.AllowVerboseErrors && !deps.Authorizer.IsAdmin(targetID) {
+		return nil, errors.New("verbose_errors is not allowed on this bot")
+	}
+	visible := GetUserVisibleLoras(targetID, deps)
+	for _, name := range exported.DefaultLoras {
+		if _, found := findLoraByName(name, visible); !found {
+			return nil, fmt.Errorf("default LoRA %q is not available", name)
+		}
+	}
+
+	return &st.UserGenerationConfig{
+		UserID:            targetID,
+		ImageSize:         exported.ImageSize,
+		NumInferenceSteps: exported.NumInferenceSteps,
+		GuidanceScale:     exported.GuidanceScale,
+		NumImages:         exported.NumImages,
+		Language:          exported.Language,
+		AutoDelete:        exported.AutoDelete,
+		DeliveryMode:      exported.DeliveryMode,
+		LockedSeed:        exported.LockedSeed,
+		ShowPrompt:        exported.ShowPrompt,
+		QuickRepeat:       exported.QuickRepeat,
+		VerboseErrors:     exported.VerboseErrors,
+		DefaultLoras:      exported.DefaultLoras,
+	}, nil
+}
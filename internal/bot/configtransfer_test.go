@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/auth"
+	cfg "github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/i18n"
+	"go.uber.org/zap"
+)
+
+func TestParseExportedUserConfig(t *testing.T) {
+	i18nManager, err := i18n.NewManager("en", nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	deps := BotDeps{
+		Logger:     zap.NewNop(),
+		Authorizer: auth.NewAuthorizer([]int64{7}, nil),
+		I18n:       i18nManager,
+		LoraHealth: NewLoraHealth(),
+		live: newLiveConfig(
+			&cfg.Config{
+				DefaultLanguage: "en",
+				APIEndpoints:    cfg.APIEndpointsConfig{MaxImagePixels: 1024 * 768},
+				Limits:          cfg.LimitsConfig{MaxInferenceSteps: 30, MaxNumImages: 4},
+				UserGroups:      []cfg.UserGroup{{Name: "friends", UserIDs: []int64{8}}},
+			},
+			[]LoraConfig{{Name: "Public"}, {Name: "Private", AllowGroups: []string{"friends"}}},
+			nil,
+		),
+	}
+
+	const valid = `"version": 1, "image_size": "landscape_4_3", "num_inference_steps": 28, "guidance_scale": 3.5, "num_images": 2`
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"valid", `{` + valid + `, "language": "zh", "locked_seed": 4294967295, "default_loras": ["Public"]}`, ""},
+		{"not JSON", `version = 1`, "not a valid config file"},
+		{"unknown field", `{` + valid + `, "user_id": 8}`, `unknown field "user_id"`},
+		{"missing version", `{"image_size": "landscape_4_3", "num_inference_steps": 28, "guidance_scale": 3.5, "num_images": 2}`, "unsupported version 0"},
+		{"future version", `{"version": 2, "image_size": "landscape_4_3", "num_inference_steps": 28, "guidance_scale": 3.5, "num_images": 2}`, "unsupported version 2"},
+		{"unknown size", strings.Replace(`{`+valid+`}`, "landscape_4_3", "huge", 1), `unknown image_size "huge"`},
+		{"size above the pixel limit", strings.Replace(`{`+valid+`}`, "landscape_4_3", "square_hd", 1), "square_hd"},
+		{"steps above the cap", strings.Replace(`{`+valid+`}`, `"num_inference_steps": 28`, `"num_inference_steps": 31`, 1), "num_inference_steps must be between 1 and 30"},
+		{"steps 0", strings.Replace(`{`+valid+`}`, `"num_inference_steps": 28`, `"num_inference_steps": 0`, 1), "num_inference_steps must be between 1 and 30"},
+		{"guidance above the cap", strings.Replace(`{`+valid+`}`, `"guidance_scale": 3.5`, `"guidance_scale": 15.5`, 1), "guidance_scale must be between 0 and 15"},
+		{"guidance negative", strings.Replace(`{`+valid+`}`, `"guidance_scale": 3.5`, `"guidance_scale": -1`, 1), "guidance_scale must be between 0 and 15"},
+		{"images above the cap", strings.Replace(`{`+valid+`}`, `"num_images": 2`, `"num_images": 5`, 1), "num_images must be between 1 and 4"},
+		{"unknown language", `{` + valid + `, "language": "xx"}`, `unknown language "xx"`},
+		{"unknown delivery mode", `{` + valid + `, "delivery_mode": "fax"}`, `unknown delivery_mode "fax"`},
+		{"seed above 32 bits", `{` + valid + `, "locked_seed": 4294967296}`, "locked_seed must fit in 32 bits"},
+		{"negative seed", `{` + valid + `, "locked_seed": -1}`, "not a valid config file"},
+		{"verbose errors not allowed", `{` + valid + `, "verbose_errors": true}`, "verbose_errors is not allowed"},
+		{"unknown LoRA", `{` + valid + `, "default_loras": ["Missing"]}`, `default LoRA "Missing" is not available`},
+		{"LoRA of another group", `{` + valid + `, "default_loras": ["Private"]}`, `default LoRA "Private" is not available`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userCfg, err := parseExportedUserConfig([]byte(tt.data), 7, deps)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("parseExportedUserConfig: %v", err)
+				}
+				if userCfg.UserID != 7 || userCfg.ImageSize != "landscape_4_3" || userCfg.NumImages != 2 || userCfg.DefaultLoras[0] != "Public" {
+					t.Errorf("parseExportedUserConfig = %+v, want the file's settings for user 7", userCfg)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseExportedUserConfig error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
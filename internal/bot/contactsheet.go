@@ -0,0 +1,201 @@
+package bot
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+	"github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	// contactSheetGap is the space in pixels between and around the thumbnails.
+	contactSheetGap = 8
+	// contactSheetMaxSides is Telegram's limit on the width plus height of a photo.
+	contactSheetMaxSides = 10000
+	// contactSheetDownloads is how many result images are downloaded at once.
+	contactSheetDownloads = 4
+	// contactSheetJPEGQuality is the JPEG quality of the sheet.
+	contactSheetJPEGQuality = 85
+	// maxPhotoCaptionRunes is Telegram's caption limit for photos and documents.
+	maxPhotoCaptionRunes = 1024
+)
+
+var (
+	contactSheetBackground = color.RGBA{R: 32, G: 32, B: 32, A: 255}
+	contactSheetBadge      = color.RGBA{A: 200}
+)
+
+// sheetDigits is a 3x5 pixel font for the thumbnail numbers, one row of three bits per line.
+var sheetDigits = [10][5]uint8{
+	{7, 5, 5, 5, 7}, {2, 6, 2, 2, 7}, {7, 1, 7, 4, 7}, {7, 1, 7, 1, 7}, {5, 5, 7, 1, 1},
+	{7, 4, 7, 1, 7}, {7, 4, 7, 5, 7}, {7, 1, 1, 1, 1}, {7, 5, 7, 5, 7}, {7, 5, 7, 1, 7},
+}
+
+// useContactSheet reports whether images are sent as a contact sheet: the sheet is enabled,
+// there are at least contactSheet.minImages of them and they are all photos.
+func useContactSheet(images []falapi.ImageInfo, deps BotDeps) bool {
+	sheetCfg := deps.Cfg().ContactSheet
+	if !sheetCfg.Enabled || len(images) < sheetCfg.MinImages {
+		return false
+	}
+	for _, img := range images {
+		if detectMediaKind(img) != mediaPhoto {
+			return false
+		}
+	}
+	return true
+}
+
+// sendContactSheet sends images to chatID as one numbered thumbnail grid with an index
+// caption, followed by the full-size images as documents if contactSheet.sendOriginals is
+// set. It reports false if the sheet couldn't be built or sent, in which case nothing was
+// sent and the caller should fall back to albums. The error is the first failed original.
+func sendContactSheet(chatID int64, images []falapi.ImageInfo, labels []string, groups []resultGroup, userLang *string, deps BotDeps) ([]int, bool, error) {
+	sheetCfg := deps.Cfg().ContactSheet
+	sheet, err := buildContactSheet(images, sheetCfg.Columns, sheetCfg.ThumbSize)
+	if err != nil {
+		deps.Logger.Warn("Failed to build contact sheet, sending albums", zap.Error(err), zap.Int64("chat_id", chatID), zap.Int("image_count", len(images)))
+		return nil, false, nil
+	}
+
+	labels = deliveryLabels(config.DeliveryModeSingle, len(images), labels, groups)
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "contact_sheet.jpg", Bytes: sheet})
+	photo.Caption = truncateRunes(contactSheetIndex(labels, len(images), userLang, deps), maxPhotoCaptionRunes)
+	msg, err := sendWithRetry(chatID, photo, deps)
+	if err != nil {
+		deps.Logger.Warn("Failed to send contact sheet, sending albums", zap.Error(err), zap.Int64("chat_id", chatID), zap.Int("sheet_bytes", len(sheet)))
+		return nil, false, nil
+	}
+	ids := []int{msg.MessageID}
+	if !sheetCfg.SendOriginals {
+		return ids, true, nil
+	}
+
+	files := make([]mediaFile, 0, len(images))
+	for i, img := range images {
+		caption := strconv.Itoa(i + 1)
+		if i < len(labels) && labels[i] != "" {
+			caption += " · " + labels[i]
+		}
+		files = append(files, mediaFile{Kind: mediaDocument, File: tgbotapi.FileURL(img.URL), Caption: caption})
+	}
+	msgs, err := sendMediaFiles(chatID, files, deps)
+	for _, m := range msgs {
+		ids = append(ids, m.MessageID)
+	}
+	return ids, true, err
+}
+
+// contactSheetIndex returns the sheet caption: the image count and which numbers each LoRA
+// combination made, e.g. "1–4: Anime". Consecutive images with the same label share a line.
+func contactSheetIndex(labels []string, count int, userLang *string, deps BotDeps) string {
+	var text strings.Builder
+	text.WriteString(deps.I18n.T(userLang, "contact_sheet_caption", "count", count))
+	for start := 0; start < count; {
+		label := ""
+		if start < len(labels) {
+			label = labels[start]
+		}
+		end := start + 1
+		for end < count && end < len(labels) && labels[end] == label {
+			end++
+		}
+		if label != "" {
+			if end-start == 1 {
+				fmt.Fprintf(&text, "\n%d: %s", start+1, label)
+			} else {
+				fmt.Fprintf(&text, "\n%d–%d: %s", start+1, end, label)
+			}
+		}
+		start = end
+	}
+	return text.String()
+}
+
+// buildContactSheet downloads images and returns a JPEG grid of them, columns wide, each
+// scaled to fit a square of thumbSize pixels and numbered in its top left corner. The
+// thumbnails shrink if needed to keep the sheet within Telegram's photo size limit.
+func buildContactSheet(images []falapi.ImageInfo, columns int, thumbSize int) ([]byte, error) {
+	cols := min(columns, len(images))
+	rows := (len(images) + cols - 1) / cols
+	cell := min(thumbSize, contactSheetMaxSides/(cols+rows)-contactSheetGap)
+	if cell < 16 {
+		return nil, fmt.Errorf("too many images for a contact sheet: %d", len(images))
+	}
+
+	thumbs := make([]image.Image, len(images))
+	errs := make([]error, len(images))
+	slots := make(chan struct{}, contactSheetDownloads)
+	var wg sync.WaitGroup
+	for i, img := range images {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			src, err := downloadImage(img.URL)
+			if err != nil {
+				errs[i] = fmt.Errorf("image %d: %w", i+1, err)
+				return
+			}
+			thumbs[i] = downscaleImage(src, cell)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	width := cols*(cell+contactSheetGap) + contactSheetGap
+	height := rows*(cell+contactSheetGap) + contactSheetGap
+	sheet := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(contactSheetBackground), image.Point{}, draw.Src)
+	scale := max(2, cell/64) // Size of one font pixel
+	for i, thumb := range thumbs {
+		cellX := contactSheetGap + (i%cols)*(cell+contactSheetGap)
+		cellY := contactSheetGap + (i/cols)*(cell+contactSheetGap)
+		b := thumb.Bounds()
+		at := image.Pt(cellX+(cell-b.Dx())/2, cellY+(cell-b.Dy())/2)
+		draw.Draw(sheet, image.Rectangle{Min: at, Max: at.Add(b.Size())}, thumb, b.Min, draw.Src)
+		drawSheetNumber(sheet, image.Pt(cellX, cellY), i+1, scale)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, sheet, &jpeg.Options{Quality: contactSheetJPEGQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode contact sheet: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// drawSheetNumber draws n in white on a dark badge with its top left corner at at.
+func drawSheetNumber(dst draw.Image, at image.Point, n int, scale int) {
+	digits := strconv.Itoa(n)
+	badge := image.Rect(0, 0, (len(digits)*4+1)*scale, 7*scale).Add(at)
+	draw.Draw(dst, badge, image.NewUniform(contactSheetBadge), image.Point{}, draw.Over)
+	for d, ch := range digits {
+		glyph := sheetDigits[ch-'0']
+		for row, bits := range glyph {
+			for col := range 3 {
+				if bits&(4>>col) == 0 {
+					continue
+				}
+				x := badge.Min.X + (1+d*4+col)*scale
+				y := badge.Min.Y + (1+row)*scale
+				draw.Draw(dst, image.Rect(x, y, x+scale, y+scale), image.White, image.Point{}, draw.Src)
+			}
+		}
+	}
+}
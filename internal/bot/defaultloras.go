@@ -0,0 +1,131 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// userDefaultLoras returns the default LoRAs of userID that the user can still see, in
+// the order they were chosen. LoRAs removed from the config or no longer visible to the
+// user are skipped.
+func userDefaultLoras(userID int64, deps BotDeps) []string {
+	userCfg, err := st.GetUserGenerationConfig(deps.DB, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		deps.Logger.Warn("Failed to get user config for default LoRAs", zap.Error(err), zap.Int64("user_id", userID))
+	}
+	if userCfg == nil || len(userCfg.DefaultLoras) == 0 {
+		return nil
+	}
+	visible := GetUserVisibleLoras(userID, deps)
+	var names []string
+	for _, name := range userCfg.DefaultLoras {
+		if _, ok := findLoraByName(name, visible); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// HandleSetDefaultLorasCommand handles /setdefaultloras: a keyboard of the user's visible
+// LoRAs where each tap adds or removes a default LoRA. Default LoRAs start out selected
+// whenever the LoRA keyboard is shown.
+func HandleSetDefaultLorasCommand(chatID int64, userID int64, deps BotDeps) {
+	userLang := getUserLanguagePreference(userID, deps)
+	msg := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "defloras_prompt"))
+	msg.ReplyMarkup = defaultLorasKeyboard(userID, userDefaultLoras(userID, deps), userLang, deps)
+	deps.Bot.Send(msg)
+}
+
+// defaultLorasKeyboard lists the visible LoRAs of userID, checking those in selected.
+func defaultLorasKeyboard(userID int64, selected []string, userLang *string, deps BotDeps) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, lora := range GetUserVisibleLoras(userID, deps) {
+		text := lora.Name
+		if slices.Contains(selected, lora.Name) {
+			text = deps.I18n.T(userLang, "button_checkmark") + " " + lora.Name
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(text, "defloras_toggle_"+lora.ID))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "defloras_button_clear"), "defloras_clear"),
+		tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "defloras_button_done"), "defloras_done"),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// HandleDefaultLorasCallback handles the buttons of /setdefaultloras. Every change is saved
+// right away.
+func HandleDefaultLorasCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
+	userID := callbackQuery.From.ID
+	chatID := callbackQuery.Message.Chat.ID
+	messageID := callbackQuery.Message.MessageID
+	userLang := getUserLanguagePreference(userID, deps)
+	answer := tgbotapi.NewCallback(callbackQuery.ID, "")
+	data := callbackQuery.Data
+
+	if data == "defloras_done" {
+		deps.Bot.Request(answer)
+		names := userDefaultLoras(userID, deps)
+		text := deps.I18n.T(userLang, "defloras_saved_none")
+		if len(names) > 0 {
+			text = deps.I18n.T(userLang, "defloras_saved", "loras", strings.Join(names, ", "))
+		}
+		deps.Bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, text))
+		return
+	}
+
+	selected := userDefaultLoras(userID, deps)
+	switch {
+	case data == "defloras_clear":
+		selected = nil
+	case strings.HasPrefix(data, "defloras_toggle_"):
+		lora := findLoraByID(strings.TrimPrefix(data, "defloras_toggle_"), GetUserVisibleLoras(userID, deps))
+		if lora.ID == "" {
+			answer.Text = deps.I18n.T(userLang, "defloras_unknown")
+			deps.Bot.Request(answer)
+			return
+		}
+		if i := slices.Index(selected, lora.Name); i >= 0 {
+			selected = slices.Delete(selected, i, i+1)
+		} else {
+			selected = append(selected, lora.Name)
+		}
+	default:
+		deps.Bot.Request(answer)
+		return
+	}
+
+	userCfg, err := loadUserConfigOrDefault(userID, deps)
+	if err == nil {
+		userCfg.DefaultLoras = selected
+		if len(selected) == 0 {
+			userCfg.DefaultLoras = nil
+		}
+		err = st.SetUserGenerationConfig(deps.DB, *userCfg)
+	}
+	if err != nil {
+		deps.Logger.Error("Failed to save default LoRAs", zap.Error(err), zap.Int64("user_id", userID))
+		answer.Text = deps.I18n.T(userLang, "defloras_save_fail")
+		deps.Bot.Request(answer)
+		return
+	}
+	deps.Logger.Info("Updated default LoRAs", zap.Int64("user_id", userID), zap.Strings("loras", selected))
+	deps.Bot.Request(answer)
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, defaultLorasKeyboard(userID, selected, userLang, deps))
+	sendOrEdit(edit, deps)
+}
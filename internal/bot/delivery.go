@@ -0,0 +1,157 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+	"go.uber.org/zap"
+)
+
+// resultGroup describes the consecutive images of one LoRA combination in a result set.
+type resultGroup struct {
+	Name  string // LoRA names, e.g. "Anime+Detail"
+	Count int    // Number of images
+}
+
+// deliveryModeFor returns the result delivery mode of userID, following the user's
+// /myconfig choice or the configured default.
+func deliveryModeFor(userID int64, deps BotDeps) string {
+	userCfg, err := st.GetUserGenerationConfig(deps.DB, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		deps.Logger.Warn("Failed to get user config for delivery mode, using default", zap.Error(err), zap.Int64("user_id", userID))
+	}
+	if userCfg != nil && config.IsDeliveryMode(userCfg.DeliveryMode) {
+		return userCfg.DeliveryMode
+	}
+	return deps.Cfg().DefaultGenerationSettings.DeliveryMode
+}
+
+// showPromptInCaption reports whether result captions of userID start with the prompt.
+// On unless the user turned it off in /myconfig.
+func showPromptInCaption(userID int64, deps BotDeps) bool {
+	userCfg, err := st.GetUserGenerationConfig(deps.DB, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		deps.Logger.Warn("Failed to get user config for show-prompt, using default", zap.Error(err), zap.Int64("user_id", userID))
+	}
+	return userCfg == nil || userCfg.ShowPrompt == nil || *userCfg.ShowPrompt
+}
+
+// deliveryBatches splits count images into the index batches sent together in mode.
+// groups describes which images belong to which LoRA combination; if it doesn't cover
+// all images, per_lora falls back to a single batch.
+func deliveryBatches(mode string, count int, groups []resultGroup) [][]int {
+	indexes := func(start, end int) []int {
+		batch := make([]int, 0, end-start)
+		for i := start; i < end; i++ {
+			batch = append(batch, i)
+		}
+		return batch
+	}
+
+	switch mode {
+	case config.DeliveryModeSingle:
+		var batches [][]int
+		for i := 0; i < count; i++ {
+			batches = append(batches, []int{i})
+		}
+		return batches
+	case config.DeliveryModePerLora:
+		total := 0
+		for _, g := range groups {
+			total += g.Count
+		}
+		if total != count {
+			break
+		}
+		var batches [][]int
+		start := 0
+		for _, g := range groups {
+			if g.Count > 0 {
+				batches = append(batches, indexes(start, start+g.Count))
+			}
+			start += g.Count
+		}
+		return batches
+	}
+	return [][]int{indexes(0, count)}
+}
+
+// sendMediaBatches sends each batch with send, up to concurrency batches at a time, and
+// returns the sent message IDs and the first error in batch order. With a concurrency above
+// 1, batches are first split into albums of up to 10 so a large group is parallelized too;
+// Telegram may then show the albums in a different order.
+func sendMediaBatches(batches [][]int, concurrency int, send func([]int) ([]int, error)) ([]int, error) {
+	if concurrency > 1 {
+		var albums [][]int
+		for _, batch := range batches {
+			for start := 0; start < len(batch); start += 10 {
+				albums = append(albums, batch[start:min(start+10, len(batch))])
+			}
+		}
+		batches = albums
+	}
+
+	sentIDs := make([][]int, len(batches))
+	errs := make([]error, len(batches))
+	slots := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			sentIDs[i], errs[i] = send(batch)
+		}()
+	}
+	wg.Wait()
+
+	var allIDs []int
+	var firstErr error
+	for i := range batches {
+		allIDs = append(allIDs, sentIDs[i]...)
+		if errs[i] != nil && firstErr == nil {
+			firstErr = errs[i]
+		}
+	}
+	return allIDs, firstErr
+}
+
+// deliveryLabels fills in per-image captions for mode: in per_lora mode the first image of
+// each group carries the LoRA names, in single mode every image does. Existing labels
+// (e.g. /grid seeds) are kept.
+func deliveryLabels(mode string, count int, labels []string, groups []resultGroup) []string {
+	if mode == config.DeliveryModeGroup || len(groups) == 0 {
+		return labels
+	}
+	filled := make([]string, count)
+	copy(filled, labels)
+	start := 0
+	for _, g := range groups {
+		for i := start; i < start+g.Count && i < count; i++ {
+			if filled[i] != "" || (mode == config.DeliveryModePerLora && i != start) {
+				continue
+			}
+			filled[i] = g.Name
+		}
+		start += g.Count
+	}
+	return filled
+}
+
+// resultGroups describes the images of successfulResults, in the order they are collected
+// into the result set.
+func resultGroups(successfulResults []RequestResult) []resultGroup {
+	var groups []resultGroup
+	for _, res := range successfulResults {
+		if res.Response == nil {
+			continue
+		}
+		groups = append(groups, resultGroup{Name: strings.Join(res.LoraNames, "+"), Count: len(res.Response.Images)})
+	}
+	return groups
+}
@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	cfg "github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+)
+
+// liveConfig holds the parts of BotDeps that a config reload may replace.
+// BotDeps is copied into every handler goroutine, so it only keeps a pointer to this.
+type liveConfig struct {
+	mu        sync.RWMutex
+	config    *cfg.Config
+	loras     []LoraConfig
+	baseLoras []LoraConfig
+	prompts   *promptRules // Compiled from config
+}
+
+func newLiveConfig(config *cfg.Config, loras []LoraConfig, baseLoras []LoraConfig) *liveConfig {
+	return &liveConfig{config: config, loras: sortLoras(loras), baseLoras: sortLoras(baseLoras), prompts: compilePromptRules(config)}
+}
+
+// sortLoras returns a copy of loras in display order: pinned LoRAs first, each part
+// sorted by name. Every keyboard, listing and batch of requests follows this order.
+func sortLoras(loras []LoraConfig) []LoraConfig {
+	sorted := append([]LoraConfig(nil), loras...)
+	sort.SliceStable(sorted, func(i, j int) bool { return loraLess(sorted[i], sorted[j]) })
+	return sorted
+}
+
+// loraLess reports whether a is listed before b. Names are compared case-insensitively,
+// then exactly, so the order never depends on the input order.
+func loraLess(a, b LoraConfig) bool {
+	if a.Pinned != b.Pinned {
+		return a.Pinned
+	}
+	if la, lb := strings.ToLower(a.Name), strings.ToLower(b.Name); la != lb {
+		return la < lb
+	}
+	return a.Name < b.Name
+}
+
+// Cfg returns the current bot configuration. The returned value must be treated as read-only;
+// a reload swaps in a new *cfg.Config instead of modifying the old one.
+func (d BotDeps) Cfg() *cfg.Config {
+	d.live.mu.RLock()
+	defer d.live.mu.RUnlock()
+	return d.live.config
+}
+
+// StandardLoRAs returns the configured standard LoRAs. The slice must not be modified.
+func (d BotDeps) StandardLoRAs() []LoraConfig {
+	d.live.mu.RLock()
+	defer d.live.mu.RUnlock()
+	return d.live.loras
+}
+
+// promptRules returns the compiled prompt rules of the current configuration.
+func (d BotDeps) promptRules() *promptRules {
+	d.live.mu.RLock()
+	defer d.live.mu.RUnlock()
+	return d.live.prompts
+}
+
+// BaseLoRAs returns the configured base LoRAs. The slice must not be modified.
+func (d BotDeps) BaseLoRAs() []LoraConfig {
+	d.live.mu.RLock()
+	defer d.live.mu.RUnlock()
+	return d.live.baseLoras
+}
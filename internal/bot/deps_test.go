@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"sync"
+	"testing"
+
+	cfg "github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+)
+
+// TestLiveConfigConcurrentReads swaps the live config while handlers read it. Run with
+// -race: every access must go through the accessors and their lock.
+func TestLiveConfigConcurrentReads(t *testing.T) {
+	deps := BotDeps{live: newLiveConfig(
+		&cfg.Config{DefaultLanguage: "en"},
+		[]LoraConfig{{Name: "b"}, {Name: "a"}},
+		[]LoraConfig{{Name: "base"}},
+	)}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				if deps.Cfg().DefaultLanguage == "" {
+					t.Error("Cfg() returned a config without a language")
+					return
+				}
+				if loras := deps.StandardLoRAs(); len(loras) == 0 || loras[0].Name != "a" {
+					t.Errorf("StandardLoRAs() = %v, want sorted LoRAs", loras)
+					return
+				}
+				if len(deps.BaseLoRAs()) == 0 {
+					t.Error("BaseLoRAs() returned no LoRAs")
+					return
+				}
+			}
+		}()
+	}
+	for i := range 1000 {
+		lang := "en"
+		if i%2 == 0 {
+			lang = "zh"
+		}
+		swapped := newLiveConfig(
+			&cfg.Config{DefaultLanguage: lang},
+			[]LoraConfig{{Name: "c"}, {Name: "a"}},
+			[]LoraConfig{{Name: "base"}},
+		)
+		deps.live.mu.Lock()
+		deps.live.config, deps.live.loras, deps.live.baseLoras, deps.live.prompts = swapped.config, swapped.loras, swapped.baseLoras, swapped.prompts
+		deps.live.mu.Unlock()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,164 @@
+package bot
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// EditThrottler rate-limits edits of the same message, so rapid status updates don't run
+// into Telegram's edit limits. Progress edits are coalesced (only the newest text is sent);
+// final edits are sent in order and retried once if Telegram answers 429.
+type EditThrottler struct {
+	bot      *tgbotapi.BotAPI
+	interval time.Duration // Minimum time between two edits of one message
+	logger   *zap.Logger
+
+	mu       sync.Mutex
+	messages map[editKey]*throttledMessage
+}
+
+type editKey struct {
+	chatID    int64
+	messageID int
+}
+
+type throttledMessage struct {
+	sendMu     sync.Mutex // Serializes sends of this message; other fields are guarded by EditThrottler.mu
+	pending    *tgbotapi.EditMessageTextConfig
+	pendingSeq uint64
+	seq        uint64 // Sequence number of the newest edit
+	sentSeq    uint64 // Sequence number of the newest edit sent
+	finalSeq   uint64 // Edits older than this were superseded by EditNow or Forget
+	lastSent   time.Time
+	timer      *time.Timer
+}
+
+// NewEditThrottler creates a throttler that edits a message at most once per interval.
+func NewEditThrottler(bot *tgbotapi.BotAPI, interval time.Duration, logger *zap.Logger) *EditThrottler {
+	return &EditThrottler{
+		bot:      bot,
+		interval: interval,
+		logger:   logger,
+		messages: make(map[editKey]*throttledMessage),
+	}
+}
+
+// Edit queues a progress edit. It is sent right away if the message wasn't edited within
+// the interval, otherwise once the interval has passed, replacing any edit still waiting.
+func (t *EditThrottler) Edit(edit tgbotapi.EditMessageTextConfig) {
+	key := editKey{chatID: edit.ChatID, messageID: edit.MessageID}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m := t.message(key)
+	m.seq++
+	m.pending, m.pendingSeq = &edit, m.seq
+	if m.timer == nil {
+		wait := time.Until(m.lastSent.Add(t.interval))
+		if wait < 0 {
+			wait = 0
+		}
+		m.timer = time.AfterFunc(wait, func() { t.flush(key) })
+	}
+}
+
+// EditNow sends a final edit, waiting out the interval if needed. Queued progress edits of
+// the message are dropped, so they can't overwrite it afterwards.
+func (t *EditThrottler) EditNow(edit tgbotapi.EditMessageTextConfig) error {
+	key := editKey{chatID: edit.ChatID, messageID: edit.MessageID}
+
+	t.mu.Lock()
+	m := t.message(key)
+	m.seq++
+	seq := m.seq
+	m.pending, m.finalSeq = nil, seq
+	t.mu.Unlock()
+
+	return t.send(key, m, edit, seq)
+}
+
+// Forget drops queued edits of a message, e.g. before deleting it.
+func (t *EditThrottler) Forget(chatID int64, messageID int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if m, ok := t.messages[editKey{chatID: chatID, messageID: messageID}]; ok {
+		m.seq++
+		m.pending, m.finalSeq = nil, m.seq
+	}
+}
+
+// message returns the state of key, creating it if needed. t.mu must be held.
+func (t *EditThrottler) message(key editKey) *throttledMessage {
+	m, ok := t.messages[key]
+	if !ok {
+		m = &throttledMessage{}
+		t.messages[key] = m
+	}
+	return m
+}
+
+// flush sends the waiting edit of key, or forgets the message once it's idle.
+func (t *EditThrottler) flush(key editKey) {
+	t.mu.Lock()
+	m, ok := t.messages[key]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	m.timer = nil
+	edit, seq := m.pending, m.pendingSeq
+	m.pending = nil
+	if edit == nil {
+		if time.Since(m.lastSent) >= t.interval {
+			delete(t.messages, key)
+		}
+		t.mu.Unlock()
+		return
+	}
+	t.mu.Unlock()
+
+	t.send(key, m, *edit, seq)
+}
+
+// send edits the message unless a newer edit was already sent, then schedules the next flush.
+func (t *EditThrottler) send(key editKey, m *throttledMessage, edit tgbotapi.EditMessageTextConfig, seq uint64) error {
+	m.sendMu.Lock()
+	defer m.sendMu.Unlock()
+
+	t.mu.Lock()
+	if seq <= m.sentSeq || seq < m.finalSeq {
+		t.mu.Unlock()
+		return nil // Superseded
+	}
+	wait := time.Until(m.lastSent.Add(t.interval))
+	t.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+
+	_, err := t.bot.Send(edit)
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) && tgErr.RetryAfter > 0 {
+		t.logger.Warn("Message edit rate limited, retrying", zap.Int64("chat_id", key.chatID), zap.Int("message_id", key.messageID), zap.Int("retry_after", tgErr.RetryAfter))
+		time.Sleep(time.Duration(tgErr.RetryAfter) * time.Second)
+		_, err = t.bot.Send(edit)
+	}
+	if isMessageNotModified(err) {
+		err = nil // Same text as before; nothing to do
+	}
+	if err != nil {
+		t.logger.Warn("Failed to edit message", zap.Error(err), zap.Int64("chat_id", key.chatID), zap.Int("message_id", key.messageID))
+	}
+
+	t.mu.Lock()
+	m.sentSeq, m.lastSent = seq, time.Now()
+	if m.timer == nil {
+		m.timer = time.AfterFunc(t.interval, func() { t.flush(key) })
+	}
+	t.mu.Unlock()
+	return err
+}
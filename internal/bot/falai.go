@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -25,6 +27,21 @@ type GenerationParameters struct {
 	NumInferenceSteps int
 	GuidanceScale     float64
 	NumImages         int
+	Seed              *uint64 // nil lets fal pick a random seed
+	SafetyChecker     bool    // Sent as enable_safety_checker; defaultGenerationSettings.enableSafetyChecker
+	Model             string  // Generation endpoint, apiEndpoints.fluxLora unless overridden
+}
+
+// GenerationOverrides holds one-off parameter overrides for a single generation
+// (e.g. /resize). Zero values keep the user's config. They are never persisted.
+type GenerationOverrides struct {
+	ImageSize         string
+	NumInferenceSteps int
+	GuidanceScale     *float64
+	NumImages         int
+	Seed              *uint64
+	Model             string // One of apiEndpoints.GenerationModels()
+	SafetyChecker     *bool  // Admins only, ignored for other users
 }
 
 // prepareGenerationParameters fetches user config and merges with defaults and state.
@@ -35,13 +52,15 @@ func prepareGenerationParameters(userID int64, userState *UserState, deps BotDep
 		// Continue with defaults, but log the error
 	}
 
-	defaultCfg := deps.Config.DefaultGenerationSettings
+	defaultCfg := deps.Cfg().DefaultGenerationSettings
 	params := &GenerationParameters{
 		Prompt:            userState.OriginalCaption,
 		ImageSize:         defaultCfg.ImageSize,
 		NumInferenceSteps: defaultCfg.NumInferenceSteps,
 		GuidanceScale:     defaultCfg.GuidanceScale,
 		NumImages:         defaultCfg.NumImages,
+		SafetyChecker:     defaultCfg.EnableSafetyChecker,
+		Model:             deps.Cfg().APIEndpoints.FluxLora,
 	}
 
 	if userCfg != nil {
@@ -49,16 +68,83 @@ func prepareGenerationParameters(userID int64, userState *UserState, deps BotDep
 		params.NumInferenceSteps = userCfg.NumInferenceSteps
 		params.GuidanceScale = userCfg.GuidanceScale
 		params.NumImages = userCfg.NumImages
+		params.Seed = userCfg.LockedSeed // nil unless the user locked a seed
+	}
+	if size, loraName := loraPreferredImageSize(userState, deps); size != "" {
+		deps.Logger.Debug("Using the preferred image size of the selected LoRA", zap.Int64("user_id", userID), zap.String("lora", loraName), zap.String("image_size", size))
+		params.ImageSize = size
+	}
+
+	if o := userState.Overrides; o != nil {
+		if o.ImageSize != "" {
+			params.ImageSize = o.ImageSize
+		}
+		if o.NumInferenceSteps > 0 {
+			params.NumInferenceSteps = o.NumInferenceSteps
+		}
+		if o.GuidanceScale != nil {
+			params.GuidanceScale = *o.GuidanceScale
+		}
+		if o.NumImages > 0 {
+			params.NumImages = o.NumImages
+		}
+		if o.Seed != nil {
+			params.Seed = o.Seed
+		}
+		if o.SafetyChecker != nil && deps.Authorizer.IsAdmin(userID) {
+			params.SafetyChecker = *o.SafetyChecker
+			deps.Logger.Info("Admin overrode the safety checker for this generation", zap.Int64("user_id", userID), zap.Bool("safety_checker", params.SafetyChecker))
+		}
+		if o.Model != "" {
+			// The allowlist may have changed since the model was picked
+			if slices.Contains(deps.Cfg().APIEndpoints.GenerationModels(), o.Model) {
+				params.Model = o.Model
+			} else {
+				deps.Logger.Warn("Ignoring model override not in apiEndpoints.models", zap.String("model", o.Model), zap.Int64("user_id", userID))
+			}
+		}
+	}
+
+	if userState.Sweep {
+		applySweepParameters(params)
+	}
+
+	// Reject sizes the model can't produce before they cost a failed request
+	if err := checkImageSizeLimit(params.ImageSize, nil, deps.Cfg().APIEndpoints.MaxImagePixels); err != nil {
+		deps.Logger.Warn("Rejected generation exceeding model image size limit", zap.Error(err), zap.Int64("user_id", userID))
+		return nil, err
 	}
 
 	return params, nil
 }
 
+// loraPreferredImageSize returns the preferredImageSize of the LoRA and its name if state
+// selects a single standard LoRA that has one and no one-off size was picked. Sizes over the
+// model's pixel limit are ignored, so the user's own size applies.
+func loraPreferredImageSize(state *UserState, deps BotDeps) (string, string) {
+	if len(state.SelectedLoras) != 1 || (state.Overrides != nil && state.Overrides.ImageSize != "") {
+		return "", ""
+	}
+	lora, found := findLoraByName(state.SelectedLoras[0], deps.StandardLoRAs())
+	if !found || lora.PreferredImageSize == "" {
+		return "", ""
+	}
+	if checkImageSizeLimit(lora.PreferredImageSize, nil, deps.Cfg().APIEndpoints.MaxImagePixels) != nil {
+		return "", ""
+	}
+	return lora.PreferredImageSize, lora.Name
+}
+
 // RequestInfo holds details for a single LoRA combination request.
 type RequestInfo struct {
 	StandardLora LoraConfig
 	BaseLoras    []LoraConfig
 	Params       *GenerationParameters
+	ChatID       int64     // Chat that receives verbose poll reports and low balance warnings
+	Verbose      bool      // Report every poll's status to ChatID
+	Order        int       // Position among the generation's requests; results are sorted by it
+	FlowStart    time.Time // Start of the photo flow, counting the caption time; zero without a budget
+	Deadline     time.Time // End of the photo flow's time budget; zero without a budget
 }
 
 // validateAndPrepareRequests checks LoRAs, balance, and prepares individual requests.
@@ -77,7 +163,7 @@ func validateAndPrepareRequests(userID int64, userState *UserState, params *Gene
 	// Find the selected Base LoRAs (if any)
 	selectedBaseLoras := []LoraConfig{}
 	for _, name := range userState.SelectedBaseLoras {
-		detail, found := findLoraByName(name, deps.BaseLoRA)
+		detail, found := findLoraByName(name, deps.BaseLoRAs())
 		if !found {
 			deps.Logger.Error("Selected Base LoRA name not found in config, proceeding without it", zap.String("name", name), zap.Int64("userID", userID))
 			continue
@@ -86,33 +172,65 @@ func validateAndPrepareRequests(userID int64, userState *UserState, params *Gene
 		selectedBaseLoras = append(selectedBaseLoras, detail)
 	}
 
+	if userState.GridSize > 0 && len(userState.SelectedLoras) != 1 {
+		initialErrors = append(initialErrors, deps.I18n.T(userLang, "grid_error_one_lora"))
+		return nil, initialErrors, 0
+	}
+
 	numRequests := 0
-	standardLoraDetailsMap := make(map[string]LoraConfig)
+	var standardLoras []LoraConfig
+	seen := make(map[string]struct{})
 
 	// Validate standard LoRAs
 	for _, name := range userState.SelectedLoras {
-		detail, found := findLoraByName(name, deps.LoRA)
-		if found {
-			standardLoraDetailsMap[name] = detail
-			numRequests++
+		detail, found := findLoraByName(name, deps.StandardLoRAs())
+		if found && !deps.Authorizer.IsAdmin(userID) && deps.LoraHealth.IsDisabled(name) {
+			deps.Logger.Info("Selected LoRA is disabled for repeated failures", zap.String("name", name), zap.Int64("userID", userID))
+			initialErrors = append(initialErrors, deps.I18n.T(userLang, "generate_error_lora_disabled", "name", name))
+		} else if found {
+			if _, dup := seen[name]; !dup {
+				seen[name] = struct{}{}
+				standardLoras = append(standardLoras, detail)
+				numRequests++
+			}
+			if userState.GridSize > 0 {
+				numRequests = userState.GridSize // One request per seed
+			}
 		} else {
 			deps.Logger.Error("Selected standard LoRA name not found in config during preparation", zap.String("name", name), zap.Int64("userID", userID))
 			initialErrors = append(initialErrors, deps.I18n.T(userLang, "generate_error_find_lora", "name", name))
 		}
 	}
 
+	// The user's part of the prompt, as it will be sent, must not contain blocked content,
+	// whether typed or captioned
+	userPrompt := sanitizeUserPrompt(params.Prompt, deps.promptRules())
+	if rule, blocked := blockedPromptRule(userPrompt, deps.promptRules()); blocked {
+		deps.Logger.Warn("Blocked generation: prompt matches the blocklist", zap.Int64("user_id", userID), zap.String("rule", rule), zap.String("prompt", userPrompt))
+		initialErrors = append(initialErrors, deps.I18n.T(userLang, "generate_error_prompt_blocked"))
+		return nil, initialErrors, 0
+	}
+
 	// Balance Check (adjusted for valid requests)
-	if deps.BalanceManager != nil && numRequests > 0 {
+	if !chargesUser(userID, deps) && deps.BalanceManager != nil {
+		deps.Logger.Info("Admin generation is free, skipping balance check", zap.Int64("user_id", userID), zap.Int("num_requests", numRequests))
+	} else if deps.BalanceManager != nil && numRequests > 0 {
 		totalCost := deps.BalanceManager.GetCost() * float64(numRequests)
-		currentBal := deps.BalanceManager.GetBalance(userID)
-		if currentBal < totalCost {
-			formattedCost := fmt.Sprintf("%.2f", totalCost)
-			formattedCurrent := fmt.Sprintf("%.2f", currentBal)
+		currentBal, err := deps.BalanceManager.GetBalanceWithError(userID)
+		if err != nil {
+			// Refuse rather than guess; a failing DB must not grant the initial balance
+			deps.Logger.Error("Failed to check balance before generation", zap.Error(err), zap.Int64("user_id", userID))
+			initialErrors = append(initialErrors, deps.I18n.T(userLang, "generate_error_balance_unavailable"))
+			return nil, initialErrors, 0
+		}
+		if deps.BalanceManager.Spendable(currentBal) < totalCost {
+			formattedCost := formatBalance(totalCost, userLang, deps)
+			formattedCurrent := formatBalance(currentBal, userLang, deps)
 			errMsg := deps.I18n.T(userLang, "generate_error_insufficient_balance_multi",
 				"cost", formattedCost,
 				"count", numRequests,
 				"current", formattedCurrent,
-			)
+			) + balanceReserveNote(userLang, deps)
 			deps.Logger.Warn("Insufficient balance for multiple requests", zap.Int64("user_id", userID), zap.Int("num_requests", numRequests), zap.Float64("total_cost", totalCost), zap.Float64("current_balance", currentBal))
 			initialErrors = append(initialErrors, errMsg)
 			return nil, initialErrors, 0 // Return immediately if balance insufficient
@@ -121,13 +239,25 @@ func validateAndPrepareRequests(userID int64, userState *UserState, params *Gene
 		}
 	}
 
-	// Build the list of valid RequestInfo
-	for _, standardLora := range standardLoraDetailsMap {
-		validRequests = append(validRequests, RequestInfo{
-			StandardLora: standardLora,
-			BaseLoras:    selectedBaseLoras,
-			Params:       params,
-		})
+	// Build the list of valid RequestInfo, in display order so requests are always
+	// submitted and delivered in the same sequence
+	if userState.GridSize > 0 {
+		for _, standardLora := range standardLoras { // Exactly one LoRA in grid mode
+			validRequests = append(validRequests, prepareGridRequests(standardLora, selectedBaseLoras, userState.GridSize, userState, params)...)
+		}
+	} else {
+		for _, standardLora := range sortLoras(standardLoras) {
+			validRequests = append(validRequests, RequestInfo{
+				StandardLora: standardLora,
+				BaseLoras:    selectedBaseLoras,
+				Params:       params,
+				ChatID:       userState.ChatID,
+				Verbose:      userState.Verbose,
+			})
+		}
+	}
+	for i := range validRequests {
+		validRequests[i].Order = i
 	}
 
 	return validRequests, initialErrors, numRequests
@@ -139,43 +269,151 @@ type RequestResult struct {
 	Error     error
 	ReqID     string
 	LoraNames []string // LoRAs used for this specific request (Standard + Base if used)
+	Order     int      // RequestInfo.Order of the request
+	falErr    error    // Raw submit or poll error behind Error, for LoRA health tracking
+	// Payload is the request body sent to fal, stored with the history entry
+	Payload map[string]interface{}
 }
 
-func buildPrompt(basePrompt string, loras ...LoraConfig) string {
-	prompt := strings.TrimSpace(basePrompt)
-	if len(loras) == 0 {
-		return prompt
-	}
+// apiLoras returns the LoRAs to submit for standard and its base LoRAs: at most maxLoras,
+// the standard LoRA first, skipping base LoRAs whose URL is already used.
+func apiLoras(standard LoraConfig, baseLoras []LoraConfig, maxLoras int, logger *zap.Logger) []falapi.LoraWeight {
+	lorasForAPI := []falapi.LoraWeight{{Path: standard.URL, Scale: standard.Weight}}
+	addedURLs := map[string]struct{}{standard.URL: {}}
 
-	parts := make([]string, 0, len(loras))
-	for _, lora := range loras {
-		appendPrompt := strings.TrimSpace(lora.AppendPrompt)
-		if appendPrompt != "" {
-			parts = append(parts, appendPrompt)
+	for _, baseLora := range baseLoras {
+		if len(lorasForAPI) >= maxLoras {
+			logger.Debug("Skipping adding Base LoRA to API as request already has max LoRAs",
+				zap.String("base_lora", baseLora.Name),
+				zap.String("standard_lora", standard.Name),
+				zap.Int("max_loras", maxLoras),
+			)
+			continue
+		}
+		if _, exists := addedURLs[baseLora.URL]; !exists {
+			lorasForAPI = append(lorasForAPI, falapi.LoraWeight{Path: baseLora.URL, Scale: baseLora.Weight})
+			addedURLs[baseLora.URL] = struct{}{}
+			logger.Debug("Adding selected Base LoRA to API request", zap.String("base_lora", baseLora.Name), zap.String("standard_lora", standard.Name))
+		} else {
+			logger.Debug("Skipping adding Base LoRA to API as its URL is same as another LoRA", zap.String("base_lora", baseLora.Name), zap.String("standard_lora", standard.Name))
 		}
 	}
-	if len(parts) == 0 {
-		return prompt
+	return lorasForAPI
+}
+
+// queuePositionInterval is how often a queued generation refreshes its queue position.
+const queuePositionInterval = 3 * time.Second
+
+// queueGenerationRequests takes a generation slot ticket for each of count requests, in
+// order. If the queue can't take all of them it gives up those it got and reports false.
+func queueGenerationRequests(count int, deps BotDeps) ([]*GenerationTicket, bool) {
+	tickets := make([]*GenerationTicket, 0, count)
+	for range count {
+		ticket, ok := deps.Limiter.Enqueue()
+		if !ok {
+			for _, t := range tickets {
+				t.Cancel()
+			}
+			return nil, false
+		}
+		tickets = append(tickets, ticket)
 	}
+	return tickets, true
+}
 
-	prefix := strings.Join(parts, " ")
-	if prompt == "" {
-		return prefix
+// showQueuePosition keeps the status message showing the queue position of first, the
+// earliest ticket of a generation, until it gets a slot or is stopped. It then shows
+// startedText.
+func showQueuePosition(chatID int64, messageID int, first *GenerationTicket, startedText string, userLang *string, deps BotDeps) {
+	ticker := time.NewTicker(queuePositionInterval)
+	defer ticker.Stop()
+	shown := 0
+	for {
+		position := first.Position()
+		if position == 0 {
+			break
+		}
+		if position != shown {
+			shown = position
+			deps.Edits.Edit(tgbotapi.NewEditMessageText(chatID, messageID, deps.I18n.T(userLang, "generate_queued", "position", position)))
+		}
+		select {
+		case <-first.ready:
+		case <-ticker.C:
+		}
 	}
-	return prefix + " " + prompt
+	deps.Edits.Edit(tgbotapi.NewEditMessageText(chatID, messageID, startedText))
 }
 
-// executeAndPollRequest handles a single generation request lifecycle.
-func executeAndPollRequest(reqInfo RequestInfo, userID int64, deps BotDeps, resultsChan chan<- RequestResult, wg *sync.WaitGroup) {
+// executeAndPollRequest handles a single generation request lifecycle. The request is
+// tracked from queueing on, so /stop can cancel it at any point; a stopped request is
+// refunded and reported as stopped, even if its result arrived meanwhile.
+func executeAndPollRequest(reqInfo RequestInfo, ticket *GenerationTicket, userID int64, deps BotDeps, resultsChan chan<- RequestResult, wg *sync.WaitGroup) {
 	defer wg.Done()
 	userLang := getUserLanguagePreference(userID, deps)
-	requestResult := RequestResult{LoraNames: []string{reqInfo.StandardLora.Name}}
+	loraNames := []string{reqInfo.StandardLora.Name}
 	for _, baseLora := range reqInfo.BaseLoras {
-		requestResult.LoraNames = append(requestResult.LoraNames, baseLora.Name)
+		loraNames = append(loraNames, baseLora.Name)
+	}
+	stopCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	tracked := deps.Inflight.Track(userID, loraNames, stop)
+	ctx := stopCtx
+	if !reqInfo.Deadline.IsZero() {
+		var cancelBudget context.CancelFunc
+		ctx, cancelBudget = context.WithDeadline(stopCtx, reqInfo.Deadline)
+		defer cancelBudget()
+	}
+
+	var requestResult RequestResult
+	deducted := false
+	if ticket.Wait(ctx) == nil {
+		requestResult, deducted = submitAndPollRequest(ctx, tracked, reqInfo, userID, loraNames, deps)
+		deps.Limiter.Release()
+	} else {
+		requestResult = RequestResult{LoraNames: loraNames, Order: reqInfo.Order}
+	}
+	overBudget := requestResult.Response == nil && errors.Is(ctx.Err(), context.DeadlineExceeded)
+
+	if !deps.Inflight.Finish(tracked) || overBudget {
+		deps.Logger.Info("Generation request stopped", zap.Int64("user_id", userID), zap.String("request_id", requestResult.ReqID), zap.Strings("loras", loraNames), zap.Bool("refund", deducted), zap.Bool("over_budget", overBudget))
+		if requestResult.ReqID != "" {
+			if err := deps.FalClient.CancelRequest(requestResult.ReqID, reqInfo.Params.Model); err != nil {
+				deps.Logger.Debug("fal did not cancel stopped request", zap.Error(err), zap.String("request_id", requestResult.ReqID))
+			}
+		}
+		if deducted {
+			if err := deps.BalanceManager.AddBalance(userID, deps.BalanceManager.GetCost()); err != nil {
+				deps.Logger.Error("Failed to refund stopped request", zap.Error(err), zap.Int64("user_id", userID), zap.String("request_id", requestResult.ReqID))
+			}
+		}
+		requestResult.Response = nil
+		requestResult.Error = errors.New(deps.I18n.T(userLang, "generate_stopped", "loras", strings.Join(loraNames, "+")))
+		if overBudget {
+			requestResult.Error = errors.New(deps.I18n.T(userLang, "generate_photo_budget_exceeded",
+				"loras", strings.Join(loraNames, "+"),
+				"elapsed", formatElapsed(time.Since(reqInfo.FlowStart)),
+				"budget", formatElapsed(reqInfo.Deadline.Sub(reqInfo.FlowStart)),
+			))
+		}
+	} else {
+		recordLoraOutcome(reqInfo, requestResult, deps)
+	}
+	resultsChan <- requestResult
+}
+
+// submitAndPollRequest charges, submits and polls one request, and reports whether the
+// user was charged. ctx is canceled when the user stops the request.
+func submitAndPollRequest(ctx context.Context, tracked *inflightRequest, reqInfo RequestInfo, userID int64, loraNames []string, deps BotDeps) (RequestResult, bool) {
+	userLang := getUserLanguagePreference(userID, deps)
+	requestResult := RequestResult{LoraNames: loraNames, Order: reqInfo.Order}
+	if ctx.Err() != nil {
+		return requestResult, false // Stopped while waiting for a slot
 	}
 
 	// --- Individual Balance Deduction --- //
-	if deps.BalanceManager != nil {
+	deducted := false
+	if chargesUser(userID, deps) {
 		canProceed, deductErr := deps.BalanceManager.CheckAndDeduct(userID)
 		if !canProceed {
 			var errMsg string
@@ -186,42 +424,24 @@ func executeAndPollRequest(reqInfo RequestInfo, userID int64, deps BotDeps, resu
 			}
 			deps.Logger.Warn("Individual balance deduction failed", zap.Int64("user_id", userID), zap.String("lora", reqInfo.StandardLora.Name), zap.Error(deductErr))
 			requestResult.Error = fmt.Errorf(errMsg)
-			resultsChan <- requestResult
-			return
+			return requestResult, false
 		}
+		deducted = true
 		deps.Logger.Info("Balance deducted for LoRA request", zap.Int64("user_id", userID), zap.String("lora", reqInfo.StandardLora.Name))
+		warnLowBalance(reqInfo.ChatID, userID, deps)
 	}
 
-	maxLoras := deps.Config.APIEndpoints.MaxLoras
+	maxLoras := deps.Cfg().APIEndpoints.MaxLoras
 	if maxLoras <= 0 {
 		maxLoras = 2
 	}
 
 	// --- Prepare LoRAs for API (Max from config) --- //
-	lorasForAPI := []falapi.LoraWeight{{Path: reqInfo.StandardLora.URL, Scale: reqInfo.StandardLora.Weight}}
-	addedURLs := map[string]struct{}{reqInfo.StandardLora.URL: {}}
-
-	for _, baseLora := range reqInfo.BaseLoras {
-		if len(lorasForAPI) >= maxLoras {
-			deps.Logger.Debug("Skipping adding Base LoRA to API as request already has max LoRAs",
-				zap.String("base_lora", baseLora.Name),
-				zap.String("standard_lora", reqInfo.StandardLora.Name),
-				zap.Int("max_loras", maxLoras),
-			)
-			continue
-		}
-		if _, exists := addedURLs[baseLora.URL]; !exists {
-			lorasForAPI = append(lorasForAPI, falapi.LoraWeight{Path: baseLora.URL, Scale: baseLora.Weight})
-			addedURLs[baseLora.URL] = struct{}{}
-			deps.Logger.Debug("Adding selected Base LoRA to API request", zap.String("base_lora", baseLora.Name), zap.String("standard_lora", reqInfo.StandardLora.Name))
-		} else {
-			deps.Logger.Debug("Skipping adding Base LoRA to API as its URL is same as another LoRA", zap.String("base_lora", baseLora.Name), zap.String("standard_lora", reqInfo.StandardLora.Name))
-		}
-	}
+	lorasForAPI := apiLoras(reqInfo.StandardLora, reqInfo.BaseLoras, maxLoras, deps.Logger)
 
 	promptLoras := append([]LoraConfig{}, reqInfo.BaseLoras...)
 	promptLoras = append(promptLoras, reqInfo.StandardLora)
-	prompt := buildPrompt(reqInfo.Params.Prompt, promptLoras...)
+	prompt := buildPrompt(reqInfo.Params.Prompt, deps.promptRules(), userPromptTags(userID, deps.Cfg()), promptLoras...)
 
 	// --- Submit Single Request --- //
 	deps.Logger.Debug("Submitting request for LoRA combo",
@@ -229,7 +449,8 @@ func executeAndPollRequest(reqInfo RequestInfo, userID int64, deps BotDeps, resu
 		zap.Int("api_lora_count", len(lorasForAPI)),
 		zap.Float64("guidance_scale", reqInfo.Params.GuidanceScale),
 	)
-	requestID, err := deps.FalClient.SubmitGenerationRequest(
+	requestID, err := deps.FalClient.SubmitGenerationRequestTo(
+		reqInfo.Params.Model,
 		prompt,
 		lorasForAPI,
 		requestResult.LoraNames,
@@ -237,42 +458,100 @@ func executeAndPollRequest(reqInfo RequestInfo, userID int64, deps BotDeps, resu
 		reqInfo.Params.NumInferenceSteps,
 		reqInfo.Params.GuidanceScale,
 		reqInfo.Params.NumImages,
+		reqInfo.Params.Seed,
+		reqInfo.Params.SafetyChecker,
 	)
+	if falapi.IsInsufficientCredits(err) {
+		var errMsg string
+		errMsg, deducted = handleInsufficientCredits(err, userID, requestResult.LoraNames, "", deducted, userLang, deps)
+		requestResult.Error = errors.New(errMsg)
+		return requestResult, deducted
+	}
 	if err != nil {
-		errMsg := deps.I18n.T(userLang, "generate_submit_fail", "loras", strings.Join(requestResult.LoraNames, "+"), "error", err.Error())
-		deps.Logger.Error("SubmitGenerationRequest failed", zap.Error(err), zap.Int64("user_id", userID), zap.Strings("loras", requestResult.LoraNames))
+		errMsg := deps.I18n.T(userLang, "generate_submit_fail_generic", "loras", strings.Join(requestResult.LoraNames, "+"))
+		if verboseErrorsEnabled(userID, deps) {
+			errMsg = deps.I18n.T(userLang, "generate_submit_fail", "loras", strings.Join(requestResult.LoraNames, "+"), "error", err.Error())
+		}
+		failedID := recordFailedRequest(userID, "", reqInfo.Params, prompt, requestResult.LoraNames, lorasForAPI, err, deps)
+		deps.Logger.Error("SubmitGenerationRequest failed", zap.Error(err), zap.Int64("user_id", userID), zap.Strings("loras", requestResult.LoraNames), zap.Int64("failed_request_id", failedID))
 		requestResult.Error = fmt.Errorf(errMsg)
-		if deps.BalanceManager != nil {
-			deps.Logger.Warn("Submission failed after deduction, no refund method.", zap.Int64("user_id", userID), zap.Strings("loras", requestResult.LoraNames), zap.Float64("amount", deps.BalanceManager.GetCost()))
+		requestResult.falErr = err
+		if deducted {
+			if refundErr := deps.BalanceManager.AddBalance(userID, deps.BalanceManager.GetCost()); refundErr != nil {
+				deps.Logger.Error("Failed to refund request whose submission failed", zap.Error(refundErr), zap.Int64("user_id", userID), zap.Strings("loras", requestResult.LoraNames))
+			} else {
+				deducted = false
+				deps.Logger.Info("Refunded request whose submission failed", zap.Int64("user_id", userID), zap.Strings("loras", requestResult.LoraNames), zap.Float64("amount", deps.BalanceManager.GetCost()))
+			}
 		}
-		resultsChan <- requestResult
-		return
+		return requestResult, deducted
 	}
 	requestResult.ReqID = requestID
-	deps.Logger.Info("Submitted individual task", zap.Int64("user_id", userID), zap.String("request_id", requestID), zap.Strings("loras", requestResult.LoraNames))
+	requestResult.Payload = deps.FalClient.GenerationPayload(
+		prompt,
+		lorasForAPI,
+		reqInfo.Params.ImageSize,
+		reqInfo.Params.NumInferenceSteps,
+		reqInfo.Params.GuidanceScale,
+		reqInfo.Params.NumImages,
+		reqInfo.Params.Seed,
+		reqInfo.Params.SafetyChecker,
+	)
+	submittedAt := time.Now()
+	deps.Inflight.Submitted(tracked, requestID, reqInfo.Params.Model)
+	deps.Logger.Info("Submitted individual task", zap.Int64("user_id", userID), zap.String("request_id", requestID), zap.Strings("loras", requestResult.LoraNames), zap.Bool("safety_checker", reqInfo.Params.SafetyChecker), zap.String("model", reqInfo.Params.Model))
 
 	// --- Poll For Result --- //
 	pollInterval := 5 * time.Second
 	generationTimeout := 5 * time.Minute
-	ctx, cancel := context.WithTimeout(context.Background(), generationTimeout)
+	ctx, cancel := context.WithTimeout(ctx, generationTimeout)
 	defer cancel()
 
-	result, err := deps.FalClient.PollForResult(ctx, requestID, deps.Config.APIEndpoints.FluxLora, pollInterval)
+	var reporter *verboseReporter
+	var onStatus falapi.StatusCallback
+	if reqInfo.Verbose && reqInfo.ChatID != 0 {
+		reporter = newVerboseReporter(reqInfo.ChatID, requestID, requestResult.LoraNames, reqInfo.Params.SafetyChecker, userLang, deps)
+		onStatus = reporter.OnStatus
+	}
+
+	var result *falapi.GenerateResponse
+	if deps.Webhooks != nil {
+		// fal POSTs the result (or failure) to our webhook receiver instead of being polled
+		deps.Webhooks.Register(requestID)
+		result, err = waitForWebhookResult(ctx, requestID, reqInfo.Params.Model, pollInterval, onStatus, deps)
+	} else {
+		result, err = deps.FalClient.PollForResultWithStatus(ctx, requestID, reqInfo.Params.Model, pollInterval, onStatus)
+	}
+	if reporter != nil {
+		reporter.Finish(result, err)
+	}
+	if falapi.IsInsufficientCredits(err) {
+		var errMsg string
+		errMsg, deducted = handleInsufficientCredits(err, userID, requestResult.LoraNames, requestID, deducted, userLang, deps)
+		requestResult.Error = errors.New(errMsg)
+		return requestResult, deducted
+	}
 	if err != nil {
-		errMsg := formatPollError(err, requestResult.LoraNames, requestID, userLang, deps.I18n)
-		deps.Logger.Error("PollForResult failed", zap.Error(err), zap.Int64("user_id", userID), zap.String("request_id", requestID), zap.Strings("loras", requestResult.LoraNames))
+		errMsg := formatPollError(err, requestResult.LoraNames, requestID, verboseErrorsEnabled(userID, deps), userLang, deps.I18n)
+		var failedID int64
+		if !errors.Is(err, context.Canceled) { // Stopped by the user, nothing to reproduce
+			failedID = recordFailedRequest(userID, requestID, reqInfo.Params, prompt, requestResult.LoraNames, lorasForAPI, err, deps)
+		}
+		deps.Logger.Error("PollForResult failed", zap.Error(err), zap.Int64("user_id", userID), zap.String("request_id", requestID), zap.Strings("loras", requestResult.LoraNames), zap.Int64("failed_request_id", failedID))
 		requestResult.Error = fmt.Errorf(errMsg)
-		resultsChan <- requestResult
-		return
+		requestResult.falErr = err
+		return requestResult, deducted
 	}
 
 	deps.Logger.Info("Successfully polled result", zap.String("request_id", requestID), zap.Strings("loras", requestResult.LoraNames))
+	deps.WaitEstimates.Record(reqInfo.Params.Model, time.Since(submittedAt))
 	requestResult.Response = result
-	resultsChan <- requestResult
+	return requestResult, deducted
 }
 
-// formatPollError translates polling errors into user-friendly messages using i18n.
-func formatPollError(err error, loraNames []string, requestID string, userLang *string, i18nManager *i18n.Manager) string {
+// formatPollError translates polling errors into user-friendly messages using i18n. Raw
+// error text and 422 details are only included if verbose is set.
+func formatPollError(err error, loraNames []string, requestID string, verbose bool, userLang *string, i18nManager *i18n.Manager) string {
 	rawErrMsg := err.Error()
 	loraNamesStr := strings.Join(loraNames, "+")
 	truncatedID := truncateID(requestID)
@@ -291,13 +570,15 @@ func formatPollError(err error, loraNames []string, requestID string, userLang *
 				detailMsg = detail.Detail[0].Msg
 			}
 		}
-		if detailMsg != "" {
+		if detailMsg != "" && verbose {
 			return i18nManager.T(userLang, "generate_poll_error_422_detail", "loras", loraNamesStr, "detail", detailMsg)
 		} else {
 			return i18nManager.T(userLang, "generate_poll_error_422", "loras", loraNamesStr)
 		}
-	} else {
+	} else if verbose {
 		return i18nManager.T(userLang, "generate_poll_fail", "loras", loraNamesStr, "reqID", truncatedID, "error", rawErrMsg)
+	} else {
+		return i18nManager.T(userLang, "generate_poll_fail_generic", "loras", loraNamesStr, "reqID", truncatedID)
 	}
 }
 
@@ -305,6 +586,7 @@ func formatPollError(err error, loraNames []string, requestID string, userLang *
 func collectAndProcessResults(chatID int64, originalMessageID int, validRequestCount int, initialErrors []string, resultsChan <-chan RequestResult, deps BotDeps) ([]RequestResult, []RequestResult) {
 	var successfulResults []RequestResult
 	var errorsCollected []RequestResult
+	var requestErrors []RequestResult
 	numCompleted := 0
 	userLang := getUserLanguagePreference(chatID, deps) // Assuming chatID can represent user preference context here
 
@@ -318,28 +600,36 @@ func collectAndProcessResults(chatID int64, originalMessageID int, validRequestC
 		numCompleted++
 		// Update status periodically - Using i18n key directly
 		statusUpdate := deps.I18n.T(userLang, "generate_status_update", "completed", numCompleted, "total", validRequestCount)
-		editStatus := tgbotapi.NewEditMessageText(chatID, originalMessageID, statusUpdate)
-		deps.Bot.Send(editStatus)
+		deps.Edits.Edit(tgbotapi.NewEditMessageText(chatID, originalMessageID, statusUpdate))
 
 		if res.Error != nil {
-			errorsCollected = append(errorsCollected, res)
+			requestErrors = append(requestErrors, res)
 			deps.Logger.Warn("Collected error result", zap.Strings("loras", res.LoraNames), zap.String("reqID", res.ReqID), zap.Error(res.Error))
 		} else if res.Response != nil {
 			successfulResults = append(successfulResults, res)
 			deps.Logger.Info("Collected successful result", zap.Strings("loras", res.LoraNames), zap.String("reqID", res.ReqID), zap.Int("image_count", len(res.Response.Images)))
 		} else {
 			deps.Logger.Error("Collected result with nil Response and nil Error", zap.Strings("loras", res.LoraNames), zap.String("reqID", res.ReqID))
-			errorsCollected = append(errorsCollected, RequestResult{Error: fmt.Errorf(deps.I18n.T(userLang, "generate_result_empty", "loras", strings.Join(res.LoraNames, ",")))})
+			requestErrors = append(requestErrors, RequestResult{Error: fmt.Errorf(deps.I18n.T(userLang, "generate_result_empty", "loras", strings.Join(res.LoraNames, ","))), Order: res.Order})
 		}
 	}
-	return successfulResults, errorsCollected
+
+	// Results arrive in completion order; report them in request order instead
+	byOrder := func(results []RequestResult) {
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Order < results[j].Order })
+	}
+	byOrder(successfulResults)
+	byOrder(requestErrors)
+	return successfulResults, append(errorsCollected, requestErrors...)
 }
 
 // buildResultCaption constructs the final caption string based on results.
 func buildResultCaption(prompt string, successfulResults []RequestResult, errorsCollected []RequestResult, duration time.Duration, userID int64, deps BotDeps) string {
 	userLang := getUserLanguagePreference(userID, deps)
 	captionBuilder := strings.Builder{}
-	captionBuilder.WriteString(deps.I18n.T(userLang, "generate_caption_prompt", "prompt", prompt))
+	if showPromptInCaption(userID, deps) {
+		captionBuilder.WriteString(deps.I18n.T(userLang, "generate_caption_prompt", "prompt", prompt))
+	}
 
 	if len(successfulResults) > 0 {
 		var successNames []string
@@ -368,28 +658,71 @@ func buildResultCaption(prompt string, successfulResults []RequestResult, errors
 	captionBuilder.WriteString(deps.I18n.T(userLang, "generate_caption_duration", "duration", fmt.Sprintf("%.1f", duration.Seconds())))
 	if deps.BalanceManager != nil {
 		finalBalance := deps.BalanceManager.GetBalance(userID)
-		captionBuilder.WriteString(deps.I18n.T(userLang, "generate_caption_balance", "balance", fmt.Sprintf("%.2f", finalBalance)))
+		captionBuilder.WriteString(deps.I18n.T(userLang, "generate_caption_balance", "balance", formatBalance(finalBalance, userLang, deps)))
 	}
 	return captionBuilder.String()
 }
 
 // sendResultsToUser sends the generated images and caption via Telegram.
-// It handles single image and media group sending, and updates/deletes the original status message.
-func sendResultsToUser(chatID int64, originalMessageID int, caption string, images []falapi.ImageInfo, deps BotDeps) error {
+// It handles single image and media group sending, and updates/deletes the original status message
+// once everything was sent. labels, if not nil, holds a short caption for each image (e.g. its
+// seed in a /grid). mode is a config.DeliveryMode* value; groups tells per_lora which images
+// belong together. Large results are sent as one contact sheet instead when contactSheet is
+// enabled, falling back to mode if the sheet fails.
+// It returns the IDs of all messages sent, so they can be auto-deleted later.
+func sendResultsToUser(chatID int64, originalMessageID int, caption string, images []falapi.ImageInfo, labels []string, groups []resultGroup, mode string, deps BotDeps) ([]int, error) {
+	labels = deliveryLabels(mode, len(images), labels, groups)
+	var sentIDs []int
+	send := func(c tgbotapi.Chattable) error {
+		msg, err := sendWithRetry(chatID, c, deps)
+		if err == nil {
+			sentIDs = append(sentIDs, msg.MessageID)
+		}
+		return err
+	}
+	labelOf := func(i int) string {
+		if i < len(labels) {
+			return labels[i]
+		}
+		return ""
+	}
+	// sendMediaBatch sends the images at indexes as album(s), returning the sent message IDs
+	// and the first error. It may run concurrently for several batches.
+	sendMediaBatch := func(indexes []int) ([]int, error) {
+		files := make([]mediaFile, 0, len(indexes))
+		for _, i := range indexes {
+			// Media items only carry their label, the full caption is sent separately
+			files = append(files, mediaFile{Kind: detectMediaKind(images[i]), File: tgbotapi.FileURL(images[i].URL), Caption: labelOf(i)})
+		}
+		msgs, err := sendMediaFiles(chatID, files, deps)
+		ids := make([]int, 0, len(msgs))
+		for _, msg := range msgs {
+			ids = append(ids, msg.MessageID)
+		}
+		return ids, err
+	}
+
+	// sendCaption sends the caption, split into several messages if it is too long for one
+	sendCaption := func() error {
+		msgs, err := sendMarkdownMessages(chatID, caption, deps)
+		for _, msg := range msgs {
+			sentIDs = append(sentIDs, msg.MessageID)
+		}
+		return err
+	}
+
 	var sendErr error
 	userLang := getUserLanguagePreference(chatID, deps) // Assuming chatID gives user context
 
 	if len(images) == 1 {
-		// Send photo without caption first
-		photoMsg := tgbotapi.NewPhoto(chatID, tgbotapi.FileURL(images[0].URL))
-		if _, err := deps.Bot.Send(photoMsg); err != nil {
-			deps.Logger.Error("Failed to send single photo (without caption)", zap.Error(err), zap.Int64("chat_id", chatID))
+		// Send photo (or animation/video/document, by content type) without caption first
+		photoMsg := newSingleMediaMessage(chatID, images[0], labelOf(0))
+		if err := send(photoMsg); err != nil {
+			deps.Logger.Error("Failed to send single photo (without caption)", zap.Error(err), zap.Int64("chat_id", chatID), zap.String("content_type", images[0].ContentType))
 			sendErr = err // Record the first error
 		} else {
 			// Then send the caption as a separate message
-			captionMsg := tgbotapi.NewMessage(chatID, caption)
-			captionMsg.ParseMode = tgbotapi.ModeMarkdown
-			if _, err := deps.Bot.Send(captionMsg); err != nil {
+			if err := sendCaption(); err != nil {
 				deps.Logger.Error("Failed to send caption for single photo", zap.Error(err), zap.Int64("chat_id", chatID))
 				if sendErr == nil { // Only record if sending photo succeeded
 					sendErr = err
@@ -398,34 +731,36 @@ func sendResultsToUser(chatID int64, originalMessageID int, caption string, imag
 		}
 	} else if len(images) > 1 {
 		// Send caption first for multiple images (existing logic is fine)
-		captionMsg := tgbotapi.NewMessage(chatID, caption)
-		captionMsg.ParseMode = tgbotapi.ModeMarkdown
-		if _, err := deps.Bot.Send(captionMsg); err != nil {
+		if err := sendCaption(); err != nil {
 			deps.Logger.Error("Failed to send caption before media group", zap.Error(err), zap.Int64("chat_id", chatID))
 			// Continue trying to send images, record the error
 			sendErr = err
 		}
 
-		var mediaGroup []interface{}
-		for i, img := range images {
-			// Ensure media items themselves don't have captions
-			photo := tgbotapi.NewInputMediaPhoto(tgbotapi.FileURL(img.URL))
-			mediaGroup = append(mediaGroup, photo)
-			if len(mediaGroup) == 10 || i == len(images)-1 { // Send when group reaches 10 or it's the last image
-				mediaMessage := tgbotapi.NewMediaGroup(chatID, mediaGroup)
-				if _, err := deps.Bot.Request(mediaMessage); err != nil {
-					deps.Logger.Error("Failed to send image group chunk", zap.Error(err), zap.Int64("chat_id", chatID), zap.Int("chunk_size", len(mediaGroup)))
-					if sendErr == nil { // Record the first sending error
-						sendErr = err
-					}
-				}
-				mediaGroup = []interface{}{} // Reset for next chunk
+		sentAsSheet := false
+		if useContactSheet(images, deps) {
+			ids, ok, err := sendContactSheet(chatID, images, labels, groups, userLang, deps)
+			sentIDs = append(sentIDs, ids...)
+			if err != nil && sendErr == nil {
+				sendErr = err
+			}
+			sentAsSheet = ok
+		}
+		if !sentAsSheet {
+			concurrency := deps.Cfg().MediaSendConcurrency
+			sendStart := time.Now()
+			ids, err := sendMediaBatches(deliveryBatches(mode, len(images), groups), concurrency, sendMediaBatch)
+			sentIDs = append(sentIDs, ids...)
+			if err != nil && sendErr == nil {
+				sendErr = err
 			}
+			deps.Logger.Debug("Sent result media", zap.Int64("chat_id", chatID), zap.Int("image_count", len(images)), zap.Int("concurrency", concurrency), zap.Duration("duration", time.Since(sendStart)))
 		}
 	}
 
 	// Handle original message update/deletion
 	if sendErr == nil {
+		deps.Edits.Forget(chatID, originalMessageID)
 		deleteMsg := tgbotapi.NewDeleteMessage(chatID, originalMessageID)
 		if _, errDel := deps.Bot.Request(deleteMsg); errDel != nil {
 			deps.Logger.Warn("Failed to delete original status message after sending results", zap.Error(errDel), zap.Int64("chat_id", chatID), zap.Int("message_id", originalMessageID))
@@ -436,15 +771,9 @@ func sendResultsToUser(chatID int64, originalMessageID int, caption string, imag
 			"error", sendErr.Error(),
 			"caption", caption,
 		)
-		if len(failedSendText) > 4090 {
-			failedSendText = failedSendText[:4090] + "..."
-		}
-		editErr := tgbotapi.NewEditMessageText(chatID, originalMessageID, failedSendText)
-		editErr.ParseMode = tgbotapi.ModeMarkdown
-		editErr.ReplyMarkup = nil
-		deps.Bot.Send(editErr)
+		editMarkdownWithOverflow(chatID, originalMessageID, failedSendText, deps)
 	}
-	return sendErr // Return the first sending error encountered, if any
+	return sentIDs, sendErr // Return the first sending error encountered, if any
 }
 
 // handleAllFailures edits the original message to indicate complete failure.
@@ -464,40 +793,63 @@ func handleAllFailures(chatID int64, originalMessageID int, errorsCollected []Re
 	}
 	if deps.BalanceManager != nil {
 		finalBalance := deps.BalanceManager.GetBalance(userID)
-		errMsgBuilder.WriteString(deps.I18n.T(userLang, "generate_caption_balance", "balance", fmt.Sprintf("%.2f", finalBalance)))
-	}
-	errMsgStr := errMsgBuilder.String()
-
-	if len(errMsgStr) > 4090 {
-		errMsgStr = errMsgStr[:4090] + "..."
+		errMsgBuilder.WriteString(deps.I18n.T(userLang, "generate_caption_balance", "balance", formatBalance(finalBalance, userLang, deps)))
 	}
-
-	editErr := tgbotapi.NewEditMessageText(chatID, originalMessageID, errMsgStr)
-	editErr.ParseMode = tgbotapi.ModeMarkdown
-	editErr.ReplyMarkup = nil
-	deps.Bot.Send(editErr)
+	// Long failure lists continue in further messages instead of being cut off
+	editMarkdownWithOverflow(chatID, originalMessageID, errMsgBuilder.String(), deps)
 }
 
 // GenerateImagesForUser orchestrates the image generation process.
 func GenerateImagesForUser(userState *UserState, deps BotDeps) {
+	deps.StateManager.ClearState(userState.UserID) // Clear state early
+	if refuseForMaintenance(userState.ChatID, userState.UserID, userState.MessageID, deps) {
+		return
+	}
+	if !deps.Authorizer.IsAdmin(userState.UserID) {
+		if wait := deps.Cooldowns.Remaining(userState.UserID); wait > 0 {
+			userLang := getUserLanguagePreference(userState.UserID, deps)
+			deps.Logger.Info("Generation refused during cooldown", zap.Int64("user_id", userState.UserID), zap.Duration("remaining", wait))
+			edit := tgbotapi.NewEditMessageText(userState.ChatID, userState.MessageID, deps.I18n.T(userLang, "generate_cooldown", "wait", formatRetryWait(wait)))
+			deps.Edits.EditNow(edit)
+			return
+		}
+	}
+	if runGeneration(userState, deps) {
+		deps.Cooldowns.Finished(userState.UserID)
+		deps.Repeats.Remember(userState)
+		if quickRepeatEnabled(userState.UserID, deps) {
+			sendQuickRepeatKeyboard(userState.ChatID, userState.UserID, deps)
+		}
+	}
+}
+
+// runGeneration generates images for userState without touching the state manager,
+// so callers running several generations (e.g. /batch) don't clear a newer flow.
+// It reports whether at least one image was produced.
+func runGeneration(userState *UserState, deps BotDeps) bool {
 	userID := userState.UserID
 	chatID := userState.ChatID
 	originalMessageID := userState.MessageID
-	deps.StateManager.ClearState(userID) // Clear state early
 	userLang := getUserLanguagePreference(userID, deps)
 
 	if chatID == 0 || originalMessageID == 0 {
 		deps.Logger.Error("GenerateImagesForUser called with invalid state", zap.Int64("userID", userID), zap.Int64("chatID", chatID), zap.Int("messageID", originalMessageID))
 		deps.Bot.Send(tgbotapi.NewMessage(userID, deps.I18n.T(userLang, "generate_error_invalid_state")))
-		return
+		return false
 	}
 
 	// 1. Prepare Parameters
 	params, err := prepareGenerationParameters(userID, userState, deps)
 	if err != nil {
 		// Error already logged in prepareGenerationParameters
+		var sizeErr *imageSizeLimitError
+		if errors.As(err, &sizeErr) {
+			edit := tgbotapi.NewEditMessageText(chatID, originalMessageID, deps.I18n.T(userLang, "generate_error_image_too_large", "size", sizeErr.Size, "pixels", sizeErr.Pixels, "max", sizeErr.MaxPixels))
+			deps.Edits.EditNow(edit)
+			return false
+		}
 		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "error_generic")))
-		return
+		return false
 	}
 
 	// 2. Validate LoRAs, Check Balance, Prepare Requests
@@ -507,23 +859,63 @@ func GenerateImagesForUser(userState *UserState, deps BotDeps) {
 		deps.Logger.Error("No valid generation requests could be prepared", zap.Int64("userID", userID), zap.Strings("initialErrors", initialErrors))
 		edit := tgbotapi.NewEditMessageText(chatID, originalMessageID, strings.Join(initialErrors, "\n"))
 		edit.ReplyMarkup = nil
-		deps.Bot.Send(edit)
-		return
+		deps.Edits.EditNow(edit)
+		return false
+	}
+
+	// Photos share one time budget between captioning and generating
+	var flowStart, deadline time.Time
+	if budget := photoFlowBudget(userState, deps); budget > 0 {
+		flowStart = time.Now().Add(-userState.CaptionElapsed)
+		deadline = flowStart.Add(budget)
+		if !deadline.After(time.Now()) {
+			deps.Logger.Info("Generation refused, photo flow budget used up by captioning", zap.Int64("userID", userID), zap.Duration("caption_elapsed", userState.CaptionElapsed))
+			edit := tgbotapi.NewEditMessageText(chatID, originalMessageID, deps.I18n.T(userLang, "generate_photo_budget_used",
+				"elapsed", formatElapsed(userState.CaptionElapsed),
+				"budget", formatElapsed(budget),
+			))
+			deps.Edits.EditNow(edit)
+			return false
+		}
 	}
 
-	// 3. Execute Concurrent Requests
+	// 3. Queue and Execute Concurrent Requests
+	if ok, generating := deps.ChatLimiter.Start(chatID, userID); !ok {
+		deps.Logger.Info("Generation refused, chat is at its generation limit", zap.Int64("userID", userID), zap.Int64("chatID", chatID), zap.Int64s("generating", generating))
+		edit := tgbotapi.NewEditMessageText(chatID, originalMessageID, deps.I18n.T(userLang, "generate_chat_busy",
+			"max", len(generating),
+			"users", strings.Join(chatMemberNames(chatID, generating, deps), ", "),
+		))
+		deps.Edits.EditNow(edit)
+		return false
+	}
+	defer deps.ChatLimiter.Done(chatID, userID)
+	tickets, queued := queueGenerationRequests(len(validRequests), deps)
+	if !queued {
+		deps.Logger.Warn("Generation rejected, queue is full", zap.Int64("userID", userID), zap.Int("count", validRequestCount))
+		edit := tgbotapi.NewEditMessageText(chatID, originalMessageID, deps.I18n.T(userLang, "generate_queue_full"))
+		deps.Edits.EditNow(edit)
+		return false
+	}
 	startTime := time.Now()
 	var wg sync.WaitGroup
 	resultsChan := make(chan RequestResult, validRequestCount)
 
 	deps.Logger.Info("Starting concurrent generation requests", zap.Int("count", validRequestCount), zap.Strings("selected_base_loras", userState.SelectedBaseLoras))
 	statusUpdate := deps.I18n.T(userLang, "generate_submit_multi", "count", validRequestCount)
-	editStatus := tgbotapi.NewEditMessageText(chatID, originalMessageID, statusUpdate)
-	deps.Bot.Send(editStatus)
+	typicalWait, _ := deps.WaitEstimates.Typical(params.Model)
+	statusUpdate += deps.I18n.T(userLang, "generate_typical_wait", "wait", formatTypicalWait(typicalWait))
+	if position := tickets[0].Position(); position > 0 {
+		deps.Logger.Info("Generation queued", zap.Int64("userID", userID), zap.Int("position", position))
+		go showQueuePosition(chatID, originalMessageID, tickets[0], statusUpdate, userLang, deps)
+	} else {
+		deps.Edits.Edit(tgbotapi.NewEditMessageText(chatID, originalMessageID, statusUpdate))
+	}
 
-	for _, reqInfo := range validRequests {
+	for i, reqInfo := range validRequests {
+		reqInfo.FlowStart, reqInfo.Deadline = flowStart, deadline
 		wg.Add(1)
-		go executeAndPollRequest(reqInfo, userID, deps, resultsChan, &wg)
+		go executeAndPollRequest(reqInfo, tickets[i], userID, deps, resultsChan, &wg)
 	}
 
 	go func() {
@@ -545,10 +937,46 @@ func GenerateImagesForUser(userState *UserState, deps BotDeps) {
 		}
 	}
 
+	recordGenerationHistory(userID, params, successfulResults, deps)
+
 	if len(allImages) > 0 {
 		finalCaption := buildResultCaption(params.Prompt, successfulResults, errorsCollected, duration, userID, deps)
-		sendResultsToUser(chatID, originalMessageID, finalCaption, allImages, deps)
-	} else {
-		handleAllFailures(chatID, originalMessageID, errorsCollected, userID, deps)
+		if len(userState.RemixModifiers) > 0 {
+			finalCaption += deps.I18n.T(userLang, "generate_caption_remix", "modifiers", strings.Join(userState.RemixModifiers, ", "))
+		}
+		var labels []string
+		var groups []resultGroup
+		if userState.GridSize > 0 {
+			allImages, labels = gridImages(successfulResults, userLang, deps)
+		} else if userState.Sweep {
+			allImages, labels = sweepImages(successfulResults)
+		} else {
+			groups = resultGroups(successfulResults)
+		}
+		if deps.Cfg().Moderation.Enabled {
+			// Held results are never delivered unreviewed, even if holding them fails
+			if err := holdForModeration(userID, chatID, originalMessageID, finalCaption, allImages, labels, groups, deps); err != nil {
+				deps.Logger.Error("Failed to hold result for moderation", zap.Error(err), zap.Int64("user_id", userID))
+				deps.Edits.EditNow(tgbotapi.NewEditMessageText(chatID, originalMessageID, deps.I18n.T(userLang, "moderation_hold_failed")))
+				return false
+			}
+			return true
+		}
+		deps.LastResults.Remember(userID, finalCaption, allImages, labels, groups)
+		ttl := autoDeleteTTL(userID, deps)
+		if ttl > 0 {
+			deleteAt := time.Now().Add(ttl).Format("2006-01-02 15:04 MST")
+			finalCaption += deps.I18n.T(userLang, "generate_caption_auto_delete", "time", deleteAt)
+		}
+		sentIDs, _ := sendResultsToUser(chatID, originalMessageID, finalCaption, allImages, labels, groups, deliveryModeFor(userID, deps), deps)
+		if deps.Cfg().SeedButtons && len(sentIDs) > 0 {
+			if buttonsID := sendSeedButtons(chatID, userState, successfulResults, userLang, deps); buttonsID != 0 {
+				sentIDs = append(sentIDs, buttonsID)
+			}
+		}
+		scheduleAutoDelete(chatID, sentIDs, ttl, deps)
+		return true
 	}
+	handleAllFailures(chatID, originalMessageID, errorsCollected, userID, deps)
+	return false
 }
@@ -0,0 +1,32 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+	"go.uber.org/zap"
+)
+
+// TestWeightlessLoraIsSubmittedAtFullScale checks that LoRAs without a weight are sent
+// with scale 1.0, fal's default, instead of 0, which would disable them.
+func TestWeightlessLoraIsSubmittedAtFullScale(t *testing.T) {
+	standard, err := GenerateLoraConfig(config.LoraConfig{Name: "Style", URL: "https://example.com/style.safetensors"})
+	if err != nil {
+		t.Fatalf("GenerateLoraConfig: %v", err)
+	}
+	base, err := GenerateLoraConfig(config.LoraConfig{Name: "Base", URL: "https://example.com/base.safetensors", Weight: 0.5})
+	if err != nil {
+		t.Fatalf("GenerateLoraConfig: %v", err)
+	}
+
+	loras := apiLoras(standard, []LoraConfig{base}, 2, zap.NewNop())
+	if len(loras) != 2 {
+		t.Fatalf("apiLoras returned %d LoRAs, want 2", len(loras))
+	}
+	if loras[0].Scale != 1.0 {
+		t.Errorf("weightless LoRA scale = %g, want 1.0", loras[0].Scale)
+	}
+	if loras[1].Scale != 0.5 {
+		t.Errorf("base LoRA scale = %g, want 0.5", loras[1].Scale)
+	}
+}
@@ -0,0 +1,138 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+	falapi "github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+	"go.uber.org/zap"
+)
+
+// falBalanceSnapshotRetention is how long balance snapshots are kept.
+const falBalanceSnapshotRetention = 30 * 24 * time.Hour
+
+// FalBalanceTracker fetches the fal account balance with caching, so admin commands and
+// the snapshot loop never call the billing API more often than once per cache period.
+type FalBalanceTracker struct {
+	client   *falapi.Client
+	db       *sql.DB
+	cacheTTL time.Duration
+	logger   *zap.Logger
+
+	mu        sync.Mutex
+	balance   float64
+	fetchedAt time.Time
+}
+
+// NewFalBalanceTracker creates a tracker that reuses a fetched balance for cacheTTL.
+func NewFalBalanceTracker(client *falapi.Client, db *sql.DB, cacheTTL time.Duration, logger *zap.Logger) *FalBalanceTracker {
+	return &FalBalanceTracker{client: client, db: db, cacheTTL: cacheTTL, logger: logger}
+}
+
+// Balance returns the fal account balance and when it was fetched, calling the API only
+// when the cached value is older than the cache period.
+func (t *FalBalanceTracker) Balance() (float64, time.Time, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.fetchedAt.IsZero() && time.Since(t.fetchedAt) < t.cacheTTL {
+		return t.balance, t.fetchedAt, nil
+	}
+	balance, err := t.client.GetAccountBalance()
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	t.balance = balance
+	t.fetchedAt = time.Now()
+	return t.balance, t.fetchedAt, nil
+}
+
+// RunSnapshots records a balance snapshot every interval until the process exits.
+func (t *FalBalanceTracker) RunSnapshots(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		t.recordSnapshot()
+		<-ticker.C
+	}
+}
+
+func (t *FalBalanceTracker) recordSnapshot() {
+	balance, fetchedAt, err := t.Balance()
+	if err != nil {
+		t.logger.Warn("Failed to fetch fal balance for snapshot", zap.Error(err))
+		return
+	}
+	if err := st.AddFalBalanceSnapshot(t.db, balance, fetchedAt, falBalanceSnapshotRetention); err != nil {
+		t.logger.Warn("Failed to record fal balance snapshot", zap.Error(err))
+		return
+	}
+	t.logger.Debug("Recorded fal balance snapshot", zap.Float64("balance", balance))
+}
+
+// HandleFalBalanceCommand handles the admin /falbalance command: the current fal account
+// balance and how much was consumed over the last day and week.
+func HandleFalBalanceCommand(chatID int64, userID int64, deps BotDeps) {
+	userLang := getUserLanguagePreference(userID, deps)
+	if !deps.Authorizer.IsAdmin(userID) {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "myconfig_command_admin_only")))
+		return
+	}
+
+	balance, fetchedAt, err := deps.FalBalance.Balance()
+	if err != nil {
+		deps.Logger.Error("Failed to get account balance", zap.Error(err), zap.Int64("user_id", userID))
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "balance_admin_fetch_failed", "error", err.Error())))
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString(deps.I18n.T(userLang, "falbalance_current", "balance", fmt.Sprintf("%.2f", balance), "time", fetchedAt.Format("2006-01-02 15:04 MST")))
+
+	if deps.Cfg().FalBalance.SnapshotIntervalMinutes <= 0 {
+		text.WriteString(deps.I18n.T(userLang, "falbalance_tracking_disabled"))
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, text.String()))
+		return
+	}
+
+	var dailyRate float64
+	for _, period := range []struct {
+		key    string
+		window time.Duration
+	}{
+		{"falbalance_period_day", 24 * time.Hour},
+		{"falbalance_period_week", 7 * 24 * time.Hour},
+	} {
+		name := deps.I18n.T(userLang, period.key)
+		snapshot, err := st.GetOldestFalBalanceSnapshotSince(deps.DB, time.Now().Add(-period.window))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			deps.Logger.Error("Failed to load fal balance snapshot", zap.Error(err))
+		}
+		elapsed := time.Duration(0)
+		if snapshot != nil {
+			elapsed = fetchedAt.Sub(snapshot.RecordedAt)
+		}
+		if snapshot == nil || elapsed < time.Hour {
+			text.WriteString(deps.I18n.T(userLang, "falbalance_period_no_data", "period", name))
+			continue
+		}
+		consumed := snapshot.Balance - balance
+		perDay := consumed / elapsed.Hours() * 24
+		dailyRate = perDay // Periods go from short to long, so the longest one with data wins
+		text.WriteString(deps.I18n.T(userLang, "falbalance_period_usage",
+			"period", name,
+			"consumed", fmt.Sprintf("%.2f", consumed),
+			"hours", fmt.Sprintf("%.0f", elapsed.Hours()),
+			"perDay", fmt.Sprintf("%.2f", perDay),
+		))
+	}
+	if dailyRate > 0 {
+		text.WriteString(deps.I18n.T(userLang, "falbalance_days_left", "days", fmt.Sprintf("%.1f", balance/dailyRate)))
+	}
+	deps.Bot.Send(tgbotapi.NewMessage(chatID, text.String()))
+}
@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// creditAlertInterval is the minimum time between two out-of-credits alerts to admins.
+const creditAlertInterval = 15 * time.Minute
+
+// CreditAlerter tells the admins when fal rejects generations because the account is out
+// of credits, at most once per creditAlertInterval.
+type CreditAlerter struct {
+	mu        sync.Mutex
+	lastAlert time.Time
+}
+
+func NewCreditAlerter() *CreditAlerter {
+	return &CreditAlerter{}
+}
+
+// Alert messages every admin about err unless they were alerted recently.
+func (a *CreditAlerter) Alert(err error, deps BotDeps) {
+	a.mu.Lock()
+	if !a.lastAlert.IsZero() && time.Since(a.lastAlert) < creditAlertInterval {
+		a.mu.Unlock()
+		return
+	}
+	a.lastAlert = time.Now()
+	a.mu.Unlock()
+
+	for _, adminID := range deps.Cfg().Admins.AdminUserIDs {
+		adminLang := getUserLanguagePreference(adminID, deps)
+		msg := tgbotapi.NewMessage(adminID, deps.I18n.T(adminLang, "admin_alert_fal_credits", "error", err.Error()))
+		if _, sendErr := deps.Bot.Send(msg); sendErr != nil {
+			deps.Logger.Warn("Failed to alert admin about fal credits", zap.Error(sendErr), zap.Int64("admin_id", adminID))
+		}
+	}
+}
+
+// handleInsufficientCredits handles a submit or poll error caused by fal being out of
+// credits: the user is refunded if they were charged and the admins are alerted. It returns
+// the message for the user, which only says they were not charged if the refund worked, and
+// whether the user is still charged.
+func handleInsufficientCredits(err error, userID int64, loraNames []string, requestID string, deducted bool, userLang *string, deps BotDeps) (string, bool) {
+	deps.Logger.Error("fal account is out of credits", zap.Error(err), zap.Int64("user_id", userID), zap.String("request_id", requestID), zap.Strings("loras", loraNames))
+	if deducted {
+		if refundErr := deps.BalanceManager.AddBalance(userID, deps.BalanceManager.GetCost()); refundErr != nil {
+			deps.Logger.Error("Failed to refund request rejected for fal credits", zap.Error(refundErr), zap.Int64("user_id", userID), zap.String("request_id", requestID))
+		} else {
+			deducted = false
+		}
+	}
+	deps.CreditAlerts.Alert(err, deps)
+	if deducted {
+		return deps.I18n.T(userLang, "generate_submit_fail_generic", "loras", strings.Join(loraNames, "+")), deducted
+	}
+	return deps.I18n.T(userLang, "generate_service_unavailable", "loras", strings.Join(loraNames, "+")), deducted
+}
@@ -0,0 +1,81 @@
+package bot
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	falapi "github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+	"go.uber.org/zap"
+)
+
+// HandleGridCommand handles /grid <prompt>. It runs the normal LoRA selection, then
+// generates limits.gridSize images with sequential seeds so the user can pick a favorite.
+func HandleGridCommand(message *tgbotapi.Message, deps BotDeps) {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+	userLang := getUserLanguagePreference(userID, deps)
+
+	prompt := strings.TrimSpace(message.CommandArguments())
+	if prompt == "" {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "grid_usage", "count", deps.Cfg().Limits.GridSize)))
+		return
+	}
+
+	deps.StateManager.ClearState(userID)
+	startLoraSelection(&UserState{
+		UserID:          userID,
+		ChatID:          chatID,
+		OriginalCaption: prompt,
+		SelectedLoras:   []string{},
+		GridSize:        deps.Cfg().Limits.GridSize,
+	}, deps)
+}
+
+// prepareGridRequests builds one single-image request per seed (seed, seed+1, ...) for one
+// standard LoRA. A seed fixed by overrides is used as the start; otherwise one is picked here
+// so the seeds stay sequential.
+func prepareGridRequests(standardLora LoraConfig, baseLoras []LoraConfig, gridSize int, userState *UserState, params *GenerationParameters) []RequestInfo {
+	var startSeed uint64
+	if params.Seed != nil {
+		startSeed = *params.Seed
+	} else {
+		startSeed = uint64(rand.Int31()) // Stay well inside the int range fal accepts
+	}
+
+	requests := make([]RequestInfo, 0, gridSize)
+	for i := 0; i < gridSize; i++ {
+		seed := startSeed + uint64(i)
+		gridParams := *params
+		gridParams.Seed = &seed
+		gridParams.NumImages = 1
+		requests = append(requests, RequestInfo{
+			StandardLora: standardLora,
+			BaseLoras:    baseLoras,
+			Params:       &gridParams,
+			ChatID:       userState.ChatID,
+			Verbose:      userState.Verbose,
+		})
+	}
+	return requests
+}
+
+// gridImages orders the grid results by seed and labels each image with its seed.
+func gridImages(successfulResults []RequestResult, userLang *string, deps BotDeps) ([]falapi.ImageInfo, []string) {
+	sorted := append([]RequestResult{}, successfulResults...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Response.Seed < sorted[j].Response.Seed
+	})
+
+	var images []falapi.ImageInfo
+	var labels []string
+	for _, res := range sorted {
+		for _, img := range res.Response.Images {
+			images = append(images, img)
+			labels = append(labels, deps.I18n.T(userLang, "grid_image_label", "seed", res.Response.Seed))
+		}
+	}
+	deps.Logger.Debug("Prepared grid images", zap.Int("count", len(images)))
+	return images, labels
+}
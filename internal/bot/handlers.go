@@ -7,6 +7,7 @@ import (
 	"os"
 	"runtime"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -55,6 +56,9 @@ func HandleUpdate(update tgbotapi.Update, deps BotDeps) {
 					// Use ModeMarkdown for panic message as well, simpler
 					msg.ParseMode = tgbotapi.ModeMarkdown
 					deps.Bot.Send(msg)
+				} else if verboseErrorsEnabled(userID, deps) {
+					// Opted in to verbose errors: the error, but never the stack trace
+					deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "error_panic_verbose", "error", errMsg)))
 				} else {
 					// Send generic error to non-admin - Use I18n
 					deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "error_generic")))
@@ -64,6 +68,17 @@ func HandleUpdate(update tgbotapi.Update, deps BotDeps) {
 		}
 	}()
 
+	if update.Message != nil && update.Message.From != nil && !isUserAllowed(update.Message.From.ID, deps) {
+		rejectUnauthorizedMessage(update.Message, deps)
+		return
+	}
+	if update.CallbackQuery != nil && !isUserAllowed(update.CallbackQuery.From.ID, deps) {
+		userLang := firstContactLanguage(update.CallbackQuery.From, deps)
+		deps.Logger.Info("Rejected callback from unauthorized user", zap.Int64("user_id", update.CallbackQuery.From.ID))
+		deps.Bot.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, deps.I18n.T(userLang, "unauthorized_user_callback")))
+		return
+	}
+
 	if update.Message != nil {
 		HandleMessage(update.Message, deps)
 	} else if update.CallbackQuery != nil {
@@ -71,6 +86,39 @@ func HandleUpdate(update tgbotapi.Update, deps BotDeps) {
 	}
 }
 
+// isUserAllowed reports whether userID may use the bot. Without auth.enforce everyone may;
+// with it, only authorizedUserIDs, members of userGroups and the admins.
+func isUserAllowed(userID int64, deps BotDeps) bool {
+	cfg := deps.Cfg()
+	if !cfg.Auth.Enforce || deps.Authorizer.IsAllowed(userID) {
+		return true
+	}
+	for _, group := range cfg.UserGroups {
+		if slices.Contains(group.UserIDs, userID) {
+			return true
+		}
+	}
+	return false
+}
+
+// rejectUnauthorizedMessage tells a user refused by auth.enforce that they can't use the
+// bot, with auth.contact if configured so they know whom to ask. In group chats the message
+// is ignored silently, so other members don't see a refusal for every message.
+func rejectUnauthorizedMessage(message *tgbotapi.Message, deps BotDeps) {
+	userID := message.From.ID
+	userLang := firstContactLanguage(message.From, deps)
+	deps.Logger.Info("Rejected message from unauthorized user", zap.Int64("user_id", userID), zap.String("username", message.From.UserName))
+	if message.Chat.ID < 0 {
+		return
+	}
+
+	text := deps.I18n.T(userLang, "unauthorized_user_message")
+	if contact := deps.Cfg().Auth.Contact; contact != "" {
+		text += deps.I18n.T(userLang, "unauthorized_user_contact", "contact", contact, "userID", userID)
+	}
+	deps.Bot.Send(tgbotapi.NewMessage(message.Chat.ID, text))
+}
+
 func HandleMessage(message *tgbotapi.Message, deps BotDeps) {
 	userID := message.From.ID
 	chatID := message.Chat.ID
@@ -97,10 +145,56 @@ func HandleMessage(message *tgbotapi.Message, deps BotDeps) {
 			HandleSetCommand(message, deps)
 		case "cancel":
 			HandleCancelCommand(message, deps)
+		case "stop":
+			HandleStopCommand(chatID, userID, deps)
 		case "log":
 			HandleLogCommand(chatID, userID, deps)
 		case "shortlog":
 			HandleShortLogCommand(chatID, userID, deps)
+		case "vgen":
+			HandleVerboseGenerateCommand(message, deps)
+		case "resize":
+			HandleResizeCommand(message, deps)
+		case "batch":
+			HandleBatchCommand(message, deps)
+		case "grid":
+			HandleGridCommand(message, deps)
+		case "sweep":
+			HandleSweepCommand(message, deps)
+		case "prompt":
+			HandlePromptCommand(chatID, userID, deps)
+		case "lockseed":
+			HandleLockSeedCommand(message, deps)
+		case "unlockseed":
+			HandleUnlockSeedCommand(message, deps)
+		case "falbalance":
+			HandleFalBalanceCommand(chatID, userID, deps)
+		case "me":
+			HandleMeCommand(chatID, userID, deps)
+		case "last":
+			HandleLastCommand(chatID, userID, deps)
+		case "setdefaultloras":
+			HandleSetDefaultLorasCommand(chatID, userID, deps)
+		case "eta":
+			HandleEtaCommand(chatID, userID, deps)
+		case "support":
+			HandleSupportCommand(chatID, userID, deps)
+		case "presets":
+			HandlePresetsCommand(chatID, userID, deps)
+		case "exportconfig":
+			HandleExportConfigCommand(message, deps)
+		case "importconfig":
+			HandleImportConfigCommand(message, deps)
+		case "inflight":
+			HandleInflightCommand(chatID, userID, deps)
+		case "replay":
+			HandleReplayCommand(message, deps)
+		case "enablelora":
+			HandleEnableLoraCommand(message, deps)
+		case "maintenance":
+			HandleMaintenanceCommand(message, deps)
+		case "i18nstatus":
+			HandleI18nStatusCommand(chatID, userID, deps)
 		default:
 			// Use I18n for unknown command message
 			reply := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "unknown_command"))
@@ -113,14 +207,34 @@ func HandleMessage(message *tgbotapi.Message, deps BotDeps) {
 	if message.Photo != nil && len(message.Photo) > 0 {
 		// Clear any previous state before starting a new action with a photo
 		deps.StateManager.ClearState(userID)
+		clearQuickRepeatKeyboard(message.Chat.ID, userID, deps)
 		HandlePhotoMessage(message, deps)
 		return
 	}
 
+	// 文件消息处理 (prompt list for /batch, config file for /importconfig)
+	if message.Document != nil {
+		if state, exists := deps.StateManager.GetState(userID); exists && state.Action == "awaiting_batch_prompts" {
+			HandleBatchDocument(message, state, deps)
+			return
+		} else if exists && strings.HasPrefix(state.Action, "awaiting_import_config_") {
+			HandleImportConfigDocument(message, state, deps)
+			return
+		}
+	}
+
 	// 文本消息处理 (Prompt or potentially config update)
 	if message.Text != "" {
+		// Quick-repeat keyboard buttons take precedence over any pending input
+		if HandleQuickRepeatMessage(message, deps) {
+			return
+		}
 		state, exists := deps.StateManager.GetState(userID)
-		if exists && strings.HasPrefix(state.Action, "awaiting_config_") {
+		if exists && state.Action == "awaiting_batch_prompts" {
+			HandleBatchInput(message, state, deps)
+		} else if exists && state.Action == "awaiting_preset_prompt" {
+			HandlePresetPrompt(message, state, deps)
+		} else if exists && strings.HasPrefix(state.Action, "awaiting_config_") {
 			// Let HandleConfigUpdateInput manage state clearing on completion/error
 			HandleConfigUpdateInput(message, state, deps)
 		} else if exists && strings.HasPrefix(state.Action, "awaiting_admin_balance_") {
@@ -143,6 +257,22 @@ func HandlePhotoMessage(message *tgbotapi.Message, deps BotDeps) {
 	chatID := message.Chat.ID
 	userLang := getUserLanguagePreference(userID, deps)
 
+	if refuseForMaintenance(chatID, userID, 0, deps) {
+		return
+	}
+	if !deps.Cfg().CaptioningEnabled() {
+		deps.Logger.Debug("Ignoring photo, captioning is disabled", zap.Int64("user_id", userID))
+		offerCaptionFallback(chatID, userID, 0, deps.I18n.T(userLang, "photo_captioning_disabled"), userLang, deps)
+		return
+	}
+
+	// Captioning has its own per-user limit, so photo spam can't flood the caption endpoint
+	if ok, wait := deps.CaptionLimiter.Allow(userID); !ok {
+		deps.Logger.Info("Caption rate limit exceeded", zap.Int64("user_id", userID), zap.Duration("retry_in", wait))
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "photo_caption_rate_limited", "wait", formatRetryWait(wait))))
+		return
+	}
+
 	// 1. Get image URL from Telegram
 	if len(message.Photo) == 0 {
 		deps.Logger.Warn("Photo message received but no photo data", zap.Int64("user_id", userID))
@@ -157,129 +287,192 @@ func HandlePhotoMessage(message *tgbotapi.Message, deps BotDeps) {
 		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "photo_process_fail_no_data")))
 		return
 	}
-	imageURL := file.Link(deps.Bot.Token)
+	imageURL := inputImageURL(file.Link(deps.Bot.Token), photo.Width, photo.Height, deps)
 
-	// 2. Send initial "Submitting..." message
-	var msgIDToEdit int
-	waitMsg := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "photo_submit_captioning"))
-	sentMsg, err := deps.Bot.Send(waitMsg)
-	if err == nil && sentMsg.MessageID != 0 {
+	// 2. Caption with the first caption model; the user can re-caption with the others
+	startCaptioning(chatID, userID, 0, imageURL, 0, "", deps)
+}
+
+// startCaptioning captions imageURL with caption endpoint modelIndex in the background. The
+// status is shown on messageID, or on a new message if it is 0, with a button to abort.
+// previousCaption is the caption a re-caption replaces, kept if the new one fails; it is
+// empty for a new photo.
+func startCaptioning(chatID int64, userID int64, messageID int, imageURL string, modelIndex int, previousCaption string, deps BotDeps) {
+	userLang := getUserLanguagePreference(userID, deps)
+	captionEndpoint := deps.Cfg().APIEndpoints.CaptionEndpoints()[modelIndex]
+
+	// 1. Show the "Submitting..." status with a button to abort captioning
+	captionTimeout := 2 * time.Minute // Timeout for captioning
+	budget := photoFlowBudget(&UserState{ImageFileURL: imageURL}, deps)
+	budgetBound := budget > 0 && budget < captionTimeout // The budget ends first
+	if budgetBound {
+		captionTimeout = budget
+	}
+	captionStart := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), captionTimeout)
+	cancelKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "photo_caption_abort_button"), "caption_abort")),
+	)
+	msgIDToEdit := messageID
+	if messageID != 0 {
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, deps.I18n.T(userLang, "photo_recaptioning", "model", captionEndpoint))
+		edit.ReplyMarkup = &cancelKeyboard
+		deps.Edits.EditNow(edit)
+	} else {
+		waitMsg := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "photo_submit_captioning"))
+		waitMsg.ReplyMarkup = cancelKeyboard
+		sentMsg, err := deps.Bot.Send(waitMsg)
+		if err != nil {
+			deps.Logger.Error(deps.I18n.T(userLang, "photo_fail_send_wait_msg"), zap.Error(err), zap.Int64("user_id", userID))
+		}
 		msgIDToEdit = sentMsg.MessageID
-	} else if err != nil {
-		deps.Logger.Error(deps.I18n.T(userLang, "photo_fail_send_wait_msg"), zap.Error(err), zap.Int64("user_id", userID))
+	}
+	if msgIDToEdit != 0 {
+		deps.StateManager.SetState(userID, &UserState{
+			UserID:        userID,
+			ChatID:        chatID,
+			MessageID:     msgIDToEdit,
+			Action:        "captioning",
+			CancelCaption: cancel,
+		})
 	}
 
-	// 3. Start captioning process in a Goroutine
+	// 2. Start captioning process in a Goroutine
 	go func(imgURL string, originalChatID int64, originalUserID int64, editMsgID int) {
+		defer cancel()
 		// Get user lang inside goroutine as well, in case default changed?
 		// Or assume the lang preference at the start of the handler is sufficient.
 		// Let's use the initial userLang for messages within this goroutine.
 		currentUserLang := userLang
 
-		captionEndpoint := deps.Config.APIEndpoints.FlorenceCaption // Get caption endpoint from config
-		pollInterval := 5 * time.Second                             // Adjust interval as needed
-		captionTimeout := 2 * time.Minute                           // Timeout for captioning
+		pollInterval := 5 * time.Second // Adjust interval as needed
 
-		// 3a. Submit caption request
-		requestID, err := deps.FalClient.SubmitCaptionRequest(imgURL)
-		if err != nil {
-			// Log detailed error, send more specific error to user if possible
-			errTextKey := "photo_caption_fail"
-			if errors.Is(err, context.DeadlineExceeded) {
-				errTextKey = "photo_caption_timeout"
+		// 2a. Submit the caption request and poll for the result, retrying transient failures.
+		// Progress edits keep the cancel button.
+		statusEdit := func(text string) {
+			if editMsgID != 0 {
+				edit := tgbotapi.NewEditMessageText(originalChatID, editMsgID, text)
+				edit.ReplyMarkup = &cancelKeyboard
+				deps.Edits.Edit(edit)
 			}
-			errText := deps.I18n.T(currentUserLang, errTextKey, "error", err.Error())
-			deps.Logger.Error(deps.I18n.T(currentUserLang, "photo_polling_fail"), zap.Error(err), zap.Int64("user_id", originalUserID), zap.String("request_id", requestID))
+		}
+		onSubmitted := func(requestID string) {
+			deps.Logger.Info("Submitted caption task", zap.Int64("user_id", originalUserID), zap.String("request_id", requestID), zap.String("caption_endpoint", captionEndpoint))
+			statusEdit(deps.I18n.T(currentUserLang, "photo_caption_submitted", "reqID", truncateID(requestID)))
+		}
+		onRetry := func(attempt, maxAttempts int, err error) {
+			deps.Logger.Warn("Transient captioning failure, retrying", zap.Error(err), zap.Int64("user_id", originalUserID), zap.Int("attempt", attempt), zap.Int("max_attempts", maxAttempts))
+			statusEdit(deps.I18n.T(currentUserLang, "photo_caption_retrying", "attempt", attempt, "max", maxAttempts))
+		}
+		requestID, captionText, err := captionWithRetry(ctx, imgURL, captionEndpoint, pollInterval, deps.Cfg().CaptionMaxAttempts, onSubmitted, onRetry, deps)
+
+		if errors.Is(ctx.Err(), context.Canceled) {
+			// Aborted with the cancel button, /cancel or a new flow; the state is already gone
+			deps.Logger.Info("Captioning cancelled", zap.Int64("user_id", originalUserID), zap.String("request_id", requestID))
 			if editMsgID != 0 {
-				edit := tgbotapi.NewEditMessageText(originalChatID, editMsgID, errText)
+				edit := tgbotapi.NewEditMessageText(originalChatID, editMsgID, deps.I18n.T(currentUserLang, "photo_caption_cancelled"))
 				edit.ReplyMarkup = nil
-				deps.Bot.Send(edit)
-			} else {
-				deps.Bot.Send(tgbotapi.NewMessage(originalChatID, errText))
+				deps.Edits.EditNow(edit)
 			}
 			return
 		}
-
-		deps.Logger.Info("Submitted caption task", zap.Int64("user_id", originalUserID), zap.String("request_id", requestID))
-		statusUpdate := deps.I18n.T(currentUserLang, "photo_caption_submitted", "reqID", truncateID(requestID))
-		if editMsgID != 0 {
-			deps.Bot.Send(tgbotapi.NewEditMessageText(originalChatID, editMsgID, statusUpdate))
-		}
-
-		// 3b. Poll for caption result
-		ctx, cancel := context.WithTimeout(context.Background(), captionTimeout)
-		defer cancel()
-		captionText, err := deps.FalClient.PollForCaptionResult(ctx, requestID, captionEndpoint, pollInterval)
-
 		if err != nil {
-			// Log detailed error, provide more specific error if possible
+			deps.Logger.Error(deps.I18n.T(currentUserLang, "photo_polling_fail"), zap.Error(err), zap.Int64("user_id", originalUserID), zap.String("request_id", requestID))
+			if previousCaption != "" {
+				// A failed re-caption keeps the caption the user already had; the next
+				// re-caption moves on to the following model
+				msgText := deps.I18n.T(currentUserLang, "photo_recaption_failed", "model", captionEndpoint) +
+					deps.I18n.T(currentUserLang, "photo_caption_received_prompt", "caption", previousCaption)
+				showCaptionConfirmation(&UserState{
+					UserID:          originalUserID,
+					ChatID:          originalChatID,
+					MessageID:       editMsgID,
+					OriginalCaption: previousCaption,
+					ImageFileURL:    imgURL,
+					CaptionModel:    modelIndex,
+				}, msgText, deps.I18n.T(currentUserLang, "photo_caption_confirm_button"), currentUserLang, deps)
+				return
+			}
+			// Provide more specific error if possible
 			errTextKey := "photo_caption_fail"
 			if errors.Is(err, context.DeadlineExceeded) {
 				errTextKey = "photo_caption_timeout"
 			}
 			errText := deps.I18n.T(currentUserLang, errTextKey, "error", err.Error())
-			deps.Logger.Error(deps.I18n.T(currentUserLang, "photo_polling_fail"), zap.Error(err), zap.Int64("user_id", originalUserID), zap.String("request_id", requestID))
-			if editMsgID != 0 {
-				edit := tgbotapi.NewEditMessageText(originalChatID, editMsgID, errText)
-				edit.ReplyMarkup = nil
-				deps.Bot.Send(edit)
-			} else {
-				deps.Bot.Send(tgbotapi.NewMessage(originalChatID, errText))
+			if budgetBound && errors.Is(err, context.DeadlineExceeded) {
+				errText = deps.I18n.T(currentUserLang, "photo_caption_budget_exceeded", "elapsed", formatElapsed(time.Since(captionStart)), "budget", formatElapsed(budget))
 			}
+			offerCaptionFallback(originalChatID, originalUserID, editMsgID, errText, currentUserLang, deps)
 			return
 		}
 
 		deps.Logger.Info("Caption received successfully", zap.Int64("user_id", originalUserID), zap.String("request_id", requestID), zap.String("caption", captionText))
 
-		// 4. Caption Success: Store state and ask for confirmation
-		newState := &UserState{
-			UserID:          originalUserID,
-			ChatID:          originalChatID,
-			MessageID:       editMsgID,
-			Action:          "awaiting_caption_confirmation",
-			OriginalCaption: captionText,
-			SelectedLoras:   []string{},
+		// Low-information captions ("a photo") make weak prompts; judged before the template is added
+		shortCaption := isShortCaption(captionText, deps.Cfg().MinCaptionLength)
+		if shortCaption {
+			deps.Logger.Info("Caption is below the minimum length", zap.Int64("user_id", originalUserID), zap.String("request_id", requestID), zap.Int("min_length", deps.Cfg().MinCaptionLength))
+		}
+
+		// Wrap the raw caption in the operator's template; the confirm step shows the wrapped prompt
+		if deps.Cfg().CaptionPromptTemplate != "" {
+			captionText = applyCaptionTemplate(captionText, deps.Cfg().CaptionPromptTemplate)
+			deps.Logger.Debug("Applied caption prompt template", zap.Int64("user_id", originalUserID), zap.String("prompt", captionText))
 		}
-		deps.StateManager.SetState(originalUserID, newState)
 
-		// 5. Send caption and confirmation keyboard (editing the status message)
-		// Use I18n for text and buttons
+		// 3. Caption Success: ask for confirmation (editing the status message)
 		msgText := deps.I18n.T(currentUserLang, "photo_caption_received_prompt", "caption", captionText)
-		confirmationKeyboard := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(currentUserLang, "photo_caption_confirm_button"), "caption_confirm"),
-				tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(currentUserLang, "photo_caption_cancel_button"), "caption_cancel"),
-			),
-		)
-
-		var finalMsg tgbotapi.Chattable
-		if editMsgID != 0 {
-			editMsg := tgbotapi.NewEditMessageText(originalChatID, editMsgID, msgText)
-			// Switch back to ModeMarkdown
-			editMsg.ParseMode = tgbotapi.ModeMarkdown
-			editMsg.ReplyMarkup = &confirmationKeyboard
-			finalMsg = editMsg
-		} else {
-			newMsg := tgbotapi.NewMessage(originalChatID, msgText)
-			// Switch back to ModeMarkdown
-			newMsg.ParseMode = tgbotapi.ModeMarkdown
-			newMsg.ReplyMarkup = &confirmationKeyboard
-			finalMsg = newMsg
+		confirmButton := deps.I18n.T(currentUserLang, "photo_caption_confirm_button")
+		if shortCaption {
+			// Sending a text prompt instead starts the normal prompt flow
+			msgText = deps.I18n.T(currentUserLang, "photo_caption_short_prompt", "caption", captionText)
+			confirmButton = deps.I18n.T(currentUserLang, "photo_caption_short_use_button")
 		}
-		_, err = deps.Bot.Send(finalMsg)
-		if err != nil {
-			deps.Logger.Error("Failed to send caption result & confirmation keyboard", zap.Error(err), zap.Int64("user_id", originalUserID))
+		if len(deps.Cfg().APIEndpoints.CaptionEndpoints()) > 1 {
+			msgText += deps.I18n.T(currentUserLang, "photo_caption_model_line", "model", captionEndpoint)
 		}
-
+		showCaptionConfirmation(&UserState{
+			UserID:          originalUserID,
+			ChatID:          originalChatID,
+			MessageID:       editMsgID,
+			OriginalCaption: captionText,
+			ImageFileURL:    imgURL,
+			CaptionModel:    modelIndex,
+			CaptionElapsed:  time.Since(captionStart),
+		}, msgText, confirmButton, currentUserLang, deps)
 	}(imageURL, chatID, userID, msgIDToEdit)
 
 	// Return immediately, the goroutine handles the rest
 }
 
 func HandleTextMessage(message *tgbotapi.Message, deps BotDeps) {
-	userID := message.From.ID
-	chatID := message.Chat.ID
+	startTextPromptFlow(message.Chat.ID, message.From.ID, message.Text, false, deps)
+}
+
+// startTextPromptFlow remembers the prompt for /prompt, stores it in a fresh state and shows
+// the LoRA selection keyboard.
+// verbose enables per-poll status reports for the resulting generation (admin /vgen).
+func startTextPromptFlow(chatID int64, userID int64, prompt string, verbose bool, deps BotDeps) {
+	if refuseForMaintenance(chatID, userID, 0, deps) {
+		return
+	}
+	rememberPrompt(userID, prompt, deps)
+	startLoraSelection(&UserState{
+		UserID:          userID,
+		ChatID:          chatID,
+		OriginalCaption: prompt,
+		SelectedLoras:   []string{},
+		Verbose:         verbose,
+	}, deps)
+}
+
+// startLoraSelection sends the "select LoRA" message, then stores newState and shows the
+// LoRA keyboard on that message via showLoraSelection.
+func startLoraSelection(newState *UserState, deps BotDeps) {
+	userID := newState.UserID
+	chatID := newState.ChatID
 	userLang := getUserLanguagePreference(userID, deps)
+	clearQuickRepeatKeyboard(chatID, userID, deps)
 
 	// Send message indicating LoRA selection will start
 	waitMsg := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "text_prompt_received"))
@@ -295,26 +488,41 @@ func HandleTextMessage(message *tgbotapi.Message, deps BotDeps) {
 	}
 
 	// Set state and show LoRA selection
-	newState := &UserState{
-		UserID:          userID,
-		ChatID:          chatID,
-		MessageID:       msgIDForKeyboard,
-		Action:          "awaiting_lora_selection",
-		OriginalCaption: message.Text,
-		SelectedLoras:   []string{},
-	}
-	deps.StateManager.SetState(userID, newState)
+	newState.MessageID = msgIDForKeyboard
 
 	// Edit the bot's message (if sent successfully) to show LoRA keyboard
 	if msgIDForKeyboard != 0 {
 		// SendLoraSelectionKeyboard now handles its own ParseMode
-		SendLoraSelectionKeyboard(chatID, msgIDForKeyboard, newState, deps, true)
+		showLoraSelection(chatID, msgIDForKeyboard, newState, deps, true)
 	} else {
 		// Fallback if sending waitMsg failed? Maybe send a new message with keyboard.
 		deps.Logger.Warn(deps.I18n.T(userLang, "text_warn_keyboard_new_msg"), zap.Int64("user_id", userID))
 		// deps.Logger.Warn("Could not send wait message, sending keyboard as new message", zap.Int64("user_id", userID))
-		SendLoraSelectionKeyboard(chatID, 0, newState, deps, false) // Send as new message
+		showLoraSelection(chatID, 0, newState, deps, false) // Send as new message
+	}
+}
+
+// HandleVerboseGenerateCommand handles the admin /vgen command. It starts the normal
+// prompt flow, but the resulting generation reports every poll's status and fal's timings.
+func HandleVerboseGenerateCommand(message *tgbotapi.Message, deps BotDeps) {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+	userLang := getUserLanguagePreference(userID, deps)
+
+	if !deps.Authorizer.IsAdmin(userID) {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "myconfig_command_admin_only")))
+		return
 	}
+
+	prompt := strings.TrimSpace(message.CommandArguments())
+	if prompt == "" {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "vgen_usage")))
+		return
+	}
+
+	deps.StateManager.ClearState(userID)
+	deps.Logger.Info("Admin started verbose generation", zap.Int64("user_id", userID))
+	startTextPromptFlow(chatID, userID, prompt, true, deps)
 }
 
 // HandleStartCommand handles the /start command.
@@ -338,8 +546,8 @@ func HandleBalanceCommand(message *tgbotapi.Message, deps BotDeps) {
 			reply := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "error_generic"))
 			deps.Bot.Send(reply)
 		} else {
-			formattedBalance := fmt.Sprintf("%.2f", balance)
-			reply := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "balance_current", "balance", formattedBalance))
+			formattedBalance := formatBalance(balance, userLang, deps)
+			reply := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "balance_current", "balance", formattedBalance)+balanceReserveNote(userLang, deps))
 			deps.Bot.Send(reply)
 		}
 	} else {
@@ -355,7 +563,7 @@ func HandleBalanceCommand(message *tgbotapi.Message, deps BotDeps) {
 				deps.Logger.Error("Failed to send admin balance message", zap.Error(err), zap.Int64("user_id", userID))
 				return
 			}
-			balance, err := deps.FalClient.GetAccountBalance()
+			balance, _, err := deps.FalBalance.Balance()
 			if err != nil {
 				deps.Logger.Error("Failed to get account balance", zap.Error(err), zap.Int64("user_id", userID))
 				edit := tgbotapi.NewEditMessageText(chatID, msg.MessageID, deps.I18n.T(userLang, "balance_admin_fetch_failed", "error", err.Error()))
@@ -384,9 +592,9 @@ func HandleLorasCommand(chatID int64, userID int64, deps BotDeps) {
 		loraList.WriteString(deps.I18n.T(userLang, "loras_none_available"))
 	}
 
-	if deps.Authorizer.IsAdmin(userID) && len(deps.BaseLoRA) > 0 {
+	if deps.Authorizer.IsAdmin(userID) && len(deps.BaseLoRAs()) > 0 {
 		loraList.WriteString(deps.I18n.T(userLang, "loras_base_title_admin") + "\n")
-		for _, lora := range deps.BaseLoRA {
+		for _, lora := range deps.BaseLoRAs() {
 			loraList.WriteString(deps.I18n.T(userLang, "loras_item", "name", lora.Name) + "\n")
 		}
 	}
@@ -475,6 +683,7 @@ func HandleCancelCommand(message *tgbotapi.Message, deps BotDeps) {
 	chatID := message.Chat.ID
 	userLang := getUserLanguagePreference(userID, deps) // Get user lang
 
+	batchCancelled := deps.Batches.Cancel(userID)
 	state, exists := deps.StateManager.GetState(userID)
 	if exists {
 		deps.StateManager.ClearState(userID)
@@ -487,12 +696,41 @@ func HandleCancelCommand(message *tgbotapi.Message, deps BotDeps) {
 			reply := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "cancel_success"))
 			deps.Bot.Send(reply)
 		}
+	} else if batchCancelled {
+		reply := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "batch_cancel_requested"))
+		deps.Bot.Send(reply)
 	} else {
 		reply := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "cancel_failed"))
 		deps.Bot.Send(reply)
 	}
 }
 
+// HandleStopCommand handles /stop: it cancels every generation and caption job of the user,
+// including a running /batch, and clears their state. executeAndPollRequest refunds the
+// charges of generations that didn't finish.
+func HandleStopCommand(chatID int64, userID int64, deps BotDeps) {
+	userLang := getUserLanguagePreference(userID, deps)
+
+	stopped := 0
+	if deps.Batches.Cancel(userID) { // First, so the batch can't start another prompt
+		stopped++
+	}
+	stopped += deps.Inflight.StopUser(userID)
+	if state, exists := deps.StateManager.GetState(userID); exists {
+		if state.Action == "captioning" {
+			stopped++
+		}
+		deps.StateManager.ClearState(userID) // Also cancels captioning
+	}
+
+	deps.Logger.Info("User stopped all jobs via /stop", zap.Int64("user_id", userID), zap.Int("stopped", stopped))
+	if stopped == 0 {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "stop_nothing")))
+		return
+	}
+	deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "stop_success", "count", stopped)))
+}
+
 // HandleHelpCommand sends the help message.
 func HandleHelpCommand(chatID int64, deps BotDeps) {
 	// Adjusted help text for ModeMarkdown (escape * and `)
@@ -515,6 +753,13 @@ func HandleHelpCommand(chatID int64, deps BotDeps) {
 		deps.I18n.T(userLang, "help_command_balance"),
 		deps.I18n.T(userLang, "help_command_version"),
 		deps.I18n.T(userLang, "help_command_cancel"),
+		deps.I18n.T(userLang, "help_command_stop"),
+		deps.I18n.T(userLang, "help_command_last"),
+		deps.I18n.T(userLang, "help_command_setdefaultloras"),
+		deps.I18n.T(userLang, "help_command_presets"),
+		deps.I18n.T(userLang, "help_command_exportconfig"),
+		deps.I18n.T(userLang, "help_command_eta"),
+		deps.I18n.T(userLang, "help_command_support"),
 		deps.I18n.T(userLang, "help_command_set"),
 		"", // Empty line
 		deps.I18n.T(userLang, "help_flow_title"),
@@ -547,7 +792,7 @@ func HandleLogCommand(chatID int64, userID int64, deps BotDeps) {
 	}
 
 	// 2. Check if file logging is enabled (by checking if the path is set)
-	logFilePath := deps.Config.LogConfig.File
+	logFilePath := deps.Cfg().LogConfig.File
 	if logFilePath == "" {
 		reply := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "log_file_disabled"))
 		deps.Bot.Send(reply)
@@ -580,7 +825,7 @@ func HandleShortLogCommand(chatID int64, userID int64, deps BotDeps) {
 	}
 
 	// 2. Check if file logging is enabled (by checking if the path is set)
-	logFilePath := deps.Config.LogConfig.File
+	logFilePath := deps.Cfg().LogConfig.File
 	if logFilePath == "" {
 		reply := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "log_file_disabled"))
 		deps.Bot.Send(reply)
@@ -729,3 +974,51 @@ func HandleAdminBalanceInput(message *tgbotapi.Message, state *UserState, deps B
 	}
 	HandleSetCommand(syntheticMsg, deps)
 }
+
+// maxMissingKeysListed caps how many missing keys /i18nstatus prints per language.
+const maxMissingKeysListed = 30
+
+// HandleI18nStatusCommand handles the admin /i18nstatus command, reporting the
+// translation coverage of every language against the default language.
+func HandleI18nStatusCommand(chatID int64, userID int64, deps BotDeps) {
+	userLang := getUserLanguagePreference(userID, deps)
+
+	if !deps.Authorizer.IsAdmin(userID) {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "myconfig_command_admin_only")))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(deps.I18n.T(userLang, "i18nstatus_title", "default", deps.I18n.GetDefaultLanguageTag().String()))
+	for _, lang := range deps.I18n.CoverageReport() {
+		percent := 100.0
+		if lang.Total > 0 {
+			percent = float64(lang.Total-len(lang.Missing)) / float64(lang.Total) * 100
+		}
+		sb.WriteString(deps.I18n.T(userLang, "i18nstatus_language",
+			"code", lang.Code,
+			"defined", lang.Defined,
+			"total", lang.Total,
+			"percent", fmt.Sprintf("%.1f", percent),
+			"missingCount", len(lang.Missing),
+		))
+		listed := lang.Missing
+		if len(listed) > maxMissingKeysListed {
+			listed = listed[:maxMissingKeysListed]
+		}
+		for _, key := range listed {
+			sb.WriteString("\n  - " + key)
+		}
+		if len(lang.Missing) > len(listed) {
+			sb.WriteString(deps.I18n.T(userLang, "i18nstatus_more_missing", "count", len(lang.Missing)-len(listed)))
+		}
+	}
+
+	// Sent as plain text: key names contain underscores that Markdown would eat
+	for _, chunk := range splitMarkdownMessage(sb.String(), maxMessageRunes) {
+		if _, err := deps.Bot.Send(tgbotapi.NewMessage(chatID, chunk)); err != nil {
+			deps.Logger.Warn("Failed to send i18n status", zap.Error(err), zap.Int64("user_id", userID))
+			return
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// healthCheckTimeout bounds each dependency check of a probe.
+const healthCheckTimeout = 3 * time.Second
+
+// healthChecker serves /healthz and /readyz for container liveness and readiness probes.
+type healthChecker struct {
+	db         *sql.DB
+	falBaseURL string
+	httpClient *http.Client
+	updates    atomic.Bool // Whether the update loop is running
+}
+
+// dependencyStatus is the result of one check in a health response.
+type dependencyStatus struct {
+	Status string `json:"status"` // "ok" or "fail"
+	Error  string `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status string                      `json:"status"` // "ok" if every check passed, else "fail"
+	Checks map[string]dependencyStatus `json:"checks"`
+}
+
+func newHealthChecker(db *sql.DB, falBaseURL string) *healthChecker {
+	return &healthChecker{
+		db:         db,
+		falBaseURL: falBaseURL,
+		httpClient: &http.Client{Timeout: healthCheckTimeout},
+	}
+}
+
+// SetUpdatesRunning records whether the update loop is consuming Telegram updates.
+func (h *healthChecker) SetUpdatesRunning(running bool) {
+	h.updates.Store(running)
+}
+
+// checkDB pings the database.
+func (h *healthChecker) checkDB(ctx context.Context) error {
+	return h.db.PingContext(ctx)
+}
+
+// checkFal reports whether the fal base URL answers at all; any HTTP status counts, since
+// the queue host has no unauthenticated health route.
+func (h *healthChecker) checkFal(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, h.falBaseURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// check runs the liveness checks, plus the update loop check if ready is set.
+func (h *healthChecker) check(ctx context.Context, ready bool) healthResponse {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	checks := map[string]error{
+		"database": h.checkDB(ctx),
+		"fal":      h.checkFal(ctx),
+		"telegram": nil, // The bot token is validated at startup; StartBot exits if it is invalid
+	}
+	if ready {
+		checks["updates"] = nil
+		if !h.updates.Load() {
+			checks["updates"] = errors.New("update loop is not running")
+		}
+	}
+
+	resp := healthResponse{Status: "ok", Checks: make(map[string]dependencyStatus, len(checks))}
+	for name, err := range checks {
+		if err != nil {
+			resp.Status = "fail"
+			resp.Checks[name] = dependencyStatus{Status: "fail", Error: err.Error()}
+		} else {
+			resp.Checks[name] = dependencyStatus{Status: "ok"}
+		}
+	}
+	return resp
+}
+
+func (h *healthChecker) handler(ready bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := h.check(r.Context(), ready)
+		w.Header().Set("Content-Type", "application/json")
+		if resp.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// startHealthServer serves /healthz (database, fal and Telegram) and /readyz (additionally
+// the update loop) on listenAddr in the background.
+func startHealthServer(listenAddr string, checker *healthChecker, logger *zap.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", checker.handler(false))
+	mux.Handle("/readyz", checker.handler(true))
+	server := &http.Server{Addr: listenAddr, Handler: mux}
+	go func() {
+		logger.Info("Starting health endpoint", zap.String("listen_addr", listenAddr))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("Health endpoint stopped", zap.Error(err))
+		}
+	}()
+}
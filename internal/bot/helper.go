@@ -3,27 +3,65 @@ package bot
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+	fapi "github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
 	"go.uber.org/zap"
 )
 
+// imageSizeLimitError reports an image size larger than apiEndpoints.maxImagePixels.
+type imageSizeLimitError struct {
+	Size      string
+	Pixels    int
+	MaxPixels int
+}
+
+func (e *imageSizeLimitError) Error() string {
+	return fmt.Sprintf("image size %s has %d pixels, model limit is %d", e.Size, e.Pixels, e.MaxPixels)
+}
+
+// checkImageSizeLimit validates size against maxPixels (0 = no limit). Named sizes are
+// resolved via fapi.ImageSizePresets; custom sizes are checked by width*height.
+// Unknown names pass, leaving the decision to fal.
+func checkImageSizeLimit(size string, custom *fapi.ImageSize, maxPixels int) error {
+	if maxPixels <= 0 {
+		return nil
+	}
+	dims, ok := fapi.ImageSizePresets[size]
+	if custom != nil {
+		dims, ok = *custom, true
+		size = fmt.Sprintf("%dx%d", custom.Width, custom.Height)
+	}
+	if !ok {
+		return nil
+	}
+	if pixels := dims.Width * dims.Height; pixels > maxPixels {
+		return &imageSizeLimitError{Size: size, Pixels: pixels, MaxPixels: maxPixels}
+	}
+	return nil
+}
+
 // GetUserVisibleLoras determines which LoRAs are visible to a specific user based on config.
 func GetUserVisibleLoras(userID int64, deps BotDeps) []LoraConfig {
 	// Admins see all standard LoRAs defined in the main list
 	if deps.Authorizer.IsAdmin(userID) {
-		return deps.LoRA
+		return deps.StandardLoRAs()
 	}
 
 	// If config is nil or sections are missing, return empty (or handle error)
-	if deps.Config == nil {
+	if deps.Cfg() == nil {
 		deps.Logger.Error("Config is nil in GetUserVisibleLoras")
 		return []LoraConfig{}
 	}
 
 	// 1. Find all groups the user belongs to
 	userGroupSet := make(map[string]struct{}) // Use a set for efficient lookup
-	for _, group := range deps.Config.UserGroups {
+	for _, group := range deps.Cfg().UserGroups {
 		for _, id := range group.UserIDs {
 			if id == userID {
 				userGroupSet[group.Name] = struct{}{}
@@ -34,7 +72,10 @@ func GetUserVisibleLoras(userID int64, deps BotDeps) []LoraConfig {
 
 	// 2. Filter LoRAs based on AllowGroups
 	visibleLoras := []LoraConfig{}
-	for _, lora := range deps.LoRA { // Iterate through standard LoRAs
+	for _, lora := range deps.StandardLoRAs() { // Iterate through standard LoRAs
+		if deps.LoraHealth.IsDisabled(lora.Name) {
+			continue // Failing repeatedly, see LoraHealth
+		}
 		// Case 1: AllowGroups is empty - LoRA is public to all authorized users
 		if len(lora.AllowGroups) == 0 {
 			visibleLoras = append(visibleLoras, lora)
@@ -69,7 +110,7 @@ func findLoraByID(loraID string, allLoras []LoraConfig) LoraConfig {
 		}
 	}
 	// Also check BaseLoRA if needed, or handle separately
-	// for _, lora := range deps.BaseLoRA { ... }
+	// for _, lora := range deps.BaseLoRAs() { ... }
 	return LoraConfig{} // Return empty if not found
 }
 
@@ -84,6 +125,67 @@ func findLoraByName(name string, loras []LoraConfig) (LoraConfig, bool) {
 	return LoraConfig{}, false
 }
 
+// maxLoraDetails is how many LoRAs the generation confirmation lists before collapsing the rest.
+const maxLoraDetails = 6
+
+// loraDetailsText lists the weight of each selected LoRA and whether it appends prompt text,
+// for the generation confirmation. Base LoRAs follow the standard ones.
+func loraDetailsText(standardNames, baseNames []string, userLang *string, deps BotDeps) string {
+	type detail struct {
+		name string
+		base bool
+	}
+	var details []detail
+	for _, name := range standardNames {
+		details = append(details, detail{name: name})
+	}
+	for _, name := range baseNames {
+		details = append(details, detail{name: name, base: true})
+	}
+	if len(details) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(deps.I18n.T(userLang, "lora_details_header"))
+	for i, d := range details {
+		if len(details) > maxLoraDetails && i == maxLoraDetails-1 {
+			b.WriteString(deps.I18n.T(userLang, "lora_details_more", "count", len(details)-i))
+			break
+		}
+		list := deps.StandardLoRAs()
+		if d.base {
+			list = deps.BaseLoRAs()
+		}
+		lora, found := findLoraByName(d.name, list)
+		if !found {
+			continue
+		}
+		b.WriteString(deps.I18n.T(userLang, "lora_details_item", "name", lora.Name, "weight", strconv.FormatFloat(lora.Weight, 'f', -1, 64)))
+		if d.base {
+			b.WriteString(deps.I18n.T(userLang, "lora_details_base_tag"))
+		}
+		if lora.AppendPrompt != "" {
+			b.WriteString(deps.I18n.T(userLang, "lora_details_append_tag"))
+		}
+	}
+	return b.String()
+}
+
+// firstContactLanguage returns the language for a message to user, who may never have
+// used the bot: their saved preference, else their Telegram client language if the bot
+// has a translation for it, else nil for the default language.
+func firstContactLanguage(user *tgbotapi.User, deps BotDeps) *string {
+	if userLang := getUserLanguagePreference(user.ID, deps); userLang != nil {
+		return userLang
+	}
+	code, _, _ := strings.Cut(strings.ToLower(user.LanguageCode), "-") // e.g. "zh-hans"
+	if _, ok := deps.I18n.GetAvailableLanguages()[code]; ok {
+		return &code
+	}
+	return nil
+}
+
 // getUserLanguagePreference retrieves the user's preferred language code.
 // Returns nil if no preference is set or an error occurs, allowing fallback to default.
 func getUserLanguagePreference(userID int64, deps BotDeps) *string {
@@ -116,10 +218,10 @@ func getUserLanguagePreference(userID int64, deps BotDeps) *string {
 // Helper to get user groups (can be moved to a more suitable place like auth or utils)
 func GetUserGroups(userID int64, deps BotDeps) map[string]struct{} {
 	userGroupSet := make(map[string]struct{})
-	if deps.Config == nil || deps.Config.UserGroups == nil {
+	if deps.Cfg() == nil || deps.Cfg().UserGroups == nil {
 		return userGroupSet // Return empty set if config is missing
 	}
-	for _, group := range deps.Config.UserGroups {
+	for _, group := range deps.Cfg().UserGroups {
 		for _, id := range group.UserIDs {
 			if id == userID {
 				userGroupSet[group.Name] = struct{}{}
@@ -137,3 +239,280 @@ func truncateID(id string) string {
 	}
 	return id
 }
+
+// truncateRunes shortens s to at most max runes, appending "..." when cut.
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max]) + "..."
+}
+
+// maxMessageRunes is the length Markdown texts are split at, below Telegram's 4096
+// characters to leave room for closing and reopening code blocks.
+const maxMessageRunes = 4000
+
+// splitMarkdownMessage splits a Markdown text into chunks of at most limit runes, breaking
+// at line ends where possible and at spaces within overlong lines. A code block or `code`
+// span cut by a break is closed at the end of its chunk and reopened in the next, so every
+// chunk renders on its own.
+func splitMarkdownMessage(text string, limit int) []string {
+	if utf8.RuneCountInString(text) <= limit {
+		return []string{text}
+	}
+	room := limit - 8 // Keeps space for closing and reopening a code block
+	var chunks []string
+	var current strings.Builder
+	currentLen := 0
+	inBlock := false
+	flush := func() {
+		if currentLen == 0 {
+			return
+		}
+		chunk := strings.TrimRight(current.String(), "\n")
+		if inBlock {
+			chunk += "\n```"
+		}
+		chunks = append(chunks, chunk)
+		current.Reset()
+		currentLen = 0
+		if inBlock {
+			current.WriteString("```\n")
+			currentLen = 4
+		}
+	}
+	for _, line := range strings.SplitAfter(text, "\n") {
+		for _, piece := range splitLongLine(line, room, inBlock) {
+			pieceLen := utf8.RuneCountInString(piece)
+			if currentLen+pieceLen > room {
+				flush()
+			}
+			current.WriteString(piece)
+			currentLen += pieceLen
+		}
+		if strings.Count(line, "```")%2 == 1 {
+			inBlock = !inBlock
+		}
+	}
+	flush()
+	return chunks
+}
+
+// splitLongLine cuts a line longer than limit runes into pieces, preferably after a space.
+// Outside code blocks, a `code` span cut in two is closed and reopened.
+func splitLongLine(line string, limit int, inBlock bool) []string {
+	var pieces []string
+	reopen := ""
+	for utf8.RuneCountInString(reopen+line) > limit {
+		runes := []rune(reopen + line)
+		cut := limit - 1 // Keeps space for a closing backtick
+		if i := strings.LastIndex(string(runes[:cut]), " "); i > 0 {
+			cut = utf8.RuneCountInString(string(runes[:cut])[:i+1])
+		}
+		piece := string(runes[:cut])
+		line = string(runes[cut:])
+		reopen = ""
+		if !inBlock && strings.Count(strings.ReplaceAll(piece, "```", ""), "`")%2 == 1 {
+			piece += "`"
+			reopen = "`"
+		}
+		pieces = append(pieces, piece)
+	}
+	return append(pieces, reopen+line)
+}
+
+// isMessageNotModified reports whether err is Telegram refusing an edit because the text
+// and keyboard are identical to the current ones.
+func isMessageNotModified(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "message is not modified")
+}
+
+// sendOrEdit sends c, treating an edit that changes nothing as success. This happens when a
+// button press re-renders the same text and keyboard.
+func sendOrEdit(c tgbotapi.Chattable, deps BotDeps) (tgbotapi.Message, error) {
+	msg, err := deps.Bot.Send(c)
+	if isMessageNotModified(err) {
+		return msg, nil
+	}
+	return msg, err
+}
+
+// sendMarkdownMessages sends text as Markdown, split into several messages if it is too
+// long for one. Failed sends are retried with retrySend; it stops at the first error that
+// remains and returns the messages sent so far.
+func sendMarkdownMessages(chatID int64, text string, deps BotDeps) ([]tgbotapi.Message, error) {
+	var sent []tgbotapi.Message
+	for _, chunk := range splitMarkdownMessage(text, maxMessageRunes) {
+		msg := tgbotapi.NewMessage(chatID, chunk)
+		msg.ParseMode = tgbotapi.ModeMarkdown
+		m, err := sendWithRetry(chatID, msg, deps)
+		if err != nil {
+			return sent, err
+		}
+		sent = append(sent, m)
+	}
+	return sent, nil
+}
+
+// editMarkdownWithOverflow replaces the text of a status message with Markdown text and
+// removes its keyboard. Text too long for one message continues in new messages.
+func editMarkdownWithOverflow(chatID int64, messageID int, text string, deps BotDeps) {
+	chunks := splitMarkdownMessage(text, maxMessageRunes)
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, chunks[0])
+	edit.ParseMode = tgbotapi.ModeMarkdown
+	edit.ReplyMarkup = nil
+	deps.Edits.EditNow(edit)
+	for _, chunk := range chunks[1:] {
+		msg := tgbotapi.NewMessage(chatID, chunk)
+		msg.ParseMode = tgbotapi.ModeMarkdown
+		if _, err := deps.Bot.Send(msg); err != nil {
+			deps.Logger.Error("Failed to send continuation of a long message", zap.Error(err), zap.Int64("chat_id", chatID))
+			return
+		}
+	}
+}
+
+// partialTranslationThreshold is the coverage below which users are warned about a language.
+const partialTranslationThreshold = 0.95
+
+// warnIfPartialTranslation tells the user (in the newly selected language) when its
+// translation is incomplete, since missing keys are shown as raw message IDs.
+func warnIfPartialTranslation(chatID int64, userID int64, langCode string, deps BotDeps) {
+	coverage := deps.I18n.Coverage(langCode)
+	if coverage >= partialTranslationThreshold {
+		return
+	}
+	missing := deps.I18n.MissingKeys(langCode)
+	deps.Logger.Warn("User selected a partially translated language",
+		zap.Int64("user_id", userID),
+		zap.String("language", langCode),
+		zap.Float64("coverage", coverage),
+		zap.Int("missing_keys", len(missing)),
+	)
+	langName, _ := deps.I18n.GetLanguageName(langCode)
+	deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(&langCode, "language_partial_translation_warning",
+		"langName", langName,
+		"percent", fmt.Sprintf("%.0f", coverage*100),
+		"missing", len(missing),
+	)))
+}
+
+// loadUserConfigOrDefault returns the stored config of userID, or a new one filled with the
+// configured defaults if the user has none yet.
+func loadUserConfigOrDefault(userID int64, deps BotDeps) (*st.UserGenerationConfig, error) {
+	userCfg, err := st.GetUserGenerationConfig(deps.DB, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if userCfg == nil {
+		defaultCfg := deps.Cfg().DefaultGenerationSettings
+		userCfg = &st.UserGenerationConfig{
+			UserID:            userID,
+			ImageSize:         defaultCfg.ImageSize,
+			NumInferenceSteps: defaultCfg.NumInferenceSteps,
+			GuidanceScale:     defaultCfg.GuidanceScale,
+			NumImages:         defaultCfg.NumImages,
+			Language:          deps.Cfg().DefaultLanguage,
+		}
+	}
+	return userCfg, nil
+}
+
+// chargesUser reports whether generations of userID are checked against and deducted from
+// their balance. Admins are free if balance.adminsFree is set.
+func chargesUser(userID int64, deps BotDeps) bool {
+	if deps.BalanceManager == nil {
+		return false
+	}
+	return !deps.Cfg().Balance.AdminsFree || !deps.Authorizer.IsAdmin(userID)
+}
+
+// balanceReserveNote returns the note appended to balance messages that part of every
+// balance is reserved (balance.minReserve), or "" without a reserve.
+func balanceReserveNote(userLang *string, deps BotDeps) string {
+	reserve := deps.BalanceManager.GetReserve()
+	if reserve <= 0 {
+		return ""
+	}
+	return deps.I18n.T(userLang, "balance_reserve_note", "reserve", formatBalance(reserve, userLang, deps))
+}
+
+// warnLowBalance tells userID in chatID, once per drop below balance.lowThreshold, that their
+// balance is running low, with how many generations are left and where to top up.
+func warnLowBalance(chatID int64, userID int64, deps BotDeps) {
+	balance, low, err := deps.BalanceManager.MarkLowBalance(userID)
+	if err != nil {
+		deps.Logger.Warn("Failed to check for low balance", zap.Error(err), zap.Int64("user_id", userID))
+		return
+	}
+	if !low {
+		return
+	}
+	userLang := getUserLanguagePreference(userID, deps)
+	remaining := 0
+	cost := deps.BalanceManager.GetCost()
+	if spendable := deps.BalanceManager.Spendable(balance); spendable > 0 && cost > 0 {
+		remaining = int(spendable / cost)
+	}
+	text := deps.I18n.T(userLang, "balance_low_warning", "balance", formatBalance(balance, userLang, deps), "count", remaining)
+	if contact := deps.Cfg().Support.Contact; contact != "" {
+		text += deps.I18n.T(userLang, "balance_low_topup_contact", "contact", contact)
+	} else {
+		text += deps.I18n.T(userLang, "balance_low_topup_support")
+	}
+	deps.Logger.Info("Warning user about low balance", zap.Int64("user_id", userID), zap.Float64("balance", balance))
+	deps.Bot.Send(tgbotapi.NewMessage(chatID, text))
+}
+
+// formatBalance formats a balance or cost amount with the configured unit in the user's
+// language, e.g. "5.00 credits". Without a configured unit the translated "points" is used.
+func formatBalance(amount float64, userLang *string, deps BotDeps) string {
+	unitCfg := deps.Cfg().Balance
+	lang := deps.Cfg().DefaultLanguage
+	if userLang != nil && *userLang != "" {
+		lang = *userLang
+	}
+
+	name, plural := unitCfg.UnitName, unitCfg.UnitNamePlural
+	if unit, ok := unitCfg.Units[lang]; ok {
+		name, plural = unit.Name, unit.NamePlural
+	}
+	if name == "" {
+		name = deps.I18n.T(userLang, "balance_unit_one")
+		plural = deps.I18n.T(userLang, "balance_unit_other")
+	}
+	if plural == "" {
+		plural = name
+	}
+
+	formatted := fmt.Sprintf("%.2f", amount)
+	if formatted == "1.00" {
+		return formatted + " " + name
+	}
+	return formatted + " " + plural
+}
+
+// chatMemberNames returns how userIDs are shown in chatID: their @username, else their first
+// name, else their ID. Each user is listed once.
+func chatMemberNames(chatID int64, userIDs []int64, deps BotDeps) []string {
+	var names []string
+	seen := make(map[int64]struct{})
+	for _, userID := range userIDs {
+		if _, dup := seen[userID]; dup {
+			continue
+		}
+		seen[userID] = struct{}{}
+		name := strconv.FormatInt(userID, 10)
+		member, err := deps.Bot.GetChatMember(tgbotapi.GetChatMemberConfig{ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID}})
+		if err != nil {
+			deps.Logger.Debug("Failed to look up chat member name", zap.Error(err), zap.Int64("chat_id", chatID), zap.Int64("user_id", userID))
+		} else if member.User != nil && member.User.UserName != "" {
+			name = "@" + member.User.UserName
+		} else if member.User != nil && member.User.FirstName != "" {
+			name = member.User.FirstName
+		}
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,49 @@
+package bot
+
+import (
+	"encoding/json"
+	"maps"
+
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+	"go.uber.org/zap"
+)
+
+// recordGenerationHistory stores one history entry per successful LoRA combination,
+// including the seed fal used, so the result can be re-run later (e.g. /resize), and the
+// request body sent to fal with that seed filled in, so it can be reproduced exactly.
+// Failures are logged only; history must never block result delivery.
+func recordGenerationHistory(userID int64, params *GenerationParameters, successfulResults []RequestResult, deps BotDeps) {
+	if deps.DB == nil || params == nil {
+		return
+	}
+	for _, res := range successfulResults {
+		if res.Response == nil || len(res.LoraNames) == 0 {
+			continue
+		}
+		entry := st.GenerationHistory{
+			UserID:            userID,
+			Prompt:            params.Prompt,
+			StandardLora:      res.LoraNames[0], // executeAndPollRequest puts the standard LoRA first
+			BaseLoras:         res.LoraNames[1:],
+			ImageSize:         params.ImageSize,
+			NumInferenceSteps: params.NumInferenceSteps,
+			GuidanceScale:     params.GuidanceScale,
+			NumImages:         params.NumImages,
+			Seed:              res.Response.Seed,
+		}
+		if res.Payload != nil {
+			payload := maps.Clone(res.Payload)
+			if _, ok := payload["seed"]; !ok {
+				payload["seed"] = res.Response.Seed // fal picked it
+			}
+			if data, err := json.Marshal(payload); err != nil {
+				deps.Logger.Warn("Failed to encode request payload for history", zap.Error(err), zap.Int64("user_id", userID), zap.String("request_id", res.ReqID))
+			} else {
+				entry.Payload = data
+			}
+		}
+		if _, err := st.AddGenerationHistory(deps.DB, entry); err != nil {
+			deps.Logger.Warn("Failed to record generation history", zap.Error(err), zap.Int64("user_id", userID), zap.String("request_id", res.ReqID))
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png" // Decode PNG photos too
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// downscaleJPEGQuality is the JPEG quality of downscaled photos.
+const downscaleJPEGQuality = 90
+
+// maxInputImageBytes bounds the download of a photo that is downscaled.
+const maxInputImageBytes = 20 << 20 // Telegram's bot download limit
+
+var inputImageClient = &http.Client{Timeout: 30 * time.Second}
+
+// inputImageURL returns the URL to submit to fal for a photo of width x height at fileURL.
+// Photos larger than imageInput.maxDimension are downscaled and returned as a JPEG data
+// URI; on failure the original URL is used, so captioning still works.
+func inputImageURL(fileURL string, width int, height int, deps BotDeps) string {
+	inputCfg := deps.Cfg().ImageInput
+	if !inputCfg.DownscaleEnabled() || max(width, height) <= inputCfg.MaxDimension {
+		return fileURL
+	}
+	dataURI, err := downscaleImageURL(fileURL, inputCfg.MaxDimension)
+	if err != nil {
+		deps.Logger.Warn("Failed to downscale photo, using original", zap.Error(err), zap.Int("width", width), zap.Int("height", height))
+		return fileURL
+	}
+	deps.Logger.Debug("Downscaled photo", zap.Int("width", width), zap.Int("height", height), zap.Int("max_dimension", inputCfg.MaxDimension), zap.Int("data_uri_bytes", len(dataURI)))
+	return dataURI
+}
+
+// downscaleImageURL downloads the image at url and returns a JPEG data URI of it with the
+// longest side scaled to maxDimension.
+func downscaleImageURL(url string, maxDimension int) (string, error) {
+	src, err := downloadImage(url)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, downscaleImage(src, maxDimension), &jpeg.Options{Quality: downscaleJPEGQuality}); err != nil {
+		return "", fmt.Errorf("failed to encode image: %w", err)
+	}
+	return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// downloadImage downloads and decodes the JPEG or PNG image at url.
+func downloadImage(url string) (image.Image, error) {
+	resp, err := inputImageClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image download failed with status %d", resp.StatusCode)
+	}
+	src, _, err := image.Decode(io.LimitReader(resp.Body, maxInputImageBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return src, nil
+}
+
+// downscaleImage scales src so its longest side is maxDimension, keeping the aspect ratio.
+// Each target pixel is the average of the source pixels it covers. Images that already fit
+// are returned unchanged.
+func downscaleImage(src image.Image, maxDimension int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if max(srcW, srcH) <= maxDimension {
+		return src
+	}
+	scale := float64(maxDimension) / float64(max(srcW, srcH))
+	dstW := max(1, int(math.Round(float64(srcW)*scale)))
+	dstH := max(1, int(math.Round(float64(srcH)*scale)))
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := y * srcH / dstH
+		y1 := max(y0+1, (y+1)*srcH/dstH)
+		for x := 0; x < dstW; x++ {
+			x0 := x * srcW / dstW
+			x1 := max(x0+1, (x+1)*srcW/dstW)
+			var r, g, b, a uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(b / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
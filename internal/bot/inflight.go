@@ -0,0 +1,136 @@
+package bot
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxInflightListed caps the requests listed by /inflight; the count covers all of them.
+const maxInflightListed = 20
+
+// inflightRequest is a generation request of a user, from queueing until its result or
+// error arrived. RequestID is empty until the request was submitted to fal.
+type inflightRequest struct {
+	UserID    int64
+	RequestID string
+	Model     string
+	LoraNames []string
+	Started   time.Time
+	cancel    context.CancelFunc
+}
+
+// InflightRegistry tracks the generation requests being queued, polled or awaited via
+// webhook, so /inflight can list them and /stop can cancel them.
+type InflightRegistry struct {
+	mu       sync.Mutex
+	requests map[*inflightRequest]struct{}
+}
+
+func NewInflightRegistry() *InflightRegistry {
+	return &InflightRegistry{requests: make(map[*inflightRequest]struct{})}
+}
+
+// Track registers a request of userID before it is queued. cancel is called if the user
+// stops it.
+func (r *InflightRegistry) Track(userID int64, loraNames []string, cancel context.CancelFunc) *inflightRequest {
+	req := &inflightRequest{
+		UserID:    userID,
+		LoraNames: loraNames,
+		Started:   time.Now(),
+		cancel:    cancel,
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests[req] = struct{}{}
+	return req
+}
+
+// Submitted records the fal request ID of a tracked request.
+func (r *InflightRegistry) Submitted(req *inflightRequest, requestID string, model string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	req.RequestID, req.Model, req.Started = requestID, model, time.Now()
+}
+
+// Finish unregisters a request once its result or error arrived. It reports false if the
+// request was stopped first; StopUser and Finish each take a request only once, so exactly
+// one of them decides its outcome.
+func (r *InflightRegistry) Finish(req *inflightRequest) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.requests[req]; !ok {
+		return false
+	}
+	delete(r.requests, req)
+	return true
+}
+
+// StopUser unregisters and cancels every request of userID and reports how many there were.
+func (r *InflightRegistry) StopUser(userID int64) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stopped := 0
+	for req := range r.requests {
+		if req.UserID == userID {
+			delete(r.requests, req)
+			req.cancel()
+			stopped++
+		}
+	}
+	return stopped
+}
+
+// List returns the requests submitted to fal, oldest first.
+func (r *InflightRegistry) List() []inflightRequest {
+	r.mu.Lock()
+	list := make([]inflightRequest, 0, len(r.requests))
+	for req := range r.requests {
+		if req.RequestID != "" {
+			list = append(list, *req)
+		}
+	}
+	r.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.Before(list[j].Started) })
+	return list
+}
+
+// HandleInflightCommand handles the admin /inflight command, listing the fal requests the
+// bot is currently waiting on with their age.
+func HandleInflightCommand(chatID int64, userID int64, deps BotDeps) {
+	userLang := getUserLanguagePreference(userID, deps)
+	if !deps.Authorizer.IsAdmin(userID) {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "myconfig_command_admin_only")))
+		return
+	}
+
+	requests := deps.Inflight.List()
+	if len(requests) == 0 {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "inflight_none")))
+		return
+	}
+
+	age := func(req inflightRequest) string {
+		return time.Since(req.Started).Truncate(time.Second).String()
+	}
+	var text strings.Builder
+	text.WriteString(deps.I18n.T(userLang, "inflight_title", "count", len(requests), "oldest", age(requests[0]), "reqID", requests[0].RequestID))
+	for i, req := range requests {
+		if i == maxInflightListed {
+			text.WriteString(deps.I18n.T(userLang, "inflight_more", "count", len(requests)-i))
+			break
+		}
+		text.WriteString(deps.I18n.T(userLang, "inflight_item",
+			"age", age(req),
+			"user", req.UserID,
+			"reqID", req.RequestID,
+			"loras", strings.Join(req.LoraNames, "+"),
+		))
+	}
+	deps.Bot.Send(tgbotapi.NewMessage(chatID, text.String()))
+}
@@ -3,12 +3,23 @@ package bot
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	falapi "github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
 	"go.uber.org/zap"
 )
 
 // Helper to send or edit the Lora selection keyboard
+// stateModel returns the generation endpoint state will use: its one-off override if set,
+// else apiEndpoints.fluxLora.
+func stateModel(state *UserState, deps BotDeps) string {
+	if state.Overrides != nil && state.Overrides.Model != "" {
+		return state.Overrides.Model
+	}
+	return deps.Cfg().APIEndpoints.FluxLora
+}
+
 func SendLoraSelectionKeyboard(chatID int64, messageID int, state *UserState, deps BotDeps, edit bool) {
 	// Get LoRAs visible to this user
 	visibleLoras := GetUserVisibleLoras(state.UserID, deps)
@@ -57,6 +68,13 @@ func SendLoraSelectionKeyboard(chatID int64, messageID int, state *UserState, de
 		// rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("无可用 LoRA 风格", "lora_noop")))
 	}
 
+	// --- Model for this generation only, if the operator offers more than one ---
+	if models := deps.Cfg().APIEndpoints.GenerationModels(); len(models) > 1 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "lora_selection_keyboard_model_button", "model", stateModel(state, deps)), "lora_model_next"),
+		))
+	}
+
 	// --- Remove Base LoRA selection from this keyboard ---
 	// Base LoRAs are selected in the next step (SendBaseLoraSelectionKeyboard)
 
@@ -102,6 +120,15 @@ func SendLoraSelectionKeyboard(chatID int64, messageID int, state *UserState, de
 	escapedCaption = strings.ReplaceAll(escapedCaption, "_", "\\_") // Escape underscores
 
 	loraPromptBuilder.WriteString(deps.I18n.T(userLang, "lora_selection_keyboard_prompt_suffix", "prompt", escapedCaption))
+	if tags := userPromptTags(state.UserID, deps.Cfg()); tags.Prefix != "" || tags.Suffix != "" {
+		tagText := func(text string) string { // Shown in inline code, which can't contain backticks
+			if text = strings.TrimSpace(text); text == "" {
+				return "-"
+			}
+			return strings.ReplaceAll(text, "`", "'")
+		}
+		loraPromptBuilder.WriteString(deps.I18n.T(userLang, "lora_selection_keyboard_prompt_tags", "prefix", tagText(tags.Prefix), "suffix", tagText(tags.Suffix)))
+	}
 	// loraPromptBuilder.WriteString(":\nPrompt: ```\n")
 	// loraPromptBuilder.WriteString(escapedCaption) // Use escaped version
 	// loraPromptBuilder.WriteString("\n```")
@@ -123,17 +150,38 @@ func SendLoraSelectionKeyboard(chatID int64, messageID int, state *UserState, de
 		msg = newMsg
 	}
 
-	if _, err := deps.Bot.Send(msg); err != nil {
+	if _, err := sendOrEdit(msg, deps); err != nil {
 		deps.Logger.Error("Failed to send/edit Lora selection keyboard", zap.Error(err), zap.Int64("user_id", state.UserID))
 	}
 }
 
+// showLoraSelection stores state for LoRA selection and shows the LoRA keyboard. With
+// autoSelectSingleLora set, a user who can see only one LoRA gets it selected and goes
+// straight to the Base LoRA and confirm step. Otherwise the user's default LoRAs start out
+// selected if nothing is selected yet.
+func showLoraSelection(chatID int64, messageID int, state *UserState, deps BotDeps, edit bool) {
+	if visible := GetUserVisibleLoras(state.UserID, deps); deps.Cfg().AutoSelectSingleLora && len(visible) == 1 {
+		deps.Logger.Debug("Auto-selecting the only visible LoRA", zap.Int64("user_id", state.UserID), zap.String("lora", visible[0].Name))
+		state.SelectedLoras = []string{visible[0].Name}
+		state.Action = "awaiting_base_lora_selection"
+		deps.StateManager.SetState(state.UserID, state)
+		SendBaseLoraSelectionKeyboard(chatID, messageID, state, deps, edit)
+		return
+	}
+	if len(state.SelectedLoras) == 0 {
+		state.SelectedLoras = userDefaultLoras(state.UserID, deps)
+	}
+	state.Action = "awaiting_lora_selection"
+	deps.StateManager.SetState(state.UserID, state)
+	SendLoraSelectionKeyboard(chatID, messageID, state, deps, edit)
+}
+
 // SendBaseLoraSelectionKeyboard sends or edits the message for selecting a single Base LoRA.
 func SendBaseLoraSelectionKeyboard(chatID int64, messageID int, state *UserState, deps BotDeps, edit bool) {
 	// Determine visible Base LoRAs (e.g., only for admins, or based on groups)
 	visibleBaseLoras := []LoraConfig{}
 	if deps.Authorizer.IsAdmin(state.UserID) {
-		visibleBaseLoras = deps.BaseLoRA // Admins can select from all base LoRAs
+		visibleBaseLoras = deps.BaseLoRAs() // Admins can select from all base LoRAs
 		deps.Logger.Debug("Admin user, showing all base LoRAs for selection", zap.Int64("user_id", state.UserID), zap.Int("count", len(visibleBaseLoras)))
 	} else {
 		deps.Logger.Debug("Non-admin user, not showing base LoRAs for explicit selection", zap.Int64("user_id", state.UserID))
@@ -146,7 +194,7 @@ func SendBaseLoraSelectionKeyboard(chatID int64, messageID int, state *UserState
 
 	// Build prompt text using i18n
 	promptBuilder.WriteString(deps.I18n.T(userLang, "base_lora_selection_keyboard_selected_standard", "selection", fmt.Sprintf("`%s`", strings.Join(state.SelectedLoras, "`, `"))))
-	maxLoras := deps.Config.APIEndpoints.MaxLoras
+	maxLoras := deps.Cfg().APIEndpoints.MaxLoras
 	if maxLoras <= 0 {
 		maxLoras = 2
 	}
@@ -154,6 +202,20 @@ func SendBaseLoraSelectionKeyboard(chatID int64, messageID int, state *UserState
 	if len(state.SelectedBaseLoras) > 0 {
 		promptBuilder.WriteString(deps.I18n.T(userLang, "base_lora_selection_keyboard_current_base", "name", strings.Join(state.SelectedBaseLoras, ", ")))
 	}
+	size, steps := quickAdjustValues(state, deps)
+	promptBuilder.WriteString(deps.I18n.T(userLang, "base_lora_selection_keyboard_params", "size", size, "steps", steps))
+	if state.Sweep {
+		promptBuilder.WriteString(deps.I18n.T(userLang, "base_lora_selection_keyboard_params_sweep"))
+	} else if oneImagePerLora(state) {
+		promptBuilder.WriteString(deps.I18n.T(userLang, "base_lora_selection_keyboard_params_one_image"))
+	}
+	if state.Overrides != nil && *state.Overrides != (GenerationOverrides{}) {
+		promptBuilder.WriteString(deps.I18n.T(userLang, "base_lora_selection_keyboard_params_one_off"))
+	}
+	if _, loraName := loraPreferredImageSize(state, deps); loraName != "" {
+		promptBuilder.WriteString(deps.I18n.T(userLang, "base_lora_selection_keyboard_params_lora_size", "name", loraName))
+	}
+	promptBuilder.WriteString(generationEstimateLine(state, userLang, deps))
 
 	// --- Base LoRA Buttons --- // Use I18n for button text
 	currentRow := []tgbotapi.InlineKeyboardButton{}
@@ -195,6 +257,25 @@ func SendBaseLoraSelectionKeyboard(chatID int64, messageID int, state *UserState
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 		tgbotapi.NewInlineKeyboardButtonData(skipButtonText, "base_lora_skip"), // Callback remains the same
 	))
+	// One-off size/steps tweaks for this generation only
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "base_lora_selection_keyboard_steps_down_button"), "base_lora_adjust_steps_down"),
+		tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "base_lora_selection_keyboard_steps_up_button"), "base_lora_adjust_steps_up"),
+		tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "base_lora_selection_keyboard_size_button"), "base_lora_adjust_size"),
+	))
+	oneImageText := deps.I18n.T(userLang, "base_lora_selection_keyboard_one_image_button")
+	if oneImagePerLora(state) {
+		oneImageText = deps.I18n.T(userLang, "button_checkmark") + " " + oneImageText
+	}
+	optionRow := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(oneImageText, "base_lora_one_image"))
+	if deps.Authorizer.IsAdmin(state.UserID) {
+		safetyKey := "base_lora_selection_keyboard_safety_off_button"
+		if safetyCheckerEnabled(state, deps) {
+			safetyKey = "base_lora_selection_keyboard_safety_on_button"
+		}
+		optionRow = append(optionRow, tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, safetyKey), "base_lora_toggle_safety"))
+	}
+	rows = append(rows, optionRow)
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 		tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "base_lora_selection_keyboard_confirm_button"), "lora_confirm_generate"),
 		tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "base_lora_selection_keyboard_cancel_button"), "base_lora_cancel"),
@@ -219,7 +300,128 @@ func SendBaseLoraSelectionKeyboard(chatID int64, messageID int, state *UserState
 		msg = newMsg
 	}
 
-	if _, err := deps.Bot.Send(msg); err != nil {
+	if _, err := sendOrEdit(msg, deps); err != nil {
 		deps.Logger.Error("Failed to send/edit Base LoRA selection keyboard", zap.Error(err), zap.Int64("user_id", state.UserID))
 	}
 }
+
+// generationEstimateLine tells how long the generation of state will roughly take, given
+// the concurrency limit: e.g. 5 LoRAs with 2 at a time run in 3 rounds. The prompts of a
+// /batch run one after another. It is empty for a single request.
+func generationEstimateLine(state *UserState, userLang *string, deps BotDeps) string {
+	requests := len(state.SelectedLoras)
+	if state.GridSize > 0 {
+		requests = state.GridSize
+	}
+	prompts := max(1, len(state.BatchPrompts))
+	if requests*prompts <= 1 {
+		return ""
+	}
+	rounds, wait := estimateGeneration(requests, stateModel(state, deps), deps)
+	rounds, wait = rounds*prompts, wait*time.Duration(prompts)
+	if rounds == 1 {
+		return deps.I18n.T(userLang, "base_lora_selection_keyboard_estimate_parallel", "count", requests, "wait", formatTypicalWait(wait))
+	}
+	return deps.I18n.T(userLang, "base_lora_selection_keyboard_estimate_rounds", "count", requests*prompts, "rounds", rounds, "wait", formatTypicalWait(wait))
+}
+
+// quickAdjustStep is how many inference steps the confirm screen's -/+ buttons change.
+const quickAdjustStep = 5
+
+// quickAdjustValues returns the image size and inference steps the next generation of state
+// will use: its one-off overrides, else the user's config or the defaults.
+func quickAdjustValues(state *UserState, deps BotDeps) (string, int) {
+	size, steps := deps.Cfg().DefaultGenerationSettings.ImageSize, deps.Cfg().DefaultGenerationSettings.NumInferenceSteps
+	if userCfg, err := loadUserConfigOrDefault(state.UserID, deps); err == nil {
+		size, steps = userCfg.ImageSize, userCfg.NumInferenceSteps
+	}
+	if preferred, _ := loraPreferredImageSize(state, deps); preferred != "" {
+		size = preferred
+	}
+	if o := state.Overrides; o != nil {
+		if o.ImageSize != "" {
+			size = o.ImageSize
+		}
+		if o.NumInferenceSteps > 0 {
+			steps = o.NumInferenceSteps
+		}
+	}
+	return size, steps
+}
+
+// applyQuickAdjust changes the one-off size or steps override of state for a
+// base_lora_adjust_* action. It returns false if the value is already at its limit.
+func applyQuickAdjust(state *UserState, action string, deps BotDeps) bool {
+	size, steps := quickAdjustValues(state, deps)
+	switch action {
+	case "steps_down", "steps_up":
+		newSteps := steps - quickAdjustStep
+		if action == "steps_up" {
+			newSteps = steps + quickAdjustStep
+		}
+		newSteps = max(1, min(deps.Cfg().Limits.MaxInferenceSteps, newSteps))
+		if newSteps == steps {
+			return false
+		}
+		steps = newSteps
+	case "size":
+		// Cycle through the offered sizes, skipping those over the model's pixel limit
+		start := 0
+		for i, option := range falapi.ImageSizePresetNames {
+			if option == size {
+				start = i + 1
+				break
+			}
+		}
+		next := ""
+		for i := 0; i < len(falapi.ImageSizePresetNames); i++ {
+			option := falapi.ImageSizePresetNames[(start+i)%len(falapi.ImageSizePresetNames)]
+			if option != size && checkImageSizeLimit(option, nil, deps.Cfg().APIEndpoints.MaxImagePixels) == nil {
+				next = option
+				break
+			}
+		}
+		if next == "" {
+			return false
+		}
+		size = next
+	default:
+		return false
+	}
+
+	if state.Overrides == nil {
+		state.Overrides = &GenerationOverrides{}
+	}
+	state.Overrides.ImageSize = size
+	state.Overrides.NumInferenceSteps = steps
+	return true
+}
+
+// oneImagePerLora reports whether the generation of state is limited to one image per
+// LoRA combination.
+func oneImagePerLora(state *UserState) bool {
+	return state.Overrides != nil && state.Overrides.NumImages == 1
+}
+
+// safetyCheckerEnabled reports whether the generation of state will run with the safety
+// checker: an admin's one-off override, else the configured default.
+func safetyCheckerEnabled(state *UserState, deps BotDeps) bool {
+	if state.Overrides != nil && state.Overrides.SafetyChecker != nil {
+		return *state.Overrides.SafetyChecker
+	}
+	return deps.Cfg().DefaultGenerationSettings.EnableSafetyChecker
+}
+
+// toggleOneImagePerLora switches the one-off override of state between one image per
+// LoRA combination and the user's configured number of images. It reports the new setting.
+func toggleOneImagePerLora(state *UserState) bool {
+	if oneImagePerLora(state) {
+		state.Overrides.NumImages = 0
+		return false
+	}
+	if state.Overrides == nil {
+		state.Overrides = &GenerationOverrides{}
+	}
+	state.Overrides.NumImages = 1
+	return true
+}
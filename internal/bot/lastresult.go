@@ -0,0 +1,126 @@
+package bot
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// lastResultTTL is how long /last can re-send a result; fal's result URLs don't live forever.
+const lastResultTTL = time.Hour
+
+// lastResult is a delivered result as /last sends it again.
+type lastResult struct {
+	caption     string // Without the auto-delete note
+	images      []falapi.ImageInfo
+	labels      []string
+	groups      []resultGroup
+	deliveredAt time.Time
+}
+
+// LastResultStore remembers each user's last delivered result for /last. Nothing is
+// persisted; after a restart /last offers to regenerate instead.
+type LastResultStore struct {
+	mu      sync.Mutex
+	results map[int64]lastResult
+}
+
+func NewLastResultStore() *LastResultStore {
+	return &LastResultStore{results: make(map[int64]lastResult)}
+}
+
+// Remember stores a result just delivered to userID.
+func (s *LastResultStore) Remember(userID int64, caption string, images []falapi.ImageInfo, labels []string, groups []resultGroup) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[userID] = lastResult{caption: caption, images: images, labels: labels, groups: groups, deliveredAt: time.Now()}
+}
+
+// Get returns the last result of userID unless it is older than lastResultTTL.
+func (s *LastResultStore) Get(userID int64) (lastResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, ok := s.results[userID]
+	if ok && time.Since(result.deliveredAt) > lastResultTTL {
+		delete(s.results, userID)
+		return lastResult{}, false
+	}
+	return result, ok
+}
+
+// HandleLastCommand handles /last: the user's last result is sent again free of charge. If
+// it expired or its images can't be sent anymore, regenerating it is offered instead.
+func HandleLastCommand(chatID int64, userID int64, deps BotDeps) {
+	userLang := getUserLanguagePreference(userID, deps)
+	result, ok := deps.LastResults.Get(userID)
+	if !ok {
+		offerLastRegenerate(chatID, userID, "last_expired", deps)
+		return
+	}
+
+	statusMsg, err := deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "last_resending")))
+	if err != nil {
+		deps.Logger.Error("Failed to send /last status message", zap.Error(err), zap.Int64("user_id", userID))
+		return
+	}
+	caption := result.caption
+	ttl := autoDeleteTTL(userID, deps)
+	if ttl > 0 {
+		deleteAt := time.Now().Add(ttl).Format("2006-01-02 15:04 MST")
+		caption += deps.I18n.T(userLang, "generate_caption_auto_delete", "time", deleteAt)
+	}
+	sentIDs, err := sendResultsToUser(chatID, statusMsg.MessageID, caption, result.images, result.labels, result.groups, deliveryModeFor(userID, deps), deps)
+	scheduleAutoDelete(chatID, sentIDs, ttl, deps)
+	if err != nil {
+		deps.Logger.Warn("Failed to re-send last result, URLs may have expired", zap.Error(err), zap.Int64("user_id", userID))
+		offerLastRegenerate(chatID, userID, "last_send_failed", deps)
+		return
+	}
+	deps.Logger.Info("Re-sent last result", zap.Int64("user_id", userID), zap.Int("image_count", len(result.images)))
+}
+
+// offerLastRegenerate replies with the message of key and, if the last generation is
+// known, a button to generate it again.
+func offerLastRegenerate(chatID int64, userID int64, key string, deps BotDeps) {
+	userLang := getUserLanguagePreference(userID, deps)
+	if _, ok := deps.Repeats.Last(userID); !ok {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "last_nothing")))
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, key))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "last_button_regenerate"), "last_regenerate"),
+	))
+	deps.Bot.Send(msg)
+}
+
+// HandleLastCallback handles the regenerate button of /last, which generates the user's
+// last generation again at the usual cost.
+func HandleLastCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
+	userID := callbackQuery.From.ID
+	chatID := callbackQuery.Message.Chat.ID
+	messageID := callbackQuery.Message.MessageID
+	userLang := getUserLanguagePreference(userID, deps)
+	answer := tgbotapi.NewCallback(callbackQuery.ID, "")
+
+	last, ok := deps.Repeats.Last(userID)
+	if callbackQuery.Data != "last_regenerate" || !ok {
+		answer.Text = deps.I18n.T(userLang, "last_nothing")
+		deps.Bot.Request(answer)
+		return
+	}
+	deps.Bot.Request(answer)
+
+	deps.StateManager.ClearState(userID)
+	last.ChatID = chatID
+	last.MessageID = messageID
+	last.Action = "generating"
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, deps.I18n.T(userLang, "quick_repeat_starting"))
+	deps.Edits.EditNow(edit)
+	deps.Logger.Info("Regenerating last result from /last", zap.Int64("user_id", userID))
+	go GenerateImagesForUser(&last, deps)
+}
@@ -0,0 +1,279 @@
+package bot
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// GenerationLimiter caps the number of fal generation requests in flight across all users.
+// Requests beyond the cap wait in a FIFO queue of at most maxQueued entries.
+// A nil *GenerationLimiter means unlimited, so callers never need to check for it.
+type GenerationLimiter struct {
+	mu        sync.Mutex
+	max       int
+	maxQueued int // 0 = unbounded
+	active    int
+	waiting   []*GenerationTicket
+}
+
+// GenerationTicket is a place in the queue of a GenerationLimiter. A nil ticket holds a
+// slot of an unlimited limiter.
+type GenerationTicket struct {
+	l     *GenerationLimiter
+	ready chan struct{} // closed once the ticket holds a slot
+}
+
+// NewGenerationLimiter returns a limiter allowing max concurrent requests with up to
+// maxQueued more waiting (0 = any number), or nil if max <= 0.
+func NewGenerationLimiter(max int, maxQueued int) *GenerationLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &GenerationLimiter{max: max, maxQueued: maxQueued}
+}
+
+// Enqueue takes a slot if one is free and nobody is waiting, and otherwise queues for one.
+// It reports false if the queue is full.
+func (l *GenerationLimiter) Enqueue() (*GenerationTicket, bool) {
+	return l.enqueue(false)
+}
+
+func (l *GenerationLimiter) enqueue(ignoreDepth bool) (*GenerationTicket, bool) {
+	if l == nil {
+		return nil, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t := &GenerationTicket{l: l, ready: make(chan struct{})}
+	if l.active < l.max && len(l.waiting) == 0 {
+		l.active++
+		close(t.ready)
+		return t, true
+	}
+	if !ignoreDepth && l.maxQueued > 0 && len(l.waiting) >= l.maxQueued {
+		return nil, false
+	}
+	l.waiting = append(l.waiting, t)
+	return t, true
+}
+
+// Acquire blocks until a slot is free. It queues even if the queue is full.
+func (l *GenerationLimiter) Acquire() {
+	t, _ := l.enqueue(true)
+	t.Wait(context.Background())
+}
+
+// Release frees a slot taken by Acquire or a ticket, handing it to the longest waiting
+// ticket if any.
+func (l *GenerationLimiter) Release() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.waiting) > 0 {
+		next := l.waiting[0]
+		l.waiting = l.waiting[1:]
+		close(next.ready)
+		return
+	}
+	l.active--
+}
+
+// Wait blocks until t holds a slot, which the caller must Release. If ctx is done first,
+// t gives up its place and Wait returns ctx.Err().
+func (t *GenerationTicket) Wait(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	select {
+	case <-t.ready:
+		return nil
+	case <-ctx.Done():
+		t.Cancel()
+		return ctx.Err()
+	}
+}
+
+// Cancel gives up a ticket that was never waited for, releasing its slot if it got one.
+func (t *GenerationTicket) Cancel() {
+	if t == nil {
+		return
+	}
+	t.l.mu.Lock()
+	if i := slices.Index(t.l.waiting, t); i >= 0 {
+		t.l.waiting = slices.Delete(t.l.waiting, i, i+1)
+		t.l.mu.Unlock()
+		return
+	}
+	t.l.mu.Unlock()
+	t.l.Release() // Got a slot meanwhile
+}
+
+// Position returns the 1-based queue position of t, or 0 once it holds a slot or was
+// canceled.
+func (t *GenerationTicket) Position() int {
+	if t == nil {
+		return 0
+	}
+	t.l.mu.Lock()
+	defer t.l.mu.Unlock()
+	return slices.Index(t.l.waiting, t) + 1
+}
+
+// UserRateLimiter is a per-user token bucket: a user may make up to burst requests back to
+// back, after which requests are allowed at perMinute. A nil *UserRateLimiter means
+// unlimited, like GenerationLimiter.
+type UserRateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration // Time to refill one token
+	burst    float64
+	buckets  map[int64]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewUserRateLimiter returns a limiter allowing perMinute requests per user with bursts of
+// burst, or nil if perMinute <= 0.
+func NewUserRateLimiter(perMinute, burst int) *UserRateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &UserRateLimiter{
+		interval: time.Minute / time.Duration(perMinute),
+		burst:    float64(max(burst, 1)),
+		buckets:  make(map[int64]*tokenBucket),
+	}
+}
+
+// Allow takes a token for userID. If none is left it reports false and how long until the
+// next one is available.
+func (l *UserRateLimiter) Allow(userID int64) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	bucket, ok := l.buckets[userID]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, updated: now}
+		l.buckets[userID] = bucket
+	}
+	bucket.tokens = min(l.burst, bucket.tokens+float64(now.Sub(bucket.updated))/float64(l.interval))
+	bucket.updated = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) * float64(l.interval))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// formatRetryWait rounds wait up to whole seconds for messages, e.g. "12s".
+func formatRetryWait(wait time.Duration) string {
+	return (wait + time.Second - 1).Truncate(time.Second).String()
+}
+
+// GenerationCooldown makes users wait a fixed time after a completed generation before
+// starting the next one. Unlike UserRateLimiter it counts from completion, so long
+// generations can't be queued back to back. A nil *GenerationCooldown means no cooldown.
+type GenerationCooldown struct {
+	mu       sync.Mutex
+	duration time.Duration
+	finished map[int64]time.Time // Last completed generation per user
+}
+
+// NewGenerationCooldown returns a cooldown of d, or nil if d <= 0.
+func NewGenerationCooldown(d time.Duration) *GenerationCooldown {
+	if d <= 0 {
+		return nil
+	}
+	return &GenerationCooldown{duration: d, finished: make(map[int64]time.Time)}
+}
+
+// Remaining reports how long userID still has to wait, or 0 if they may generate.
+func (c *GenerationCooldown) Remaining(userID int64) time.Duration {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	finished, ok := c.finished[userID]
+	if !ok {
+		return 0
+	}
+	remaining := c.duration - time.Since(finished)
+	if remaining <= 0 {
+		delete(c.finished, userID)
+		return 0
+	}
+	return remaining
+}
+
+// Finished starts the cooldown of userID.
+func (c *GenerationCooldown) Finished(userID int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finished[userID] = time.Now()
+}
+
+// ChatGenerationLimiter caps the generations running at once in one group chat, so members
+// of a shared chat can't flood it. It is separate from the global GenerationLimiter and
+// applies to groups only; private chats have a single user. A generation counts from its
+// start until its results are delivered. A nil *ChatGenerationLimiter means no limit.
+type ChatGenerationLimiter struct {
+	mu     sync.Mutex
+	max    int
+	active map[int64][]int64 // Chat ID to the users generating there, one entry per generation
+}
+
+// NewChatGenerationLimiter returns a limiter allowing max generations per group chat, or nil
+// if max <= 0.
+func NewChatGenerationLimiter(max int) *ChatGenerationLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &ChatGenerationLimiter{max: max, active: make(map[int64][]int64)}
+}
+
+// Start takes a slot of chatID for a generation of userID. If the chat is full it returns
+// false and the users generating there, in the order they started.
+func (l *ChatGenerationLimiter) Start(chatID int64, userID int64) (bool, []int64) {
+	if l == nil || chatID >= 0 { // Unlimited, or a private chat
+		return true, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.active[chatID]) >= l.max {
+		return false, slices.Clone(l.active[chatID])
+	}
+	l.active[chatID] = append(l.active[chatID], userID)
+	return true, nil
+}
+
+// Done releases the slot a successful Start took for userID in chatID.
+func (l *ChatGenerationLimiter) Done(chatID int64, userID int64) {
+	if l == nil || chatID >= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	users := l.active[chatID]
+	if i := slices.Index(users, userID); i != -1 {
+		users = slices.Delete(users, i, i+1)
+	}
+	if len(users) == 0 {
+		delete(l.active, chatID)
+		return
+	}
+	l.active[chatID] = users
+}
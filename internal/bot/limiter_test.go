@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestChatGenerationLimiter(t *testing.T) {
+	const group, otherGroup = -100, -200
+	l := NewChatGenerationLimiter(2)
+
+	if ok, _ := l.Start(group, 1); !ok {
+		t.Fatal("first generation in the group was refused")
+	}
+	if ok, _ := l.Start(group, 2); !ok {
+		t.Fatal("second generation in the group was refused")
+	}
+	ok, generating := l.Start(group, 3)
+	if ok {
+		t.Fatal("generation in a full group was accepted")
+	}
+	if !slices.Equal(generating, []int64{1, 2}) {
+		t.Errorf("generating users = %v, want [1 2] in start order", generating)
+	}
+	if ok, _ := l.Start(otherGroup, 3); !ok {
+		t.Error("a full group blocked another group")
+	}
+	for range 5 {
+		if ok, _ := l.Start(3, 3); !ok {
+			t.Fatal("a private chat was limited")
+		}
+	}
+
+	l.Done(group, 1)
+	if ok, _ := l.Start(group, 2); !ok {
+		t.Fatal("freed slot was not reusable")
+	}
+	// User 2 now holds both slots; each Done frees exactly one
+	l.Done(group, 2)
+	if ok, _ := l.Start(group, 4); !ok {
+		t.Fatal("slot freed by Done was not reusable")
+	}
+	if ok, generating = l.Start(group, 5); ok || !slices.Equal(generating, []int64{2, 4}) {
+		t.Errorf("Start after one Done of a double slot = %v, %v, want false, [2 4]", ok, generating)
+	}
+	l.Done(group, 2)
+	l.Done(group, 4)
+	if len(l.active) != 1 {
+		t.Errorf("active chats = %v, want only the other group", l.active)
+	}
+}
+
+func TestChatGenerationLimiterUnlimited(t *testing.T) {
+	for _, max := range []int{0, -1} {
+		l := NewChatGenerationLimiter(max)
+		if l != nil {
+			t.Fatalf("NewChatGenerationLimiter(%d) = %v, want nil", max, l)
+		}
+		for range 5 {
+			if ok, _ := l.Start(-100, 1); !ok {
+				t.Fatalf("unlimited limiter (max %d) refused a generation", max)
+			}
+		}
+		l.Done(-100, 1)
+	}
+}
@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+	"go.uber.org/zap"
+)
+
+// checkLoraURL reports whether url answers with a non-error status. HEAD is tried first;
+// hosts that reject it get a GET for the first byte only.
+func checkLoraURL(ctx context.Context, client *http.Client, url string) error {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return fmt.Errorf("not an http(s) URL")
+	}
+
+	status, err := probeURL(ctx, client, http.MethodHead, url)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented || status == http.StatusForbidden) {
+		status, err = probeURL(ctx, client, http.MethodGet, url)
+	}
+	if err != nil {
+		return err
+	}
+	if status >= 400 {
+		return fmt.Errorf("HTTP %d", status)
+	}
+	return nil
+}
+
+func probeURL(ctx context.Context, client *http.Client, method, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1024))
+	return resp.StatusCode, nil
+}
+
+// checkLoras checks the URLs of loras concurrently and returns the error of every
+// unreachable one, keyed by LoRA ID.
+func checkLoras(loras []LoraConfig, checkCfg config.LoraCheckConfig) map[string]error {
+	client := &http.Client{Timeout: time.Duration(checkCfg.TimeoutSeconds) * time.Second}
+	sem := make(chan struct{}, checkCfg.Concurrency)
+	failures := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, lora := range loras {
+		wg.Add(1)
+		go func(lora LoraConfig) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), client.Timeout)
+			defer cancel()
+			if err := checkLoraURL(ctx, client, lora.URL); err != nil {
+				mu.Lock()
+				failures[lora.ID] = err
+				mu.Unlock()
+			}
+		}(lora)
+	}
+	wg.Wait()
+	return failures
+}
+
+// runLoraCheck runs the startup LoRA URL check and applies loraCheck.onFailure. It returns
+// the LoRAs to keep, or an error if the bot must not start.
+func runLoraCheck(checkCfg config.LoraCheckConfig, loras, baseLoras []LoraConfig, logger *zap.Logger) ([]LoraConfig, []LoraConfig, error) {
+	start := time.Now()
+	failures := checkLoras(append(append([]LoraConfig{}, loras...), baseLoras...), checkCfg)
+
+	filter := func(kind string, list []LoraConfig) []LoraConfig {
+		var healthy []LoraConfig
+		for _, lora := range list {
+			if err, failed := failures[lora.ID]; failed {
+				logger.Warn("LoRA URL is unreachable", zap.String("kind", kind), zap.String("name", lora.Name), zap.String("url", lora.URL), zap.Error(err))
+				if checkCfg.OnFailure == config.LoraCheckDisable {
+					continue
+				}
+			}
+			healthy = append(healthy, lora)
+		}
+		return healthy
+	}
+	keptLoras := filter("standard", loras)
+	keptBaseLoras := filter("base", baseLoras)
+
+	total := len(loras) + len(baseLoras)
+	logger.Info("LoRA URL check finished",
+		zap.Int("healthy", total-len(failures)),
+		zap.Int("unhealthy", len(failures)),
+		zap.String("on_failure", checkCfg.OnFailure),
+		zap.Duration("duration", time.Since(start)),
+	)
+
+	if len(failures) > 0 && checkCfg.OnFailure == config.LoraCheckAbort {
+		return nil, nil, fmt.Errorf("%d of %d LoRA URLs are unreachable", len(failures), total)
+	}
+	return keptLoras, keptBaseLoras, nil
+}
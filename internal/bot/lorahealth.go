@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+	"github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// LoraHealth counts the failed generations of each standard LoRA and disables a LoRA for
+// everyone but admins once it fails loraHealth.failureThreshold times within the window.
+// Nothing is persisted; a restart enables every LoRA again.
+type LoraHealth struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time // By LoRA name, oldest first
+	disabled map[string]time.Time   // LoRA name to the time it is enabled again
+}
+
+func NewLoraHealth() *LoraHealth {
+	return &LoraHealth{
+		failures: make(map[string][]time.Time),
+		disabled: make(map[string]time.Time),
+	}
+}
+
+// countsAgainstLora reports whether err says something about the LoRA of a request rather
+// than about fal or the network: a rejected or failed generation, not an outage, a timeout,
+// a stop by the user or an account problem.
+func countsAgainstLora(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		falapi.IsTransient(err) || falapi.IsInsufficientCredits(err) {
+		return false
+	}
+	var httpErr *falapi.HTTPError
+	if errors.As(err, &httpErr) && (httpErr.StatusCode == http.StatusUnauthorized || httpErr.StatusCode == http.StatusForbidden) {
+		return false
+	}
+	return true
+}
+
+// Succeeded forgets the failures of the LoRA name and enables it again if it was disabled,
+// which lets an admin re-enable a fixed LoRA by generating with it.
+func (h *LoraHealth) Succeeded(name string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.failures, name)
+	_, wasDisabled := h.disabled[name]
+	delete(h.disabled, name)
+	return wasDisabled
+}
+
+// Failed records a failure of the LoRA name and reports whether it disabled the LoRA.
+func (h *LoraHealth) Failed(name string, healthCfg config.LoraHealthConfig) bool {
+	now := time.Now()
+	window := time.Duration(healthCfg.WindowMinutes) * time.Minute
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	recent := h.failures[name]
+	for len(recent) > 0 && now.Sub(recent[0]) > window {
+		recent = recent[1:]
+	}
+	recent = append(recent, now)
+	if len(recent) < healthCfg.FailureThreshold {
+		h.failures[name] = recent
+		return false
+	}
+	delete(h.failures, name)
+	_, alreadyDisabled := h.disabled[name]
+	h.disabled[name] = now.Add(time.Duration(healthCfg.DisableMinutes) * time.Minute)
+	return !alreadyDisabled
+}
+
+// IsDisabled reports whether the LoRA name is currently disabled.
+func (h *LoraHealth) IsDisabled(name string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	until, ok := h.disabled[name]
+	if ok && time.Now().After(until) {
+		delete(h.disabled, name)
+		return false
+	}
+	return ok
+}
+
+// Enable enables the LoRA name again and reports whether it was disabled.
+func (h *LoraHealth) Enable(name string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok := h.disabled[name]
+	delete(h.disabled, name)
+	delete(h.failures, name)
+	return ok
+}
+
+// Disabled returns the names of the disabled LoRAs, sorted, with the time each is enabled
+// again.
+func (h *LoraHealth) Disabled() ([]string, map[string]time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	until := make(map[string]time.Time)
+	var names []string
+	for name, t := range h.disabled {
+		if now.After(t) {
+			delete(h.disabled, name)
+			continue
+		}
+		names = append(names, name)
+		until[name] = t
+	}
+	sort.Strings(names)
+	return names, until
+}
+
+// recordLoraOutcome updates the health of the standard LoRA of a finished request. Requests
+// with base LoRAs only count when they succeed, as a failure can't be blamed on one LoRA.
+func recordLoraOutcome(reqInfo RequestInfo, result RequestResult, deps BotDeps) {
+	healthCfg := deps.Cfg().LoraHealth
+	if !healthCfg.Enabled {
+		return
+	}
+	name := reqInfo.StandardLora.Name
+	if result.Error == nil && result.Response != nil {
+		if deps.LoraHealth.Succeeded(name) {
+			deps.Logger.Info("Disabled LoRA succeeded, enabled it again", zap.String("lora", name), zap.String("request_id", result.ReqID))
+		}
+		return
+	}
+	if len(reqInfo.BaseLoras) > 0 || result.falErr == nil || !countsAgainstLora(result.falErr) {
+		return
+	}
+	if !deps.LoraHealth.Failed(name, healthCfg) {
+		return
+	}
+	deps.Logger.Warn("LoRA disabled after repeated failures", zap.String("lora", name), zap.Int("failures", healthCfg.FailureThreshold), zap.Int("window_minutes", healthCfg.WindowMinutes), zap.Error(result.falErr))
+	for _, adminID := range deps.Cfg().Admins.AdminUserIDs {
+		adminLang := getUserLanguagePreference(adminID, deps)
+		text := deps.I18n.T(adminLang, "admin_alert_lora_disabled",
+			"name", name,
+			"failures", healthCfg.FailureThreshold,
+			"window", healthCfg.WindowMinutes,
+			"minutes", healthCfg.DisableMinutes,
+			"error", truncateRunes(result.falErr.Error(), 200),
+		)
+		if _, err := deps.Bot.Send(tgbotapi.NewMessage(adminID, text)); err != nil {
+			deps.Logger.Warn("Failed to alert admin about disabled LoRA", zap.Error(err), zap.Int64("admin_id", adminID))
+		}
+	}
+}
+
+// HandleEnableLoraCommand handles the admin /enablelora command. Without an argument it lists
+// the LoRAs disabled for repeated failures; "/enablelora <name>" enables one again.
+func HandleEnableLoraCommand(message *tgbotapi.Message, deps BotDeps) {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+	userLang := getUserLanguagePreference(userID, deps)
+	if !deps.Authorizer.IsAdmin(userID) {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "myconfig_command_admin_only")))
+		return
+	}
+
+	name := strings.TrimSpace(message.CommandArguments())
+	if name == "" {
+		names, until := deps.LoraHealth.Disabled()
+		if len(names) == 0 {
+			deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "enablelora_none")))
+			return
+		}
+		var text strings.Builder
+		text.WriteString(deps.I18n.T(userLang, "enablelora_list_title"))
+		for _, name := range names {
+			text.WriteString(deps.I18n.T(userLang, "enablelora_list_item", "name", name, "until", until[name].Format("15:04 MST")))
+		}
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, text.String()))
+		return
+	}
+
+	if !deps.LoraHealth.Enable(name) {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "enablelora_not_disabled", "name", name)))
+		return
+	}
+	deps.Logger.Info("Admin enabled LoRA again", zap.Int64("admin_id", userID), zap.String("lora", name))
+	deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "enablelora_enabled", "name", name)))
+}
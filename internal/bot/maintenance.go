@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// Maintenance is the switch of maintenance mode. While it is on, users other than admins
+// can't start captions or generations, but running ones finish. It is stored in the
+// database, so it survives restarts.
+type Maintenance struct {
+	db *sql.DB
+	on atomic.Bool
+}
+
+// NewMaintenance loads the stored maintenance mode; it is off if it was never set or can't
+// be read.
+func NewMaintenance(db *sql.DB, logger *zap.Logger) *Maintenance {
+	m := &Maintenance{db: db}
+	value, err := st.GetBotSetting(db, st.SettingMaintenance)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Error("Failed to load maintenance mode, starting with it off", zap.Error(err))
+	}
+	if value == "on" {
+		logger.Warn("Maintenance mode is on, only admins can generate")
+		m.on.Store(true)
+	}
+	return m
+}
+
+// On reports whether maintenance mode is on.
+func (m *Maintenance) On() bool {
+	return m.on.Load()
+}
+
+// Set turns maintenance mode on or off and stores it.
+func (m *Maintenance) Set(on bool) error {
+	value := "off"
+	if on {
+		value = "on"
+	}
+	if err := st.SetBotSetting(m.db, st.SettingMaintenance, value); err != nil {
+		return err
+	}
+	m.on.Store(on)
+	return nil
+}
+
+// refuseForMaintenance tells userID that no new work is accepted and reports true if
+// maintenance mode is on and they aren't an admin. The notice edits messageID if set.
+func refuseForMaintenance(chatID int64, userID int64, messageID int, deps BotDeps) bool {
+	if !deps.Maintenance.On() || deps.Authorizer.IsAdmin(userID) {
+		return false
+	}
+	userLang := getUserLanguagePreference(userID, deps)
+	text := deps.I18n.T(userLang, "maintenance_refused")
+	deps.Logger.Info("Refused new work during maintenance", zap.Int64("user_id", userID))
+	if messageID != 0 {
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+		edit.ReplyMarkup = nil
+		deps.Edits.EditNow(edit)
+	} else {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, text))
+	}
+	return true
+}
+
+// HandleMaintenanceCommand handles the admin /maintenance command: "/maintenance on" or
+// "/maintenance off" switch maintenance mode, without an argument it shows the current mode.
+func HandleMaintenanceCommand(message *tgbotapi.Message, deps BotDeps) {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+	userLang := getUserLanguagePreference(userID, deps)
+	if !deps.Authorizer.IsAdmin(userID) {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "myconfig_command_admin_only")))
+		return
+	}
+
+	var on bool
+	switch strings.ToLower(strings.TrimSpace(message.CommandArguments())) {
+	case "":
+		key := "maintenance_status_off"
+		if deps.Maintenance.On() {
+			key = "maintenance_status_on"
+		}
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, key)))
+		return
+	case "on":
+		on = true
+	case "off":
+		on = false
+	default:
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "maintenance_usage")))
+		return
+	}
+
+	if err := deps.Maintenance.Set(on); err != nil {
+		deps.Logger.Error("Failed to switch maintenance mode", zap.Error(err), zap.Int64("admin_id", userID), zap.Bool("on", on))
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "error_generic")))
+		return
+	}
+	deps.Logger.Info("Admin switched maintenance mode", zap.Int64("admin_id", userID), zap.Bool("on", on))
+	if on {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "maintenance_turned_on", "running", len(deps.Inflight.List()))))
+	} else {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "maintenance_turned_off")))
+	}
+}
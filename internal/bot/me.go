@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// statusCardTTL is how long a /me card is reused before balance and history are read again.
+const statusCardTTL = 30 * time.Second
+
+// statusCard is the data shown by /me.
+type statusCard struct {
+	balance        float64
+	hasBalance     bool // Balance tracking is enabled and the balance could be read
+	lastGeneration time.Time
+	fetchedAt      time.Time
+}
+
+// StatusCardCache briefly caches /me cards, so users checking their status constantly cost
+// one balance and one history query per TTL.
+type StatusCardCache struct {
+	mu    sync.Mutex
+	cards map[int64]statusCard
+}
+
+func NewStatusCardCache() *StatusCardCache {
+	return &StatusCardCache{cards: make(map[int64]statusCard)}
+}
+
+// Get returns the card of userID, loading it if the cached one is missing or stale.
+func (c *StatusCardCache) Get(userID int64, deps BotDeps) statusCard {
+	c.mu.Lock()
+	card, ok := c.cards[userID]
+	c.mu.Unlock()
+	if ok && time.Since(card.fetchedAt) < statusCardTTL {
+		return card
+	}
+
+	card = statusCard{fetchedAt: time.Now()}
+	if deps.BalanceManager != nil {
+		balance, err := deps.BalanceManager.GetBalanceWithError(userID)
+		if err != nil {
+			deps.Logger.Warn("Failed to load balance for /me", zap.Error(err), zap.Int64("user_id", userID))
+		} else {
+			card.balance, card.hasBalance = balance, true
+		}
+	}
+	entries, err := st.ListGenerationHistory(deps.DB, userID, 1)
+	if err != nil {
+		deps.Logger.Warn("Failed to load last generation for /me", zap.Error(err), zap.Int64("user_id", userID))
+	} else if len(entries) > 0 {
+		card.lastGeneration = entries[0].CreatedAt
+	}
+
+	c.mu.Lock()
+	c.cards[userID] = card
+	c.mu.Unlock()
+	return card
+}
+
+// HandleMeCommand handles /me: a one-message status with the balance, the generations it
+// still covers and the time of the last generation.
+func HandleMeCommand(chatID int64, userID int64, deps BotDeps) {
+	userLang := getUserLanguagePreference(userID, deps)
+	card := deps.StatusCards.Get(userID, deps)
+
+	var parts []string
+	if deps.Maintenance.On() {
+		parts = append(parts, deps.I18n.T(userLang, "maintenance_banner"))
+	}
+	if deps.BalanceManager == nil {
+		parts = append(parts, deps.I18n.T(userLang, "me_balance_disabled"))
+	} else if !card.hasBalance {
+		parts = append(parts, deps.I18n.T(userLang, "me_balance_unavailable"))
+	} else {
+		remaining := 0
+		if cost := deps.BalanceManager.GetCost(); cost > 0 && card.balance > 0 {
+			remaining = int(math.Floor(card.balance/cost + 1e-9)) // Tolerate float error, e.g. 0.3/0.1
+		}
+		parts = append(parts,
+			deps.I18n.T(userLang, "me_balance", "balance", formatBalance(card.balance, userLang, deps)),
+			deps.I18n.T(userLang, "me_remaining", "count", remaining),
+		)
+	}
+	if card.lastGeneration.IsZero() {
+		parts = append(parts, deps.I18n.T(userLang, "me_last_never"))
+	} else {
+		parts = append(parts, deps.I18n.T(userLang, "me_last", "time", card.lastGeneration.Format("2006-01-02 15:04 MST")))
+	}
+
+	deps.Bot.Send(tgbotapi.NewMessage(chatID, strings.Join(parts, " · ")))
+}
@@ -0,0 +1,171 @@
+package bot
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	falapi "github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+	"go.uber.org/zap"
+)
+
+// mediaKind selects the Telegram method used to deliver a result file.
+type mediaKind int
+
+const (
+	mediaPhoto     mediaKind = iota // sendPhoto, groupable
+	mediaVideo                      // sendVideo, groupable
+	mediaAnimation                  // sendAnimation (GIF), sent on its own
+	mediaDocument                   // sendDocument, for anything Telegram can't show inline
+)
+
+// detectMediaKind classifies a fal output by its content type, falling back to the
+// URL's file extension when fal doesn't report one.
+func detectMediaKind(img falapi.ImageInfo) mediaKind {
+	contentType := strings.ToLower(strings.TrimSpace(img.ContentType))
+	if i := strings.Index(contentType, ";"); i != -1 {
+		contentType = strings.TrimSpace(contentType[:i])
+	}
+
+	switch contentType {
+	case "image/jpeg", "image/jpg", "image/png", "image/webp":
+		return mediaPhoto
+	case "image/gif":
+		return mediaAnimation
+	case "video/mp4":
+		return mediaVideo
+	case "":
+		// Unknown, decide by extension below
+	default:
+		return mediaDocument
+	}
+
+	ext := ""
+	if u, err := url.Parse(img.URL); err == nil {
+		ext = strings.ToLower(path.Ext(u.Path))
+	}
+	switch ext {
+	case ".gif":
+		return mediaAnimation
+	case ".mp4":
+		return mediaVideo
+	case ".webm", ".mov", ".zip":
+		return mediaDocument
+	default:
+		// fal image models historically return JPEG/PNG without a content type
+		return mediaPhoto
+	}
+}
+
+// newSingleMediaMessage builds the message used to send one result file on its own.
+// caption may be empty.
+func newSingleMediaMessage(chatID int64, img falapi.ImageInfo, caption string) tgbotapi.Chattable {
+	return newMediaMessage(chatID, detectMediaKind(img), tgbotapi.FileURL(img.URL), caption)
+}
+
+// newMediaMessage builds the message that sends file as kind. caption may be empty.
+func newMediaMessage(chatID int64, kind mediaKind, file tgbotapi.RequestFileData, caption string) tgbotapi.Chattable {
+	switch kind {
+	case mediaAnimation:
+		msg := tgbotapi.NewAnimation(chatID, file)
+		msg.Caption = caption
+		return msg
+	case mediaVideo:
+		msg := tgbotapi.NewVideo(chatID, file)
+		msg.Caption = caption
+		return msg
+	case mediaDocument:
+		msg := tgbotapi.NewDocument(chatID, file)
+		msg.Caption = caption
+		return msg
+	default:
+		msg := tgbotapi.NewPhoto(chatID, file)
+		msg.Caption = caption
+		return msg
+	}
+}
+
+// newGroupMedia returns the media group item for img, or false if img can't be grouped
+// (Telegram albums only mix photos and videos). caption may be empty.
+func newGroupMedia(img falapi.ImageInfo, caption string) (interface{}, bool) {
+	return newGroupMediaFile(detectMediaKind(img), tgbotapi.FileURL(img.URL), caption)
+}
+
+// newGroupMediaFile is newGroupMedia for a file of a known kind.
+func newGroupMediaFile(kind mediaKind, file tgbotapi.RequestFileData, caption string) (interface{}, bool) {
+	switch kind {
+	case mediaPhoto:
+		media := tgbotapi.NewInputMediaPhoto(file)
+		media.Caption = caption
+		return media, true
+	case mediaVideo:
+		media := tgbotapi.NewInputMediaVideo(file)
+		media.Caption = caption
+		return media, true
+	default:
+		return nil, false
+	}
+}
+
+// mediaFile is one file to send with sendMediaFiles.
+type mediaFile struct {
+	Kind    mediaKind
+	File    tgbotapi.RequestFileData
+	Caption string // Per-file caption, may be empty
+}
+
+// sendMediaFiles sends files to chatID: photos and videos as albums of up to 10, everything
+// else on its own. Failed sends are retried with retrySend. It returns all sent messages and
+// the first error; a failed send doesn't stop the remaining ones.
+func sendMediaFiles(chatID int64, files []mediaFile, deps BotDeps) ([]tgbotapi.Message, error) {
+	var sent []tgbotapi.Message
+	var firstErr error
+	record := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	var groupable []int // Indexes into files
+	var mediaGroup []interface{}
+	for i, f := range files {
+		if media, ok := newGroupMediaFile(f.Kind, f.File, f.Caption); ok {
+			mediaGroup = append(mediaGroup, media)
+			groupable = append(groupable, i)
+			continue
+		}
+		msg, err := sendWithRetry(chatID, newMediaMessage(chatID, f.Kind, f.File, f.Caption), deps)
+		if err != nil {
+			deps.Logger.Error("Failed to send ungroupable media", zap.Error(err), zap.Int64("chat_id", chatID))
+			record(err)
+			continue
+		}
+		sent = append(sent, msg)
+	}
+	for start := 0; start < len(mediaGroup); start += 10 {
+		end := start + 10
+		if end > len(mediaGroup) {
+			end = len(mediaGroup)
+		}
+		if end-start == 1 {
+			// Albums need at least two items
+			f := files[groupable[start]]
+			msg, err := sendWithRetry(chatID, newMediaMessage(chatID, f.Kind, f.File, f.Caption), deps)
+			if err != nil {
+				deps.Logger.Error("Failed to send media", zap.Error(err), zap.Int64("chat_id", chatID))
+				record(err)
+				continue
+			}
+			sent = append(sent, msg)
+			continue
+		}
+		msgs, err := sendMediaGroupWithRetry(tgbotapi.NewMediaGroup(chatID, mediaGroup[start:end]), deps)
+		sent = append(sent, msgs...)
+		if err != nil {
+			deps.Logger.Error("Failed to send image group chunk", zap.Error(err), zap.Int64("chat_id", chatID), zap.Int("chunk_size", end-start))
+			record(err)
+		}
+	}
+	return sent, firstErr
+}
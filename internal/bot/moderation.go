@@ -0,0 +1,219 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+	falapi "github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+	"go.uber.org/zap"
+)
+
+// mediaKindNames maps media kinds to the names stored with held moderation items.
+var mediaKindNames = map[mediaKind]string{
+	mediaPhoto:     "photo",
+	mediaVideo:     "video",
+	mediaAnimation: "animation",
+	mediaDocument:  "document",
+}
+
+func mediaKindByName(name string) mediaKind {
+	for kind, n := range mediaKindNames {
+		if n == name {
+			return kind
+		}
+	}
+	return mediaDocument
+}
+
+// sentMediaItem extracts the Telegram file of a sent media message, so it can be sent
+// again later without depending on fal's URL.
+func sentMediaItem(msg tgbotapi.Message) (st.ModerationItem, bool) {
+	item := st.ModerationItem{Label: msg.Caption}
+	switch {
+	case len(msg.Photo) > 0:
+		item.FileID, item.Kind = msg.Photo[len(msg.Photo)-1].FileID, mediaKindNames[mediaPhoto] // Largest size
+	case msg.Animation != nil:
+		item.FileID, item.Kind = msg.Animation.FileID, mediaKindNames[mediaAnimation]
+	case msg.Video != nil:
+		item.FileID, item.Kind = msg.Video.FileID, mediaKindNames[mediaVideo]
+	case msg.Document != nil:
+		item.FileID, item.Kind = msg.Document.FileID, mediaKindNames[mediaDocument]
+	default:
+		return item, false
+	}
+	return item, true
+}
+
+// holdForModeration posts a finished result to the moderation chat with approve/reject
+// buttons instead of delivering it. The files are re-hosted on Telegram by that post, so
+// the held result doesn't depend on fal's URLs expiring.
+func holdForModeration(userID, chatID int64, statusMessageID int, caption string, images []falapi.ImageInfo, labels []string, groups []resultGroup, deps BotDeps) error {
+	modChatID := deps.Cfg().Moderation.ChatID
+	userLang := getUserLanguagePreference(userID, deps)
+
+	// Moderators (and later the user) see which LoRA made each image
+	labels = deliveryLabels(config.DeliveryModeSingle, len(images), labels, groups)
+	files := make([]mediaFile, 0, len(images))
+	for i, img := range images {
+		label := ""
+		if i < len(labels) {
+			label = labels[i]
+		}
+		files = append(files, mediaFile{Kind: detectMediaKind(img), File: tgbotapi.FileURL(img.URL), Caption: label})
+	}
+	msgs, err := sendMediaFiles(modChatID, files, deps)
+	if err != nil {
+		return fmt.Errorf("failed to post result to moderation chat: %w", err)
+	}
+	var items []st.ModerationItem
+	for _, msg := range msgs {
+		if item, ok := sentMediaItem(msg); ok {
+			items = append(items, item)
+		}
+	}
+
+	id, err := st.AddPendingModeration(deps.DB, st.PendingModeration{
+		UserID:          userID,
+		ChatID:          chatID,
+		StatusMessageID: statusMessageID,
+		Caption:         caption,
+		Items:           items,
+	})
+	if err != nil {
+		return err
+	}
+
+	modLang := deps.Cfg().DefaultLanguage
+	request := tgbotapi.NewMessage(modChatID, deps.I18n.T(&modLang, "moderation_request", "id", id, "user", userID, "caption", caption))
+	request.ParseMode = tgbotapi.ModeMarkdown
+	request.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(&modLang, "moderation_button_approve"), fmt.Sprintf("mod_approve_%d", id)),
+		tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(&modLang, "moderation_button_reject"), fmt.Sprintf("mod_reject_%d", id)),
+	))
+	if _, err := deps.Bot.Send(request); err != nil {
+		return fmt.Errorf("failed to post moderation request: %w", err)
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, statusMessageID, deps.I18n.T(userLang, "moderation_pending"))
+	edit.ReplyMarkup = nil
+	deps.Edits.EditNow(edit)
+	deps.Logger.Info("Result held for moderation", zap.Int64("moderation_id", id), zap.Int64("user_id", userID), zap.Int("items", len(items)))
+	return nil
+}
+
+// HandleModerationCallback handles mod_approve_<id> and mod_reject_<id> from the moderation chat.
+func HandleModerationCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
+	moderatorID := callbackQuery.From.ID
+	modLang := getUserLanguagePreference(moderatorID, deps)
+	answer := tgbotapi.NewCallback(callbackQuery.ID, "")
+
+	if !deps.Authorizer.IsAdmin(moderatorID) {
+		answer.Text = deps.I18n.T(modLang, "myconfig_command_admin_only")
+		deps.Bot.Request(answer)
+		return
+	}
+
+	data := callbackQuery.Data
+	var status, idStr string
+	switch {
+	case strings.HasPrefix(data, "mod_approve_"):
+		status, idStr = st.ModerationApproved, strings.TrimPrefix(data, "mod_approve_")
+	case strings.HasPrefix(data, "mod_reject_"):
+		status, idStr = st.ModerationRejected, strings.TrimPrefix(data, "mod_reject_")
+	default:
+		answer.Text = deps.I18n.T(modLang, "lora_select_unknown_action")
+		deps.Bot.Request(answer)
+		return
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		answer.Text = deps.I18n.T(modLang, "moderation_not_found")
+		deps.Bot.Request(answer)
+		return
+	}
+
+	held, err := st.GetPendingModeration(deps.DB, id)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			deps.Logger.Error("Failed to load held result", zap.Error(err), zap.Int64("moderation_id", id))
+		}
+		answer.Text = deps.I18n.T(modLang, "moderation_not_found")
+		deps.Bot.Request(answer)
+		return
+	}
+	decided, err := st.DecidePendingModeration(deps.DB, id, status, moderatorID)
+	if err != nil {
+		answer.Text = deps.I18n.T(modLang, "error_generic")
+		deps.Bot.Request(answer)
+		return
+	}
+	if !decided {
+		answer.Text = deps.I18n.T(modLang, "moderation_already_decided")
+		deps.Bot.Request(answer)
+		return
+	}
+	deps.Bot.Request(answer)
+
+	userLang := getUserLanguagePreference(held.UserID, deps)
+	resultKey := "moderation_decided_rejected"
+	if status == st.ModerationApproved {
+		resultKey = "moderation_decided_approved"
+		deliverModeratedResult(held, userLang, deps)
+	} else {
+		edit := tgbotapi.NewEditMessageText(held.ChatID, held.StatusMessageID, deps.I18n.T(userLang, "moderation_rejected"))
+		edit.ReplyMarkup = nil
+		deps.Edits.EditNow(edit)
+	}
+	deps.Logger.Info("Moderation decided", zap.Int64("moderation_id", id), zap.String("status", status), zap.Int64("moderator_id", moderatorID), zap.Int64("user_id", held.UserID))
+
+	if callbackQuery.Message != nil {
+		edit := tgbotapi.NewEditMessageText(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID,
+			callbackQuery.Message.Text+"\n\n"+deps.I18n.T(modLang, resultKey, "moderator", moderatorID))
+		edit.ReplyMarkup = nil
+		deps.Bot.Send(edit)
+	}
+}
+
+// deliverModeratedResult sends an approved result to the user and removes the status message.
+// Auto-delete starts counting at delivery.
+func deliverModeratedResult(held *st.PendingModeration, userLang *string, deps BotDeps) {
+	files := make([]mediaFile, 0, len(held.Items))
+	for _, item := range held.Items {
+		files = append(files, mediaFile{Kind: mediaKindByName(item.Kind), File: tgbotapi.FileID(item.FileID), Caption: item.Label})
+	}
+
+	caption := held.Caption
+	ttl := autoDeleteTTL(held.UserID, deps)
+	if ttl > 0 {
+		deleteAt := time.Now().Add(ttl).Format("2006-01-02 15:04 MST")
+		caption += deps.I18n.T(userLang, "generate_caption_auto_delete", "time", deleteAt)
+	}
+	var sentIDs []int
+	captionMsgs, err := sendMarkdownMessages(held.ChatID, caption, deps)
+	if err != nil {
+		deps.Logger.Error("Failed to send caption of approved result", zap.Error(err), zap.Int64("moderation_id", held.ID))
+	}
+	for _, msg := range captionMsgs {
+		sentIDs = append(sentIDs, msg.MessageID)
+	}
+	msgs, err := sendMediaFiles(held.ChatID, files, deps)
+	for _, msg := range msgs {
+		sentIDs = append(sentIDs, msg.MessageID)
+	}
+	if err != nil {
+		deps.Logger.Error("Failed to deliver approved result", zap.Error(err), zap.Int64("moderation_id", held.ID))
+		edit := tgbotapi.NewEditMessageText(held.ChatID, held.StatusMessageID, deps.I18n.T(userLang, "moderation_deliver_failed"))
+		deps.Edits.EditNow(edit)
+	} else {
+		deps.Edits.Forget(held.ChatID, held.StatusMessageID)
+		deps.Bot.Request(tgbotapi.NewDeleteMessage(held.ChatID, held.StatusMessageID))
+	}
+	scheduleAutoDelete(held.ChatID, sentIDs, ttl, deps)
+}
@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// userPresets returns the group presets offered to userID: those of every group the user is
+// in, in config order, skipping names already taken by an earlier group and presets using a
+// LoRA the user can't see.
+func userPresets(userID int64, deps BotDeps) []config.GroupPreset {
+	var presets []config.GroupPreset
+	seen := make(map[string]struct{})
+	for _, group := range deps.Cfg().UserGroups {
+		if !slices.Contains(group.UserIDs, userID) {
+			continue
+		}
+		for _, preset := range group.Presets {
+			if _, dup := seen[preset.Name]; dup {
+				continue
+			}
+			seen[preset.Name] = struct{}{}
+			if presetUsable(preset, userID, deps) {
+				presets = append(presets, preset)
+			}
+		}
+	}
+	return presets
+}
+
+// presetUsable reports whether userID can see every standard LoRA of preset and its base
+// LoRAs still exist.
+func presetUsable(preset config.GroupPreset, userID int64, deps BotDeps) bool {
+	visible := GetUserVisibleLoras(userID, deps)
+	for _, name := range preset.Loras {
+		if _, found := findLoraByName(name, visible); !found {
+			return false
+		}
+	}
+	for _, name := range preset.BaseLoras {
+		if _, found := findLoraByName(name, deps.BaseLoRAs()); !found {
+			return false
+		}
+	}
+	return true
+}
+
+// presetOverrides returns the one-off overrides that apply the parameters of preset, or nil
+// if it keeps all of the user's settings.
+func presetOverrides(preset config.GroupPreset) *GenerationOverrides {
+	overrides := GenerationOverrides{
+		ImageSize:         preset.ImageSize,
+		NumInferenceSteps: preset.NumInferenceSteps,
+		NumImages:         preset.NumImages,
+	}
+	if preset.GuidanceScale > 0 {
+		guidance := preset.GuidanceScale
+		overrides.GuidanceScale = &guidance
+	}
+	if overrides == (GenerationOverrides{}) {
+		return nil
+	}
+	return &overrides
+}
+
+// HandlePresetsCommand handles /presets and lists the presets of the user's groups as
+// buttons. Picking one asks for a prompt, then goes straight to the confirm keyboard.
+func HandlePresetsCommand(chatID int64, userID int64, deps BotDeps) {
+	userLang := getUserLanguagePreference(userID, deps)
+	presets := userPresets(userID, deps)
+	if len(presets) == 0 {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "presets_none")))
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString(deps.I18n.T(userLang, "presets_title"))
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, preset := range presets {
+		loras := strings.Join(append(slices.Clone(preset.BaseLoras), preset.Loras...), " + ")
+		text.WriteString(deps.I18n.T(userLang, "presets_item", "name", preset.Name, "loras", loras))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(preset.Name, fmt.Sprintf("preset_pick_%d", i)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "lora_selection_keyboard_cancel_button"), "preset_cancel"),
+	))
+	msg := tgbotapi.NewMessage(chatID, text.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	deps.Bot.Send(msg)
+}
+
+// HandlePresetCallback handles preset_pick_<index> and preset_cancel.
+func HandlePresetCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
+	userID := callbackQuery.From.ID
+	if callbackQuery.Message == nil {
+		answer := tgbotapi.NewCallback(callbackQuery.ID, deps.I18n.T(nil, "callback_error_nil_message"))
+		deps.Bot.Request(answer)
+		return
+	}
+	chatID := callbackQuery.Message.Chat.ID
+	messageID := callbackQuery.Message.MessageID
+	data := callbackQuery.Data
+	userLang := getUserLanguagePreference(userID, deps)
+	answer := tgbotapi.NewCallback(callbackQuery.ID, "")
+
+	switch {
+	case data == "preset_cancel":
+		deps.Bot.Request(answer)
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, deps.I18n.T(userLang, "cancel_state_success"))
+		edit.ReplyMarkup = nil
+		deps.Bot.Send(edit)
+
+	case strings.HasPrefix(data, "preset_pick_"):
+		// The list is built again, a LoRA may have become unavailable since /presets
+		presets := userPresets(userID, deps)
+		index, err := strconv.Atoi(strings.TrimPrefix(data, "preset_pick_"))
+		if err != nil || index < 0 || index >= len(presets) {
+			answer.Text = deps.I18n.T(userLang, "presets_unavailable")
+			deps.Bot.Request(answer)
+			return
+		}
+		preset := presets[index]
+		deps.Bot.Request(answer)
+
+		deps.StateManager.ClearState(userID)
+		clearQuickRepeatKeyboard(chatID, userID, deps)
+		deps.StateManager.SetState(userID, &UserState{
+			UserID:            userID,
+			ChatID:            chatID,
+			MessageID:         messageID,
+			Action:            "awaiting_preset_prompt",
+			SelectedLoras:     slices.Clone(preset.Loras),
+			SelectedBaseLoras: slices.Clone(preset.BaseLoras),
+			Overrides:         presetOverrides(preset),
+		})
+		deps.Logger.Info("User picked group preset", zap.Int64("user_id", userID), zap.String("preset", preset.Name))
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, deps.I18n.T(userLang, "presets_send_prompt", "name", preset.Name))
+		edit.ReplyMarkup = nil
+		deps.Bot.Send(edit)
+
+	default:
+		answer.Text = deps.I18n.T(userLang, "lora_select_unknown_action")
+		deps.Bot.Request(answer)
+	}
+}
+
+// HandlePresetPrompt takes the prompt for a picked preset and shows the confirm keyboard with
+// the preset's LoRAs and parameters.
+func HandlePresetPrompt(message *tgbotapi.Message, state *UserState, deps BotDeps) {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+	userLang := getUserLanguagePreference(userID, deps)
+
+	prompt := strings.TrimSpace(message.Text)
+	rememberPrompt(userID, prompt, deps)
+	state.OriginalCaption = prompt
+	state.ChatID = chatID
+	state.Action = "awaiting_base_lora_selection"
+
+	sentMsg, err := deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "text_prompt_received")))
+	if err != nil {
+		deps.Logger.Warn("Failed to send message for preset confirm keyboard, sending keyboard as new message", zap.Error(err), zap.Int64("user_id", userID))
+	}
+	state.MessageID = sentMsg.MessageID
+	deps.StateManager.SetState(userID, state)
+	SendBaseLoraSelectionKeyboard(chatID, sentMsg.MessageID, state, deps, sentMsg.MessageID != 0)
+}
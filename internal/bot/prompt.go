@@ -0,0 +1,196 @@
+package bot
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	cfg "github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+)
+
+var (
+	// separatorRunRegex matches runs of prompt separators (",", ";", "|") such as ",,, ;" that are
+	// used to push operator tags out of the model's attention window.
+	separatorRunRegex = regexp.MustCompile(`\s*[,;|](\s*[,;|])+\s*`)
+	whitespaceRegex   = regexp.MustCompile(`\s+`)
+)
+
+// promptRules is the prompt sanitizer and blocklist of a config with their regexps compiled.
+// It is built once per config load and kept in liveConfig, so generations don't compile
+// regexps.
+type promptRules struct {
+	sanitizer    cfg.PromptSanitizerConfig
+	blockedTerms []*regexp.Regexp
+	blocklist    []blocklistRule
+}
+
+// blocklistRule is a compiled promptBlocklist term or pattern.
+type blocklistRule struct {
+	rule string // As configured, for the log
+	re   *regexp.Regexp
+}
+
+// compilePromptRules compiles the prompt rules of config. Blocklist terms match
+// case-insensitively, with any run of whitespace matching the whitespace in a term.
+func compilePromptRules(config *cfg.Config) *promptRules {
+	rules := &promptRules{sanitizer: config.PromptSanitizer}
+	for _, term := range config.PromptSanitizer.BlockedTerms {
+		if term = strings.TrimSpace(term); term != "" {
+			rules.blockedTerms = append(rules.blockedTerms, regexp.MustCompile(`(?i)`+regexp.QuoteMeta(term)))
+		}
+	}
+	for _, term := range config.PromptBlocklist.Terms {
+		words := strings.Fields(term)
+		if len(words) == 0 {
+			continue
+		}
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		rules.blocklist = append(rules.blocklist, blocklistRule{rule: term, re: regexp.MustCompile(`(?i)` + strings.Join(words, `\s+`))})
+	}
+	for _, pattern := range config.PromptBlocklist.Patterns {
+		re, err := regexp.Compile(`(?i)` + pattern)
+		if err != nil { // Rejected by config validation
+			continue
+		}
+		rules.blocklist = append(rules.blocklist, blocklistRule{rule: pattern, re: re})
+	}
+	return rules
+}
+
+// sanitizeUserPrompt neutralizes user input before it is combined with operator text.
+// It replaces control characters and newlines with spaces, removes blocked terms,
+// collapses separator runs, trims leading/trailing separators and enforces MaxLength.
+// The prompt is returned trimmed but otherwise untouched when the sanitizer is disabled.
+func sanitizeUserPrompt(prompt string, rules *promptRules) string {
+	sanitizer := rules.sanitizer
+	prompt = strings.TrimSpace(prompt)
+	if !sanitizer.Enabled {
+		return prompt
+	}
+
+	prompt = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, prompt)
+
+	for _, term := range rules.blockedTerms {
+		prompt = term.ReplaceAllString(prompt, " ")
+	}
+
+	prompt = separatorRunRegex.ReplaceAllString(prompt, ", ")
+	prompt = whitespaceRegex.ReplaceAllString(prompt, " ")
+	prompt = strings.Trim(prompt, " ,;|")
+
+	if sanitizer.MaxLength > 0 {
+		if runes := []rune(prompt); len(runes) > sanitizer.MaxLength {
+			prompt = strings.Trim(string(runes[:sanitizer.MaxLength]), " ,;|")
+		}
+	}
+	return prompt
+}
+
+// blockedPromptRule returns the promptBlocklist term or pattern userPrompt matches, if any.
+// Pass only the user's text: operator tags and LoRA trigger words must never block a prompt.
+func blockedPromptRule(userPrompt string, rules *promptRules) (string, bool) {
+	for _, rule := range rules.blocklist {
+		if rule.re.MatchString(userPrompt) {
+			return rule.rule, true
+		}
+	}
+	return "", false
+}
+
+// promptTags is the operator text placed directly around a user prompt: the global prompt
+// prefix and suffix, or those of the user's group.
+type promptTags struct {
+	Prefix string
+	Suffix string
+}
+
+// globalPromptTags returns the tags of users without a group override.
+func globalPromptTags(config *cfg.Config) promptTags {
+	return promptTags{Prefix: config.GlobalPromptPrefix, Suffix: config.GlobalPromptSuffix}
+}
+
+// userPromptTags returns the prompt tags of userID. The prefix and the suffix each come
+// from the first group of the user that sets them, in config order, else the global ones.
+func userPromptTags(userID int64, config *cfg.Config) promptTags {
+	tags := globalPromptTags(config)
+	var prefixSet, suffixSet bool
+	for _, group := range config.UserGroups {
+		if !slices.Contains(group.UserIDs, userID) {
+			continue
+		}
+		if group.PromptPrefix != nil && !prefixSet {
+			tags.Prefix, prefixSet = *group.PromptPrefix, true
+		}
+		if group.PromptSuffix != nil && !suffixSet {
+			tags.Suffix, suffixSet = *group.PromptSuffix, true
+		}
+	}
+	return tags
+}
+
+// buildPrompt assembles the final prompt sent to fal. The order is fixed:
+//
+//	sanitizer prefix, prefix LoRA append_prompt texts (in the given order), tags prefix,
+//	user prompt, suffix LoRA append_prompt texts (in the given order), tags suffix,
+//	sanitizer suffix
+//
+// Operator text is never passed through the sanitizer, so mandatory tags always survive;
+// only the user prompt is cleaned.
+func buildPrompt(basePrompt string, rules *promptRules, tags promptTags, loras ...LoraConfig) string {
+	sanitizer := rules.sanitizer
+	parts := make([]string, 0, len(loras)+5)
+	addPart := func(text string) {
+		if text = strings.TrimSpace(text); text != "" {
+			parts = append(parts, text)
+		}
+	}
+
+	if sanitizer.Enabled {
+		addPart(sanitizer.Prefix)
+	}
+	for _, lora := range loras {
+		if lora.PromptPosition != cfg.PromptPositionSuffix {
+			addPart(lora.AppendPrompt)
+		}
+	}
+	addPart(tags.Prefix)
+	addPart(sanitizeUserPrompt(basePrompt, rules))
+	for _, lora := range loras {
+		if lora.PromptPosition == cfg.PromptPositionSuffix {
+			addPart(lora.AppendPrompt)
+		}
+	}
+	addPart(tags.Suffix)
+	if sanitizer.Enabled {
+		addPart(sanitizer.Suffix)
+	}
+	return strings.Join(parts, " ")
+}
+
+// applyCaptionTemplate wraps a photo caption in the configured template by replacing
+// every "{caption}" placeholder. An empty template keeps the raw caption.
+func applyCaptionTemplate(caption string, template string) string {
+	if template == "" {
+		return caption
+	}
+	return strings.TrimSpace(strings.ReplaceAll(template, "{caption}", strings.TrimSpace(caption)))
+}
+
+// BuildPromptFromConfig builds the final prompt for LoRAs taken straight from the config
+// file, in the same order the bot uses for users without a group prompt override. It lets
+// the CLI reproduce bot prompts exactly.
+func BuildPromptFromConfig(basePrompt string, config *cfg.Config, loras ...cfg.LoraConfig) string {
+	botLoras := make([]LoraConfig, 0, len(loras))
+	for _, lora := range loras {
+		botLoras = append(botLoras, LoraConfig{Name: lora.Name, URL: lora.URL, Weight: lora.Weight, AppendPrompt: lora.AppendPrompt, PromptPosition: lora.PromptPosition})
+	}
+	return buildPrompt(basePrompt, compilePromptRules(config), globalPromptTags(config), botLoras...)
+}
@@ -0,0 +1,170 @@
+package bot
+
+import (
+	"testing"
+
+	cfg "github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+)
+
+func TestSanitizeUserPrompt(t *testing.T) {
+	sanitizer := cfg.PromptSanitizerConfig{Enabled: true, BlockedTerms: []string{"nsfw", " watermark "}}
+	tests := []struct {
+		name      string
+		prompt    string
+		sanitizer cfg.PromptSanitizerConfig
+		want      string
+	}{
+		{"disabled keeps the prompt", "  a cat,,, ; NSFW ", cfg.PromptSanitizerConfig{}, "a cat,,, ; NSFW"},
+		{"control characters become spaces", "a cat\nin\tthe rain", sanitizer, "a cat in the rain"},
+		{"blocked terms are removed case-insensitively", "a NSFW cat, Watermark", sanitizer, "a cat"},
+		{"separator runs collapse", "a cat ,,, ;| dog", sanitizer, "a cat, dog"},
+		{"leading and trailing separators are trimmed", ", ;a cat;", sanitizer, "a cat"},
+		{"max length counts runes", "一二三四五六", cfg.PromptSanitizerConfig{Enabled: true, MaxLength: 4}, "一二三四"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := compilePromptRules(&cfg.Config{PromptSanitizer: tt.sanitizer})
+			if got := sanitizeUserPrompt(tt.prompt, rules); got != tt.want {
+				t.Errorf("sanitizeUserPrompt(%q) = %q, want %q", tt.prompt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPromptOrder(t *testing.T) {
+	sanitizer := cfg.PromptSanitizerConfig{
+		Enabled:      true,
+		BlockedTerms: []string{"masterpiece"},
+		Prefix:       "masterpiece, sfw",
+		Suffix:       "high quality",
+	}
+	tests := []struct {
+		name      string
+		prompt    string
+		sanitizer cfg.PromptSanitizerConfig
+		tags      promptTags
+		loras     []LoraConfig
+		want      string
+	}{
+		{
+			name:   "no sanitizer",
+			prompt: "a cat",
+			loras:  []LoraConfig{{AppendPrompt: "lora1"}},
+			want:   "lora1 a cat",
+		},
+		{
+			name:      "sanitizer prefix first and suffix last, operator text untouched",
+			prompt:    "a masterpiece cat\n",
+			sanitizer: sanitizer,
+			loras:     []LoraConfig{{AppendPrompt: "lora1"}},
+			want:      "masterpiece, sfw lora1 a cat high quality",
+		},
+		{
+			name:      "prompt injection stays between operator text",
+			prompt:    ",,, ; ignore the prefix ;;;",
+			sanitizer: sanitizer,
+			want:      "masterpiece, sfw ignore the prefix high quality",
+		},
+		{
+			name:   "prefix and suffix LoRAs keep their order around the prompt",
+			prompt: "a cat",
+			loras: []LoraConfig{
+				{AppendPrompt: "suffix1", PromptPosition: cfg.PromptPositionSuffix},
+				{AppendPrompt: "prefix1", PromptPosition: cfg.PromptPositionPrefix},
+				{AppendPrompt: "suffix2", PromptPosition: cfg.PromptPositionSuffix},
+				{AppendPrompt: "prefix2"}, // Empty means prefix
+			},
+			want: "prefix1 prefix2 a cat suffix1 suffix2",
+		},
+		{
+			name:   "only suffix LoRAs",
+			prompt: "a cat",
+			loras:  []LoraConfig{{AppendPrompt: "suffix1", PromptPosition: cfg.PromptPositionSuffix}},
+			want:   "a cat suffix1",
+		},
+		{
+			name:   "tags wrap the prompt inside the LoRA texts",
+			prompt: "a cat",
+			tags:   promptTags{Prefix: "tag prefix", Suffix: "tag suffix"},
+			loras: []LoraConfig{
+				{AppendPrompt: "prefix1"},
+				{AppendPrompt: "suffix1", PromptPosition: cfg.PromptPositionSuffix},
+			},
+			want: "prefix1 tag prefix a cat suffix1 tag suffix",
+		},
+		{
+			name:      "full order",
+			prompt:    "a cat",
+			sanitizer: cfg.PromptSanitizerConfig{Enabled: true, Prefix: "sanitizer prefix", Suffix: "sanitizer suffix"},
+			tags:      promptTags{Prefix: "tag prefix", Suffix: "tag suffix"},
+			loras: []LoraConfig{
+				{AppendPrompt: "suffix1", PromptPosition: cfg.PromptPositionSuffix},
+				{AppendPrompt: "prefix1", PromptPosition: cfg.PromptPositionPrefix},
+			},
+			want: "sanitizer prefix prefix1 tag prefix a cat suffix1 tag suffix sanitizer suffix",
+		},
+		{
+			name:      "empty parts are skipped",
+			prompt:    "  ",
+			sanitizer: cfg.PromptSanitizerConfig{Enabled: true, Suffix: "high quality"},
+			loras:     []LoraConfig{{AppendPrompt: " "}},
+			want:      "high quality",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := compilePromptRules(&cfg.Config{PromptSanitizer: tt.sanitizer})
+			if got := buildPrompt(tt.prompt, rules, tt.tags, tt.loras...); got != tt.want {
+				t.Errorf("buildPrompt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserPromptTags(t *testing.T) {
+	groupPrefix, empty := "group prefix", ""
+	config := &cfg.Config{
+		GlobalPromptPrefix: "global prefix",
+		GlobalPromptSuffix: "global suffix",
+		UserGroups: []cfg.UserGroup{
+			{Name: "first", UserIDs: []int64{1, 2}, PromptPrefix: &groupPrefix},
+			{Name: "second", UserIDs: []int64{2}, PromptPrefix: &empty, PromptSuffix: &empty},
+		},
+	}
+	tests := []struct {
+		userID int64
+		want   promptTags
+	}{
+		{0, promptTags{Prefix: "global prefix", Suffix: "global suffix"}},
+		{1, promptTags{Prefix: "group prefix", Suffix: "global suffix"}},
+		{2, promptTags{Prefix: "group prefix", Suffix: ""}}, // First group setting each one wins
+	}
+	for _, tt := range tests {
+		if got := userPromptTags(tt.userID, config); got != tt.want {
+			t.Errorf("userPromptTags(%d) = %+v, want %+v", tt.userID, got, tt.want)
+		}
+	}
+}
+
+func TestBlockedPromptRule(t *testing.T) {
+	rules := compilePromptRules(&cfg.Config{PromptBlocklist: cfg.PromptBlocklistConfig{
+		Terms:    []string{"bad  phrase"},
+		Patterns: []string{`\bforbidden\w*`},
+	}})
+	tests := []struct {
+		prompt   string
+		wantRule string
+		blocked  bool
+	}{
+		{"a cat", "", false},
+		{"a BAD\nphrase here", "bad  phrase", true},
+		{"something Forbiddenness", `\bforbidden\w*`, true},
+		{"unforbidden", "", false},
+	}
+	for _, tt := range tests {
+		rule, blocked := blockedPromptRule(tt.prompt, rules)
+		if rule != tt.wantRule || blocked != tt.blocked {
+			t.Errorf("blockedPromptRule(%q) = %q, %v; want %q, %v", tt.prompt, rule, blocked, tt.wantRule, tt.blocked)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// Quick-repeat actions and the i18n keys of their reply keyboard buttons.
+const (
+	quickRepeatRegenerate = "regenerate"
+	quickRepeatNewSeed    = "new_seed"
+	quickRepeatRemix      = "remix"
+	quickRepeatUpscale    = "upscale"
+	quickRepeatEditPrompt = "edit_prompt"
+)
+
+var quickRepeatButtonKeys = map[string]string{
+	quickRepeatRegenerate: "quick_repeat_button_regenerate",
+	quickRepeatNewSeed:    "quick_repeat_button_new_seed",
+	quickRepeatRemix:      "quick_repeat_button_remix",
+	quickRepeatUpscale:    "quick_repeat_button_upscale",
+	quickRepeatEditPrompt: "quick_repeat_button_edit_prompt",
+}
+
+// QuickRepeatStore remembers each user's last generation for the quick-repeat reply
+// keyboard, and whether the keyboard is currently shown. Nothing is persisted; after a
+// restart the buttons fall back to the user's generation history.
+type QuickRepeatStore struct {
+	mu    sync.Mutex
+	last  map[int64]UserState
+	shown map[int64]bool
+}
+
+func NewQuickRepeatStore() *QuickRepeatStore {
+	return &QuickRepeatStore{
+		last:  make(map[int64]UserState),
+		shown: make(map[int64]bool),
+	}
+}
+
+// Remember stores the generation inputs of state as the user's last generation. A one-off
+// seed is dropped, so regenerating rolls new images unless the user locked a seed.
+func (s *QuickRepeatStore) Remember(state *UserState) {
+	snapshot := UserState{
+		UserID:            state.UserID,
+		OriginalCaption:   state.OriginalCaption,
+		SelectedLoras:     append([]string(nil), state.SelectedLoras...),
+		SelectedBaseLoras: append([]string(nil), state.SelectedBaseLoras...),
+		GridSize:          state.GridSize,
+		Sweep:             state.Sweep,
+	}
+	if state.Overrides != nil {
+		overrides := *state.Overrides
+		overrides.Seed = nil
+		snapshot.Overrides = &overrides
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last[state.UserID] = snapshot
+}
+
+// Last returns a copy of the user's last generation inputs.
+func (s *QuickRepeatStore) Last(userID int64) (UserState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot, ok := s.last[userID]
+	if ok && snapshot.Overrides != nil {
+		overrides := *snapshot.Overrides
+		snapshot.Overrides = &overrides
+	}
+	return snapshot, ok
+}
+
+// SetShown records whether the user currently has the keyboard and reports the previous value.
+func (s *QuickRepeatStore) SetShown(userID int64, shown bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	was := s.shown[userID]
+	if shown {
+		s.shown[userID] = true
+	} else {
+		delete(s.shown, userID)
+	}
+	return was
+}
+
+// quickRepeatEnabled reports whether userID turned on the quick-repeat keyboard in /myconfig.
+func quickRepeatEnabled(userID int64, deps BotDeps) bool {
+	userCfg, err := st.GetUserGenerationConfig(deps.DB, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		deps.Logger.Warn("Failed to get user config for quick repeat, using default", zap.Error(err), zap.Int64("user_id", userID))
+	}
+	return userCfg != nil && userCfg.QuickRepeat != nil && *userCfg.QuickRepeat
+}
+
+// quickRepeatAction maps a reply keyboard button text to its action. Labels of every
+// language are accepted, so buttons keep working after the user switches languages.
+func quickRepeatAction(text string, deps BotDeps) (string, bool) {
+	for code := range deps.I18n.GetAvailableLanguages() {
+		lang := code
+		for action, key := range quickRepeatButtonKeys {
+			if text == deps.I18n.T(&lang, key) {
+				return action, true
+			}
+		}
+	}
+	return "", false
+}
+
+// sendQuickRepeatKeyboard shows the quick-repeat reply keyboard below a delivered result.
+func sendQuickRepeatKeyboard(chatID int64, userID int64, deps BotDeps) {
+	userLang := getUserLanguagePreference(userID, deps)
+	button := func(action string) tgbotapi.KeyboardButton {
+		return tgbotapi.NewKeyboardButton(deps.I18n.T(userLang, quickRepeatButtonKeys[action]))
+	}
+	keyboard := tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(button(quickRepeatRegenerate), button(quickRepeatNewSeed), button(quickRepeatRemix)),
+		tgbotapi.NewKeyboardButtonRow(button(quickRepeatUpscale), button(quickRepeatEditPrompt)),
+	)
+	msg := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "quick_repeat_keyboard_shown"))
+	msg.ReplyMarkup = keyboard
+	if _, err := deps.Bot.Send(msg); err != nil {
+		deps.Logger.Warn("Failed to send quick-repeat keyboard", zap.Error(err), zap.Int64("user_id", userID))
+		return
+	}
+	deps.Repeats.SetShown(userID, true)
+}
+
+// clearQuickRepeatKeyboard removes the quick-repeat keyboard if it is shown. Telegram only
+// removes reply keyboards with a new message, which is deleted right away.
+func clearQuickRepeatKeyboard(chatID int64, userID int64, deps BotDeps) {
+	if !deps.Repeats.SetShown(userID, false) {
+		return
+	}
+	userLang := getUserLanguagePreference(userID, deps)
+	msg := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "quick_repeat_keyboard_hidden"))
+	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(false)
+	sent, err := deps.Bot.Send(msg)
+	if err != nil {
+		deps.Logger.Warn("Failed to remove quick-repeat keyboard", zap.Error(err), zap.Int64("user_id", userID))
+		return
+	}
+	deps.Bot.Request(tgbotapi.NewDeleteMessage(chatID, sent.MessageID))
+}
+
+// HandleQuickRepeatMessage runs the quick-repeat action of a reply keyboard button press.
+// It reports false if text isn't a button label or the user has the keyboard turned off,
+// so the text is handled as a prompt instead.
+func HandleQuickRepeatMessage(message *tgbotapi.Message, deps BotDeps) bool {
+	action, ok := quickRepeatAction(message.Text, deps)
+	if !ok || !quickRepeatEnabled(message.From.ID, deps) {
+		return false
+	}
+	userID := message.From.ID
+	chatID := message.Chat.ID
+	userLang := getUserLanguagePreference(userID, deps)
+	deps.StateManager.ClearState(userID)
+
+	last, found := deps.Repeats.Last(userID)
+	entries, err := st.ListGenerationHistory(deps.DB, userID, 1)
+	if err != nil {
+		deps.Logger.Error("Failed to load last generation for quick repeat", zap.Error(err), zap.Int64("user_id", userID))
+	}
+	if !found && len(entries) > 0 {
+		// Forgotten since a restart; repeat the newest history entry instead
+		entry := entries[0]
+		guidanceScale := entry.GuidanceScale
+		last = UserState{
+			UserID:            userID,
+			OriginalCaption:   entry.Prompt,
+			SelectedLoras:     []string{entry.StandardLora},
+			SelectedBaseLoras: entry.BaseLoras,
+			Overrides: &GenerationOverrides{
+				ImageSize:         entry.ImageSize,
+				NumInferenceSteps: entry.NumInferenceSteps,
+				GuidanceScale:     &guidanceScale,
+				NumImages:         entry.NumImages,
+			},
+		}
+		found = true
+	}
+	if !found {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "quick_repeat_nothing")))
+		return true
+	}
+
+	switch action {
+	case quickRepeatUpscale:
+		if len(entries) == 0 {
+			deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "resize_no_history")))
+			return true
+		}
+		msg := tgbotapi.NewMessage(chatID, resizeSizePromptText(&entries[0], userLang, deps))
+		msg.ReplyMarkup = resizeSizeKeyboard(&entries[0], userLang, deps)
+		deps.Bot.Send(msg)
+
+	case quickRepeatEditPrompt:
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "quick_repeat_edit_prompt", "prompt", last.OriginalCaption)))
+
+	default: // Regenerate, new seed or remix
+		if action == quickRepeatNewSeed || action == quickRepeatRemix {
+			if last.Overrides == nil {
+				last.Overrides = &GenerationOverrides{}
+			}
+			seed := uint64(rand.Int31()) // Stay well inside the int range fal accepts
+			last.Overrides.Seed = &seed
+		}
+		status := deps.I18n.T(userLang, "quick_repeat_starting")
+		if action == quickRepeatRemix {
+			last.OriginalCaption, last.RemixModifiers = remixPrompt(last.OriginalCaption, deps)
+		}
+		if len(last.RemixModifiers) > 0 {
+			status = deps.I18n.T(userLang, "quick_repeat_remix_starting", "modifiers", strings.Join(last.RemixModifiers, ", "))
+		}
+		statusMsg, err := deps.Bot.Send(tgbotapi.NewMessage(chatID, status))
+		if err != nil {
+			deps.Logger.Error("Failed to send quick-repeat status message", zap.Error(err), zap.Int64("user_id", userID))
+			return true
+		}
+		last.ChatID = chatID
+		last.MessageID = statusMsg.MessageID
+		last.Action = "generating"
+		deps.Logger.Info("Repeating last generation", zap.Int64("user_id", userID), zap.String("action", action))
+		go GenerateImagesForUser(&last, deps)
+	}
+	return true
+}
+
+// remixPrompt appends up to remix.modifiersPerRemix random modifiers from the remix pool
+// to prompt, skipping those it already contains, and returns the new prompt and the
+// modifiers added.
+func remixPrompt(prompt string, deps BotDeps) (string, []string) {
+	remixCfg := deps.Cfg().Remix
+	lowerPrompt := strings.ToLower(prompt)
+	var candidates []string
+	for _, modifier := range remixCfg.Modifiers {
+		if modifier = strings.TrimSpace(modifier); modifier != "" && !strings.Contains(lowerPrompt, strings.ToLower(modifier)) {
+			candidates = append(candidates, modifier)
+		}
+	}
+	rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	added := candidates[:min(remixCfg.ModifiersPerRemix, len(candidates))]
+	if len(added) == 0 {
+		return prompt, nil
+	}
+	return strings.TrimRight(prompt, " ,") + ", " + strings.Join(added, ", "), added
+}
@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+	"go.uber.org/zap"
+)
+
+// recentPromptsLimit is how many prompts are kept per user and offered by /prompt.
+const recentPromptsLimit = 10
+
+// rememberPrompt adds prompt to the user's recent prompts. Failures are only logged.
+func rememberPrompt(userID int64, prompt string, deps BotDeps) {
+	prompt = strings.TrimSpace(prompt)
+	if deps.DB == nil || prompt == "" {
+		return
+	}
+	if err := st.AddRecentPrompt(deps.DB, userID, prompt, recentPromptsLimit); err != nil {
+		deps.Logger.Warn("Failed to remember prompt", zap.Error(err), zap.Int64("user_id", userID))
+	}
+}
+
+// HandlePromptCommand handles /prompt and lists the user's recent prompts as buttons.
+// Tapping one starts LoRA selection with that prompt.
+func HandlePromptCommand(chatID int64, userID int64, deps BotDeps) {
+	userLang := getUserLanguagePreference(userID, deps)
+
+	prompts, err := st.ListRecentPrompts(deps.DB, userID, recentPromptsLimit)
+	if err != nil {
+		deps.Logger.Error("Failed to list recent prompts", zap.Error(err), zap.Int64("user_id", userID))
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "error_generic")))
+		return
+	}
+	if len(prompts) == 0 {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "prompt_no_recent")))
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, p := range prompts {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(truncateRunes(p.Prompt, 60), fmt.Sprintf("prompt_pick_%d", p.ID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "lora_selection_keyboard_cancel_button"), "prompt_cancel"),
+	))
+	msg := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "prompt_pick_recent"))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	deps.Bot.Send(msg)
+}
+
+// HandlePromptCallback handles prompt_pick_<id> and prompt_cancel.
+func HandlePromptCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
+	userID := callbackQuery.From.ID
+	if callbackQuery.Message == nil {
+		answer := tgbotapi.NewCallback(callbackQuery.ID, deps.I18n.T(nil, "callback_error_nil_message"))
+		deps.Bot.Request(answer)
+		return
+	}
+	chatID := callbackQuery.Message.Chat.ID
+	messageID := callbackQuery.Message.MessageID
+	data := callbackQuery.Data
+	userLang := getUserLanguagePreference(userID, deps)
+	answer := tgbotapi.NewCallback(callbackQuery.ID, "")
+
+	switch {
+	case data == "prompt_cancel":
+		deps.Bot.Request(answer)
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, deps.I18n.T(userLang, "cancel_state_success"))
+		edit.ReplyMarkup = nil
+		deps.Bot.Send(edit)
+
+	case strings.HasPrefix(data, "prompt_pick_"):
+		promptID, err := strconv.ParseInt(strings.TrimPrefix(data, "prompt_pick_"), 10, 64)
+		if err != nil {
+			answer.Text = deps.I18n.T(userLang, "prompt_not_found")
+			deps.Bot.Request(answer)
+			return
+		}
+		recent, err := st.GetRecentPrompt(deps.DB, promptID)
+		if err != nil || recent.UserID != userID {
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				deps.Logger.Error("Failed to load recent prompt", zap.Error(err), zap.Int64("prompt_id", promptID))
+			}
+			answer.Text = deps.I18n.T(userLang, "prompt_not_found")
+			deps.Bot.Request(answer)
+			return
+		}
+		deps.Bot.Request(answer)
+
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, deps.I18n.T(userLang, "prompt_selected", "prompt", truncateRunes(recent.Prompt, 200)))
+		edit.ReplyMarkup = nil
+		deps.Bot.Send(edit)
+
+		deps.StateManager.ClearState(userID)
+		startTextPromptFlow(chatID, userID, recent.Prompt, false, deps)
+
+	default:
+		answer.Text = deps.I18n.T(userLang, "lora_select_unknown_action")
+		deps.Bot.Request(answer)
+	}
+}
@@ -0,0 +1,170 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+	"github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// failedRequestRetention is how many failed requests are kept for /replay.
+const failedRequestRetention = 200
+
+// maxFailedRequestsListed is how many failed requests /replay without an ID lists.
+const maxFailedRequestsListed = 10
+
+// recordFailedRequest stores a failed request exactly as it was submitted, so admins can
+// replay it, and returns its ID (0 if it couldn't be stored).
+func recordFailedRequest(userID int64, requestID string, params *GenerationParameters, prompt string, loraNames []string, loras []falapi.LoraWeight, failure error, deps BotDeps) int64 {
+	stored := make([]st.FailedRequestLora, 0, len(loras))
+	for _, lora := range loras {
+		stored = append(stored, st.FailedRequestLora{Path: lora.Path, Scale: lora.Scale})
+	}
+	id, err := st.AddFailedRequest(deps.DB, st.FailedRequest{
+		UserID:            userID,
+		RequestID:         requestID,
+		Model:             params.Model,
+		Prompt:            prompt,
+		LoraNames:         loraNames,
+		Loras:             stored,
+		ImageSize:         params.ImageSize,
+		NumInferenceSteps: params.NumInferenceSteps,
+		GuidanceScale:     params.GuidanceScale,
+		NumImages:         params.NumImages,
+		Seed:              params.Seed,
+		SafetyChecker:     params.SafetyChecker,
+		Error:             failure.Error(),
+	}, failedRequestRetention)
+	if err != nil {
+		deps.Logger.Warn("Failed to store failed request for replay", zap.Error(err), zap.Int64("user_id", userID), zap.String("request_id", requestID))
+		return 0
+	}
+	return id
+}
+
+// HandleReplayCommand handles the admin /replay command. Without an argument it lists the
+// latest failed requests; "/replay <id>" submits one again, free of charge, and reports
+// whether it succeeds now.
+func HandleReplayCommand(message *tgbotapi.Message, deps BotDeps) {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+	userLang := getUserLanguagePreference(userID, deps)
+	if !deps.Authorizer.IsAdmin(userID) {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "myconfig_command_admin_only")))
+		return
+	}
+
+	arg := strings.TrimPrefix(strings.TrimSpace(message.CommandArguments()), "#")
+	if arg == "" {
+		listFailedRequests(chatID, userLang, deps)
+		return
+	}
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "replay_usage")))
+		return
+	}
+	failed, err := st.GetFailedRequest(deps.DB, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "replay_not_found", "id", id)))
+		return
+	}
+	if err != nil {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "error_generic")))
+		return
+	}
+
+	statusMsg, err := deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "replay_started", "id", id, "loras", strings.Join(failed.LoraNames, "+"))))
+	if err != nil {
+		deps.Logger.Error("Failed to send replay status message", zap.Error(err), zap.Int64("user_id", userID))
+		return
+	}
+	deps.Logger.Info("Admin replaying failed request", zap.Int64("admin_id", userID), zap.Int64("failed_request_id", id), zap.Int64("owner_id", failed.UserID))
+	go runReplay(chatID, statusMsg.MessageID, failed, userLang, deps)
+}
+
+// listFailedRequests shows the newest failed requests with their IDs for /replay.
+func listFailedRequests(chatID int64, userLang *string, deps BotDeps) {
+	failed, err := st.ListFailedRequests(deps.DB, maxFailedRequestsListed)
+	if err != nil {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "error_generic")))
+		return
+	}
+	if len(failed) == 0 {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "replay_none")))
+		return
+	}
+	var text strings.Builder
+	text.WriteString(deps.I18n.T(userLang, "replay_list_title"))
+	for _, r := range failed {
+		text.WriteString(deps.I18n.T(userLang, "replay_list_item",
+			"id", r.ID,
+			"time", r.CreatedAt.Format("2006-01-02 15:04"),
+			"user", r.UserID,
+			"loras", strings.Join(r.LoraNames, "+"),
+			"error", truncateRunes(r.Error, 80),
+		))
+	}
+	deps.Bot.Send(tgbotapi.NewMessage(chatID, text.String()))
+}
+
+// runReplay submits failed again with the stored parameters and edits the status message
+// with the outcome. The replay bypasses balance but still takes a generation slot.
+func runReplay(chatID int64, messageID int, failed *st.FailedRequest, userLang *string, deps BotDeps) {
+	loras := make([]falapi.LoraWeight, 0, len(failed.Loras))
+	for _, lora := range failed.Loras {
+		loras = append(loras, falapi.LoraWeight{Path: lora.Path, Scale: lora.Scale})
+	}
+
+	deps.Limiter.Acquire()
+	defer deps.Limiter.Release()
+
+	start := time.Now()
+	report := func(key string, args ...interface{}) {
+		args = append(args, "id", failed.ID, "original", failed.Error, "duration", time.Since(start).Truncate(time.Second).String())
+		deps.Edits.EditNow(tgbotapi.NewEditMessageText(chatID, messageID, deps.I18n.T(userLang, key, args...)))
+	}
+
+	requestID, err := deps.FalClient.SubmitGenerationRequestTo(
+		failed.Model,
+		failed.Prompt,
+		loras,
+		failed.LoraNames,
+		failed.ImageSize,
+		failed.NumInferenceSteps,
+		failed.GuidanceScale,
+		failed.NumImages,
+		failed.Seed,
+		failed.SafetyChecker,
+	)
+	if err != nil {
+		deps.Logger.Warn("Replay submission failed", zap.Error(err), zap.Int64("failed_request_id", failed.ID))
+		report("replay_failed", "reqID", "-", "error", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	var result *falapi.GenerateResponse
+	if deps.Webhooks != nil {
+		deps.Webhooks.Register(requestID)
+		result, err = deps.Webhooks.Wait(ctx, requestID)
+	} else {
+		result, err = deps.FalClient.PollForResult(ctx, requestID, failed.Model, 5*time.Second)
+	}
+	if err != nil {
+		deps.Logger.Warn("Replay failed again", zap.Error(err), zap.Int64("failed_request_id", failed.ID), zap.String("request_id", requestID))
+		report("replay_failed", "reqID", requestID, "error", err.Error())
+		return
+	}
+	deps.Logger.Info("Replay succeeded", zap.Int64("failed_request_id", failed.ID), zap.String("request_id", requestID), zap.Int("image_count", len(result.Images)))
+	report("replay_succeeded", "reqID", requestID, "count", len(result.Images))
+}
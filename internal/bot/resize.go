@@ -0,0 +1,206 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+	falapi "github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+	"go.uber.org/zap"
+)
+
+// resizeHistoryLimit is how many recent history entries /resize offers.
+const resizeHistoryLimit = 10
+
+// HandleResizeCommand handles /resize [historyID]. Without an ID it lists the user's
+// recent results; with one it shows the size keyboard for that entry directly.
+func HandleResizeCommand(message *tgbotapi.Message, deps BotDeps) {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+	userLang := getUserLanguagePreference(userID, deps)
+
+	if arg := strings.TrimSpace(message.CommandArguments()); arg != "" {
+		historyID, err := strconv.ParseInt(strings.TrimPrefix(arg, "#"), 10, 64)
+		if err != nil {
+			deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "resize_invalid_id")))
+			return
+		}
+		entry, ok := getOwnedHistoryEntry(userID, historyID, deps)
+		if !ok {
+			deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "resize_not_found")))
+			return
+		}
+		msg := tgbotapi.NewMessage(chatID, resizeSizePromptText(entry, userLang, deps))
+		keyboard := resizeSizeKeyboard(entry, userLang, deps)
+		msg.ReplyMarkup = keyboard
+		deps.Bot.Send(msg)
+		return
+	}
+
+	entries, err := st.ListGenerationHistory(deps.DB, userID, resizeHistoryLimit)
+	if err != nil {
+		deps.Logger.Error("Failed to list history for /resize", zap.Error(err), zap.Int64("user_id", userID))
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "error_generic")))
+		return
+	}
+	if len(entries) == 0 {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "resize_no_history")))
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, entry := range entries {
+		buttonText := fmt.Sprintf("#%d %s: %s", entry.ID, entry.StandardLora, truncateRunes(entry.Prompt, 30))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonText, fmt.Sprintf("resize_pick_%d", entry.ID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "lora_selection_keyboard_cancel_button"), "resize_cancel"),
+	))
+	msg := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "resize_pick_prompt"))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	deps.Bot.Send(msg)
+}
+
+// HandleResizeCallback handles resize_pick_<id>, resize_size_<id>_<size> and resize_cancel.
+// The chosen size is used for this one request only and never written to the user's config.
+func HandleResizeCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
+	userID := callbackQuery.From.ID
+	if callbackQuery.Message == nil {
+		answer := tgbotapi.NewCallback(callbackQuery.ID, deps.I18n.T(nil, "callback_error_nil_message"))
+		deps.Bot.Request(answer)
+		return
+	}
+	chatID := callbackQuery.Message.Chat.ID
+	messageID := callbackQuery.Message.MessageID
+	data := callbackQuery.Data
+	userLang := getUserLanguagePreference(userID, deps)
+	answer := tgbotapi.NewCallback(callbackQuery.ID, "")
+
+	switch {
+	case data == "resize_cancel":
+		deps.Bot.Request(answer)
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, deps.I18n.T(userLang, "cancel_state_success"))
+		edit.ReplyMarkup = nil
+		deps.Bot.Send(edit)
+
+	case strings.HasPrefix(data, "resize_pick_"):
+		historyID, err := strconv.ParseInt(strings.TrimPrefix(data, "resize_pick_"), 10, 64)
+		if err != nil {
+			answer.Text = deps.I18n.T(userLang, "resize_invalid_id")
+			deps.Bot.Request(answer)
+			return
+		}
+		entry, ok := getOwnedHistoryEntry(userID, historyID, deps)
+		if !ok {
+			answer.Text = deps.I18n.T(userLang, "resize_not_found")
+			deps.Bot.Request(answer)
+			return
+		}
+		deps.Bot.Request(answer)
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, resizeSizePromptText(entry, userLang, deps))
+		keyboard := resizeSizeKeyboard(entry, userLang, deps)
+		edit.ReplyMarkup = &keyboard
+		deps.Bot.Send(edit)
+
+	case strings.HasPrefix(data, "resize_size_"):
+		// Format: resize_size_<id>_<size>; sizes contain underscores, IDs don't.
+		idStr, size, found := strings.Cut(strings.TrimPrefix(data, "resize_size_"), "_")
+		historyID, err := strconv.ParseInt(idStr, 10, 64)
+		if !found || err != nil || !falapi.IsImageSizePreset(size) {
+			deps.Logger.Warn("Invalid resize callback data", zap.String("data", data), zap.Int64("user_id", userID))
+			answer.Text = deps.I18n.T(userLang, "config_callback_image_size_invalid")
+			deps.Bot.Request(answer)
+			return
+		}
+		entry, ok := getOwnedHistoryEntry(userID, historyID, deps)
+		if !ok {
+			answer.Text = deps.I18n.T(userLang, "resize_not_found")
+			deps.Bot.Request(answer)
+			return
+		}
+		if _, visible := findLoraByName(entry.StandardLora, GetUserVisibleLoras(userID, deps)); !visible {
+			answer.Text = deps.I18n.T(userLang, "resize_lora_unavailable", "name", entry.StandardLora)
+			deps.Bot.Request(answer)
+			return
+		}
+		deps.Bot.Request(answer)
+
+		seed := entry.Seed
+		guidanceScale := entry.GuidanceScale
+		state := &UserState{
+			UserID:            userID,
+			ChatID:            chatID,
+			MessageID:         messageID,
+			Action:            "generating",
+			OriginalCaption:   entry.Prompt,
+			SelectedLoras:     []string{entry.StandardLora},
+			SelectedBaseLoras: entry.BaseLoras,
+			Overrides: &GenerationOverrides{
+				ImageSize:         size,
+				NumInferenceSteps: entry.NumInferenceSteps,
+				GuidanceScale:     &guidanceScale,
+				NumImages:         entry.NumImages,
+				Seed:              &seed,
+			},
+		}
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, deps.I18n.T(userLang, "resize_starting", "id", entry.ID, "size", size, "seed", entry.Seed))
+		edit.ReplyMarkup = nil
+		deps.Bot.Send(edit)
+
+		deps.Logger.Info("Regenerating history entry at new size", zap.Int64("user_id", userID), zap.Int64("history_id", entry.ID), zap.String("size", size))
+		go GenerateImagesForUser(state, deps)
+
+	default:
+		answer.Text = deps.I18n.T(userLang, "lora_select_unknown_action")
+		deps.Bot.Request(answer)
+	}
+}
+
+// getOwnedHistoryEntry loads a history entry and checks that it belongs to userID.
+// Entries of other users are reported as not found.
+func getOwnedHistoryEntry(userID int64, historyID int64, deps BotDeps) (*st.GenerationHistory, bool) {
+	entry, err := st.GetGenerationHistory(deps.DB, historyID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			deps.Logger.Error("Failed to load history entry", zap.Error(err), zap.Int64("history_id", historyID))
+		}
+		return nil, false
+	}
+	if entry.UserID != userID {
+		deps.Logger.Warn("User requested a history entry they don't own", zap.Int64("user_id", userID), zap.Int64("history_id", historyID), zap.Int64("owner_id", entry.UserID))
+		return nil, false
+	}
+	return entry, true
+}
+
+func resizeSizePromptText(entry *st.GenerationHistory, userLang *string, deps BotDeps) string {
+	return deps.I18n.T(userLang, "resize_size_prompt",
+		"id", entry.ID,
+		"size", entry.ImageSize,
+		"lora", entry.StandardLora,
+		"prompt", truncateRunes(entry.Prompt, 200),
+	)
+}
+
+func resizeSizeKeyboard(entry *st.GenerationHistory, userLang *string, deps BotDeps) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, size := range falapi.ImageSizePresetNames {
+		buttonText := size
+		if size == entry.ImageSize {
+			buttonText = deps.I18n.T(userLang, "button_arrow_right") + " " + size
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonText, fmt.Sprintf("resize_size_%d_%s", entry.ID, size)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(deps.I18n.T(userLang, "lora_selection_keyboard_cancel_button"), "resize_cancel"),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
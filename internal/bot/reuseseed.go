@@ -0,0 +1,203 @@
+package bot
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	// seedButtonTTL is how long the generation behind a "reuse seed" button is remembered.
+	seedButtonTTL = 24 * time.Hour
+	// maxSeedButtons caps the buttons under one result.
+	maxSeedButtons = 10
+)
+
+// seedButtonKey identifies the message carrying the buttons.
+type seedButtonKey struct {
+	chatID    int64
+	messageID int
+}
+
+// seedButtonEntry is the generation behind a button message: its inputs, with the seed
+// dropped, and the standard LoRAs each seed was generated with.
+type seedButtonEntry struct {
+	state   UserState
+	loras   map[uint64][]string
+	created time.Time
+}
+
+// SeedButtonStore remembers the generations behind "reuse seed" buttons, keyed by the
+// button message. Nothing is persisted; after a restart or seedButtonTTL a button still
+// locks its seed, but can't start the same prompt again.
+type SeedButtonStore struct {
+	mu      sync.Mutex
+	entries map[seedButtonKey]seedButtonEntry
+}
+
+func NewSeedButtonStore() *SeedButtonStore {
+	return &SeedButtonStore{entries: make(map[seedButtonKey]seedButtonEntry)}
+}
+
+// Remember stores the generation inputs of state and the standard LoRAs per seed for the
+// button message, and forgets expired entries.
+func (s *SeedButtonStore) Remember(chatID int64, messageID int, state *UserState, loras map[uint64][]string) {
+	snapshot := UserState{
+		UserID:            state.UserID,
+		OriginalCaption:   state.OriginalCaption,
+		SelectedBaseLoras: slices.Clone(state.SelectedBaseLoras),
+	}
+	if state.Overrides != nil {
+		overrides := *state.Overrides
+		overrides.Seed = nil // The tapped seed is locked instead
+		snapshot.Overrides = &overrides
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, entry := range s.entries {
+		if now.Sub(entry.created) > seedButtonTTL {
+			delete(s.entries, key)
+		}
+	}
+	s.entries[seedButtonKey{chatID, messageID}] = seedButtonEntry{state: snapshot, loras: loras, created: now}
+}
+
+// Get returns a copy of the generation inputs behind the button message, with the standard
+// LoRAs of seed selected.
+func (s *SeedButtonStore) Get(chatID int64, messageID int, seed uint64) (UserState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[seedButtonKey{chatID, messageID}]
+	if !ok || time.Since(entry.created) > seedButtonTTL {
+		return UserState{}, false
+	}
+	snapshot := entry.state
+	snapshot.SelectedLoras = slices.Clone(entry.loras[seed])
+	snapshot.SelectedBaseLoras = slices.Clone(entry.state.SelectedBaseLoras)
+	if snapshot.Overrides != nil {
+		overrides := *snapshot.Overrides
+		snapshot.Overrides = &overrides
+	}
+	return snapshot, true
+}
+
+// sendSeedButtons sends a "reuse seed" button for each distinct seed of successfulResults
+// below a delivered result and returns the message ID, or 0 if nothing was sent. Results
+// of one request share a seed, so there is one button per request rather than per image.
+func sendSeedButtons(chatID int64, userState *UserState, successfulResults []RequestResult, userLang *string, deps BotDeps) int {
+	baseLoras := make(map[string]struct{}, len(userState.SelectedBaseLoras))
+	for _, name := range userState.SelectedBaseLoras {
+		baseLoras[name] = struct{}{}
+	}
+	var seeds []uint64
+	loras := make(map[uint64][]string)
+	labels := make(map[uint64]string)
+	for _, res := range successfulResults {
+		if res.Response == nil || len(seeds) == maxSeedButtons {
+			continue
+		}
+		seed := res.Response.Seed
+		if _, dup := loras[seed]; dup {
+			continue
+		}
+		standard := []string{}
+		for _, name := range res.LoraNames {
+			if _, isBase := baseLoras[name]; !isBase {
+				standard = append(standard, name)
+			}
+		}
+		seeds = append(seeds, seed)
+		loras[seed] = standard
+		labels[seed] = strings.Join(res.LoraNames, "+")
+	}
+	if len(seeds) == 0 {
+		return 0
+	}
+
+	// Name the LoRAs only when the buttons would otherwise look alike
+	sameLoras := true
+	for _, seed := range seeds {
+		sameLoras = sameLoras && labels[seed] == labels[seeds[0]]
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, seed := range seeds {
+		text := deps.I18n.T(userLang, "seed_button_reuse", "seed", seed)
+		if !sameLoras {
+			text = deps.I18n.T(userLang, "seed_button_reuse_lora", "seed", seed, "loras", labels[seed])
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(text, fmt.Sprintf("seed_reuse_%d", seed)),
+		))
+	}
+	msg := tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "seed_buttons_text"))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	sent, err := deps.Bot.Send(msg)
+	if err != nil {
+		deps.Logger.Warn("Failed to send seed buttons", zap.Error(err), zap.Int64("chat_id", chatID))
+		return 0
+	}
+	deps.SeedButtons.Remember(chatID, sent.MessageID, userState, loras)
+	return sent.MessageID
+}
+
+// HandleSeedReuseCallback handles seed_reuse_<seed>: it locks the seed like /lockseed and
+// starts the LoRA selection for the prompt behind the button, with the LoRAs of that seed
+// selected. If the generation was forgotten, the seed is still locked for the next prompt.
+func HandleSeedReuseCallback(callbackQuery *tgbotapi.CallbackQuery, deps BotDeps) {
+	userID := callbackQuery.From.ID
+	if callbackQuery.Message == nil {
+		answer := tgbotapi.NewCallback(callbackQuery.ID, deps.I18n.T(nil, "callback_error_nil_message"))
+		deps.Bot.Request(answer)
+		return
+	}
+	chatID := callbackQuery.Message.Chat.ID
+	messageID := callbackQuery.Message.MessageID
+	userLang := getUserLanguagePreference(userID, deps)
+	answer := tgbotapi.NewCallback(callbackQuery.ID, "")
+
+	seed, err := strconv.ParseUint(strings.TrimPrefix(callbackQuery.Data, "seed_reuse_"), 10, 64)
+	if err != nil {
+		answer.Text = deps.I18n.T(userLang, "lora_select_unknown_action")
+		deps.Bot.Request(answer)
+		return
+	}
+	if err := setLockedSeed(userID, &seed, deps); err != nil {
+		answer.Text = deps.I18n.T(userLang, "error_generic")
+		deps.Bot.Request(answer)
+		return
+	}
+	deps.Bot.Request(answer)
+
+	state, found := deps.SeedButtons.Get(chatID, messageID, seed)
+	if !found {
+		deps.Logger.Info("Seed button generation expired, locked seed only", zap.Int64("user_id", userID), zap.Uint64("seed", seed))
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "seed_reuse_expired", "seed", seed)))
+		return
+	}
+
+	// LoRAs may have been removed since, or the tapping user may not see them in a group chat
+	visible := GetUserVisibleLoras(userID, deps)
+	state.SelectedLoras = slices.DeleteFunc(state.SelectedLoras, func(name string) bool {
+		_, found := findLoraByName(name, visible)
+		return !found
+	})
+	state.SelectedBaseLoras = slices.DeleteFunc(state.SelectedBaseLoras, func(name string) bool {
+		_, found := findLoraByName(name, deps.BaseLoRAs())
+		return !found
+	})
+	state.UserID = userID
+	state.ChatID = chatID
+
+	deps.StateManager.ClearState(userID)
+	deps.Logger.Info("Reusing seed from result", zap.Int64("user_id", userID), zap.Uint64("seed", seed), zap.Strings("loras", state.SelectedLoras))
+	deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "seed_reuse_started", "seed", seed)))
+	startLoraSelection(&state, deps)
+}
@@ -0,0 +1,79 @@
+package bot
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+	"go.uber.org/zap"
+)
+
+// HandleLockSeedCommand handles /lockseed [seed]. Without a seed it locks the seed of the
+// user's last result, or a random one if there is none. While locked, every generation
+// uses that seed.
+func HandleLockSeedCommand(message *tgbotapi.Message, deps BotDeps) {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+	userLang := getUserLanguagePreference(userID, deps)
+
+	var seed uint64
+	if arg := strings.TrimSpace(message.CommandArguments()); arg != "" {
+		parsed, err := strconv.ParseUint(arg, 10, 32)
+		if err != nil {
+			deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "lockseed_invalid")))
+			return
+		}
+		seed = parsed
+	} else {
+		seed = defaultSeedToLock(userID, deps)
+	}
+
+	if err := setLockedSeed(userID, &seed, deps); err != nil {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "error_generic")))
+		return
+	}
+	deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "lockseed_success", "seed", seed)))
+}
+
+// HandleUnlockSeedCommand handles /unlockseed, going back to random seeds.
+func HandleUnlockSeedCommand(message *tgbotapi.Message, deps BotDeps) {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+	userLang := getUserLanguagePreference(userID, deps)
+
+	if err := setLockedSeed(userID, nil, deps); err != nil {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "error_generic")))
+		return
+	}
+	deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "unlockseed_success")))
+}
+
+// defaultSeedToLock returns the seed of the user's most recent result, or a random seed.
+func defaultSeedToLock(userID int64, deps BotDeps) uint64 {
+	entries, err := st.ListGenerationHistory(deps.DB, userID, 1)
+	if err != nil {
+		deps.Logger.Warn("Failed to load last seed, locking a random one", zap.Error(err), zap.Int64("user_id", userID))
+	}
+	if len(entries) > 0 {
+		return entries[0].Seed
+	}
+	return uint64(rand.Int31()) // Stay well inside the int range fal accepts
+}
+
+// setLockedSeed stores seed as the user's locked seed; nil unlocks it.
+func setLockedSeed(userID int64, seed *uint64, deps BotDeps) error {
+	userCfg, err := loadUserConfigOrDefault(userID, deps)
+	if err != nil {
+		deps.Logger.Error("Failed to get user config for seed lock", zap.Error(err), zap.Int64("user_id", userID))
+		return err
+	}
+	userCfg.LockedSeed = seed
+	if err := st.SetUserGenerationConfig(deps.DB, *userCfg); err != nil {
+		deps.Logger.Error("Failed to update locked seed", zap.Error(err), zap.Int64("user_id", userID))
+		return err
+	}
+	deps.Logger.Info("Updated locked seed", zap.Int64("user_id", userID), zap.Bool("locked", seed != nil))
+	return nil
+}
@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"errors"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// maxSendRetryAfter caps how long a rate-limited send waits before its next try. Telegram
+// asks for longer waits only when flood limits are hit hard, and then giving up and telling
+// the user is better than blocking the delivery for minutes.
+const maxSendRetryAfter = time.Minute
+
+// retrySend calls send until it succeeds, fails for good or sendMaxAttempts is used up. Rate
+// limits wait as long as Telegram's retry_after asks; network and server errors back off by
+// sendRetryBackoffMs per attempt. Requests Telegram rejected, e.g. a bad request or a bot
+// blocked by the user, fail right away.
+//
+// Only use it for the messages users wait for: results and the caption confirmation.
+// Status updates are best effort and go through deps.Edits.
+func retrySend(chatID int64, send func() error, deps BotDeps) error {
+	maxAttempts := deps.Cfg().SendMaxAttempts
+	backoff := time.Duration(deps.Cfg().SendRetryBackoffMs) * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := send()
+		if err == nil || attempt >= maxAttempts {
+			return err
+		}
+		wait, retry := sendRetryWait(err, attempt, backoff)
+		if !retry {
+			return err
+		}
+		deps.Logger.Warn("Telegram send failed, retrying", zap.Error(err), zap.Int64("chat_id", chatID), zap.Int("attempt", attempt), zap.Int("max_attempts", maxAttempts), zap.Duration("wait", wait))
+		time.Sleep(wait)
+	}
+}
+
+// sendRetryWait returns how long to wait before retrying a send that failed with err on
+// the given attempt, and whether to retry at all.
+func sendRetryWait(err error, attempt int, backoff time.Duration) (time.Duration, bool) {
+	var tgErr *tgbotapi.Error
+	if !errors.As(err, &tgErr) {
+		return time.Duration(attempt) * backoff, true // Network error or garbled response
+	}
+	if tgErr.RetryAfter > 0 {
+		wait := time.Duration(tgErr.RetryAfter) * time.Second
+		return wait, wait <= maxSendRetryAfter
+	}
+	return time.Duration(attempt) * backoff, tgErr.Code >= 500
+}
+
+// sendWithRetry is deps.Bot.Send with retrySend.
+func sendWithRetry(chatID int64, c tgbotapi.Chattable, deps BotDeps) (tgbotapi.Message, error) {
+	var msg tgbotapi.Message
+	err := retrySend(chatID, func() error {
+		var err error
+		msg, err = deps.Bot.Send(c)
+		return err
+	}, deps)
+	return msg, err
+}
+
+// sendMediaGroupWithRetry is deps.Bot.SendMediaGroup with retrySend.
+func sendMediaGroupWithRetry(group tgbotapi.MediaGroupConfig, deps BotDeps) ([]tgbotapi.Message, error) {
+	var msgs []tgbotapi.Message
+	err := retrySend(group.ChatID, func() error {
+		var err error
+		msgs, err = deps.Bot.SendMediaGroup(group)
+		return err
+	}, deps)
+	return msgs, err
+}
@@ -57,9 +57,13 @@ func (sm *StateManager) GetState(userID int64) (*UserState, bool) {
 }
 
 // ClearState removes a user's state.
+// A running caption request of the state is cancelled, since nothing would pick up its result.
 func (sm *StateManager) ClearState(userID int64) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	if state, ok := sm.states[userID]; ok && state.CancelCaption != nil {
+		state.CancelCaption()
+	}
 	delete(sm.states, userID)
 }
 
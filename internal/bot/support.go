@@ -0,0 +1,60 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// supportErrorMaxAge is how old a failed generation may be to still be shown by /support.
+const supportErrorMaxAge = 24 * time.Hour
+
+// HandleSupportCommand handles /support: the operator contact info from the [support]
+// config, plus what an admin needs to look into a problem: the user's ID, the reference of
+// their last failed generation and the bot version.
+func HandleSupportCommand(chatID int64, userID int64, deps BotDeps) {
+	userLang := getUserLanguagePreference(userID, deps)
+	supportCfg := deps.Cfg().Support
+
+	var text strings.Builder
+	text.WriteString(deps.I18n.T(userLang, "support_title"))
+	if supportCfg.Contact == "" && supportCfg.DocsURL == "" && supportCfg.StatusURL == "" {
+		text.WriteString(deps.I18n.T(userLang, "support_no_contact"))
+	}
+	if supportCfg.Contact != "" {
+		text.WriteString(deps.I18n.T(userLang, "support_contact", "contact", supportCfg.Contact))
+	}
+	if supportCfg.DocsURL != "" {
+		text.WriteString(deps.I18n.T(userLang, "support_docs", "url", supportCfg.DocsURL))
+	}
+	if supportCfg.StatusURL != "" {
+		text.WriteString(deps.I18n.T(userLang, "support_status", "url", supportCfg.StatusURL))
+	}
+
+	text.WriteString(deps.I18n.T(userLang, "support_details_title"))
+	text.WriteString(deps.I18n.T(userLang, "support_user_id", "userID", userID))
+	failed, err := st.GetLastFailedRequestForUser(deps.DB, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		deps.Logger.Warn("Failed to load last failed request for /support", zap.Error(err), zap.Int64("user_id", userID))
+	}
+	switch {
+	case failed == nil || time.Since(failed.CreatedAt) > supportErrorMaxAge:
+		text.WriteString(deps.I18n.T(userLang, "support_no_recent_error"))
+	case failed.RequestID != "":
+		text.WriteString(deps.I18n.T(userLang, "support_last_error", "id", failed.ID, "requestID", failed.RequestID, "time", failed.CreatedAt.Format("2006-01-02 15:04 MST")))
+	default: // Failed before fal assigned a request ID
+		text.WriteString(deps.I18n.T(userLang, "support_last_error_unsubmitted", "id", failed.ID, "time", failed.CreatedAt.Format("2006-01-02 15:04 MST")))
+	}
+	text.WriteString(deps.I18n.T(userLang, "support_version", "version", deps.Version, "buildDate", deps.BuildDate))
+	text.WriteString(deps.I18n.T(userLang, "support_footer"))
+
+	msg := tgbotapi.NewMessage(chatID, text.String())
+	msg.DisableWebPagePreview = true
+	deps.Bot.Send(msg)
+}
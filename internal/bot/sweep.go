@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"math/rand"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	falapi "github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+	"go.uber.org/zap"
+)
+
+// HandleSweepCommand handles /sweep <prompt>. It selects every LoRA the user can see, up to
+// limits.maxSweepLoras, and opens the confirm keyboard; the generation then makes one image
+// per LoRA with a shared seed, so the styles can be compared side by side.
+func HandleSweepCommand(message *tgbotapi.Message, deps BotDeps) {
+	userID := message.From.ID
+	chatID := message.Chat.ID
+	userLang := getUserLanguagePreference(userID, deps)
+	maxLoras := deps.Cfg().Limits.MaxSweepLoras
+
+	prompt := strings.TrimSpace(message.CommandArguments())
+	if prompt == "" {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "sweep_usage", "max", maxLoras)))
+		return
+	}
+	if refuseForMaintenance(chatID, userID, 0, deps) {
+		return
+	}
+
+	isAdmin := deps.Authorizer.IsAdmin(userID)
+	var loras []string
+	for _, lora := range sortLoras(GetUserVisibleLoras(userID, deps)) {
+		if !isAdmin && deps.LoraHealth.IsDisabled(lora.Name) {
+			continue
+		}
+		loras = append(loras, lora.Name)
+	}
+	if len(loras) < 2 {
+		deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "sweep_not_enough_loras")))
+		return
+	}
+	text := deps.I18n.T(userLang, "sweep_started", "count", len(loras))
+	if len(loras) > maxLoras {
+		text = deps.I18n.T(userLang, "sweep_started_capped", "count", maxLoras, "total", len(loras))
+		loras = loras[:maxLoras]
+	}
+
+	deps.StateManager.ClearState(userID)
+	clearQuickRepeatKeyboard(chatID, userID, deps)
+	rememberPrompt(userID, prompt, deps)
+	state := &UserState{
+		UserID:          userID,
+		ChatID:          chatID,
+		Action:          "awaiting_base_lora_selection",
+		OriginalCaption: prompt,
+		SelectedLoras:   loras,
+		Overrides:       &GenerationOverrides{NumImages: 1},
+		Sweep:           true,
+	}
+	// The keyboard replaces the text of its message, so the sweep is announced in a message of its own
+	deps.Bot.Send(tgbotapi.NewMessage(chatID, text))
+	sentMsg, err := deps.Bot.Send(tgbotapi.NewMessage(chatID, deps.I18n.T(userLang, "text_prompt_received")))
+	if err != nil {
+		deps.Logger.Warn("Failed to send message for sweep confirm keyboard, sending keyboard as new message", zap.Error(err), zap.Int64("user_id", userID))
+	}
+	state.MessageID = sentMsg.MessageID
+	deps.StateManager.SetState(userID, state)
+	deps.Logger.Info("User started a LoRA sweep", zap.Int64("user_id", userID), zap.Strings("loras", loras))
+	SendBaseLoraSelectionKeyboard(chatID, sentMsg.MessageID, state, deps, sentMsg.MessageID != 0)
+}
+
+// applySweepParameters makes params generate one image per LoRA with one seed for all of
+// them: the user's locked seed, or a random one picked here.
+func applySweepParameters(params *GenerationParameters) {
+	params.NumImages = 1
+	if params.Seed == nil {
+		seed := uint64(rand.Int31()) // Stay well inside the int range fal accepts
+		params.Seed = &seed
+	}
+}
+
+// sweepImages labels each image of a sweep with the LoRAs it was generated with, keeping
+// the order of the requests.
+func sweepImages(successfulResults []RequestResult) ([]falapi.ImageInfo, []string) {
+	var images []falapi.ImageInfo
+	var labels []string
+	for _, res := range successfulResults {
+		for _, img := range res.Response.Images {
+			images = append(images, img)
+			labels = append(labels, strings.Join(res.LoraNames, " + "))
+		}
+	}
+	return images, labels
+}
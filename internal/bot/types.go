@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"context"
 	"database/sql"
 	"encoding/hex"
 	"fmt"
@@ -10,7 +11,6 @@ import (
 
 	"github.com/nerdneilsfield/telegram-fal-bot/internal/auth"
 	// No balance import needed here, storage is used
-	cfg "github.com/nerdneilsfield/telegram-fal-bot/internal/config"
 	"github.com/nerdneilsfield/telegram-fal-bot/internal/i18n"
 
 	// Remove state import as state.go is in the same package
@@ -26,12 +26,15 @@ import (
 // LoraConfig represents the configuration for a single LoRA, including a generated ID.
 // This definition is within the bot package.
 type LoraConfig struct {
-	ID           string   // Unique ID generated from Name, URL, Weight
-	Name         string   // Copied from config.LoraConfig
-	URL          string   // Copied from config.LoraConfig
-	Weight       float64  // Copied from config.LoraConfig
-	AllowGroups  []string // Copied from config.LoraConfig
-	AppendPrompt string   // Copied from config.LoraConfig
+	ID                 string   // Unique ID generated from Name, URL, Weight
+	Name               string   // Copied from config.LoraConfig
+	URL                string   // Copied from config.LoraConfig
+	Weight             float64  // Copied from config.LoraConfig
+	AllowGroups        []string // Copied from config.LoraConfig
+	AppendPrompt       string   // Copied from config.LoraConfig
+	PromptPosition     string   // Copied from config.LoraConfig
+	Pinned             bool     // Copied from config.LoraConfig
+	PreferredImageSize string   // Copied from config.LoraConfig
 }
 
 // UserState holds the current state of a user interaction.
@@ -47,6 +50,22 @@ type UserState struct {
 	// For config updates
 	ConfigFieldToUpdate string
 	ImageFileURL        string `json:"-"` // Store image URL if interaction started with photo
+	CaptionModel        int    `json:"-"` // Index into APIEndpoints.CaptionEndpoints() of the current caption
+	Verbose             bool   `json:"-"` // Report every poll's status (admin /vgen)
+	// One-off parameter overrides for this generation only (e.g. /resize)
+	Overrides *GenerationOverrides `json:"-"`
+	// Prompts of a /batch run; when set, each prompt is generated in turn with the selected LoRAs
+	BatchPrompts []string `json:"-"`
+	// Number of sequential seeds for a /grid run; 0 for a normal generation
+	GridSize int `json:"-"`
+	// A /sweep run: one image per selected LoRA, all with the same seed
+	Sweep bool `json:"-"`
+	// Modifiers a remix appended to OriginalCaption, listed in the result caption
+	RemixModifiers []string `json:"-"`
+	// Time captioning the photo took, counted against limits.photoFlowBudgetSeconds
+	CaptionElapsed time.Duration `json:"-"`
+	// Aborts the running caption request of a "captioning" state
+	CancelCaption context.CancelFunc `json:"-"`
 }
 
 // BotDeps holds the dependencies required by the bot handlers.
@@ -59,11 +78,27 @@ type BotDeps struct {
 	BalanceManager *st.SQLBalanceManager // Changed to SQLBalanceManager
 	I18n           *i18n.Manager
 	Logger         *zap.Logger
-	Config         *cfg.Config
-	LoRA           []LoraConfig // Use bot.LoraConfig (with ID)
-	BaseLoRA       []LoraConfig // Use bot.LoraConfig (with ID)
+	Limiter        *GenerationLimiter     // nil when generations are unlimited
+	ChatLimiter    *ChatGenerationLimiter // Generations running per group chat
+	CaptionLimiter *UserRateLimiter       // nil when captions are unlimited
+	Cooldowns      *GenerationCooldown    // nil when there is no generation cooldown
+	Batches        *BatchManager
+	Webhooks       *fapi.WebhookReceiver // nil when results are polled
+	FalBalance     *FalBalanceTracker
+	CreditAlerts   *CreditAlerter
+	LoraHealth     *LoraHealth
+	WaitEstimates  *WaitEstimator
+	Edits          *EditThrottler // Use for status message edits
+	Inflight       *InflightRegistry
+	Repeats        *QuickRepeatStore
+	LastResults    *LastResultStore
+	SeedButtons    *SeedButtonStore
+	StatusCards    *StatusCardCache
+	Maintenance    *Maintenance
 	Version        string
 	BuildDate      string
+	// Config and LoRA lists; read through Cfg, StandardLoRAs and BaseLoRAs
+	live *liveConfig
 }
 
 // GenerateIDWithBlake2b generates a unique ID based on string and float inputs using Blake2b hashing.
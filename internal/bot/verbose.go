@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	falapi "github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+	"go.uber.org/zap"
+)
+
+// verboseReporter reports every poll of a single generation request as edits of
+// its own status message. Used by the admin /vgen command to diagnose fal latency.
+type verboseReporter struct {
+	chatID    int64
+	messageID int
+	requestID string
+	loraNames string
+	safety    bool // Effective enable_safety_checker of the request
+	userLang  *string
+	startTime time.Time
+	polls     int
+	deps      BotDeps
+}
+
+func newVerboseReporter(chatID int64, requestID string, loraNames []string, safety bool, userLang *string, deps BotDeps) *verboseReporter {
+	return &verboseReporter{
+		chatID:    chatID,
+		requestID: requestID,
+		loraNames: strings.Join(loraNames, "+"),
+		safety:    safety,
+		userLang:  userLang,
+		startTime: time.Now(),
+		deps:      deps,
+	}
+}
+
+// OnStatus is passed to PollForResultWithStatus and called after every poll.
+func (r *verboseReporter) OnStatus(status *falapi.StatusResponse) {
+	r.polls++
+	text := r.deps.I18n.T(r.userLang, "generate_verbose_status",
+		"loras", r.loraNames,
+		"reqID", truncateID(r.requestID),
+		"poll", r.polls,
+		"elapsed", r.elapsed(),
+		"status", status.Status,
+	)
+	if status.QueuePosition != nil {
+		text += r.deps.I18n.T(r.userLang, "generate_verbose_queue_position", "position", *status.QueuePosition)
+	}
+	r.send(text+r.safetyLine(), false)
+}
+
+// Finish reports the final outcome, including fal's timings on success.
+func (r *verboseReporter) Finish(result *falapi.GenerateResponse, err error) {
+	if err != nil {
+		r.send(r.deps.I18n.T(r.userLang, "generate_verbose_failed",
+			"loras", r.loraNames,
+			"reqID", truncateID(r.requestID),
+			"poll", r.polls,
+			"elapsed", r.elapsed(),
+			"error", err.Error(),
+		)+r.safetyLine(), true)
+		return
+	}
+
+	timings := "n/a"
+	if result != nil && result.Timings != nil {
+		if raw, marshalErr := json.Marshal(result.Timings); marshalErr == nil {
+			timings = string(raw)
+		}
+	}
+	r.send(r.deps.I18n.T(r.userLang, "generate_verbose_completed",
+		"loras", r.loraNames,
+		"reqID", truncateID(r.requestID),
+		"poll", r.polls,
+		"elapsed", r.elapsed(),
+		"timings", timings,
+	)+r.safetyLine(), true)
+}
+
+// safetyLine reports whether fal's safety checker was on for the request.
+func (r *verboseReporter) safetyLine() string {
+	if r.safety {
+		return r.deps.I18n.T(r.userLang, "generate_verbose_safety_on")
+	}
+	return r.deps.I18n.T(r.userLang, "generate_verbose_safety_off")
+}
+
+func (r *verboseReporter) elapsed() string {
+	return fmt.Sprintf("%.1f", time.Since(r.startTime).Seconds())
+}
+
+// send posts the first report as a new message and edits it afterwards. Poll reports
+// are throttled; the final report is always sent.
+func (r *verboseReporter) send(text string, final bool) {
+	if r.messageID == 0 {
+		sent, err := r.deps.Bot.Send(tgbotapi.NewMessage(r.chatID, text))
+		if err != nil {
+			r.deps.Logger.Warn("Failed to send verbose poll status", zap.Error(err), zap.String("request_id", r.requestID))
+			return
+		}
+		r.messageID = sent.MessageID
+		return
+	}
+	edit := tgbotapi.NewEditMessageText(r.chatID, r.messageID, text)
+	if !final {
+		r.deps.Edits.Edit(edit)
+		return
+	}
+	if err := r.deps.Edits.EditNow(edit); err != nil {
+		r.deps.Logger.Warn("Failed to edit verbose poll status", zap.Error(err), zap.String("request_id", r.requestID))
+	}
+}
@@ -0,0 +1,25 @@
+package bot
+
+import (
+	"database/sql"
+	"errors"
+
+	st "github.com/nerdneilsfield/telegram-fal-bot/internal/storage"
+	"go.uber.org/zap"
+)
+
+// verboseErrorsEnabled reports whether userID gets detailed error messages: always for
+// admins, otherwise only if the operator allows it and the user opted in via /myconfig.
+func verboseErrorsEnabled(userID int64, deps BotDeps) bool {
+	if deps.Authorizer.IsAdmin(userID) {
+		return true
+	}
+	if !deps.Cfg().AllowVerboseErrors {
+		return false
+	}
+	userCfg, err := st.GetUserGenerationConfig(deps.DB, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		deps.Logger.Warn("Failed to get user config for verbose errors, using default", zap.Error(err), zap.Int64("user_id", userID))
+	}
+	return userCfg != nil && userCfg.VerboseErrors != nil && *userCfg.VerboseErrors
+}
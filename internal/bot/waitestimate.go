@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// waitSamplesKept is how many recent request durations are kept per model.
+	waitSamplesKept = 50
+	// minWaitSamples is how many durations a model needs before they replace the default.
+	minWaitSamples = 3
+	// defaultGenerationWait is the typical wait shown for a model without enough samples.
+	defaultGenerationWait = time.Minute
+)
+
+// WaitEstimator keeps the durations of recent successful generation requests per model,
+// from submission to result, to tell users how long a generation typically takes. Nothing
+// is persisted; after a restart the estimate starts from defaultGenerationWait.
+type WaitEstimator struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration // By model, oldest first
+}
+
+func NewWaitEstimator() *WaitEstimator {
+	return &WaitEstimator{samples: make(map[string][]time.Duration)}
+}
+
+// Record adds the duration of a successful request for model.
+func (e *WaitEstimator) Record(model string, d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	samples := append(e.samples[model], d)
+	if len(samples) > waitSamplesKept {
+		samples = samples[len(samples)-waitSamplesKept:]
+	}
+	e.samples[model] = samples
+}
+
+// Typical returns the median of the recent durations of model and how many there are. With
+// fewer than minWaitSamples it returns defaultGenerationWait.
+func (e *WaitEstimator) Typical(model string) (time.Duration, int) {
+	e.mu.Lock()
+	samples := slices.Clone(e.samples[model])
+	e.mu.Unlock()
+	if len(samples) < minWaitSamples {
+		return defaultGenerationWait, len(samples)
+	}
+	slices.Sort(samples)
+	return samples[len(samples)/2], len(samples)
+}
+
+// estimateGeneration returns how many rounds it takes to run requests fal requests with at
+// most limits.maxConcurrentGenerations in flight, and roughly how long that takes at the
+// typical wait of model. Requests of other users can make it take longer.
+func estimateGeneration(requests int, model string, deps BotDeps) (int, time.Duration) {
+	rounds := 1
+	if limit := deps.Cfg().Limits.MaxConcurrentGenerations; limit > 0 {
+		rounds = (requests + limit - 1) / limit
+	}
+	typical, _ := deps.WaitEstimates.Typical(model)
+	return rounds, time.Duration(rounds) * typical
+}
+
+// formatTypicalWait rounds d for display, e.g. "45s" or "2m".
+func formatTypicalWait(d time.Duration) string {
+	if d < time.Minute {
+		return max(time.Second, d.Round(5*time.Second)).String()
+	}
+	s := d.Round(30 * time.Second).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	return s
+}
+
+// HandleEtaCommand handles /eta, listing the typical wait of every generation model.
+func HandleEtaCommand(chatID int64, userID int64, deps BotDeps) {
+	userLang := getUserLanguagePreference(userID, deps)
+	var text strings.Builder
+	text.WriteString(deps.I18n.T(userLang, "eta_title"))
+	for _, model := range deps.Cfg().APIEndpoints.GenerationModels() {
+		typical, samples := deps.WaitEstimates.Typical(model)
+		if samples < minWaitSamples {
+			text.WriteString(deps.I18n.T(userLang, "eta_item_default", "model", model, "wait", formatTypicalWait(typical)))
+			continue
+		}
+		text.WriteString(deps.I18n.T(userLang, "eta_item", "model", model, "wait", formatTypicalWait(typical), "count", samples))
+	}
+	deps.Bot.Send(tgbotapi.NewMessage(chatID, text.String()))
+}
@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+	falapi "github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+	"go.uber.org/zap"
+)
+
+// defaultWebhookPath is used when webhook.publicURL has no path.
+const defaultWebhookPath = "/fal/webhook"
+
+// startWebhookServer creates the fal webhook receiver, points falClient at it and starts
+// serving it on cfg.ListenAddr in the background. It fails if the address can't be bound.
+func startWebhookServer(cfg config.WebhookConfig, falClient *falapi.Client, logger *zap.Logger) (*falapi.WebhookReceiver, error) {
+	receiver := falapi.NewWebhookReceiver(cfg.Secret, logger)
+
+	webhookURL, err := receiver.WebhookURL(cfg.PublicURL)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(cfg.PublicURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook public URL: %w", err)
+	}
+	path := u.Path
+	if path == "" || path == "/" {
+		path = defaultWebhookPath
+		u.Path = path
+		webhookURL, err = receiver.WebhookURL(u.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+	falClient.SetWebhookURL(webhookURL)
+
+	mux := http.NewServeMux()
+	mux.Handle(path, receiver)
+	listener, err := net.Listen("tcp", cfg.ListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", cfg.ListenAddr, err)
+	}
+	server := &http.Server{Handler: mux}
+	logger.Info("Starting fal webhook receiver", zap.String("listen_addr", listener.Addr().String()), zap.String("path", path))
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("fal webhook receiver stopped", zap.Error(err))
+		}
+	}()
+	return receiver, nil
+}
+
+// waitForWebhookResult waits for the webhook of the registered requestID. Webhooks carry no
+// progress, so a set onStatus is fed from status polls meanwhile. If no webhook arrives in
+// time, e.g. because fal gave up on a delivery that raced ahead of Register, the result is
+// fetched once directly before giving up.
+func waitForWebhookResult(ctx context.Context, requestID string, model string, pollInterval time.Duration, onStatus falapi.StatusCallback, deps BotDeps) (*falapi.GenerateResponse, error) {
+	if onStatus != nil {
+		statusCtx, stopStatus := context.WithCancel(ctx)
+		statusDone := make(chan struct{})
+		go func() {
+			defer close(statusDone)
+			reportStatus(statusCtx, requestID, model, pollInterval, onStatus, deps)
+		}()
+		// onStatus must not run once the caller has the result
+		defer func() {
+			stopStatus()
+			<-statusDone
+		}()
+	}
+
+	result, err := deps.Webhooks.Wait(ctx, requestID)
+	if errors.Is(err, context.DeadlineExceeded) {
+		fetched, fetchErr := deps.FalClient.GetGenerationResult(requestID, model)
+		if fetchErr == nil {
+			deps.Logger.Warn("No webhook arrived in time, fetched the result directly", zap.String("request_id", requestID))
+			return fetched, nil
+		}
+		deps.Logger.Debug("Result fetch after webhook timeout failed", zap.Error(fetchErr), zap.String("request_id", requestID))
+	}
+	return result, err
+}
+
+// reportStatus polls the status of requestID every pollInterval and passes it to onStatus
+// until ctx is done or the request has finished.
+func reportStatus(ctx context.Context, requestID string, model string, pollInterval time.Duration, onStatus falapi.StatusCallback, deps BotDeps) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			status, err := deps.FalClient.GetRequestStatus(requestID, model)
+			if err != nil {
+				deps.Logger.Debug("Status poll for verbose report failed", zap.Error(err), zap.String("request_id", requestID))
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			onStatus(status)
+			if status.Status != "IN_PROGRESS" && status.Status != "IN_QUEUE" {
+				return
+			}
+		}
+	}
+}
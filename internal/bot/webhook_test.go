@@ -0,0 +1,29 @@
+package bot
+
+import (
+	"net"
+	"testing"
+
+	"github.com/nerdneilsfield/telegram-fal-bot/internal/config"
+	falapi "github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+	"go.uber.org/zap"
+)
+
+// TestStartWebhookServerReportsBindError starts the receiver on an address in use. The
+// error must reach the caller instead of stopping the process from the server goroutine.
+func TestStartWebhookServerReportsBindError(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer taken.Close()
+	falClient, err := falapi.NewClient("key", "https://queue.fal.run", "fal-ai/flux-lora", "", zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	webhookCfg := config.WebhookConfig{Enabled: true, ListenAddr: taken.Addr().String(), PublicURL: "https://bot.example.com/fal/webhook"}
+	if _, err := startWebhookServer(webhookCfg, falClient, zap.NewNop()); err == nil {
+		t.Fatal("startWebhookServer succeeded on an address in use")
+	}
+}
@@ -3,26 +3,61 @@ package config
 import (
 	"fmt"
 	"net/url"
+	"os"
+	"regexp"
+	"slices"
 	"strings"
 
+	falapi "github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
+
 	"github.com/BurntSushi/toml"
 )
 
 type Config struct {
-	BotToken                  string             `toml:"botToken"`
-	FalAIKey                  string             `toml:"falAIKey"`
-	TelegramAPIURL            string             `toml:"telegramAPIURL"`
-	DBPath                    string             `toml:"dbPath"`
-	BaseLoRAs                 []LoraConfig       `toml:"baseLoRAs"`
-	LoRAs                     []LoraConfig       `toml:"loras"`
-	LogConfig                 LogConfig          `toml:"logConfig"`
-	APIEndpoints              APIEndpointsConfig `toml:"apiEndpoints"`
-	Auth                      AuthConfig         `toml:"auth"`
-	Admins                    AdminConfig        `toml:"admins"`
-	Balance                   BalanceConfig      `toml:"balance"`
-	DefaultGenerationSettings GenerationConfig   `toml:"defaultGenerationSettings"`
-	UserGroups                []UserGroup        `toml:"userGroups"`
-	DefaultLanguage           string             `toml:"defaultLanguage"`
+	BotToken                  string                `toml:"botToken"`
+	FalAIKey                  string                `toml:"falAIKey"`
+	TelegramAPIURL            string                `toml:"telegramAPIURL"`
+	DBPath                    string                `toml:"dbPath"`
+	BaseLoRAs                 []LoraConfig          `toml:"baseLoRAs"`
+	LoRAs                     []LoraConfig          `toml:"loras"`
+	LogConfig                 LogConfig             `toml:"logConfig"`
+	APIEndpoints              APIEndpointsConfig    `toml:"apiEndpoints"`
+	Auth                      AuthConfig            `toml:"auth"`
+	Admins                    AdminConfig           `toml:"admins"`
+	Balance                   BalanceConfig         `toml:"balance"`
+	DefaultGenerationSettings GenerationConfig      `toml:"defaultGenerationSettings"`
+	UserGroups                []UserGroup           `toml:"userGroups"`
+	DefaultLanguage           string                `toml:"defaultLanguage"`
+	LanguageFallbacks         map[string][]string   `toml:"languageFallbacks"`     // Tried in order when a language lacks a key, e.g. "zh-TW" = ["zh"]
+	CaptionPromptTemplate     string                `toml:"captionPromptTemplate"` // Wraps Florence captions, must contain {caption}
+	GlobalPromptPrefix        string                `toml:"globalPromptPrefix"`    // Placed before every user prompt, e.g. quality tags
+	GlobalPromptSuffix        string                `toml:"globalPromptSuffix"`    // Placed after every user prompt
+	EnableCaptioning          *bool                 `toml:"enableCaptioning"`      // nil means enabled; use CaptioningEnabled
+	CaptionMaxAttempts        int                   `toml:"captionMaxAttempts"`    // Tries per photo on transient caption errors, defaults to 3
+	FallbackPrompt            string                `toml:"fallbackPrompt"`        // Offered when a photo can't be captioned; empty asks for a text prompt
+	MinCaptionLength          int                   `toml:"minCaptionLength"`      // Captions with fewer characters get a warning; 0 disables the check
+	StatusEditIntervalMs      int                   `toml:"statusEditIntervalMs"`  // Minimum time between edits of one status message, defaults to 1000
+	AllowVerboseErrors        bool                  `toml:"allowVerboseErrors"`    // Lets non-admins opt in to detailed error messages in /myconfig
+	AutoSelectSingleLora      bool                  `toml:"autoSelectSingleLora"`  // Skip the LoRA keyboard for users who can see only one LoRA
+	MediaSendConcurrency      int                   `toml:"mediaSendConcurrency"`  // Albums of one result sent at a time, defaults to 1 (sequential)
+	SendMaxAttempts           int                   `toml:"sendMaxAttempts"`       // Tries per result message on network errors and rate limits, defaults to 3
+	SendRetryBackoffMs        int                   `toml:"sendRetryBackoffMs"`    // Wait before the next try, times the attempt; defaults to 1000
+	SeedButtons               bool                  `toml:"seedButtons"`           // "Reuse seed" buttons below delivered results
+	PromptSanitizer           PromptSanitizerConfig `toml:"promptSanitizer"`
+	PromptBlocklist           PromptBlocklistConfig `toml:"promptBlocklist"`
+	Remix                     RemixConfig           `toml:"remix"`
+	Limits                    LimitsConfig          `toml:"limits"`
+	AutoDelete                AutoDeleteConfig      `toml:"autoDelete"`
+	Webhook                   WebhookConfig         `toml:"webhook"`
+	LoraCheck                 LoraCheckConfig       `toml:"loraCheck"`
+	LoraHealth                LoraHealthConfig      `toml:"loraHealth"`
+	FalBalance                FalBalanceConfig      `toml:"falBalance"`
+	Moderation                ModerationConfig      `toml:"moderation"`
+	Database                  DatabaseConfig        `toml:"database"`
+	Health                    HealthConfig          `toml:"health"`
+	ImageInput                ImageInputConfig      `toml:"imageInput"`
+	ContactSheet              ContactSheetConfig    `toml:"contactSheet"`
+	Support                   SupportConfig         `toml:"support"`
 }
 
 type LogConfig struct {
@@ -32,31 +67,83 @@ type LogConfig struct {
 }
 
 type APIEndpointsConfig struct {
-	BaseURL         string `toml:"baseURL"`
-	FlorenceCaption string `toml:"florenceCaption"`
-	FluxLora        string `toml:"fluxLora"`
-	MaxLoras        int    `toml:"maxLoras"`
+	BaseURL         string   `toml:"baseURL"`
+	FlorenceCaption string   `toml:"florenceCaption"`
+	CaptionModels   []string `toml:"captionModels"` // Further caption endpoints the re-caption button cycles through
+	FluxLora        string   `toml:"fluxLora"`
+	Models          []string `toml:"models"` // Further generation endpoints users can pick for one generation
+	MaxLoras        int      `toml:"maxLoras"`
+	MaxImagePixels  int      `toml:"maxImagePixels"` // Max width*height the fluxLora model accepts, 0 = no limit
+	// Sent with every fluxLora request, for model parameters the bot doesn't manage (e.g. "scheduler")
+	ExtraParams map[string]interface{} `toml:"extraParams"`
 }
 
 type AuthConfig struct {
 	AuthorizedUserIDs []int64 `toml:"authorizedUserIDs"`
+	// Refuse everyone outside authorizedUserIDs, userGroups and the admins. Off by default,
+	// so turning it on is the operator's choice
+	Enforce bool   `toml:"enforce"`
+	Contact string `toml:"contact"` // Whom rejected users can ask for access, e.g. "@admin"
 }
 
 type AdminConfig struct {
 	AdminUserIDs []int64 `toml:"adminUserIDs"`
 }
 
+const (
+	// DefaultLoraWeight is used for LoRAs without a weight, matching fal's default scale.
+	DefaultLoraWeight = 1.0
+	// MaxLoraWeight is the largest LoRA weight accepted; larger scales only produce noise.
+	MaxLoraWeight = 2.0
+)
+
 type LoraConfig struct {
-	Name         string   `toml:"name"`
-	URL          string   `toml:"url"`
-	Weight       float64  `toml:"weight"`
-	AllowGroups  []string `toml:"allowGroups,omitempty"`
-	AppendPrompt string   `toml:"append_prompt"`
+	Name               string   `toml:"name"`
+	URL                string   `toml:"url"`
+	Weight             float64  `toml:"weight"` // 0 < weight <= MaxLoraWeight; use EffectiveWeight
+	AllowGroups        []string `toml:"allowGroups,omitempty"`
+	AppendPrompt       string   `toml:"append_prompt"`
+	PromptPosition     string   `toml:"promptPosition"`     // Where AppendPrompt goes, one of the PromptPosition* constants
+	Pinned             bool     `toml:"pinned"`             // Listed before unpinned LoRAs in keyboards and /loras
+	PreferredImageSize string   `toml:"preferredImageSize"` // Used when the LoRA is selected alone and no size was picked for the generation
+
+	weightSet bool // The config file sets weight, so a 0 Weight is explicit rather than omitted
+}
+
+// Where a LoRA's append_prompt is placed relative to the user prompt.
+const (
+	PromptPositionPrefix = "prefix" // Before the user prompt
+	PromptPositionSuffix = "suffix" // After the user prompt
+)
+
+// EffectiveWeight returns the scale to submit for the LoRA. An omitted weight decodes to 0,
+// which would silently disable the LoRA, so it means DefaultLoraWeight instead. An explicit
+// 0 is rejected by ValidateConfig.
+func (l LoraConfig) EffectiveWeight() float64 {
+	if l.Weight == 0 {
+		return DefaultLoraWeight
+	}
+	return l.Weight
 }
 
 type BalanceConfig struct {
 	InitialBalance    float64 `toml:"initialBalance"`
 	CostPerGeneration float64 `toml:"costPerGeneration"`
+	AdminsFree        bool    `toml:"adminsFree"`   // Admins generate without balance checks or deduction
+	MinReserve        float64 `toml:"minReserve"`   // Part of every balance that generations can't spend, 0 = none
+	LowThreshold      float64 `toml:"lowThreshold"` // Warn users once when a charge leaves less than this, 0 = never
+	// Unit shown after balances and costs, e.g. "credit"/"credits". Empty uses the
+	// translated "points" of the user's language.
+	UnitName       string `toml:"unitName"`
+	UnitNamePlural string `toml:"unitNamePlural"` // Defaults to unitName
+	// Per-language unit names, keyed by language code (e.g. "zh"); overrides unitName
+	Units map[string]BalanceUnitConfig `toml:"units"`
+}
+
+// BalanceUnitConfig names the balance unit in one language.
+type BalanceUnitConfig struct {
+	Name       string `toml:"name"`
+	NamePlural string `toml:"namePlural"` // Defaults to name
 }
 
 type GenerationConfig struct {
@@ -64,21 +151,281 @@ type GenerationConfig struct {
 	NumInferenceSteps int     `toml:"numInferenceSteps" json:"num_inference_steps"`
 	GuidanceScale     float64 `toml:"guidanceScale" json:"guidance_scale"`
 	NumImages         int     `toml:"numImages"`
+	DeliveryMode      string  `toml:"deliveryMode" json:"delivery_mode"` // One of the DeliveryMode* constants, defaults to DeliveryModeGroup
+	// Sent to fal as enable_safety_checker; off unless set
+	EnableSafetyChecker bool `toml:"enableSafetyChecker" json:"enable_safety_checker"`
+}
+
+// Result delivery modes: how generated images are split into Telegram messages.
+const (
+	DeliveryModeGroup   = "group"    // All images in one media group
+	DeliveryModePerLora = "per_lora" // One media group per LoRA combination
+	DeliveryModeSingle  = "single"   // One message per image
+)
+
+// DeliveryModes lists the valid result delivery modes.
+var DeliveryModes = []string{DeliveryModeGroup, DeliveryModePerLora, DeliveryModeSingle}
+
+// IsDeliveryMode reports whether mode is one of DeliveryModes.
+func IsDeliveryMode(mode string) bool {
+	for _, m := range DeliveryModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// PromptSanitizerConfig controls how user prompts are cleaned before being combined
+// with operator-defined text. Prefix and Suffix are always kept, whatever the user sends.
+type PromptSanitizerConfig struct {
+	Enabled      bool     `toml:"enabled"`
+	MaxLength    int      `toml:"maxLength"`    // Max runes kept from the user prompt, 0 = unlimited
+	BlockedTerms []string `toml:"blockedTerms"` // Removed from the user prompt, case-insensitive
+	Prefix       string   `toml:"prefix"`       // Mandatory text placed before everything else
+	Suffix       string   `toml:"suffix"`       // Mandatory text placed after the user prompt
+}
+
+// PromptBlocklistConfig rejects generations whose prompt contains blocked content. Unlike
+// PromptSanitizerConfig.BlockedTerms, which are quietly removed, a match refuses the
+// generation.
+type PromptBlocklistConfig struct {
+	Terms    []string `toml:"terms"`    // Case-insensitive; whitespace in a term matches any run of whitespace
+	Patterns []string `toml:"patterns"` // Regular expressions (RE2 syntax), matched case-insensitively
+}
+
+// RemixConfig controls the "Remix" quick-repeat button, which regenerates the last prompt
+// with a few style modifiers appended.
+type RemixConfig struct {
+	Modifiers         []string `toml:"modifiers"`         // Pool the modifiers are drawn from, defaults to DefaultRemixModifiers
+	ModifiersPerRemix int      `toml:"modifiersPerRemix"` // Modifiers appended per remix, defaults to 2
+}
+
+// DefaultRemixModifiers is the remix pool used when remix.modifiers is empty.
+var DefaultRemixModifiers = []string{
+	"cinematic lighting", "golden hour", "soft pastel colors", "film grain", "dramatic shadows",
+	"watercolor style", "neon lights", "shallow depth of field", "moody atmosphere", "vibrant colors",
+	"wide angle shot", "close-up portrait", "misty", "backlit", "vintage photo",
+}
+
+// DefaultMaxGuidanceScale is the guidance scale cap used when limits.maxGuidanceScale is unset.
+const DefaultMaxGuidanceScale = 15.0
+
+// LimitsConfig caps how much work the bot accepts at once.
+type LimitsConfig struct {
+	MaxConcurrentGenerations int `toml:"maxConcurrentGenerations"` // fal requests in flight across all users, 0 = unlimited
+	MaxQueuedGenerations     int `toml:"maxQueuedGenerations"`     // Requests waiting for a slot before new ones are rejected, 0 = unlimited
+	MaxGenerationsPerChat    int `toml:"maxGenerationsPerChat"`    // Generations running at once in one group chat, defaults to 2, -1 = unlimited
+	PhotoFlowBudgetSeconds   int `toml:"photoFlowBudgetSeconds"`   // Caption plus generation time of one photo, 0 = only the per-step timeouts
+	MaxBatchPrompts          int `toml:"maxBatchPrompts"`          // Prompts accepted by one /batch, defaults to 10
+	GridSize                 int `toml:"gridSize"`                 // Seeds generated by one /grid (2-10), defaults to 4
+	MaxSweepLoras            int `toml:"maxSweepLoras"`            // LoRAs, and so images, of one /sweep (2-50), defaults to 10
+	// Upper bounds for generation settings, enforced for the defaults and /myconfig input.
+	// The lower bounds are fixed: 1 step, guidance 0 and 1 image.
+	MaxInferenceSteps int      `toml:"maxInferenceSteps"` // Defaults to 50
+	MaxGuidanceScale  *float64 `toml:"maxGuidanceScale"`  // Defaults to 15; nil when unset, as 0 is a valid cap. Read with GuidanceScaleCap
+	MaxNumImages      int      `toml:"maxNumImages"`      // Defaults to 10
+	// Per-user limit on photo captioning, separate from generations
+	CaptionsPerMinute int `toml:"captionsPerMinute"` // 0 = unlimited
+	CaptionBurst      int `toml:"captionBurst"`      // Captions allowed back to back, defaults to 3
+	// Wait after a completed generation before the same user can start another; admins are exempt
+	GenerationCooldownSeconds int `toml:"generationCooldownSeconds"` // 0 = no cooldown
+}
+
+// GuidanceScaleCap returns the highest guidance scale users may set.
+func (l LimitsConfig) GuidanceScaleCap() float64 {
+	if l.MaxGuidanceScale == nil {
+		return DefaultMaxGuidanceScale
+	}
+	return *l.MaxGuidanceScale
+}
+
+// AutoDeleteConfig removes generated results from the chat after a TTL. Users can toggle it
+// in /myconfig; DefaultEnabled applies to users who never did.
+type AutoDeleteConfig struct {
+	TTLMinutes     int  `toml:"ttlMinutes"` // 0 disables auto-delete, must stay below Telegram's 48h limit
+	DefaultEnabled bool `toml:"defaultEnabled"`
+}
+
+// LoraHealthConfig disables a LoRA for non-admins while its generations keep failing.
+type LoraHealthConfig struct {
+	Enabled          bool `toml:"enabled"`
+	FailureThreshold int  `toml:"failureThreshold"` // Failures within the window that disable a LoRA, defaults to 5
+	WindowMinutes    int  `toml:"windowMinutes"`    // Defaults to 30
+	DisableMinutes   int  `toml:"disableMinutes"`   // How long a LoRA stays disabled, defaults to 60
+}
+
+// LoraCheckConfig verifies at startup that every LoRA URL is reachable.
+type LoraCheckConfig struct {
+	Enabled        bool   `toml:"enabled"`
+	TimeoutSeconds int    `toml:"timeoutSeconds"` // Per URL, defaults to 10
+	Concurrency    int    `toml:"concurrency"`    // URLs checked at once, defaults to 4
+	OnFailure      string `toml:"onFailure"`      // One of the LoraCheck* constants, defaults to LoraCheckWarn
+}
+
+// What to do with unreachable LoRAs found by the startup check.
+const (
+	LoraCheckWarn    = "warn"    // Log them and keep them
+	LoraCheckDisable = "disable" // Log them and remove them from the bot
+	LoraCheckAbort   = "abort"   // Refuse to start
+)
+
+// FalBalanceConfig controls how the fal account balance is fetched and tracked for admins.
+type FalBalanceConfig struct {
+	SnapshotIntervalMinutes int `toml:"snapshotIntervalMinutes"` // 0 disables snapshots (and /falbalance trends)
+	CacheSeconds            int `toml:"cacheSeconds"`            // Reuse a fetched balance this long, defaults to 60
+}
+
+// ModerationConfig holds generated results for admin approval before they are delivered.
+type ModerationConfig struct {
+	Enabled bool  `toml:"enabled"`
+	ChatID  int64 `toml:"chatID"` // Chat where admins approve or reject results
+}
+
+// DatabaseConfig tunes SQLite for the bot's concurrent writes.
+//
+// SQLite allows a single writer at a time whatever the pool size. With MaxOpenConns 1 every
+// query, reads included, queues behind the one before it, so lock errors can't happen but a
+// slow write stalls everything. Larger pools let reads run in parallel under WAL, and
+// writers wait for each other for up to BusyTimeoutMs before failing with "database is
+// locked"; raise it rather than shrinking the pool if that error shows up.
+type DatabaseConfig struct {
+	BusyTimeoutMs             int    `toml:"busyTimeoutMs"`             // Wait for a lock this long before "database is locked", defaults to 5000
+	MaxOpenConns              int    `toml:"maxOpenConns"`              // 1 serializes all access, defaults to 25
+	MaxIdleConns              int    `toml:"maxIdleConns"`              // Defaults to maxOpenConns
+	CheckpointIntervalMinutes int    `toml:"checkpointIntervalMinutes"` // 0 leaves checkpoints to SQLite's auto-checkpoint
+	CheckpointMode            string `toml:"checkpointMode"`            // PASSIVE (default), FULL, RESTART or TRUNCATE
+}
+
+// WAL checkpoint modes for DatabaseConfig.CheckpointMode
+var walCheckpointModes = []string{"PASSIVE", "FULL", "RESTART", "TRUNCATE"}
+
+// HealthConfig serves /healthz and /readyz for liveness and readiness probes.
+type HealthConfig struct {
+	ListenAddr string `toml:"listenAddr"` // e.g. ":8081"; empty disables the endpoints
+}
+
+// ImageInputConfig controls how photos sent by users are prepared before fal sees them.
+type ImageInputConfig struct {
+	Downscale    *bool `toml:"downscale"`    // nil means enabled; use DownscaleEnabled
+	MaxDimension int   `toml:"maxDimension"` // Longest side in pixels after downscaling, defaults to 1536
+}
+
+// DownscaleEnabled reports whether photos larger than MaxDimension are downscaled. It is on
+// unless downscale is explicitly set to false.
+func (c ImageInputConfig) DownscaleEnabled() bool {
+	return c.Downscale == nil || *c.Downscale
 }
 
+// SupportConfig is the operator contact info shown by /support.
+type SupportConfig struct {
+	Contact   string `toml:"contact"`   // e.g. "@admin", defaults to auth.contact
+	DocsURL   string `toml:"docsURL"`   // User documentation
+	StatusURL string `toml:"statusURL"` // Status page
+}
+
+// ContactSheetConfig sends large results as one numbered thumbnail grid instead of many
+// albums.
+type ContactSheetConfig struct {
+	Enabled       bool `toml:"enabled"`
+	MinImages     int  `toml:"minImages"`     // Results with at least this many photos get a sheet, defaults to 10
+	Columns       int  `toml:"columns"`       // Thumbnails per row, defaults to 4
+	ThumbSize     int  `toml:"thumbSize"`     // Longest side of a thumbnail in pixels, defaults to 384
+	SendOriginals bool `toml:"sendOriginals"` // Also send the full-size images as documents
+}
+
+// WebhookConfig lets fal deliver generation results to an HTTP endpoint of the bot
+// instead of the bot polling for them.
+type WebhookConfig struct {
+	Enabled    bool   `toml:"enabled"`
+	ListenAddr string `toml:"listenAddr"` // Address the receiver listens on, e.g. ":8080"
+	PublicURL  string `toml:"publicURL"`  // URL fal can reach, e.g. "https://bot.example.com/fal/webhook"
+	Secret     string `toml:"secret"`     // Optional token fal must send back; checked on every delivery
+}
+
+// MaxMediaSendConcurrency caps mediaSendConcurrency; Telegram rate limits bursts of albums
+// to one chat, so more parallel sends only trade speed for 429 errors.
+const MaxMediaSendConcurrency = 4
+
 type UserGroup struct {
 	Name    string  `toml:"name"`
 	UserIDs []int64 `toml:"userIDs"`
+	// Replace GlobalPromptPrefix/GlobalPromptSuffix for members when set; "" removes them
+	PromptPrefix *string `toml:"promptPrefix"`
+	PromptSuffix *string `toml:"promptSuffix"`
+	// Offered to members by /presets
+	Presets []GroupPreset `toml:"presets"`
 }
 
+// GroupPreset is a named LoRA and parameter combination operators recommend to the members
+// of a group. Zero parameters keep the user's own settings.
+type GroupPreset struct {
+	Name              string   `toml:"name"`
+	Loras             []string `toml:"loras"`     // Standard LoRA names, at least one
+	BaseLoras         []string `toml:"baseLoras"` // Base LoRA names
+	ImageSize         string   `toml:"imageSize"`
+	NumInferenceSteps int      `toml:"numInferenceSteps"`
+	GuidanceScale     float64  `toml:"guidanceScale"`
+	NumImages         int      `toml:"numImages"`
+}
+
+// Environment variables that override botToken and falAIKey when set, so secrets can be
+// injected in containers instead of being written to the config file.
+const (
+	BotTokenEnv = "TELEGRAM_BOT_TOKEN"
+	FalAIKeyEnv = "FAL_KEY"
+)
+
+// envReference matches a config value that is entirely a ${NAME} environment reference.
+var envReference = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
 func LoadConfig(path string) (*Config, error) {
 	var cfg Config
 	if _, err := toml.DecodeFile(path, &cfg); err != nil {
 		return nil, err
 	}
+	if err := markExplicitLoraWeights(path, &cfg); err != nil {
+		return nil, err
+	}
+	cfg.BotToken = resolveSecret(cfg.BotToken, BotTokenEnv)
+	cfg.FalAIKey = resolveSecret(cfg.FalAIKey, FalAIKeyEnv)
 	return &cfg, nil
 }
 
+// markExplicitLoraWeights records which LoRAs of the file at path set weight. A decoded
+// Weight of 0 can't tell "weight = 0" from an omitted weight otherwise.
+func markExplicitLoraWeights(path string, cfg *Config) error {
+	var raw struct {
+		BaseLoRAs []map[string]interface{} `toml:"baseLoRAs"`
+		LoRAs     []map[string]interface{} `toml:"loras"`
+	}
+	if _, err := toml.DecodeFile(path, &raw); err != nil {
+		return err
+	}
+	mark := func(loras []LoraConfig, rawLoras []map[string]interface{}) {
+		for i := range loras {
+			if i < len(rawLoras) {
+				_, loras[i].weightSet = rawLoras[i]["weight"]
+			}
+		}
+	}
+	mark(cfg.BaseLoRAs, raw.BaseLoRAs)
+	mark(cfg.LoRAs, raw.LoRAs)
+	return nil
+}
+
+// resolveSecret returns the value of envName if it is set, else value with a ${NAME}
+// reference expanded.
+func resolveSecret(value, envName string) string {
+	if env := os.Getenv(envName); env != "" {
+		return env
+	}
+	if m := envReference.FindStringSubmatch(value); m != nil {
+		return os.Getenv(m[1])
+	}
+	return value
+}
+
 func ValidateURL(urlString string) bool {
 	if urlString == "" {
 		return false
@@ -91,7 +438,10 @@ func ValidateURL(urlString string) bool {
 }
 
 func MaskedPrint(str string) string {
-	// only show the last 4 characters
+	// only show the last 4 characters, and none of short values
+	if len(str) <= 4 {
+		return strings.Repeat("*", len(str))
+	}
 	return strings.Repeat("*", len(str)-4) + str[len(str)-4:]
 }
 
@@ -119,30 +469,96 @@ func PrintConfig(cfg *Config) {
 	fmt.Printf("\tDefaultGenerationSettings: %v\n", cfg.DefaultGenerationSettings)
 	fmt.Printf("\tUserGroups: %v\n", cfg.UserGroups)
 	fmt.Printf("\tDefaultLanguage: %s\n", cfg.DefaultLanguage)
+	fmt.Printf("\tLanguageFallbacks: %v\n", cfg.LanguageFallbacks)
+	fmt.Printf("\tCaptionPromptTemplate: %q\n", cfg.CaptionPromptTemplate)
+	fmt.Printf("\tGlobalPromptPrefix: %q\n", cfg.GlobalPromptPrefix)
+	fmt.Printf("\tGlobalPromptSuffix: %q\n", cfg.GlobalPromptSuffix)
+	fmt.Printf("\tCaptioningEnabled: %v\n", cfg.CaptioningEnabled())
+	fmt.Printf("\tCaptionMaxAttempts: %d\n", cfg.CaptionMaxAttempts)
+	fmt.Printf("\tFallbackPrompt: %q\n", cfg.FallbackPrompt)
+	fmt.Printf("\tMinCaptionLength: %d\n", cfg.MinCaptionLength)
+	fmt.Printf("\tStatusEditIntervalMs: %d\n", cfg.StatusEditIntervalMs)
+	fmt.Printf("\tMediaSendConcurrency: %d\n", cfg.MediaSendConcurrency)
+	fmt.Printf("\tSendMaxAttempts: %d\n", cfg.SendMaxAttempts)
+	fmt.Printf("\tSendRetryBackoffMs: %d\n", cfg.SendRetryBackoffMs)
+	fmt.Printf("\tAllowVerboseErrors: %v\n", cfg.AllowVerboseErrors)
+	fmt.Printf("\tAutoSelectSingleLora: %v\n", cfg.AutoSelectSingleLora)
+	fmt.Printf("\tSeedButtons: %v\n", cfg.SeedButtons)
+	fmt.Printf("\tPromptSanitizer: %v\n", cfg.PromptSanitizer)
+	fmt.Printf("\tPromptBlocklist: %d terms, %d patterns\n", len(cfg.PromptBlocklist.Terms), len(cfg.PromptBlocklist.Patterns))
+	fmt.Printf("\tRemix: %v\n", cfg.Remix)
+	fmt.Printf("\tLimits: %v (maxGuidanceScale %g)\n", cfg.Limits, cfg.Limits.GuidanceScaleCap())
+	fmt.Printf("\tAutoDelete: %v\n", cfg.AutoDelete)
+	fmt.Printf("\tLoraCheck: %v\n", cfg.LoraCheck)
+	fmt.Printf("\tLoraHealth: %v\n", cfg.LoraHealth)
+	fmt.Printf("\tFalBalance: %v\n", cfg.FalBalance)
+	fmt.Printf("\tModeration: %v\n", cfg.Moderation)
+	fmt.Printf("\tDatabase: %v\n", cfg.Database)
+	fmt.Printf("\tHealth: %v\n", cfg.Health)
+	fmt.Printf("\tImageInput: Downscale: %v, MaxDimension: %d\n", cfg.ImageInput.DownscaleEnabled(), cfg.ImageInput.MaxDimension)
+	fmt.Printf("\tContactSheet: %v\n", cfg.ContactSheet)
+	fmt.Printf("\tSupport: %v\n", cfg.Support)
+	fmt.Printf("\tWebhook: Enabled: %v, ListenAddr: %s, PublicURL: %s, Secret: %s\n", cfg.Webhook.Enabled, cfg.Webhook.ListenAddr, cfg.Webhook.PublicURL, MaskedPrint(cfg.Webhook.Secret))
 	fmt.Println("--------------------------------")
 	fmt.Println()
 }
 
+// CaptioningEnabled reports whether uploaded photos are captioned. Captioning is on unless
+// enableCaptioning is explicitly set to false.
+func (c *Config) CaptioningEnabled() bool {
+	return c.EnableCaptioning == nil || *c.EnableCaptioning
+}
+
+// CaptionEndpoints returns the caption endpoints in re-caption order: florenceCaption first,
+// then captionModels.
+func (c APIEndpointsConfig) CaptionEndpoints() []string {
+	return append([]string{c.FlorenceCaption}, c.CaptionModels...)
+}
+
+// GenerationModels returns the generation endpoints users can pick from: fluxLora, the
+// default, then models.
+func (c APIEndpointsConfig) GenerationModels() []string {
+	return append([]string{c.FluxLora}, c.Models...)
+}
+
 func ValidateConfig(cfg *Config) error {
 	PrintConfig(cfg)
 	if cfg.BotToken == "" {
-		return fmt.Errorf("BotToken is required")
+		return fmt.Errorf("botToken is required: set it in the config or via %s", BotTokenEnv)
 	}
 	if cfg.FalAIKey == "" {
-		return fmt.Errorf("falAIKey is required")
+		return fmt.Errorf("falAIKey is required: set it in the config or via %s", FalAIKeyEnv)
 	}
 	if cfg.TelegramAPIURL == "" || !ValidateURL(strings.ReplaceAll(cfg.TelegramAPIURL, "%s", cfg.BotToken)) {
 		return fmt.Errorf("telegramAPIURL is required and must be a valid URL")
 	}
-	if cfg.APIEndpoints.FlorenceCaption == "" || !ValidateURL(cfg.APIEndpoints.FlorenceCaption) {
-		return fmt.Errorf("APIEndpoints is required and must be a valid URL")
+	if cfg.CaptioningEnabled() && (cfg.APIEndpoints.FlorenceCaption == "" || !ValidateURL(cfg.APIEndpoints.FlorenceCaption)) {
+		return fmt.Errorf("florenceCaption is required and must be a valid URL when captioning is enabled")
+	}
+	for _, model := range cfg.APIEndpoints.CaptionModels {
+		if model == "" || !ValidateURL(model) {
+			return fmt.Errorf("apiEndpoints.captionModels entries must be valid URLs, got %q", model)
+		}
 	}
 	if cfg.APIEndpoints.FluxLora == "" || !ValidateURL(cfg.APIEndpoints.FluxLora) {
 		return fmt.Errorf("fluxLora is required and must be a valid URL")
 	}
+	for _, model := range cfg.APIEndpoints.Models {
+		if model == "" || !ValidateURL(model) {
+			return fmt.Errorf("apiEndpoints.models entries must be valid URLs, got %q", model)
+		}
+	}
 	if cfg.APIEndpoints.MaxLoras <= 0 {
 		cfg.APIEndpoints.MaxLoras = 2
 	}
+	if cfg.APIEndpoints.MaxImagePixels < 0 {
+		return fmt.Errorf("apiEndpoints.maxImagePixels must not be negative")
+	}
+	for key := range cfg.APIEndpoints.ExtraParams {
+		if slices.Contains(falapi.ManagedGenerationParams, key) {
+			return fmt.Errorf("apiEndpoints.extraParams must not set %q, it is managed by the bot", key)
+		}
+	}
 	if len(cfg.Admins.AdminUserIDs) == 0 {
 		return fmt.Errorf("adminUserIDs is required")
 	}
@@ -158,6 +574,20 @@ func ValidateConfig(cfg *Config) error {
 	if cfg.Balance.CostPerGeneration <= 0 {
 		return fmt.Errorf("costPerGeneration must be greater than 0")
 	}
+	if cfg.Balance.MinReserve < 0 {
+		return fmt.Errorf("balance.minReserve must not be negative")
+	}
+	if cfg.Balance.LowThreshold < 0 {
+		return fmt.Errorf("balance.lowThreshold must not be negative")
+	}
+	if cfg.Balance.UnitNamePlural != "" && cfg.Balance.UnitName == "" {
+		return fmt.Errorf("balance.unitNamePlural requires balance.unitName")
+	}
+	for lang, unit := range cfg.Balance.Units {
+		if unit.Name == "" {
+			return fmt.Errorf("balance.units.%s: name is required", lang)
+		}
+	}
 	if cfg.DBPath == "" {
 		return fmt.Errorf("dbPath is required")
 	}
@@ -170,21 +600,251 @@ func ValidateConfig(cfg *Config) error {
 	if cfg.DefaultGenerationSettings.ImageSize == "" {
 		return fmt.Errorf("imageSize is required")
 	}
-	if !(cfg.DefaultGenerationSettings.ImageSize == "portrait_16_9" || cfg.DefaultGenerationSettings.ImageSize == "square" || cfg.DefaultGenerationSettings.ImageSize == "landscape_16_9" || cfg.DefaultGenerationSettings.ImageSize == "landscape_4_3" || cfg.DefaultGenerationSettings.ImageSize == "portrait_4_3") {
-		return fmt.Errorf("imageSize must be one of: portrait_16_9, square, landscape_16_9, landscape_4_3, portrait_4_3")
+	if !falapi.IsImageSizePreset(cfg.DefaultGenerationSettings.ImageSize) {
+		return fmt.Errorf("imageSize must be one of: %s", strings.Join(falapi.ImageSizePresetNames, ", "))
+	}
+	// Setting bounds first, the defaults are validated against them
+	if cfg.Limits.MaxGenerationsPerChat == 0 {
+		cfg.Limits.MaxGenerationsPerChat = 2
+	}
+	if cfg.Limits.MaxGenerationsPerChat < -1 {
+		return fmt.Errorf("limits.maxGenerationsPerChat must be at least 1, or -1 for no limit")
+	}
+	if cfg.Limits.PhotoFlowBudgetSeconds < 0 {
+		return fmt.Errorf("limits.photoFlowBudgetSeconds must not be negative")
+	}
+	if cfg.Limits.MaxInferenceSteps == 0 {
+		cfg.Limits.MaxInferenceSteps = 50
+	}
+	if cfg.Limits.MaxInferenceSteps < 1 {
+		return fmt.Errorf("limits.maxInferenceSteps must be at least 1")
+	}
+	if cfg.Limits.MaxGuidanceScale == nil {
+		maxGuidanceScale := DefaultMaxGuidanceScale
+		cfg.Limits.MaxGuidanceScale = &maxGuidanceScale
 	}
-	if cfg.DefaultGenerationSettings.NumInferenceSteps <= 0 || cfg.DefaultGenerationSettings.NumInferenceSteps > 50 {
-		return fmt.Errorf("numInferenceSteps must be greater than 0 and less than 50")
+	if *cfg.Limits.MaxGuidanceScale < 0 {
+		return fmt.Errorf("limits.maxGuidanceScale must not be negative")
 	}
-	if cfg.DefaultGenerationSettings.GuidanceScale < 0 || cfg.DefaultGenerationSettings.GuidanceScale > 15 {
-		return fmt.Errorf("guidanceScale must be between 0 and 15")
+	if cfg.Limits.MaxNumImages == 0 {
+		cfg.Limits.MaxNumImages = 10
 	}
-	if cfg.DefaultGenerationSettings.NumImages <= 0 {
-		return fmt.Errorf("numImages must be positive")
+	if cfg.Limits.MaxNumImages < 1 {
+		return fmt.Errorf("limits.maxNumImages must be at least 1")
+	}
+	if cfg.DefaultGenerationSettings.NumInferenceSteps < 1 || cfg.DefaultGenerationSettings.NumInferenceSteps > cfg.Limits.MaxInferenceSteps {
+		return fmt.Errorf("numInferenceSteps must be between 1 and %d (limits.maxInferenceSteps)", cfg.Limits.MaxInferenceSteps)
+	}
+	if cfg.DefaultGenerationSettings.GuidanceScale < 0 || cfg.DefaultGenerationSettings.GuidanceScale > cfg.Limits.GuidanceScaleCap() {
+		return fmt.Errorf("guidanceScale must be between 0 and %g (limits.maxGuidanceScale)", cfg.Limits.GuidanceScaleCap())
+	}
+	if cfg.DefaultGenerationSettings.NumImages < 1 || cfg.DefaultGenerationSettings.NumImages > cfg.Limits.MaxNumImages {
+		return fmt.Errorf("numImages must be between 1 and %d (limits.maxNumImages)", cfg.Limits.MaxNumImages)
+	}
+	if cfg.DefaultGenerationSettings.DeliveryMode == "" {
+		cfg.DefaultGenerationSettings.DeliveryMode = DeliveryModeGroup
+	}
+	if !IsDeliveryMode(cfg.DefaultGenerationSettings.DeliveryMode) {
+		return fmt.Errorf("deliveryMode must be one of: %s", strings.Join(DeliveryModes, ", "))
 	}
 	if cfg.DefaultLanguage == "" {
 		return fmt.Errorf("defaultLanguage is required")
 	}
+	if cfg.CaptionMaxAttempts == 0 {
+		cfg.CaptionMaxAttempts = 3
+	}
+	if cfg.CaptionMaxAttempts < 1 || cfg.CaptionMaxAttempts > 10 {
+		return fmt.Errorf("captionMaxAttempts must be between 1 and 10")
+	}
+	if cfg.MinCaptionLength < 0 {
+		return fmt.Errorf("minCaptionLength must not be negative")
+	}
+	if cfg.StatusEditIntervalMs == 0 {
+		cfg.StatusEditIntervalMs = 1000
+	}
+	if cfg.StatusEditIntervalMs < 0 {
+		return fmt.Errorf("statusEditIntervalMs must not be negative")
+	}
+	if cfg.MediaSendConcurrency == 0 {
+		cfg.MediaSendConcurrency = 1
+	}
+	if cfg.MediaSendConcurrency < 1 || cfg.MediaSendConcurrency > MaxMediaSendConcurrency {
+		return fmt.Errorf("mediaSendConcurrency must be between 1 and %d", MaxMediaSendConcurrency)
+	}
+	if cfg.SendMaxAttempts == 0 {
+		cfg.SendMaxAttempts = 3
+	}
+	if cfg.SendMaxAttempts < 1 || cfg.SendMaxAttempts > 10 {
+		return fmt.Errorf("sendMaxAttempts must be between 1 and 10")
+	}
+	if cfg.SendRetryBackoffMs == 0 {
+		cfg.SendRetryBackoffMs = 1000
+	}
+	if cfg.SendRetryBackoffMs < 0 {
+		return fmt.Errorf("sendRetryBackoffMs must not be negative")
+	}
+	if cfg.CaptionPromptTemplate != "" && !strings.Contains(cfg.CaptionPromptTemplate, "{caption}") {
+		return fmt.Errorf("captionPromptTemplate must contain the {caption} placeholder")
+	}
+
+	if cfg.PromptSanitizer.MaxLength < 0 {
+		return fmt.Errorf("promptSanitizer.maxLength must not be negative")
+	}
+	for _, pattern := range cfg.PromptBlocklist.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("promptBlocklist.patterns: invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	if len(cfg.Remix.Modifiers) == 0 {
+		cfg.Remix.Modifiers = DefaultRemixModifiers
+	}
+	if cfg.Remix.ModifiersPerRemix == 0 {
+		cfg.Remix.ModifiersPerRemix = 2
+	}
+	if cfg.Remix.ModifiersPerRemix < 0 {
+		return fmt.Errorf("remix.modifiersPerRemix must not be negative")
+	}
+
+	if cfg.Limits.MaxConcurrentGenerations < 0 {
+		return fmt.Errorf("limits.maxConcurrentGenerations must not be negative")
+	}
+	if cfg.Limits.MaxQueuedGenerations < 0 {
+		return fmt.Errorf("limits.maxQueuedGenerations must not be negative")
+	}
+	if cfg.Limits.MaxBatchPrompts < 0 {
+		return fmt.Errorf("limits.maxBatchPrompts must not be negative")
+	}
+	if cfg.Limits.MaxBatchPrompts == 0 {
+		cfg.Limits.MaxBatchPrompts = 10
+	}
+	if cfg.Limits.GridSize == 0 {
+		cfg.Limits.GridSize = 4
+	}
+	if cfg.Limits.GridSize < 2 || cfg.Limits.GridSize > 10 {
+		return fmt.Errorf("limits.gridSize must be between 2 and 10")
+	}
+	if cfg.Limits.MaxSweepLoras == 0 {
+		cfg.Limits.MaxSweepLoras = 10
+	}
+	if cfg.Limits.MaxSweepLoras < 2 || cfg.Limits.MaxSweepLoras > 50 {
+		return fmt.Errorf("limits.maxSweepLoras must be between 2 and 50")
+	}
+	if cfg.Limits.CaptionsPerMinute < 0 {
+		return fmt.Errorf("limits.captionsPerMinute must not be negative")
+	}
+	if cfg.Limits.CaptionBurst == 0 {
+		cfg.Limits.CaptionBurst = 3
+	}
+	if cfg.Limits.CaptionBurst < 0 {
+		return fmt.Errorf("limits.captionBurst must not be negative")
+	}
+	if cfg.Limits.GenerationCooldownSeconds < 0 {
+		return fmt.Errorf("limits.generationCooldownSeconds must not be negative")
+	}
+
+	// Telegram bots can only delete messages younger than 48 hours
+	if cfg.AutoDelete.TTLMinutes < 0 || cfg.AutoDelete.TTLMinutes >= 48*60 {
+		return fmt.Errorf("autoDelete.ttlMinutes must be between 0 and %d", 48*60-1)
+	}
+
+	if cfg.Webhook.Enabled {
+		if cfg.Webhook.ListenAddr == "" {
+			return fmt.Errorf("webhook.listenAddr is required when webhook is enabled")
+		}
+		if !ValidateURL(cfg.Webhook.PublicURL) {
+			return fmt.Errorf("webhook.publicURL must be a valid URL when webhook is enabled")
+		}
+	}
+	if cfg.Health.ListenAddr != "" && cfg.Webhook.Enabled && cfg.Health.ListenAddr == cfg.Webhook.ListenAddr {
+		return fmt.Errorf("health.listenAddr must differ from webhook.listenAddr")
+	}
+
+	if cfg.ImageInput.MaxDimension == 0 {
+		cfg.ImageInput.MaxDimension = 1536
+	}
+	if cfg.ImageInput.MaxDimension < 0 {
+		return fmt.Errorf("imageInput.maxDimension must not be negative")
+	}
+
+	if cfg.Support.Contact == "" {
+		cfg.Support.Contact = cfg.Auth.Contact
+	}
+
+	if cfg.ContactSheet.MinImages == 0 {
+		cfg.ContactSheet.MinImages = 10
+	}
+	if cfg.ContactSheet.Columns == 0 {
+		cfg.ContactSheet.Columns = 4
+	}
+	if cfg.ContactSheet.ThumbSize == 0 {
+		cfg.ContactSheet.ThumbSize = 384
+	}
+	if cfg.ContactSheet.MinImages < 2 {
+		return fmt.Errorf("contactSheet.minImages must be at least 2")
+	}
+	if cfg.ContactSheet.Columns < 0 || cfg.ContactSheet.ThumbSize < 0 {
+		return fmt.Errorf("contactSheet.columns and contactSheet.thumbSize must not be negative")
+	}
+
+	if cfg.LoraCheck.TimeoutSeconds <= 0 {
+		cfg.LoraCheck.TimeoutSeconds = 10
+	}
+	if cfg.LoraCheck.Concurrency <= 0 {
+		cfg.LoraCheck.Concurrency = 4
+	}
+	if cfg.LoraCheck.OnFailure == "" {
+		cfg.LoraCheck.OnFailure = LoraCheckWarn
+	}
+	if cfg.LoraCheck.OnFailure != LoraCheckWarn && cfg.LoraCheck.OnFailure != LoraCheckDisable && cfg.LoraCheck.OnFailure != LoraCheckAbort {
+		return fmt.Errorf("loraCheck.onFailure must be one of: %s, %s, %s", LoraCheckWarn, LoraCheckDisable, LoraCheckAbort)
+	}
+	if cfg.LoraHealth.FailureThreshold == 0 {
+		cfg.LoraHealth.FailureThreshold = 5
+	}
+	if cfg.LoraHealth.WindowMinutes == 0 {
+		cfg.LoraHealth.WindowMinutes = 30
+	}
+	if cfg.LoraHealth.DisableMinutes == 0 {
+		cfg.LoraHealth.DisableMinutes = 60
+	}
+	if cfg.LoraHealth.FailureThreshold < 0 || cfg.LoraHealth.WindowMinutes < 0 || cfg.LoraHealth.DisableMinutes < 0 {
+		return fmt.Errorf("loraHealth.failureThreshold, windowMinutes and disableMinutes must not be negative")
+	}
+
+	if cfg.FalBalance.SnapshotIntervalMinutes < 0 {
+		return fmt.Errorf("falBalance.snapshotIntervalMinutes must not be negative")
+	}
+	if cfg.FalBalance.CacheSeconds == 0 {
+		cfg.FalBalance.CacheSeconds = 60
+	}
+	if cfg.FalBalance.CacheSeconds < 0 {
+		return fmt.Errorf("falBalance.cacheSeconds must not be negative")
+	}
+
+	if cfg.Moderation.Enabled && cfg.Moderation.ChatID == 0 {
+		return fmt.Errorf("moderation.chatID is required when moderation is enabled")
+	}
+
+	if cfg.Database.BusyTimeoutMs == 0 {
+		cfg.Database.BusyTimeoutMs = 5000
+	}
+	if cfg.Database.MaxOpenConns == 0 {
+		cfg.Database.MaxOpenConns = 25
+	}
+	if cfg.Database.MaxIdleConns == 0 {
+		cfg.Database.MaxIdleConns = cfg.Database.MaxOpenConns
+	}
+	if cfg.Database.BusyTimeoutMs < 0 || cfg.Database.MaxOpenConns < 0 || cfg.Database.MaxIdleConns < 0 || cfg.Database.CheckpointIntervalMinutes < 0 {
+		return fmt.Errorf("database.busyTimeoutMs, maxOpenConns, maxIdleConns and checkpointIntervalMinutes must not be negative")
+	}
+	if cfg.Database.CheckpointMode == "" {
+		cfg.Database.CheckpointMode = "PASSIVE"
+	}
+	cfg.Database.CheckpointMode = strings.ToUpper(cfg.Database.CheckpointMode)
+	if !slices.Contains(walCheckpointModes, cfg.Database.CheckpointMode) {
+		return fmt.Errorf("database.checkpointMode must be one of: %s", strings.Join(walCheckpointModes, ", "))
+	}
 
 	groupNames := make(map[string]struct{})
 	for _, group := range cfg.UserGroups {
@@ -199,7 +859,8 @@ func ValidateConfig(cfg *Config) error {
 
 	validateLoraList := func(loras []LoraConfig, listName string) error {
 		loraNames := make(map[string]struct{})
-		for _, lora := range loras {
+		for i := range loras {
+			lora := &loras[i]
 			if lora.Name == "" {
 				return fmt.Errorf("lora name in %s cannot be empty", listName)
 			}
@@ -212,6 +873,23 @@ func ValidateConfig(cfg *Config) error {
 				return fmt.Errorf("lora '%s' in %s has an invalid URL: %s", lora.Name, listName, lora.URL)
 			}
 
+			if lora.weightSet && lora.Weight == 0 {
+				return fmt.Errorf("lora '%s' in %s has weight 0, which disables it; omit weight to use %g", lora.Name, listName, DefaultLoraWeight)
+			}
+			lora.Weight = lora.EffectiveWeight()
+			if lora.Weight <= 0 || lora.Weight > MaxLoraWeight {
+				return fmt.Errorf("lora '%s' in %s has weight %g, must be greater than 0 and at most %g", lora.Name, listName, lora.Weight, MaxLoraWeight)
+			}
+			if lora.PromptPosition == "" {
+				lora.PromptPosition = PromptPositionPrefix
+			}
+			if lora.PromptPosition != PromptPositionPrefix && lora.PromptPosition != PromptPositionSuffix {
+				return fmt.Errorf("lora '%s' in %s has promptPosition '%s', must be '%s' or '%s'", lora.Name, listName, lora.PromptPosition, PromptPositionPrefix, PromptPositionSuffix)
+			}
+			if lora.PreferredImageSize != "" && !falapi.IsImageSizePreset(lora.PreferredImageSize) {
+				return fmt.Errorf("lora '%s' in %s has preferredImageSize '%s', must be one of: %s", lora.Name, listName, lora.PreferredImageSize, strings.Join(falapi.ImageSizePresetNames, ", "))
+			}
+
 			for _, allowedGroup := range lora.AllowGroups {
 				if _, ok := groupNames[allowedGroup]; !ok {
 					return fmt.Errorf("group '%s' in allowGroups for lora '%s' (list %s) does not exist in userGroups definition", allowedGroup, lora.Name, listName)
@@ -228,5 +906,55 @@ func ValidateConfig(cfg *Config) error {
 		return err
 	}
 
+	for _, group := range cfg.UserGroups {
+		presetNames := make(map[string]struct{})
+		for _, preset := range group.Presets {
+			if preset.Name == "" {
+				return fmt.Errorf("preset name in user group '%s' cannot be empty", group.Name)
+			}
+			if _, exists := presetNames[preset.Name]; exists {
+				return fmt.Errorf("duplicate preset name found in user group '%s': %s", group.Name, preset.Name)
+			}
+			presetNames[preset.Name] = struct{}{}
+			if err := validateGroupPreset(preset, cfg); err != nil {
+				return fmt.Errorf("preset '%s' in user group '%s': %w", preset.Name, group.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateGroupPreset checks that preset only names existing LoRAs and that its parameters
+// are within limits.
+func validateGroupPreset(preset GroupPreset, cfg *Config) error {
+	if len(preset.Loras) == 0 {
+		return fmt.Errorf("loras must name at least one LoRA")
+	}
+	hasLora := func(loras []LoraConfig, name string) bool {
+		return slices.ContainsFunc(loras, func(l LoraConfig) bool { return l.Name == name })
+	}
+	for _, name := range preset.Loras {
+		if !hasLora(cfg.LoRAs, name) {
+			return fmt.Errorf("lora '%s' does not exist in loras", name)
+		}
+	}
+	for _, name := range preset.BaseLoras {
+		if !hasLora(cfg.BaseLoRAs, name) {
+			return fmt.Errorf("base lora '%s' does not exist in baseLoRAs", name)
+		}
+	}
+	if preset.ImageSize != "" && !falapi.IsImageSizePreset(preset.ImageSize) {
+		return fmt.Errorf("imageSize must be one of: %s", strings.Join(falapi.ImageSizePresetNames, ", "))
+	}
+	if preset.NumInferenceSteps < 0 || preset.NumInferenceSteps > cfg.Limits.MaxInferenceSteps {
+		return fmt.Errorf("numInferenceSteps must be between 1 and %d (limits.maxInferenceSteps)", cfg.Limits.MaxInferenceSteps)
+	}
+	if preset.GuidanceScale < 0 || preset.GuidanceScale > cfg.Limits.GuidanceScaleCap() {
+		return fmt.Errorf("guidanceScale must be between 0 and %g (limits.maxGuidanceScale)", cfg.Limits.GuidanceScaleCap())
+	}
+	if preset.NumImages < 0 || preset.NumImages > cfg.Limits.MaxNumImages {
+		return fmt.Errorf("numImages must be between 1 and %d (limits.maxNumImages)", cfg.Limits.MaxNumImages)
+	}
 	return nil
 }
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validConfig loads the example config.toml, which ValidateConfig accepts.
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	cfg, err := LoadConfig("../../config.toml")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	return cfg
+}
+
+func TestValidateConfigLoraWeights(t *testing.T) {
+	tests := []struct {
+		name       string
+		weight     float64
+		wantErr    string
+		wantWeight float64
+	}{
+		{name: "negative", weight: -0.5, wantErr: "has weight -0.5"},
+		{name: "excessive", weight: MaxLoraWeight + 0.5, wantErr: "has weight 2.5"},
+		{name: "explicit zero", weight: 0, wantErr: "has weight 0, which disables it"},
+		{name: "maximum", weight: MaxLoraWeight, wantWeight: MaxLoraWeight},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			cfg.LoRAs[0].Weight = tt.weight
+			err := ValidateConfig(cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ValidateConfig() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateConfig() error = %v", err)
+			}
+			if cfg.LoRAs[0].Weight != tt.wantWeight {
+				t.Errorf("weight = %g, want %g", cfg.LoRAs[0].Weight, tt.wantWeight)
+			}
+		})
+	}
+}
+
+// TestLoadConfigExplicitZeroWeight checks that "weight = 0" in the file is rejected
+// instead of being treated like an omitted weight.
+func TestLoadConfigExplicitZeroWeight(t *testing.T) {
+	data, err := os.ReadFile("../../config.toml")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	const weightLine = "  weight = 0.8 "
+	if !strings.Contains(string(data), weightLine) {
+		t.Fatalf("config.toml has no %q line", weightLine)
+	}
+	tests := []struct {
+		name       string
+		line       string
+		wantErr    string
+		wantWeight float64
+	}{
+		{name: "explicit zero", line: "  weight = 0 ", wantErr: "has weight 0, which disables it"},
+		{name: "omitted", line: "  ", wantWeight: DefaultLoraWeight},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(strings.Replace(string(data), weightLine, tt.line, 1)), 0o600); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			err = ValidateConfig(cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ValidateConfig() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateConfig() error = %v", err)
+			}
+			if cfg.LoRAs[0].Weight != tt.wantWeight {
+				t.Errorf("weight = %g, want %g", cfg.LoRAs[0].Weight, tt.wantWeight)
+			}
+		})
+	}
+}
+
+func TestValidateConfigMaxGuidanceScale(t *testing.T) {
+	zero, negative := 0.0, -1.0
+	tests := []struct {
+		name    string
+		cap     *float64
+		wantErr string
+		want    float64
+	}{
+		{name: "unset uses the default", cap: nil, want: DefaultMaxGuidanceScale},
+		{name: "zero is a valid cap", cap: &zero, want: 0},
+		{name: "negative", cap: &negative, wantErr: "limits.maxGuidanceScale must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			cfg.Limits.MaxGuidanceScale = tt.cap
+			cfg.DefaultGenerationSettings.GuidanceScale = 0
+			err := ValidateConfig(cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ValidateConfig() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateConfig() error = %v", err)
+			}
+			if got := cfg.Limits.GuidanceScaleCap(); got != tt.want {
+				t.Errorf("GuidanceScaleCap() = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateConfigMaxGenerationsPerChat(t *testing.T) {
+	tests := []struct {
+		name    string
+		max     int
+		wantErr string
+		want    int
+	}{
+		{name: "unset uses the default", max: 0, want: 2},
+		{name: "-1 disables the limit", max: -1, want: -1},
+		{name: "explicit limit", max: 5, want: 5},
+		{name: "below -1", max: -2, wantErr: "maxGenerationsPerChat must be at least 1, or -1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			cfg.Limits.MaxGenerationsPerChat = tt.max
+			err := ValidateConfig(cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ValidateConfig() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateConfig() error = %v", err)
+			}
+			if cfg.Limits.MaxGenerationsPerChat != tt.want {
+				t.Errorf("maxGenerationsPerChat = %d, want %d", cfg.Limits.MaxGenerationsPerChat, tt.want)
+			}
+		})
+	}
+}
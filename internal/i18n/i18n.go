@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/fs"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/BurntSushi/toml"
@@ -22,14 +23,28 @@ type Manager struct {
 	bundle          *i18n.Bundle
 	defaultLanguage language.Tag
 	Logger          *zap.Logger
-	localizers      map[string]*i18n.Localizer // Cache localizers
-	availableLangs  map[string]string          // Map code (e.g., "en") to display name (e.g., "English")
+	localizers      map[string]*i18n.Localizer     // Cache localizers
+	availableLangs  map[string]string              // Map code (e.g., "en") to display name (e.g., "English")
+	messageIDs      map[string]map[string]struct{} // Map code to the message IDs its locale file defines
+	fallbacks       map[string][]string            // Map code to the languages tried after it, see fallbackChain
+	locales         fs.FS                          // Locale files such as "en.toml"
 }
 
 // NewManager 创建一个新的 i18n 管理器
 // langDir: 包含语言 JSON 文件的目录路径
 // defaultLang: 默认语言代码 (例如 "en")
-func NewManager(defaultLang string, logger *zap.Logger) (*Manager, error) {
+// fallbacks: 每种语言缺少翻译时依次尝试的语言 (例如 "zh-TW" -> ["zh"])，可为 nil
+func NewManager(defaultLang string, fallbacks map[string][]string, logger *zap.Logger) (*Manager, error) {
+	locales, err := fs.Sub(localeFS, "locales")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded locales directory: %w", err)
+	}
+	return newManager(defaultLang, fallbacks, locales, logger)
+}
+
+// newManager is NewManager with the locale files read from locales instead of the
+// embedded ones.
+func newManager(defaultLang string, fallbacks map[string][]string, locales fs.FS, logger *zap.Logger) (*Manager, error) {
 	defaultLanguageTag, err := language.Parse(defaultLang)
 	if err != nil {
 		logger.Error("Failed to parse default language tag", zap.String("tag", defaultLang), zap.Error(err))
@@ -45,6 +60,9 @@ func NewManager(defaultLang string, logger *zap.Logger) (*Manager, error) {
 		Logger:          logger.Named("i18n"),
 		localizers:      make(map[string]*i18n.Localizer),
 		availableLangs:  make(map[string]string),
+		messageIDs:      make(map[string]map[string]struct{}),
+		fallbacks:       fallbacks,
+		locales:         locales,
 	}
 
 	err = m.LoadTranslations()
@@ -81,7 +99,7 @@ func (m *Manager) LoadTranslations() error {
 	m.bundle.RegisterUnmarshalFunc("toml", toml.Unmarshal)
 
 	m.Logger.Info("Loading translations from embedded FS", zap.String("dir", "."))
-	files, err := fs.ReadDir(localeFS, "locales") // Read the root which corresponds to the embedded 'locales' dir
+	files, err := fs.ReadDir(m.locales, ".") // Read the root which corresponds to the embedded 'locales' dir
 	if err != nil {
 		m.Logger.Error("Failed to read embedded locales root directory", zap.Error(err))
 		return fmt.Errorf("failed to read embedded locales directory: %w", err)
@@ -94,6 +112,7 @@ func (m *Manager) LoadTranslations() error {
 
 	loadedCount := 0
 	m.availableLangs = make(map[string]string) // Initialize map
+	m.messageIDs = make(map[string]map[string]struct{})
 	for _, file := range files {
 		fileName := file.Name()
 		fmt.Println("fileName", fileName)
@@ -102,7 +121,7 @@ func (m *Manager) LoadTranslations() error {
 			filePathInFS := fileName
 			m.Logger.Debug("Attempting to load translation file", zap.String("file", filePathInFS))
 			// Load the message file using the registered unmarshaler
-			_, err := m.bundle.LoadMessageFileFS(localeFS, "locales/"+filePathInFS)
+			messageFile, err := m.bundle.LoadMessageFileFS(m.locales, filePathInFS)
 			if err != nil {
 				m.Logger.Warn("Failed to load translation file from embedded FS", zap.String("file", filePathInFS), zap.Error(err))
 				continue // Skip this file
@@ -131,6 +150,13 @@ func (m *Manager) LoadTranslations() error {
 				m.Logger.Warn("Failed to parse language code from filename", zap.String("file", fileName), zap.String("extractedCode", langCode), zap.Error(parseErr))
 			}
 			m.availableLangs[langCode] = langDisplayName // Store "en" -> "en"
+
+			// Record which message IDs this language defines for coverage checks
+			ids := make(map[string]struct{}, len(messageFile.Messages))
+			for _, msg := range messageFile.Messages {
+				ids[msg.ID] = struct{}{}
+			}
+			m.messageIDs[langCode] = ids
 			m.Logger.Debug("Registered available language", zap.String("code", langCode), zap.String("name", langDisplayName))
 
 		} else if !file.IsDir() {
@@ -158,14 +184,28 @@ func (m *Manager) T(lang *string, key string, args ...interface{}) string {
 		langCode = *lang
 	}
 
-	localizer, ok := m.localizers[langCode]
-	if !ok {
-		m.Logger.Warn("No localizer found for language, using default", zap.String("requested_lang", langCode), zap.String("default_lang", m.defaultLanguage.String()))
-		localizer = m.localizers[m.defaultLanguage.String()]
-		if localizer == nil { // Should not happen if init is correct
-			m.Logger.Error("Default localizer is nil! Returning key.")
-			return key // Absolute fallback
+	// Use the first language of the chain that defines key, else the first one available
+	var localizer *i18n.Localizer
+	requestedLang := langCode
+	for _, code := range m.fallbackChain(requestedLang) {
+		l, ok := m.localizers[code]
+		if !ok {
+			continue
+		}
+		if localizer == nil {
+			localizer, langCode = l, code
 		}
+		if _, defined := m.messageIDs[code][key]; defined {
+			localizer, langCode = l, code
+			break
+		}
+	}
+	if localizer == nil { // Should not happen if init is correct
+		m.Logger.Error("Default localizer is nil! Returning key.")
+		return key // Absolute fallback
+	}
+	if _, ok := m.localizers[requestedLang]; !ok {
+		m.Logger.Debug("No localizer found for language, using fallback", zap.String("requested_lang", requestedLang), zap.String("used_lang", langCode))
 	}
 
 	localizeConfig := &i18n.LocalizeConfig{
@@ -233,6 +273,21 @@ func (m *Manager) T(lang *string, key string, args ...interface{}) string {
 	return localized
 }
 
+// fallbackChain returns the languages T tries for lang, in order: lang itself, then its
+// configured fallbacks or, without any, its parent tags (e.g. "zh-Hant-TW" -> "zh-Hant" ->
+// "zh"), then the default language.
+func (m *Manager) fallbackChain(lang string) []string {
+	chain := []string{lang}
+	if configured, ok := m.fallbacks[lang]; ok {
+		chain = append(chain, configured...)
+	} else {
+		for i := strings.LastIndex(lang, "-"); i > 0; i = strings.LastIndex(lang[:i], "-") {
+			chain = append(chain, lang[:i])
+		}
+	}
+	return append(chain, m.defaultLanguage.String())
+}
+
 // GetAvailableLanguages returns a map of language codes to their display names.
 func (m *Manager) GetAvailableLanguages() map[string]string {
 	// Return a copy to prevent external modification
@@ -253,3 +308,50 @@ func (m *Manager) GetLanguageName(code string) (string, bool) {
 func (m *Manager) GetDefaultLanguageTag() language.Tag {
 	return m.defaultLanguage
 }
+
+// MissingKeys returns the message IDs defined for the default language but not for lang, sorted.
+func (m *Manager) MissingKeys(lang string) []string {
+	defaultIDs := m.messageIDs[m.defaultLanguage.String()]
+	langIDs := m.messageIDs[lang]
+	missing := []string{}
+	for id := range defaultIDs {
+		if _, ok := langIDs[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// Coverage returns the fraction (0-1) of the default language's message IDs that lang defines.
+func (m *Manager) Coverage(lang string) float64 {
+	total := len(m.messageIDs[m.defaultLanguage.String()])
+	if total == 0 {
+		return 1
+	}
+	return float64(total-len(m.MissingKeys(lang))) / float64(total)
+}
+
+// LanguageCoverage describes how complete one language is compared to the default language.
+type LanguageCoverage struct {
+	Code    string
+	Defined int      // Message IDs defined in the language's locale file
+	Total   int      // Message IDs defined for the default language
+	Missing []string // Default language IDs the language lacks, sorted
+}
+
+// CoverageReport returns the coverage of every available language, sorted by language code.
+func (m *Manager) CoverageReport() []LanguageCoverage {
+	total := len(m.messageIDs[m.defaultLanguage.String()])
+	report := make([]LanguageCoverage, 0, len(m.availableLangs))
+	for code := range m.availableLangs {
+		report = append(report, LanguageCoverage{
+			Code:    code,
+			Defined: len(m.messageIDs[code]),
+			Total:   total,
+			Missing: m.MissingKeys(code),
+		})
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Code < report[j].Code })
+	return report
+}
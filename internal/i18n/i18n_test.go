@@ -0,0 +1,47 @@
+package i18n
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"go.uber.org/zap"
+)
+
+func TestFallbackChain(t *testing.T) {
+	locales := fstest.MapFS{
+		"en.toml":    {Data: []byte("greeting = \"Hello\"\nfarewell = \"Goodbye\"\nonly_en = \"English only\"\n")},
+		"zh.toml":    {Data: []byte("greeting = \"你好\"\nfarewell = \"再见\"\n")},
+		"zh-TW.toml": {Data: []byte("greeting = \"妳好\"\n")},
+	}
+	m, err := newManager("en", nil, locales, zap.NewNop())
+	if err != nil {
+		t.Fatalf("newManager: %v", err)
+	}
+
+	lang := "zh-TW"
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"greeting", "妳好"},          // Defined by the regional locale
+		{"farewell", "再见"},          // Missing in zh-TW, defined by its base language
+		{"only_en", "English only"}, // Missing in both, from the default language
+	}
+	for _, tt := range tests {
+		if got := m.T(&lang, tt.key); got != tt.want {
+			t.Errorf("T(%q, %q) = %q, want %q", lang, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestEmbeddedLocalesLoad(t *testing.T) {
+	m, err := NewManager("en", nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	for _, code := range []string{"en", "zh", "ja"} {
+		if _, ok := m.GetLanguageName(code); !ok {
+			t.Errorf("embedded locale %q was not loaded", code)
+		}
+	}
+}
@@ -19,15 +19,19 @@ type SQLBalanceManager struct {
 	db      *sql.DB    // Standard sql.DB connection pool
 	initial float64    // Initial balance
 	cost    float64    // Cost per generation
+	reserve float64    // Part of every balance that can't be spent
+	low     float64    // Balances below this get one warning, 0 = no warnings
 	mu      sync.Mutex // Mutex for write operations (transactions handle atomicity)
 }
 
 // NewSQLBalanceManager creates a new SQLBalanceManager
-func NewSQLBalanceManager(db *sql.DB, initialBalance, costPerGeneration float64) *SQLBalanceManager {
+func NewSQLBalanceManager(db *sql.DB, initialBalance, costPerGeneration, minReserve, lowThreshold float64) *SQLBalanceManager {
 	return &SQLBalanceManager{
 		db:      db,
 		initial: initialBalance,
 		cost:    costPerGeneration,
+		reserve: minReserve,
+		low:     lowThreshold,
 	}
 }
 
@@ -36,27 +40,46 @@ func (bm *SQLBalanceManager) GetCost() float64 {
 	return bm.cost
 }
 
+// GetReserve returns the part of every balance that generations can't spend.
+func (bm *SQLBalanceManager) GetReserve() float64 {
+	return bm.reserve
+}
+
+// Spendable returns how much of balance generations may spend.
+func (bm *SQLBalanceManager) Spendable(balance float64) float64 {
+	return balance - bm.reserve
+}
+
 // GetBalance retrieves the balance for a user. Returns initial balance if user not found.
+// Database errors are logged and also answered with the initial balance, so only use it
+// for display; gate spending with GetBalanceWithError.
 func (bm *SQLBalanceManager) GetBalance(userID int64) float64 {
+	balance, err := bm.GetBalanceWithError(userID)
+	if err != nil {
+		zap.L().Error("Failed to query balance", zap.Int64("user_id", userID), zap.Error(err))
+		// Return initial balance on error to avoid blocking display
+		return bm.initial
+	}
+	return balance
+}
+
+// GetBalanceWithError retrieves the balance for a user, returning the initial balance if
+// the user has no record yet. Unlike GetBalance, database errors are returned.
+func (bm *SQLBalanceManager) GetBalanceWithError(userID int64) (float64, error) {
 	var balance float64
 	query := `SELECT balance FROM user_balances WHERE user_id = ?`
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) // Add timeout
 	defer cancel()
 
 	err := bm.db.QueryRowContext(ctx, query, userID).Scan(&balance)
-
-	if err == nil {
-		// User found
-		return balance
-	} else if errors.Is(err, sql.ErrNoRows) {
+	if errors.Is(err, sql.ErrNoRows) {
 		// User not found, return initial balance
-		return bm.initial
-	} else {
-		// Other database error
-		zap.L().Error("Failed to query balance", zap.Int64("user_id", userID), zap.Error(err))
-		// Return initial balance on error to avoid blocking usage
-		return bm.initial
+		return bm.initial, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query balance: %w", err)
 	}
+	return balance, nil
 }
 
 // CheckAndDeduct checks if balance is sufficient and deducts the cost atomically.
@@ -98,8 +121,11 @@ func (bm *SQLBalanceManager) CheckAndDeduct(userID int64) (bool, error) {
 	}
 	// If err is sql.ErrNoRows, balanceToUse remains bm.initial
 
-	// 2. Check if sufficient balance
-	if balanceToUse < bm.cost {
+	// 2. Check if sufficient balance, keeping the reserve
+	if balanceToUse < bm.cost+bm.reserve {
+		if bm.reserve > 0 {
+			return false, fmt.Errorf("insufficient balance (%.2f, %.2f of it reserved), need %.2f", balanceToUse, bm.reserve, bm.cost)
+		}
 		return false, fmt.Errorf("insufficient balance (%.2f), need %.2f", balanceToUse, bm.cost)
 	}
 
@@ -164,14 +190,16 @@ func (bm *SQLBalanceManager) AddBalance(userID int64, amount float64) error {
 	newBalance := balanceToUse + amount
 
 	// 3. Upsert the balance
+	// A balance raised back to the low threshold gets a new warning when it drops again
 	upsertSQL := `
 		INSERT INTO user_balances (user_id, balance, created_at, updated_at)
 		VALUES (?, ?, ?, ?)
 		ON CONFLICT(user_id) DO UPDATE SET
 			balance = excluded.balance,
+			low_balance_notified = CASE WHEN excluded.balance >= ? THEN 0 ELSE low_balance_notified END,
 			updated_at = excluded.updated_at;`
 	now := time.Now()
-	_, err = tx.ExecContext(ctx, upsertSQL, userID, newBalance, now, now)
+	_, err = tx.ExecContext(ctx, upsertSQL, userID, newBalance, now, now, bm.low)
 	if err != nil {
 		return fmt.Errorf("failed to upsert user balance on add: %w", err)
 	}
@@ -203,9 +231,10 @@ func (bm *SQLBalanceManager) SetBalance(userID int64, balance float64) error {
 		VALUES (?, ?, ?, ?)
 		ON CONFLICT(user_id) DO UPDATE SET
 			balance = excluded.balance,
+			low_balance_notified = CASE WHEN excluded.balance >= ? THEN 0 ELSE low_balance_notified END,
 			updated_at = excluded.updated_at;`
 	now := time.Now()
-	_, err := bm.db.ExecContext(ctx, upsertSQL, userID, balance, now, now)
+	_, err := bm.db.ExecContext(ctx, upsertSQL, userID, balance, now, now, bm.low)
 	if err != nil {
 		return fmt.Errorf("failed to set user balance: %w", err)
 	}
@@ -214,6 +243,36 @@ func (bm *SQLBalanceManager) SetBalance(userID int64, balance float64) error {
 	return nil
 }
 
+// MarkLowBalance reports whether userID's balance is below the low threshold and the user
+// hasn't been warned since it last was at or above it, and returns the balance. It marks
+// the user as warned, so concurrent deductions warn only once; AddBalance and SetBalance
+// clear the mark when they raise the balance to the threshold again.
+func (bm *SQLBalanceManager) MarkLowBalance(userID int64) (float64, bool, error) {
+	if bm.low <= 0 {
+		return 0, false, nil
+	}
+
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var balance float64
+	updateSQL := `
+		UPDATE user_balances SET low_balance_notified = 1
+		WHERE user_id = ? AND balance < ? AND low_balance_notified = 0
+		RETURNING balance;`
+	err := bm.db.QueryRowContext(ctx, updateSQL, userID, bm.low).Scan(&balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil // Not low, already warned, or never charged
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("database error marking low balance: %w", err)
+	}
+	return balance, true, nil
+}
+
 // UserBalance represents a user's balance information
 type UserBalanceInfo struct {
 	UserID    int64
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrentBalanceUpdates hammers one user's balance from two pools on the same file,
+// as two bot processes would. Every deduction and top-up must land and none may fail with
+// "database is locked".
+func TestConcurrentBalanceUpdates(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "bot.db")
+	opts := DBOptions{BusyTimeout: 10 * time.Second, MaxOpenConns: 8}
+	var managers []*SQLBalanceManager
+	for range 2 {
+		db, err := InitDB(dbPath, opts)
+		if err != nil {
+			t.Fatalf("InitDB: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		managers = append(managers, NewSQLBalanceManager(db, 1000, 1, 0, 0))
+	}
+
+	const (
+		userID     = 42
+		workers    = 20
+		iterations = 10
+		topUp      = 2.0
+	)
+	var wg sync.WaitGroup
+	errs := make(chan error, 3*workers*iterations)
+	for i := range workers {
+		bm := managers[i%len(managers)]
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for range iterations {
+				if _, err := bm.CheckAndDeduct(userID); err != nil {
+					errs <- err
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range iterations {
+				if err := bm.AddBalance(userID, topUp); err != nil {
+					errs <- err
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range iterations {
+				if _, err := bm.GetBalanceWithError(userID); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent balance update failed: %v", err)
+	}
+
+	want := 1000 - workers*iterations*1.0 + workers*iterations*topUp
+	got, err := managers[0].GetBalanceWithError(userID)
+	if err != nil {
+		t.Fatalf("GetBalanceWithError: %v", err)
+	}
+	if got != want {
+		t.Errorf("final balance = %v, want %v", got, want)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time" // Keep for potential future use or logging
 
+	falapi "github.com/nerdneilsfield/telegram-fal-bot/pkg/falapi"
 	"go.uber.org/zap"
 	_ "modernc.org/sqlite" // Import the pure Go SQLite driver
 )
@@ -31,26 +32,175 @@ const (
 		updated_at DATETIME NOT NULL
 	);`
 
+	createGenerationHistoryTableSQL = `
+	CREATE TABLE IF NOT EXISTS generation_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		prompt TEXT NOT NULL,
+		standard_lora TEXT NOT NULL,
+		base_loras TEXT NOT NULL DEFAULT '[]',
+		image_size TEXT NOT NULL,
+		num_inference_steps INTEGER NOT NULL,
+		guidance_scale REAL NOT NULL,
+		num_images INTEGER NOT NULL,
+		seed INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL
+	);`
+
+	createRecentPromptsTableSQL = `
+	CREATE TABLE IF NOT EXISTS recent_prompts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		prompt TEXT NOT NULL,
+		used_at DATETIME NOT NULL,
+		UNIQUE (user_id, prompt)
+	);`
+
+	createFalBalanceSnapshotsTableSQL = `
+	CREATE TABLE IF NOT EXISTS fal_balance_snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		balance REAL NOT NULL,
+		recorded_at DATETIME NOT NULL
+	);`
+	createFalBalanceSnapshotsIndexSQL = `CREATE INDEX IF NOT EXISTS idx_fal_balance_snapshots_recorded_at ON fal_balance_snapshots (recorded_at);`
+
+	// items holds a JSON array of ModerationItem; files are kept as Telegram file IDs
+	createPendingModerationTableSQL = `
+	CREATE TABLE IF NOT EXISTS pending_moderation (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		chat_id INTEGER NOT NULL,
+		status_message_id INTEGER NOT NULL DEFAULT 0,
+		caption TEXT NOT NULL DEFAULT '',
+		items TEXT NOT NULL DEFAULT '[]',
+		status TEXT NOT NULL DEFAULT 'pending',
+		moderator_id INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		decided_at DATETIME
+	);`
+
+	// loras holds a JSON array of FailedRequestLora; seed is NULL when fal picked one
+	createFailedRequestsTableSQL = `
+	CREATE TABLE IF NOT EXISTS failed_requests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		request_id TEXT NOT NULL DEFAULT '',
+		model TEXT NOT NULL,
+		prompt TEXT NOT NULL,
+		lora_names TEXT NOT NULL DEFAULT '[]',
+		loras TEXT NOT NULL DEFAULT '[]',
+		image_size TEXT NOT NULL,
+		num_inference_steps INTEGER NOT NULL,
+		guidance_scale REAL NOT NULL,
+		num_images INTEGER NOT NULL,
+		seed INTEGER,
+		safety_checker INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);`
+
+	// Bot-wide switches set at runtime, e.g. maintenance mode
+	createBotSettingsTableSQL = `
+	CREATE TABLE IF NOT EXISTS bot_settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at DATETIME NOT NULL
+	);`
+
 	// Add indexes for potentially frequent lookups
 	createUserIDIndexBalanceSQL = `CREATE INDEX IF NOT EXISTS idx_user_balances_user_id ON user_balances (user_id);`
 	createUserIDIndexConfigSQL  = `CREATE INDEX IF NOT EXISTS idx_user_generation_configs_user_id ON user_generation_configs (user_id);`
+	createUserIDIndexHistorySQL = `CREATE INDEX IF NOT EXISTS idx_generation_history_user_id ON generation_history (user_id, created_at);`
 
 	// Add migration step for the language column
 	addLanguageColumnSQL = `
 	ALTER TABLE user_generation_configs
 	ADD COLUMN language TEXT NOT NULL DEFAULT '';`
+
+	// Nullable: NULL means the user follows the bot's default
+	addAutoDeleteColumnSQL = `
+	ALTER TABLE user_generation_configs
+	ADD COLUMN auto_delete INTEGER;`
+
+	// Empty means the user follows the bot's default delivery mode
+	addDeliveryModeColumnSQL = `
+	ALTER TABLE user_generation_configs
+	ADD COLUMN delivery_mode TEXT NOT NULL DEFAULT '';`
+
+	// Nullable: NULL means random seeds
+	addLockedSeedColumnSQL = `
+	ALTER TABLE user_generation_configs
+	ADD COLUMN locked_seed INTEGER;`
+
+	// Nullable: NULL means the prompt is shown
+	addShowPromptColumnSQL = `
+	ALTER TABLE user_generation_configs
+	ADD COLUMN show_prompt INTEGER;`
+
+	// Nullable: NULL means no quick-repeat keyboard
+	addQuickRepeatColumnSQL = `
+	ALTER TABLE user_generation_configs
+	ADD COLUMN quick_repeat INTEGER;`
+
+	// Sizes fal doesn't accept, e.g. from before the size list matched fal's, would fail
+	// every generation with a 422; they are reset to the column default
+	normalizeImageSizesSQL = `
+	UPDATE user_generation_configs
+	SET image_size = 'square_hd'
+	WHERE image_size NOT IN (%s);`
+
+	// Nullable: NULL means generic error messages
+	addVerboseErrorsColumnSQL = `
+	ALTER TABLE user_generation_configs
+	ADD COLUMN verbose_errors INTEGER;`
+
+	// Nullable JSON array of LoRA names; NULL means nothing is pre-selected
+	addDefaultLorasColumnSQL = `
+	ALTER TABLE user_generation_configs
+	ADD COLUMN default_loras TEXT;`
+
+	// 1 once the user was warned that their balance fell below balance.lowThreshold,
+	// until it is raised above it again
+	addLowBalanceNotifiedColumnSQL = `
+	ALTER TABLE user_balances
+	ADD COLUMN low_balance_notified INTEGER NOT NULL DEFAULT 0;`
+
+	// Nullable: entries recorded before the payload was stored have none
+	addHistoryPayloadColumnSQL = `
+	ALTER TABLE generation_history
+	ADD COLUMN payload TEXT;`
 )
 
+// DBOptions tunes SQLite for concurrent access. Zero values use the defaults.
+type DBOptions struct {
+	BusyTimeout  time.Duration // How long a connection waits for a lock before failing, defaults to 5s
+	MaxOpenConns int           // 1 serializes all access through one connection, defaults to 25
+	MaxIdleConns int           // Defaults to MaxOpenConns
+}
+
 // InitDB initializes the database connection using database/sql and runs migrations.
-func InitDB(dbPath string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite", dbPath+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+// Transactions start with BEGIN IMMEDIATE, so concurrent writers wait for the busy timeout
+// instead of failing with "database is locked" when a read turns into a write.
+func InitDB(dbPath string, opts DBOptions) (*sql.DB, error) {
+	if opts.BusyTimeout <= 0 {
+		opts.BusyTimeout = 5 * time.Second
+	}
+	if opts.MaxOpenConns <= 0 {
+		opts.MaxOpenConns = 25
+	}
+	if opts.MaxIdleConns <= 0 {
+		opts.MaxIdleConns = opts.MaxOpenConns
+	}
+
+	dsn := fmt.Sprintf("%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(%d)&_txlock=immediate", dbPath, opts.BusyTimeout.Milliseconds())
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open sqlite db: %w", err)
 	}
 
 	// Configure connection pool (optional but recommended)
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
 	// Ping database to ensure connection is valid
@@ -70,6 +220,24 @@ func InitDB(dbPath string) (*sql.DB, error) {
 	return db, nil
 }
 
+// RunWALCheckpoints checkpoints the WAL file into the database every interval with mode
+// (PASSIVE, FULL, RESTART or TRUNCATE) until the process exits. SQLite checkpoints on its own
+// once the WAL reaches 1000 pages, but a PASSIVE checkpoint never waits for readers, so under
+// constant load the WAL can keep growing; FULL and stronger modes wait up to the busy timeout.
+func RunWALCheckpoints(db *sql.DB, interval time.Duration, mode string) {
+	stmt := fmt.Sprintf("PRAGMA wal_checkpoint(%s)", mode)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		var busy, logPages, checkpointed int
+		if err := db.QueryRow(stmt).Scan(&busy, &logPages, &checkpointed); err != nil {
+			zap.L().Warn("WAL checkpoint failed", zap.String("mode", mode), zap.Error(err))
+			continue
+		}
+		zap.L().Debug("WAL checkpoint done", zap.String("mode", mode), zap.Bool("busy", busy != 0), zap.Int("wal_pages", logPages), zap.Int("checkpointed_pages", checkpointed))
+	}
+}
+
 // runMigrations executes the necessary SQL statements to create/update tables.
 func runMigrations(db *sql.DB) error {
 	// Statements to ensure tables and indexes exist
@@ -78,6 +246,14 @@ func runMigrations(db *sql.DB) error {
 		createUserGenerationConfigTableSQL,
 		createUserIDIndexBalanceSQL,
 		createUserIDIndexConfigSQL,
+		createGenerationHistoryTableSQL,
+		createUserIDIndexHistorySQL,
+		createRecentPromptsTableSQL,
+		createFalBalanceSnapshotsTableSQL,
+		createFalBalanceSnapshotsIndexSQL,
+		createPendingModerationTableSQL,
+		createFailedRequestsTableSQL,
+		createBotSettingsTableSQL,
 	}
 
 	for _, stmt := range initialStatements {
@@ -104,6 +280,106 @@ func runMigrations(db *sql.DB) error {
 		zap.L().Info("'language' column added successfully or already existed.")
 	}
 
+	if _, err := db.Exec(addAutoDeleteColumnSQL); err != nil {
+		if !isDuplicateColumnError(err) {
+			zap.L().Error("Failed to add 'auto_delete' column (unexpected error)", zap.Error(err))
+		} else {
+			zap.L().Debug("'auto_delete' column already exists.")
+		}
+	} else {
+		zap.L().Info("'auto_delete' column added.")
+	}
+
+	if _, err := db.Exec(addDeliveryModeColumnSQL); err != nil {
+		if !isDuplicateColumnError(err) {
+			zap.L().Error("Failed to add 'delivery_mode' column (unexpected error)", zap.Error(err))
+		} else {
+			zap.L().Debug("'delivery_mode' column already exists.")
+		}
+	} else {
+		zap.L().Info("'delivery_mode' column added.")
+	}
+
+	if _, err := db.Exec(addLockedSeedColumnSQL); err != nil {
+		if !isDuplicateColumnError(err) {
+			zap.L().Error("Failed to add 'locked_seed' column (unexpected error)", zap.Error(err))
+		} else {
+			zap.L().Debug("'locked_seed' column already exists.")
+		}
+	} else {
+		zap.L().Info("'locked_seed' column added.")
+	}
+
+	if _, err := db.Exec(addShowPromptColumnSQL); err != nil {
+		if !isDuplicateColumnError(err) {
+			zap.L().Error("Failed to add 'show_prompt' column (unexpected error)", zap.Error(err))
+		} else {
+			zap.L().Debug("'show_prompt' column already exists.")
+		}
+	} else {
+		zap.L().Info("'show_prompt' column added.")
+	}
+
+	if _, err := db.Exec(addQuickRepeatColumnSQL); err != nil {
+		if !isDuplicateColumnError(err) {
+			zap.L().Error("Failed to add 'quick_repeat' column (unexpected error)", zap.Error(err))
+		} else {
+			zap.L().Debug("'quick_repeat' column already exists.")
+		}
+	} else {
+		zap.L().Info("'quick_repeat' column added.")
+	}
+
+	if _, err := db.Exec(addVerboseErrorsColumnSQL); err != nil {
+		if !isDuplicateColumnError(err) {
+			zap.L().Error("Failed to add 'verbose_errors' column (unexpected error)", zap.Error(err))
+		} else {
+			zap.L().Debug("'verbose_errors' column already exists.")
+		}
+	} else {
+		zap.L().Info("'verbose_errors' column added.")
+	}
+
+	if _, err := db.Exec(addDefaultLorasColumnSQL); err != nil {
+		if !isDuplicateColumnError(err) {
+			zap.L().Error("Failed to add 'default_loras' column (unexpected error)", zap.Error(err))
+		} else {
+			zap.L().Debug("'default_loras' column already exists.")
+		}
+	} else {
+		zap.L().Info("'default_loras' column added.")
+	}
+
+	if _, err := db.Exec(addLowBalanceNotifiedColumnSQL); err != nil {
+		if !isDuplicateColumnError(err) {
+			zap.L().Error("Failed to add 'low_balance_notified' column (unexpected error)", zap.Error(err))
+		} else {
+			zap.L().Debug("'low_balance_notified' column already exists.")
+		}
+	} else {
+		zap.L().Info("'low_balance_notified' column added.")
+	}
+
+	if _, err := db.Exec(addHistoryPayloadColumnSQL); err != nil {
+		if !isDuplicateColumnError(err) {
+			zap.L().Error("Failed to add 'payload' column (unexpected error)", zap.Error(err))
+		} else {
+			zap.L().Debug("'payload' column already exists.")
+		}
+	} else {
+		zap.L().Info("'payload' column added.")
+	}
+
+	quoted := make([]string, len(falapi.ImageSizePresetNames))
+	for i, name := range falapi.ImageSizePresetNames {
+		quoted[i] = "'" + name + "'"
+	}
+	if res, err := db.Exec(fmt.Sprintf(normalizeImageSizesSQL, strings.Join(quoted, ", "))); err != nil {
+		zap.L().Error("Failed to normalize image sizes", zap.Error(err))
+	} else if n, _ := res.RowsAffected(); n > 0 {
+		zap.L().Info("Reset unsupported image sizes to square_hd", zap.Int64("rows", n))
+	}
+
 	return nil
 }
 
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const failedRequestColumns = `id, user_id, request_id, model, prompt, lora_names, loras, image_size, num_inference_steps, guidance_scale, num_images, seed, safety_checker, error, created_at`
+
+// AddFailedRequest stores a failed request and removes all but the newest keep entries, so
+// the table stays small. It returns the new entry ID.
+func AddFailedRequest(db *sql.DB, r FailedRequest, keep int) (int64, error) {
+	loraNames, err := json.Marshal(r.LoraNames)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode lora names: %w", err)
+	}
+	loras, err := json.Marshal(r.Loras)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode loras: %w", err)
+	}
+	var seed sql.NullInt64
+	if r.Seed != nil {
+		seed = sql.NullInt64{Int64: int64(*r.Seed), Valid: true}
+	}
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	insertSQL := `
+		INSERT INTO failed_requests (user_id, request_id, model, prompt, lora_names, loras, image_size, num_inference_steps, guidance_scale, num_images, seed, safety_checker, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	result, err := db.ExecContext(ctx, insertSQL,
+		r.UserID,
+		r.RequestID,
+		r.Model,
+		r.Prompt,
+		string(loraNames),
+		string(loras),
+		r.ImageSize,
+		r.NumInferenceSteps,
+		r.GuidanceScale,
+		r.NumImages,
+		seed,
+		r.SafetyChecker,
+		r.Error,
+		r.CreatedAt,
+	)
+	if err != nil {
+		zap.L().Error("Failed to add failed request", zap.Error(err), zap.Int64("userID", r.UserID))
+		return 0, fmt.Errorf("database error adding failed request: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get failed request id: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `DELETE FROM failed_requests WHERE id <= ?`, id-int64(keep)); err != nil {
+		zap.L().Error("Failed to prune failed requests", zap.Error(err))
+		return id, fmt.Errorf("database error pruning failed requests: %w", err)
+	}
+	return id, nil
+}
+
+// GetFailedRequest loads a failed request by ID. Returns sql.ErrNoRows if it doesn't exist.
+func GetFailedRequest(db *sql.DB, id int64) (*FailedRequest, error) {
+	query := `SELECT ` + failedRequestColumns + ` FROM failed_requests WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r, err := scanFailedRequest(db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		zap.L().Error("Failed to get failed request", zap.Error(err), zap.Int64("id", id))
+		return nil, fmt.Errorf("database error getting failed request: %w", err)
+	}
+	return r, nil
+}
+
+// GetLastFailedRequestForUser loads the newest failed request of userID. Returns
+// sql.ErrNoRows if the user has none.
+func GetLastFailedRequestForUser(db *sql.DB, userID int64) (*FailedRequest, error) {
+	query := `SELECT ` + failedRequestColumns + ` FROM failed_requests WHERE user_id = ? ORDER BY id DESC LIMIT 1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r, err := scanFailedRequest(db.QueryRowContext(ctx, query, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		zap.L().Error("Failed to get last failed request of user", zap.Error(err), zap.Int64("user_id", userID))
+		return nil, fmt.Errorf("database error getting last failed request: %w", err)
+	}
+	return r, nil
+}
+
+// ListFailedRequests returns the most recent failed requests of all users, newest first.
+func ListFailedRequests(db *sql.DB, limit int) ([]FailedRequest, error) {
+	query := `SELECT ` + failedRequestColumns + ` FROM failed_requests ORDER BY id DESC LIMIT ?`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, limit)
+	if err != nil {
+		zap.L().Error("Failed to list failed requests", zap.Error(err))
+		return nil, fmt.Errorf("database error listing failed requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []FailedRequest
+	for rows.Next() {
+		r, err := scanFailedRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan failed request row: %w", err)
+		}
+		requests = append(requests, *r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating failed request rows: %w", err)
+	}
+	return requests, nil
+}
+
+func scanFailedRequest(row rowScanner) (*FailedRequest, error) {
+	var r FailedRequest
+	var loraNames, loras string
+	var seed sql.NullInt64
+	if err := row.Scan(
+		&r.ID,
+		&r.UserID,
+		&r.RequestID,
+		&r.Model,
+		&r.Prompt,
+		&loraNames,
+		&loras,
+		&r.ImageSize,
+		&r.NumInferenceSteps,
+		&r.GuidanceScale,
+		&r.NumImages,
+		&seed,
+		&r.SafetyChecker,
+		&r.Error,
+		&r.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if seed.Valid {
+		s := uint64(seed.Int64)
+		r.Seed = &s
+	}
+	if err := json.Unmarshal([]byte(loraNames), &r.LoraNames); err != nil {
+		return nil, fmt.Errorf("failed to decode lora names: %w", err)
+	}
+	if err := json.Unmarshal([]byte(loras), &r.Loras); err != nil {
+		return nil, fmt.Errorf("failed to decode loras: %w", err)
+	}
+	return &r, nil
+}
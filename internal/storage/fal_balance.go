@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AddFalBalanceSnapshot records the fal account balance and removes snapshots older than
+// keep, so the table stays small.
+func AddFalBalanceSnapshot(db *sql.DB, balance float64, recordedAt time.Time, keep time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, `INSERT INTO fal_balance_snapshots (balance, recorded_at) VALUES (?, ?)`, balance, recordedAt); err != nil {
+		zap.L().Error("Failed to add fal balance snapshot", zap.Error(err))
+		return fmt.Errorf("database error adding fal balance snapshot: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `DELETE FROM fal_balance_snapshots WHERE recorded_at < ?`, recordedAt.Add(-keep)); err != nil {
+		zap.L().Error("Failed to prune fal balance snapshots", zap.Error(err))
+		return fmt.Errorf("database error pruning fal balance snapshots: %w", err)
+	}
+	return nil
+}
+
+// GetOldestFalBalanceSnapshotSince returns the first snapshot recorded at or after since.
+// Returns sql.ErrNoRows if there is none.
+func GetOldestFalBalanceSnapshotSince(db *sql.DB, since time.Time) (*FalBalanceSnapshot, error) {
+	query := `SELECT id, balance, recorded_at FROM fal_balance_snapshots
+			  WHERE recorded_at >= ?
+			  ORDER BY recorded_at ASC, id ASC
+			  LIMIT 1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var snapshot FalBalanceSnapshot
+	err := db.QueryRowContext(ctx, query, since).Scan(&snapshot.ID, &snapshot.Balance, &snapshot.RecordedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		zap.L().Error("Failed to get fal balance snapshot", zap.Error(err))
+		return nil, fmt.Errorf("database error getting fal balance snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const generationHistoryColumns = `id, user_id, prompt, standard_lora, base_loras, image_size, num_inference_steps, guidance_scale, num_images, seed, payload, created_at`
+
+// AddGenerationHistory stores a successful generation and returns the new entry ID.
+func AddGenerationHistory(db *sql.DB, entry GenerationHistory) (int64, error) {
+	baseLoras, err := json.Marshal(entry.BaseLoras)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode base loras: %w", err)
+	}
+	if entry.BaseLoras == nil {
+		baseLoras = []byte("[]")
+	}
+	var payload sql.NullString
+	if len(entry.Payload) > 0 {
+		payload = sql.NullString{String: string(entry.Payload), Valid: true}
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	insertSQL := `
+		INSERT INTO generation_history (user_id, prompt, standard_lora, base_loras, image_size, num_inference_steps, guidance_scale, num_images, seed, payload, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, insertSQL,
+		entry.UserID,
+		entry.Prompt,
+		entry.StandardLora,
+		string(baseLoras),
+		entry.ImageSize,
+		entry.NumInferenceSteps,
+		entry.GuidanceScale,
+		entry.NumImages,
+		int64(entry.Seed), // SQLite integers are signed; seeds fit comfortably
+		payload,
+		entry.CreatedAt,
+	)
+	if err != nil {
+		zap.L().Error("Failed to add generation history", zap.Error(err), zap.Int64("userID", entry.UserID))
+		return 0, fmt.Errorf("database error adding history: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get history entry id: %w", err)
+	}
+	zap.L().Debug("Added generation history entry", zap.Int64("userID", entry.UserID), zap.Int64("historyID", id))
+	return id, nil
+}
+
+// GetGenerationHistory retrieves a single history entry by ID.
+// Returns sql.ErrNoRows if the entry does not exist. Callers must check ownership.
+func GetGenerationHistory(db *sql.DB, id int64) (*GenerationHistory, error) {
+	query := `SELECT ` + generationHistoryColumns + ` FROM generation_history WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	entry, err := scanGenerationHistory(db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		zap.L().Error("Failed to get generation history", zap.Error(err), zap.Int64("historyID", id))
+		return nil, fmt.Errorf("database error getting history: %w", err)
+	}
+	return entry, nil
+}
+
+// ListGenerationHistory returns the user's most recent history entries, newest first.
+func ListGenerationHistory(db *sql.DB, userID int64, limit int) ([]GenerationHistory, error) {
+	query := `SELECT ` + generationHistoryColumns + ` FROM generation_history
+			  WHERE user_id = ?
+			  ORDER BY created_at DESC, id DESC
+			  LIMIT ?`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		zap.L().Error("Failed to list generation history", zap.Error(err), zap.Int64("userID", userID))
+		return nil, fmt.Errorf("database error listing history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []GenerationHistory
+	for rows.Next() {
+		entry, err := scanGenerationHistory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan history row: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating history rows: %w", err)
+	}
+	return entries, nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanGenerationHistory(row rowScanner) (*GenerationHistory, error) {
+	var entry GenerationHistory
+	var baseLoras string
+	var seed int64
+	var payload sql.NullString
+	if err := row.Scan(
+		&entry.ID,
+		&entry.UserID,
+		&entry.Prompt,
+		&entry.StandardLora,
+		&baseLoras,
+		&entry.ImageSize,
+		&entry.NumInferenceSteps,
+		&entry.GuidanceScale,
+		&entry.NumImages,
+		&seed,
+		&payload,
+		&entry.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	entry.Seed = uint64(seed)
+	if payload.Valid {
+		entry.Payload = json.RawMessage(payload.String)
+	}
+	if err := json.Unmarshal([]byte(baseLoras), &entry.BaseLoras); err != nil {
+		zap.L().Warn("Failed to decode base loras of history entry", zap.Error(err), zap.Int64("historyID", entry.ID))
+		entry.BaseLoras = nil
+	}
+	return &entry, nil
+}
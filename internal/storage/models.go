@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -18,13 +19,106 @@ type UserBalance struct {
 // Fields are now non-pointers as the DB schema has defaults and NOT NULL constraints.
 // GORM tags are removed.
 type UserGenerationConfig struct {
-	UserID            int64   // Telegram User ID as primary key
-	ImageSize         string  `json:"image_size"`
-	NumInferenceSteps int     `json:"num_inference_steps"`
-	GuidanceScale     float64 `json:"guidance_scale"`
-	NumImages         int     `json:"num_images"`
-	Language          string  `json:"language"` // User's language preference
+	UserID            int64    // Telegram User ID as primary key
+	ImageSize         string   `json:"image_size"`
+	NumInferenceSteps int      `json:"num_inference_steps"`
+	GuidanceScale     float64  `json:"guidance_scale"`
+	NumImages         int      `json:"num_images"`
+	Language          string   `json:"language"`       // User's language preference
+	AutoDelete        *bool    `json:"auto_delete"`    // nil follows autoDelete.defaultEnabled from the bot config
+	DeliveryMode      string   `json:"delivery_mode"`  // Empty follows defaultGenerationSettings.deliveryMode
+	LockedSeed        *uint64  `json:"locked_seed"`    // nil means random seeds
+	ShowPrompt        *bool    `json:"show_prompt"`    // Echo the prompt in result captions; nil means on
+	QuickRepeat       *bool    `json:"quick_repeat"`   // Reply keyboard for repeating the last generation; nil means off
+	VerboseErrors     *bool    `json:"verbose_errors"` // Detailed error messages, if the operator allows them; nil means off
+	DefaultLoras      []string `json:"default_loras"`  // LoRA names pre-selected in the LoRA keyboard; nil means none
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
 	// DeletedAt         gorm.DeletedAt // Removed soft delete
 }
+
+// GenerationHistory records one successful LoRA combination of a generation so it can be re-run.
+// StandardLora and BaseLoras hold LoRA names, which survive restarts unlike generated IDs.
+type GenerationHistory struct {
+	ID                int64
+	UserID            int64
+	Prompt            string
+	StandardLora      string
+	BaseLoras         []string
+	ImageSize         string
+	NumInferenceSteps int
+	GuidanceScale     float64
+	NumImages         int
+	Seed              uint64
+	Payload           json.RawMessage // Request body sent to fal; nil for entries recorded before it was stored
+	CreatedAt         time.Time
+}
+
+// RecentPrompt is one entry of a user's rolling list of recently used prompts.
+type RecentPrompt struct {
+	ID     int64
+	UserID int64
+	Prompt string
+	UsedAt time.Time
+}
+
+// FalBalanceSnapshot is the fal account balance at one point in time.
+type FalBalanceSnapshot struct {
+	ID         int64
+	Balance    float64
+	RecordedAt time.Time
+}
+
+// Moderation statuses of a PendingModeration.
+const (
+	ModerationPending  = "pending"
+	ModerationApproved = "approved"
+	ModerationRejected = "rejected"
+)
+
+// ModerationItem is one held result file, stored as a Telegram file ID so it stays
+// available for as long as the moderation takes.
+type ModerationItem struct {
+	FileID string `json:"file_id"`
+	Kind   string `json:"kind"` // "photo", "video", "animation" or "document"
+	Label  string `json:"label,omitempty"`
+}
+
+// PendingModeration is a generation result held for admin approval before delivery.
+type PendingModeration struct {
+	ID              int64
+	UserID          int64
+	ChatID          int64
+	StatusMessageID int
+	Caption         string
+	Items           []ModerationItem
+	Status          string
+	ModeratorID     int64
+	CreatedAt       time.Time
+}
+
+// FailedRequest is a generation request that failed, stored exactly as it was submitted
+// so admins can replay it.
+type FailedRequest struct {
+	ID                int64
+	UserID            int64
+	RequestID         string // fal request ID; empty if the submission itself failed
+	Model             string
+	Prompt            string // Final prompt as sent to fal
+	LoraNames         []string
+	Loras             []FailedRequestLora
+	ImageSize         string
+	NumInferenceSteps int
+	GuidanceScale     float64
+	NumImages         int
+	Seed              *uint64 // nil if fal picked the seed
+	SafetyChecker     bool
+	Error             string
+	CreatedAt         time.Time
+}
+
+// FailedRequestLora is one LoRA of a FailedRequest as sent to fal.
+type FailedRequestLora struct {
+	Path  string  `json:"path"`
+	Scale float64 `json:"scale"`
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AddPendingModeration stores a held result and returns its ID.
+func AddPendingModeration(db *sql.DB, m PendingModeration) (int64, error) {
+	items, err := json.Marshal(m.Items)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode moderation items: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	insertSQL := `
+		INSERT INTO pending_moderation (user_id, chat_id, status_message_id, caption, items, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?);`
+	result, err := db.ExecContext(ctx, insertSQL, m.UserID, m.ChatID, m.StatusMessageID, m.Caption, string(items), ModerationPending, time.Now())
+	if err != nil {
+		zap.L().Error("Failed to add pending moderation", zap.Error(err), zap.Int64("userID", m.UserID))
+		return 0, fmt.Errorf("database error adding pending moderation: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// GetPendingModeration loads a held result by ID. Returns sql.ErrNoRows if it doesn't exist.
+func GetPendingModeration(db *sql.DB, id int64) (*PendingModeration, error) {
+	query := `SELECT id, user_id, chat_id, status_message_id, caption, items, status, moderator_id, created_at
+			  FROM pending_moderation WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var m PendingModeration
+	var items string
+	err := db.QueryRowContext(ctx, query, id).Scan(&m.ID, &m.UserID, &m.ChatID, &m.StatusMessageID, &m.Caption, &items, &m.Status, &m.ModeratorID, &m.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		zap.L().Error("Failed to get pending moderation", zap.Error(err), zap.Int64("id", id))
+		return nil, fmt.Errorf("database error getting pending moderation: %w", err)
+	}
+	if err := json.Unmarshal([]byte(items), &m.Items); err != nil {
+		return nil, fmt.Errorf("failed to decode moderation items: %w", err)
+	}
+	return &m, nil
+}
+
+// DecidePendingModeration moves a pending result to status (approved or rejected). It
+// returns false if the result was already decided, so two moderators can't both act on it.
+func DecidePendingModeration(db *sql.DB, id int64, status string, moderatorID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	updateSQL := `
+		UPDATE pending_moderation SET status = ?, moderator_id = ?, decided_at = ?
+		WHERE id = ? AND status = ?;`
+	result, err := db.ExecContext(ctx, updateSQL, status, moderatorID, time.Now(), id, ModerationPending)
+	if err != nil {
+		zap.L().Error("Failed to decide pending moderation", zap.Error(err), zap.Int64("id", id))
+		return false, fmt.Errorf("database error deciding pending moderation: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("database error deciding pending moderation: %w", err)
+	}
+	return affected == 1, nil
+}
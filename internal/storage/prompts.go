@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AddRecentPrompt records prompt as the user's most recently used prompt and keeps only
+// the newest keep entries. Using the same prompt again moves it to the top.
+func AddRecentPrompt(db *sql.DB, userID int64, prompt string, keep int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	upsertSQL := `
+		INSERT INTO recent_prompts (user_id, prompt, used_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(user_id, prompt) DO UPDATE SET used_at = excluded.used_at;`
+	if _, err := db.ExecContext(ctx, upsertSQL, userID, prompt, time.Now()); err != nil {
+		zap.L().Error("Failed to add recent prompt", zap.Error(err), zap.Int64("userID", userID))
+		return fmt.Errorf("database error adding recent prompt: %w", err)
+	}
+
+	trimSQL := `
+		DELETE FROM recent_prompts
+		WHERE user_id = ? AND id NOT IN (
+			SELECT id FROM recent_prompts WHERE user_id = ? ORDER BY used_at DESC, id DESC LIMIT ?
+		);`
+	if _, err := db.ExecContext(ctx, trimSQL, userID, userID, keep); err != nil {
+		zap.L().Error("Failed to trim recent prompts", zap.Error(err), zap.Int64("userID", userID))
+		return fmt.Errorf("database error trimming recent prompts: %w", err)
+	}
+	return nil
+}
+
+// ListRecentPrompts returns the user's recent prompts, most recent first.
+func ListRecentPrompts(db *sql.DB, userID int64, limit int) ([]RecentPrompt, error) {
+	query := `SELECT id, user_id, prompt, used_at FROM recent_prompts
+			  WHERE user_id = ?
+			  ORDER BY used_at DESC, id DESC
+			  LIMIT ?`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		zap.L().Error("Failed to list recent prompts", zap.Error(err), zap.Int64("userID", userID))
+		return nil, fmt.Errorf("database error listing recent prompts: %w", err)
+	}
+	defer rows.Close()
+
+	var prompts []RecentPrompt
+	for rows.Next() {
+		var p RecentPrompt
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Prompt, &p.UsedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan recent prompt row: %w", err)
+		}
+		prompts = append(prompts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating recent prompt rows: %w", err)
+	}
+	return prompts, nil
+}
+
+// GetRecentPrompt retrieves a single recent prompt by ID.
+// Returns sql.ErrNoRows if it does not exist. Callers must check ownership.
+func GetRecentPrompt(db *sql.DB, id int64) (*RecentPrompt, error) {
+	query := `SELECT id, user_id, prompt, used_at FROM recent_prompts WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var p RecentPrompt
+	if err := db.QueryRowContext(ctx, query, id).Scan(&p.ID, &p.UserID, &p.Prompt, &p.UsedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		zap.L().Error("Failed to get recent prompt", zap.Error(err), zap.Int64("promptID", id))
+		return nil, fmt.Errorf("database error getting recent prompt: %w", err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SettingMaintenance is the bot_settings key of maintenance mode, "on" or "off".
+const SettingMaintenance = "maintenance"
+
+// GetBotSetting returns the value of a bot-wide setting.
+// Returns sql.ErrNoRows if it was never set.
+func GetBotSetting(db *sql.DB, key string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var value string
+	err := db.QueryRowContext(ctx, `SELECT value FROM bot_settings WHERE key = ?`, key).Scan(&value)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", sql.ErrNoRows
+		}
+		zap.L().Error("Failed to get bot setting", zap.Error(err), zap.String("key", key))
+		return "", fmt.Errorf("database error getting bot setting: %w", err)
+	}
+	return value, nil
+}
+
+// SetBotSetting stores the value of a bot-wide setting.
+func SetBotSetting(db *sql.DB, key string, value string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	upsertSQL := `
+		INSERT INTO bot_settings (key, value, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at;`
+	if _, err := db.ExecContext(ctx, upsertSQL, key, value, time.Now()); err != nil {
+		zap.L().Error("Failed to set bot setting", zap.Error(err), zap.String("key", key))
+		return fmt.Errorf("database error setting bot setting: %w", err)
+	}
+	return nil
+}
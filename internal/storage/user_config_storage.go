@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -18,7 +19,7 @@ import (
 // Returns sql.ErrNoRows if the user has no config set.
 // Handles potential NULL values from the database for non-pointer struct fields.
 func GetUserGenerationConfig(db *sql.DB, userID int64) (*UserGenerationConfig, error) {
-	query := `SELECT image_size, num_inference_steps, guidance_scale, num_images, language, created_at, updated_at
+	query := `SELECT image_size, num_inference_steps, guidance_scale, num_images, language, auto_delete, delivery_mode, locked_seed, show_prompt, quick_repeat, verbose_errors, default_loras, created_at, updated_at
 			  FROM user_generation_configs
 			  WHERE user_id = ?`
 
@@ -31,6 +32,13 @@ func GetUserGenerationConfig(db *sql.DB, userID int64) (*UserGenerationConfig, e
 	var guidScale sql.NullFloat64
 	var numImages sql.NullInt64 // Changed to NullInt64
 	var language sql.NullString
+	var autoDelete sql.NullBool
+	var deliveryMode sql.NullString
+	var lockedSeed sql.NullInt64
+	var showPrompt sql.NullBool
+	var quickRepeat sql.NullBool
+	var verboseErrors sql.NullBool
+	var defaultLoras sql.NullString
 	var createdAt sql.NullTime // Use NullTime for potential NULL timestamps
 	var updatedAt sql.NullTime
 
@@ -40,6 +48,13 @@ func GetUserGenerationConfig(db *sql.DB, userID int64) (*UserGenerationConfig, e
 		&guidScale,
 		&numImages,
 		&language,
+		&autoDelete,
+		&deliveryMode,
+		&lockedSeed,
+		&showPrompt,
+		&quickRepeat,
+		&verboseErrors,
+		&defaultLoras,
 		&createdAt,
 		&updatedAt,
 	)
@@ -81,6 +96,35 @@ func GetUserGenerationConfig(db *sql.DB, userID int64) (*UserGenerationConfig, e
 	if language.Valid {
 		config.Language = language.String
 	}
+	if autoDelete.Valid {
+		enabled := autoDelete.Bool
+		config.AutoDelete = &enabled
+	}
+	if deliveryMode.Valid {
+		config.DeliveryMode = deliveryMode.String
+	}
+	if lockedSeed.Valid {
+		seed := uint64(lockedSeed.Int64)
+		config.LockedSeed = &seed
+	}
+	if showPrompt.Valid {
+		show := showPrompt.Bool
+		config.ShowPrompt = &show
+	}
+	if quickRepeat.Valid {
+		enabled := quickRepeat.Bool
+		config.QuickRepeat = &enabled
+	}
+	if verboseErrors.Valid {
+		enabled := verboseErrors.Bool
+		config.VerboseErrors = &enabled
+	}
+	if defaultLoras.Valid {
+		if err := json.Unmarshal([]byte(defaultLoras.String), &config.DefaultLoras); err != nil {
+			zap.L().Warn("Failed to decode default loras of user config", zap.Error(err), zap.Int64("userID", userID))
+			config.DefaultLoras = nil
+		}
+	}
 	if createdAt.Valid {
 		config.CreatedAt = createdAt.Time
 	}
@@ -97,16 +141,52 @@ func SetUserGenerationConfig(db *sql.DB, config UserGenerationConfig) error {
 	zap.L().Debug("Attempting to set user generation config", zap.Int64("userID", config.UserID), zap.Any("config", config))
 
 	upsertSQL := `
-		INSERT INTO user_generation_configs (user_id, image_size, num_inference_steps, guidance_scale, num_images, language, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO user_generation_configs (user_id, image_size, num_inference_steps, guidance_scale, num_images, language, auto_delete, delivery_mode, locked_seed, show_prompt, quick_repeat, verbose_errors, default_loras, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(user_id) DO UPDATE SET
 			image_size = excluded.image_size,
 			num_inference_steps = excluded.num_inference_steps,
 			guidance_scale = excluded.guidance_scale,
 			num_images = excluded.num_images,
 			language = excluded.language,
+			auto_delete = excluded.auto_delete,
+			delivery_mode = excluded.delivery_mode,
+			locked_seed = excluded.locked_seed,
+			show_prompt = excluded.show_prompt,
+			quick_repeat = excluded.quick_repeat,
+			verbose_errors = excluded.verbose_errors,
+			default_loras = excluded.default_loras,
 			updated_at = excluded.updated_at;`
 
+	var autoDelete sql.NullBool
+	if config.AutoDelete != nil {
+		autoDelete = sql.NullBool{Bool: *config.AutoDelete, Valid: true}
+	}
+	var lockedSeed sql.NullInt64
+	if config.LockedSeed != nil {
+		lockedSeed = sql.NullInt64{Int64: int64(*config.LockedSeed), Valid: true}
+	}
+	var showPrompt sql.NullBool
+	if config.ShowPrompt != nil {
+		showPrompt = sql.NullBool{Bool: *config.ShowPrompt, Valid: true}
+	}
+	var quickRepeat sql.NullBool
+	if config.QuickRepeat != nil {
+		quickRepeat = sql.NullBool{Bool: *config.QuickRepeat, Valid: true}
+	}
+	var verboseErrors sql.NullBool
+	if config.VerboseErrors != nil {
+		verboseErrors = sql.NullBool{Bool: *config.VerboseErrors, Valid: true}
+	}
+	var defaultLoras sql.NullString
+	if config.DefaultLoras != nil {
+		encoded, err := json.Marshal(config.DefaultLoras)
+		if err != nil {
+			return fmt.Errorf("failed to encode default loras: %w", err)
+		}
+		defaultLoras = sql.NullString{String: string(encoded), Valid: true}
+	}
+
 	now := time.Now()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -118,8 +198,15 @@ func SetUserGenerationConfig(db *sql.DB, config UserGenerationConfig) error {
 		config.GuidanceScale,
 		config.NumImages,
 		config.Language, // Include language in insert/update
-		now,             // created_at (only used on insert)
-		now,             // updated_at
+		autoDelete,
+		config.DeliveryMode,
+		lockedSeed,
+		showPrompt,
+		quickRepeat,
+		verboseErrors,
+		defaultLoras,
+		now, // created_at (only used on insert)
+		now, // updated_at
 	)
 
 	if err != nil {
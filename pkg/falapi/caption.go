@@ -3,6 +3,7 @@ package falapi
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +12,10 @@ import (
 	"time"
 )
 
+// ErrCaptionNotConfigured is returned by the caption methods when the client was created
+// without a caption endpoint (captioning disabled).
+var ErrCaptionNotConfigured = errors.New("fal caption endpoint is not configured")
+
 // --- Caption Request/Response Structs ---
 
 // CaptionSubmitRequest: Payload for submitting caption task
@@ -36,11 +41,31 @@ type CaptionResultResponse struct {
 
 // SubmitCaptionRequest submits the caption task and returns the request ID.
 func (c *Client) SubmitCaptionRequest(imageURL string) (string, error) {
+	if c.captionURL == "" {
+		return "", ErrCaptionNotConfigured
+	}
+	// c.captionURL should be like "https://queue.fal.run/fal-ai/florence-2-large/more-detailed-caption"
+	return c.submitCaption(c.captionURL, imageURL)
+}
+
+// SubmitCaptionRequestTo submits the caption task to captionEndpoint, relative to the base
+// URL like the client's own caption path, and returns the request ID.
+func (c *Client) SubmitCaptionRequestTo(imageURL, captionEndpoint string) (string, error) {
+	if captionEndpoint == "" {
+		return "", ErrCaptionNotConfigured
+	}
+	captionURL, err := url.JoinPath(c.baseURL, captionEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("failed to construct caption URL: %w", err)
+	}
+	return c.submitCaption(captionURL, imageURL)
+}
+
+func (c *Client) submitCaption(captionURL, imageURL string) (string, error) {
 	payload := CaptionSubmitRequest{
 		ImageURL: imageURL,
 	}
-	// c.captionURL should be like "https://queue.fal.run/fal-ai/florence-2-large/more-detailed-caption"
-	respBody, err := c.doPostRequest(c.captionURL, payload)
+	respBody, err := c.doPostRequest(captionURL, payload)
 	if err != nil {
 		// Try parsing SubmitResponse even on error
 		var submitResp SubmitResponse
@@ -65,6 +90,9 @@ func (c *Client) SubmitCaptionRequest(imageURL string) (string, error) {
 
 // GetCaptionResult fetches the final caption result.
 func (c *Client) GetCaptionResult(requestID, captionEndpoint string) (string, error) {
+	if captionEndpoint == "" {
+		return "", ErrCaptionNotConfigured
+	}
 	// Construct the result URL using url.JoinPath for correctness
 	resultURL, err := url.JoinPath(c.baseURL, captionEndpoint, "requests", requestID)
 	if err != nil {
@@ -88,9 +116,10 @@ func (c *Client) GetCaptionResult(requestID, captionEndpoint string) (string, er
 	if err != nil {
 		return "", fmt.Errorf("failed to read caption result response body: %w", err)
 	}
+	c.debugBody("Received caption result response", resultURL, resp.StatusCode, body)
 
 	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("API caption result fetch failed with status %d: %s", resp.StatusCode, string(body))
+		return "", &HTTPError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("API caption result fetch failed with status %d: %s", resp.StatusCode, string(body))}
 	}
 
 	var response CaptionResultResponse
@@ -109,6 +138,9 @@ func (c *Client) GetCaptionResult(requestID, captionEndpoint string) (string, er
 
 // PollForCaptionResult polls status and fetches the caption string when completed.
 func (c *Client) PollForCaptionResult(ctx context.Context, requestID, captionEndpoint string, pollInterval time.Duration) (string, error) {
+	if captionEndpoint == "" {
+		return "", ErrCaptionNotConfigured
+	}
 	ticker := time.NewTicker(pollInterval)
 	defer ticker.Stop()
 
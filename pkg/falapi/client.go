@@ -2,15 +2,19 @@ package falapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // Client holds the API key, HTTP client, logger, and base URL.
@@ -21,9 +25,11 @@ type Client struct {
 	baseURL     string // Base URL for Fal API, e.g., "https://queue.fal.run"
 	generateURL string // Full URL for the generation endpoint
 	captionURL  string // Full URL for the caption endpoint
+	webhookURL  string // If set, fal POSTs generation results here (see WebhookReceiver)
+	extraParams map[string]interface{}
 }
 
-// NewClient creates a new Fal API client.
+// NewClient creates a new Fal API client. captionPath may be empty when captioning is disabled.
 func NewClient(apiKey, baseURL, generatePath, captionPath string, logger *zap.Logger) (*Client, error) {
 	if apiKey == "" {
 		return nil, errors.New("Fal API key is required")
@@ -34,9 +40,6 @@ func NewClient(apiKey, baseURL, generatePath, captionPath string, logger *zap.Lo
 	if generatePath == "" {
 		return nil, errors.New("Fal generate endpoint path is required")
 	}
-	if captionPath == "" {
-		return nil, errors.New("Fal caption endpoint path is required")
-	}
 
 	// Parse the base URL to validate it
 	parsedBaseURL, err := url.Parse(baseURL)
@@ -54,9 +57,12 @@ func NewClient(apiKey, baseURL, generatePath, captionPath string, logger *zap.Lo
 	if err != nil {
 		return nil, fmt.Errorf("failed to construct generate URL: %w", err)
 	}
-	capURL, err := url.JoinPath(cleanBaseURL, captionPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to construct caption URL: %w", err)
+	capURL := "" // Captioning is optional
+	if captionPath != "" {
+		capURL, err = url.JoinPath(cleanBaseURL, captionPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct caption URL: %w", err)
+		}
 	}
 
 	logger.Info("FalClient initialized", zap.String("baseURL", cleanBaseURL), zap.String("generateURL", genURL), zap.String("captionURL", capURL))
@@ -73,6 +79,97 @@ func NewClient(apiKey, baseURL, generatePath, captionPath string, logger *zap.Lo
 	}, nil
 }
 
+// SetWebhookURL makes generation requests ask fal to deliver results to webhookURL.
+// An empty URL switches back to polling only.
+func (c *Client) SetWebhookURL(webhookURL string) {
+	c.webhookURL = webhookURL
+}
+
+// SetExtraParams adds params to every generation request, for model parameters the bot
+// doesn't manage itself (e.g. "scheduler"). Keys in ManagedGenerationParams are ignored.
+func (c *Client) SetExtraParams(params map[string]interface{}) {
+	c.extraParams = params
+}
+
+// HTTPError is returned when fal answers with a non-2xx status.
+type HTTPError struct {
+	StatusCode int
+	Message    string // Full error text, e.g. "request failed with status 502: ..."
+}
+
+func (e *HTTPError) Error() string {
+	return e.Message
+}
+
+// IsTransient reports whether err is worth retrying: network failures, 429 and 5xx
+// responses. Deadline and cancellation errors of the caller's context are not transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// insufficientCreditsMarkers are lowercase fragments of fal's errors for an account that
+// has run out of credits, e.g. "User is locked. Reason: Exhausted balance."
+var insufficientCreditsMarkers = []string{"exhausted balance", "insufficient balance", "insufficient credit", "insufficient funds", "out of credits"}
+
+// IsInsufficientCredits reports whether err means the fal account has no credits left:
+// a 402 response, or an error whose text carries one of fal's billing messages.
+func IsInsufficientCredits(err error) bool {
+	if err == nil {
+		return false
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusPaymentRequired {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, marker := range insufficientCreditsMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactedPlaceholder replaces secrets in debug output.
+const redactedPlaceholder = "[REDACTED]"
+
+// redact removes the API key and the webhook URL (which carries the webhook secret) from s.
+func (c *Client) redact(s string) string {
+	if c.apiKey != "" {
+		s = strings.ReplaceAll(s, c.apiKey, redactedPlaceholder)
+	}
+	if c.webhookURL != "" {
+		s = strings.ReplaceAll(s, url.QueryEscape(c.webhookURL), redactedPlaceholder)
+		s = strings.ReplaceAll(s, c.webhookURL, redactedPlaceholder)
+	}
+	return s
+}
+
+// debugBody logs a full request or response body with secrets redacted. It only runs when
+// debug logging is enabled (logLevel = "debug"), so info-level logs never carry payloads.
+func (c *Client) debugBody(msg, requestURL string, statusCode int, body []byte) {
+	if !c.logger.Core().Enabled(zapcore.DebugLevel) {
+		return
+	}
+	fields := []zap.Field{zap.String("url", c.redact(requestURL)), zap.String("body", c.redact(string(body)))}
+	if statusCode != 0 {
+		fields = append(fields, zap.Int("status_code", statusCode))
+	}
+	c.logger.Debug(msg, fields...)
+}
+
 // Helper function for making POST requests
 func (c *Client) doPostRequest(url string, payload interface{}) ([]byte, error) {
 	jsonData, err := json.Marshal(payload)
@@ -80,8 +177,7 @@ func (c *Client) doPostRequest(url string, payload interface{}) ([]byte, error)
 		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	// Log the target URL and payload size for debugging
-	c.logger.Debug("Making POST request", zap.String("url", url), zap.Int("payload_size", len(jsonData)))
+	c.debugBody("Making POST request", url, 0, jsonData)
 
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -101,10 +197,11 @@ func (c *Client) doPostRequest(url string, payload interface{}) ([]byte, error)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
+	c.debugBody("Received POST response", url, resp.StatusCode, body)
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		// Return body even on error, as it might contain useful info (like request_id)
-		return body, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return body, &HTTPError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("request failed with status %d: %s", resp.StatusCode, string(body))}
 	}
 
 	return body, nil
@@ -114,6 +211,9 @@ func (c *Client) doPostRequest(url string, payload interface{}) ([]byte, error)
 
 // GetImageCaption sends an image URL to the captioning endpoint and returns the caption.
 func (c *Client) GetImageCaption(imageURL string) (string, error) {
+	if c.captionURL == "" {
+		return "", ErrCaptionNotConfigured
+	}
 	// ... (implementation remains here)
 	payload := map[string]string{
 		"image_url": imageURL,
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http" // Ensure net/http is imported
 	"net/url"  // Import net/url
+	"slices"
 	"strings"
 	"time"
 
@@ -23,7 +24,7 @@ type GenerateRequest struct {
 	NumInferenceSteps   int          `json:"num_inference_steps,omitempty"`
 	Seed                *int         `json:"seed,omitempty"` // Pointer to allow omitting if nil
 	Loras               []LoraWeight `json:"loras,omitempty"`
-	GuidanceScale       float64      `json:"guidance_scale,omitempty"`
+	GuidanceScale       float64      `json:"guidance_scale"`      // No omitempty: 0 is valid (e.g. schnell-style models)
 	SyncMode            bool         `json:"sync_mode,omitempty"` // Default is false (async)
 	NumImages           int          `json:"num_images,omitempty"`
 	EnableSafetyChecker bool         `json:"enable_safety_checker"`   // Removed omitempty for bool
@@ -38,6 +39,26 @@ type ImageSize struct {
 	Height int `json:"height"`
 }
 
+// ImageSizePresetNames lists the named image_size values fal accepts, in the order they
+// are offered to users.
+var ImageSizePresetNames = []string{"square_hd", "square", "portrait_4_3", "portrait_16_9", "landscape_4_3", "landscape_16_9"}
+
+// ImageSizePresets maps each of ImageSizePresetNames to the pixel dimensions it resolves to.
+var ImageSizePresets = map[string]ImageSize{
+	"square_hd":      {Width: 1024, Height: 1024},
+	"square":         {Width: 512, Height: 512},
+	"portrait_4_3":   {Width: 768, Height: 1024},
+	"portrait_16_9":  {Width: 576, Height: 1024},
+	"landscape_4_3":  {Width: 1024, Height: 768},
+	"landscape_16_9": {Width: 1024, Height: 576},
+}
+
+// IsImageSizePreset reports whether size is a named image_size fal accepts.
+func IsImageSizePreset(size string) bool {
+	_, ok := ImageSizePresets[size]
+	return ok
+}
+
 // LoraWeight struct for the 'loras' array
 type LoraWeight struct {
 	Path  string  `json:"path"`            // This should be the LoRA ID/URL from config
@@ -123,23 +144,65 @@ type ImageInfo struct {
 
 // --- API Call Functions ---
 
+// ManagedGenerationParams are the payload fields SubmitGenerationRequest sets itself; extra
+// params can't override them.
+var ManagedGenerationParams = []string{
+	"prompt", "loras", "image_size", "num_inference_steps", "guidance_scale",
+	"enable_safety_checker", "num_images", "seed",
+}
+
 // SubmitGenerationRequest submits a generation request to the Fal API.
-// It now includes numImages as a parameter.
-func (c *Client) SubmitGenerationRequest(prompt string, loras []LoraWeight, loraNames []string, imageSize string, numInferenceSteps int, guidanceScale float64, numImages int) (string, error) {
-	requestURL := c.generateURL // Use the correct endpoint URL from client
+// It now includes numImages as a parameter. A nil seed lets fal pick a random one.
+// enableSafetyChecker is sent as enable_safety_checker and logged with the request ID.
+// Extra params set with SetExtraParams are merged in.
+func (c *Client) SubmitGenerationRequest(prompt string, loras []LoraWeight, loraNames []string, imageSize string, numInferenceSteps int, guidanceScale float64, numImages int, seed *uint64, enableSafetyChecker bool) (string, error) {
+	return c.submitGeneration(c.generateURL, prompt, loras, loraNames, imageSize, numInferenceSteps, guidanceScale, numImages, seed, enableSafetyChecker)
+}
 
+// SubmitGenerationRequestTo is SubmitGenerationRequest for modelEndpoint, relative to the
+// base URL like the client's own generate path, instead of the client's generation endpoint.
+func (c *Client) SubmitGenerationRequestTo(modelEndpoint string, prompt string, loras []LoraWeight, loraNames []string, imageSize string, numInferenceSteps int, guidanceScale float64, numImages int, seed *uint64, enableSafetyChecker bool) (string, error) {
+	generateURL, err := url.JoinPath(c.baseURL, modelEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("failed to construct generate URL: %w", err)
+	}
+	return c.submitGeneration(generateURL, prompt, loras, loraNames, imageSize, numInferenceSteps, guidanceScale, numImages, seed, enableSafetyChecker)
+}
+
+// GenerationPayload returns the request body SubmitGenerationRequest sends for these
+// arguments, including the client's extra params. The bot stores it with the history so a
+// generation can be reproduced exactly.
+func (c *Client) GenerationPayload(prompt string, loras []LoraWeight, imageSize string, numInferenceSteps int, guidanceScale float64, numImages int, seed *uint64, enableSafetyChecker bool) map[string]interface{} {
 	payload := map[string]interface{}{
 		"prompt":                prompt,
 		"loras":                 loras,
 		"image_size":            imageSize,
 		"num_inference_steps":   numInferenceSteps,
-		"guidance_scale":        guidanceScale,
-		"enable_safety_checker": false,
+		"guidance_scale":        guidanceScale, // Always sent, 0 is a valid value
+		"enable_safety_checker": enableSafetyChecker,
 		"num_images":            numImages, // Include numImages in payload
 	}
+	if seed != nil {
+		payload["seed"] = *seed
+	}
+	for key, value := range c.extraParams {
+		if !slices.Contains(ManagedGenerationParams, key) {
+			payload[key] = value
+		}
+	}
+	return payload
+}
+
+func (c *Client) submitGeneration(generateURL string, prompt string, loras []LoraWeight, loraNames []string, imageSize string, numInferenceSteps int, guidanceScale float64, numImages int, seed *uint64, enableSafetyChecker bool) (string, error) {
+	requestURL := generateURL
+	if c.webhookURL != "" {
+		requestURL += "?fal_webhook=" + url.QueryEscape(c.webhookURL)
+	}
+
+	payload := c.GenerationPayload(prompt, loras, imageSize, numInferenceSteps, guidanceScale, numImages, seed, enableSafetyChecker)
 
 	// Use the helper doPostRequest for consistency
-	c.logger.Debug("Submitting generation request", zap.String("request_url", requestURL))
+	c.logger.Debug("Submitting generation request", zap.String("request_url", generateURL), zap.Bool("webhook", c.webhookURL != ""))
 	respBody, err := c.doPostRequest(requestURL, payload)
 	if err != nil {
 		// Attempt to parse SubmitResponse even on error to potentially get RequestID
@@ -151,6 +214,7 @@ func (c *Client) SubmitGenerationRequest(prompt string, loras []LoraWeight, lora
 				zap.String("request_id", submitResp.RequestID),
 				zap.Strings("lora_names_used", loraNames),
 				zap.Int("num_images_requested", numImages),
+				zap.Bool("enable_safety_checker", enableSafetyChecker),
 			)
 			return submitResp.RequestID, nil
 		}
@@ -171,6 +235,7 @@ func (c *Client) SubmitGenerationRequest(prompt string, loras []LoraWeight, lora
 		zap.String("request_id", response.RequestID),
 		zap.Strings("lora_names_used", loraNames),
 		zap.Int("num_images_requested", numImages),
+		zap.Bool("enable_safety_checker", enableSafetyChecker),
 	)
 
 	return response.RequestID, nil
@@ -230,14 +295,15 @@ func (c *Client) getRequestStatusOnce(requestID, modelEndpoint string) (*StatusR
 	if err != nil {
 		return nil, resp.StatusCode, fmt.Errorf("failed to read status response body: %w", err)
 	}
+	c.debugBody("Received status response", statusURL, resp.StatusCode, body)
 
 	if resp.StatusCode >= 400 {
 		// Try to parse error response as StatusResponse for potential details
 		var statusResp StatusResponse
 		if json.Unmarshal(body, &statusResp) == nil && statusResp.Error != nil {
-			return &statusResp, resp.StatusCode, fmt.Errorf("API status check failed with status %d: %s", resp.StatusCode, statusResp.Error.Message)
+			return &statusResp, resp.StatusCode, &HTTPError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("API status check failed with status %d: %s", resp.StatusCode, statusResp.Error.Message)}
 		}
-		return nil, resp.StatusCode, fmt.Errorf("API status check failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, resp.StatusCode, &HTTPError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("API status check failed with status %d: %s", resp.StatusCode, string(body))}
 	}
 
 	var response StatusResponse
@@ -247,6 +313,33 @@ func (c *Client) getRequestStatusOnce(requestID, modelEndpoint string) (*StatusR
 	return &response, resp.StatusCode, nil
 }
 
+// CancelRequest asks fal to cancel a queued or running request. fal answers 400 for
+// requests that already completed, which is reported as an error.
+func (c *Client) CancelRequest(requestID, modelEndpoint string) error {
+	cancelURL, err := url.JoinPath(c.baseURL, modelEndpoint, "requests", requestID, "cancel")
+	if err != nil {
+		return fmt.Errorf("failed to construct cancel URL: %w", err)
+	}
+	req, err := http.NewRequest("PUT", cancelURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create cancel request: %w", err)
+	}
+	req.Header.Set("Authorization", "Key "+c.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send cancel request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	c.debugBody("Received cancel response", cancelURL, resp.StatusCode, body)
+	if resp.StatusCode >= 400 {
+		return &HTTPError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("API cancel failed with status %d: %s", resp.StatusCode, string(body))}
+	}
+	return nil
+}
+
 // GetGenerationResult fetches the final result.
 func (c *Client) GetGenerationResult(requestID, modelEndpoint string) (*GenerateResponse, error) {
 	resultResp, statusCode, err := c.getGenerationResultOnce(requestID, modelEndpoint)
@@ -297,11 +390,12 @@ func (c *Client) getGenerationResultOnce(requestID, modelEndpoint string) (*Gene
 	if err != nil {
 		return nil, resp.StatusCode, fmt.Errorf("failed to read result response body: %w", err)
 	}
+	c.debugBody("Received result response", resultURL, resp.StatusCode, body)
 
 	if resp.StatusCode >= 400 {
 		// Attempt to parse potential error details from GenerateResponse structure if API uses it
 		// Or just return the generic error
-		return nil, resp.StatusCode, fmt.Errorf("API result fetch failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, resp.StatusCode, &HTTPError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("API result fetch failed with status %d: %s", resp.StatusCode, string(body))}
 	}
 
 	var response GenerateResponse
@@ -315,9 +409,18 @@ func (c *Client) getGenerationResultOnce(requestID, modelEndpoint string) (*Gene
 	return &response, resp.StatusCode, nil
 }
 
+// StatusCallback is invoked with the status returned by every successful poll.
+type StatusCallback func(status *StatusResponse)
+
 // PollForResult polls the status and fetches the result when completed.
 // Includes a timeout context.
 func (c *Client) PollForResult(ctx context.Context, requestID, modelEndpoint string, pollInterval time.Duration) (*GenerateResponse, error) {
+	return c.PollForResultWithStatus(ctx, requestID, modelEndpoint, pollInterval, nil)
+}
+
+// PollForResultWithStatus behaves like PollForResult but reports each poll's status
+// (including queue position) to onStatus. A nil onStatus keeps polling quiet.
+func (c *Client) PollForResultWithStatus(ctx context.Context, requestID, modelEndpoint string, pollInterval time.Duration, onStatus StatusCallback) (*GenerateResponse, error) {
 	ticker := time.NewTicker(pollInterval)
 	defer ticker.Stop()
 
@@ -334,6 +437,9 @@ func (c *Client) PollForResult(ctx context.Context, requestID, modelEndpoint str
 			}
 
 			c.logger.Debug("Polling status for request", zap.String("request_id", requestID), zap.String("status", statusResp.Status)) // Debug log
+			if onStatus != nil {
+				onStatus(statusResp)
+			}
 
 			switch statusResp.Status {
 			case "COMPLETED":
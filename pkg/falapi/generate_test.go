@@ -0,0 +1,49 @@
+package falapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// TestGuidanceScaleZeroIsSent checks that guidance_scale 0, a valid value for some models,
+// reaches fal instead of being dropped as empty.
+func TestGuidanceScaleZeroIsSent(t *testing.T) {
+	body, err := json.Marshal(GenerateRequest{Prompt: "a cat", GuidanceScale: 0})
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	if !strings.Contains(string(body), `"guidance_scale":0`) {
+		t.Errorf("GenerateRequest body %s has no guidance_scale", body)
+	}
+
+	var sent []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent, _ = io.ReadAll(r.Body)
+		w.Write([]byte(`{"request_id":"req-1"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient("key", server.URL, "fal-ai/flux-lora", "", zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if _, err := client.SubmitGenerationRequest("a cat", nil, nil, "square_hd", 28, 0, 1, nil, true); err != nil {
+		t.Fatalf("SubmitGenerationRequest: %v", err)
+	}
+	if !strings.Contains(string(sent), `"guidance_scale":0`) {
+		t.Errorf("submitted body %s has no guidance_scale", sent)
+	}
+	payload, err := json.Marshal(client.GenerationPayload("a cat", nil, "square_hd", 28, 0, 1, nil, true))
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	if !strings.Contains(string(payload), `"guidance_scale":0`) {
+		t.Errorf("GenerationPayload %s has no guidance_scale", payload)
+	}
+}
@@ -0,0 +1,197 @@
+package falapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// maxWebhookBodySize caps webhook payloads; results only contain image URLs.
+const maxWebhookBodySize = 1 << 20
+
+// WebhookPayload is the body fal POSTs to the webhook URL when a queued request finishes.
+type WebhookPayload struct {
+	RequestID        string          `json:"request_id"`
+	GatewayRequestID string          `json:"gateway_request_id"`
+	Status           string          `json:"status"`          // "OK" or "ERROR"
+	Error            string          `json:"error,omitempty"` // Set when Status is "ERROR"
+	Payload          json.RawMessage `json:"payload"`         // GenerateResponse on success, error detail on failure
+	PayloadError     string          `json:"payload_error,omitempty"`
+}
+
+// WebhookError is returned to the waiting caller when fal reports a failed request.
+type WebhookError struct {
+	RequestID string
+	Message   string
+}
+
+func (e *WebhookError) Error() string {
+	return fmt.Sprintf("generation failed: %s (request_id: %s)", e.Message, e.RequestID)
+}
+
+type webhookResult struct {
+	response *GenerateResponse
+	err      error
+}
+
+// WebhookReceiver is an http.Handler that resolves pending requests from fal webhook calls.
+// Only request IDs registered via Register are accepted; anything else gets a 404, which
+// also makes fal retry a delivery that raced ahead of Register.
+type WebhookReceiver struct {
+	mu      sync.Mutex
+	pending map[string]chan webhookResult
+	secret  string // Expected ?token= value; empty disables the check
+	logger  *zap.Logger
+}
+
+// NewWebhookReceiver creates a receiver. If secret is not empty, deliveries must carry it
+// as the "token" query parameter (see WebhookURL).
+func NewWebhookReceiver(secret string, logger *zap.Logger) *WebhookReceiver {
+	return &WebhookReceiver{
+		pending: make(map[string]chan webhookResult),
+		secret:  secret,
+		logger:  logger.Named("FalWebhook"),
+	}
+}
+
+// WebhookURL returns publicURL with the receiver's secret attached, ready to pass to fal.
+func (r *WebhookReceiver) WebhookURL(publicURL string) (string, error) {
+	u, err := url.Parse(publicURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if r.secret != "" {
+		q := u.Query()
+		q.Set("token", r.secret)
+		u.RawQuery = q.Encode()
+	}
+	return u.String(), nil
+}
+
+// Register starts waiting for the webhook of requestID. Call Wait to get the result.
+func (r *WebhookReceiver) Register(requestID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.pending[requestID]; !exists {
+		r.pending[requestID] = make(chan webhookResult, 1)
+	}
+}
+
+// Wait blocks until the webhook of a registered requestID arrives or ctx is done.
+// The request is unregistered when Wait returns.
+func (r *WebhookReceiver) Wait(ctx context.Context, requestID string) (*GenerateResponse, error) {
+	r.mu.Lock()
+	ch, ok := r.pending[requestID]
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("request %s is not registered for webhooks", requestID)
+	}
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, requestID)
+		r.mu.Unlock()
+	}()
+
+	select {
+	case res := <-ch:
+		return res.response, res.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for webhook timed out for request %s: %w", requestID, ctx.Err())
+	}
+}
+
+// ServeHTTP handles a fal webhook delivery.
+func (r *WebhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.secret != "" && subtle.ConstantTimeCompare([]byte(req.URL.Query().Get("token")), []byte(r.secret)) != 1 {
+		r.logger.Warn("Rejected webhook with invalid token", zap.String("remote_addr", req.RemoteAddr))
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxWebhookBodySize))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	var payload WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil || payload.RequestID == "" {
+		r.logger.Warn("Rejected malformed webhook payload", zap.Error(err), zap.Int("body_size", len(body)))
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	r.mu.Lock()
+	ch, ok := r.pending[payload.RequestID]
+	r.mu.Unlock()
+	if !ok {
+		r.logger.Warn("Rejected webhook for unknown request", zap.String("request_id", payload.RequestID), zap.String("status", payload.Status))
+		http.Error(w, "unknown request", http.StatusNotFound)
+		return
+	}
+
+	result := parseWebhookResult(payload)
+	if result.err != nil {
+		r.logger.Warn("Webhook reported failed request", zap.String("request_id", payload.RequestID), zap.Error(result.err))
+	} else {
+		r.logger.Info("Webhook delivered result", zap.String("request_id", payload.RequestID), zap.Int("image_count", len(result.response.Images)))
+	}
+
+	select {
+	case ch <- result:
+	default:
+		// A result was already delivered (fal retry); keep the first one
+		r.logger.Debug("Ignoring duplicate webhook delivery", zap.String("request_id", payload.RequestID))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseWebhookResult turns a webhook payload into a result or a *WebhookError.
+func parseWebhookResult(payload WebhookPayload) webhookResult {
+	if !strings.EqualFold(payload.Status, "OK") {
+		message := payload.Error
+		if detail := webhookErrorDetail(payload.Payload); detail != "" {
+			message = strings.TrimSpace(message + ": " + detail)
+		}
+		if message == "" {
+			message = "unknown error"
+		}
+		return webhookResult{err: &WebhookError{RequestID: payload.RequestID, Message: message}}
+	}
+	if payload.PayloadError != "" {
+		return webhookResult{err: &WebhookError{RequestID: payload.RequestID, Message: "invalid result payload: " + payload.PayloadError}}
+	}
+
+	var response GenerateResponse
+	if err := json.Unmarshal(payload.Payload, &response); err != nil {
+		return webhookResult{err: &WebhookError{RequestID: payload.RequestID, Message: fmt.Sprintf("failed to decode result: %v", err)}}
+	}
+	return webhookResult{response: &response}
+}
+
+// webhookErrorDetail extracts the first "detail" message from a fal error payload, if any.
+func webhookErrorDetail(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var detail struct {
+		Detail []struct {
+			Msg string `json:"msg"`
+		} `json:"detail"`
+	}
+	if json.Unmarshal(raw, &detail) == nil && len(detail.Detail) > 0 {
+		return detail.Detail[0].Msg
+	}
+	return ""
+}
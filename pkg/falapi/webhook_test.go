@@ -0,0 +1,73 @@
+package falapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestWebhookReceiver(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		wantStatus int
+	}{
+		{"missing token", "", `{"request_id":"req-1","status":"OK","payload":{}}`, http.StatusForbidden},
+		{"wrong token", "wrong", `{"request_id":"req-1","status":"OK","payload":{}}`, http.StatusForbidden},
+		{"unregistered request", "secret", `{"request_id":"other","status":"OK","payload":{}}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := NewWebhookReceiver("secret", zap.NewNop())
+			receiver.Register("req-1")
+			rec := httptest.NewRecorder()
+			receiver.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/fal/webhook?token="+tt.token, strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// deliverWebhook registers req-1, posts body to the receiver and returns what Wait gets.
+func deliverWebhook(t *testing.T, body string) (*GenerateResponse, error) {
+	t.Helper()
+	receiver := NewWebhookReceiver("secret", zap.NewNop())
+	receiver.Register("req-1")
+	rec := httptest.NewRecorder()
+	receiver.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/fal/webhook?token=secret", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return receiver.Wait(ctx, "req-1")
+}
+
+func TestWebhookReceiverDeliversResult(t *testing.T) {
+	response, err := deliverWebhook(t, `{"request_id":"req-1","status":"OK","payload":{"images":[{"url":"https://example.com/1.png","width":1024,"height":768}],"seed":7}}`)
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if len(response.Images) != 1 || response.Images[0].URL != "https://example.com/1.png" || response.Seed != 7 {
+		t.Errorf("Wait returned %+v, want the delivered image and seed", response)
+	}
+}
+
+func TestWebhookReceiverDeliversError(t *testing.T) {
+	_, err := deliverWebhook(t, `{"request_id":"req-1","status":"ERROR","error":"Invalid status code: 422","payload":{"detail":[{"msg":"guidance_scale too large"}]}}`)
+	var webhookErr *WebhookError
+	if !errors.As(err, &webhookErr) {
+		t.Fatalf("Wait error = %v, want a *WebhookError", err)
+	}
+	if webhookErr.RequestID != "req-1" || !strings.Contains(webhookErr.Message, "guidance_scale too large") {
+		t.Errorf("WebhookError = %+v, want the detail message for req-1", webhookErr)
+	}
+}